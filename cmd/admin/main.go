@@ -0,0 +1,523 @@
+// gophkeeper-admin - утилита оператора сервера GophKeeper. Работает напрямую с базой сервера
+// и не видит ни публичных ключей, ни данных: владельцы указываются отпечатками SHA256(public_key).
+// С флагом -f DSN и настройки хранилища объектов берутся из конфигурации сервера.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/backup"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/blobstore"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/config"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+)
+
+const usageText = `usage: gophkeeper-admin [-d dsn] [-f config] <command> [args]
+
+commands:
+    stats [fingerprint prefix]         usage statistics per owner
+    disable <fingerprint>              reject all requests of owner
+    enable <fingerprint>               allow requests of disabled owner
+    migrate up [n]                     apply n (default all) migrations
+    migrate down <n>                   roll back n migrations
+    migrate version                    show schema version
+    purge [-trash 720h] [-uploads 24h] [-idempotency 24h] [-tombstones 2160h]
+                                       delete expired trash and entries, stale uploads,
+                                       challenges, idempotency keys and old tombstones
+    read-only on [-retry-after 1m]     reject changes of entries until read-only off, reads keep working
+    read-only off                      leave read-only maintenance mode
+    read-only status                   show read-only maintenance mode
+    check                              check schema integrity
+    backup <file|->                    write portable backup archive of the whole database
+    restore <file|->                   restore backup archive into an empty database
+    verify <file|->                    check backup archive against its manifest
+
+flags:
+`
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+	defer cancel()
+
+	var dsn, configPath string
+	flag.StringVar(&dsn, "d", "", "postgres or sqlite:// dsn (POSTGRES_DSN if empty)")
+	flag.StringVar(&configPath, "f", os.Getenv("GOPHKEEPER_CONFIG"), "server config file (YAML): dsn and blob store")
+	flag.Usage = func() {
+		_, _ = io.WriteString(flag.CommandLine.Output(), usageText)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() > 0 && flag.Arg(0) == "verify" {
+		err := verify(os.Stdout, flag.Args()[1:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var cfg *config.Config
+	if configPath != "" {
+		var err error
+		cfg, err = config.Load(configPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	if dsn == "" {
+		dsn = os.Getenv("POSTGRES_DSN")
+	}
+	if dsn == "" && cfg != nil {
+		dsn = cfg.Storage.DSN
+	}
+	if dsn == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	s, err := open(dsn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer func() { _ = s.Close() }()
+
+	err = run(ctx, s, cfg, os.Stdout, flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		_ = s.Close()
+		os.Exit(1)
+	}
+}
+
+// open - открыть базу без применения миграций: SQLite для схемы sqlite://, иначе Postgres.
+func open(dsn string) (storage.Storage, error) {
+	if strings.HasPrefix(dsn, storage.SQLiteScheme) {
+		return storage.OpenSQLiteStorage(dsn)
+	}
+
+	return storage.OpenServerStorage(dsn)
+}
+
+// migrator - хранилище с управляемыми миграциями.
+type migrator interface {
+	MigrateUp(n int) error
+	MigrateDown(n int) error
+	SchemaVersion() (version uint, dirty bool, err error)
+	LatestSchemaVersion() (uint, error)
+}
+
+// inspector - хранилище, которое отдает статистику владельцев и проверяет свою схему.
+type inspector interface {
+	OwnerStats(ctx context.Context) ([]storage.OwnerStats, error)
+	CheckSchema(ctx context.Context) ([]string, error)
+}
+
+func run(ctx context.Context, s storage.Storage, cfg *config.Config, w io.Writer, args []string) error {
+	switch args[0] {
+	case "stats", "check":
+		i, ok := s.(inspector)
+		if !ok {
+			return fmt.Errorf("%s is not supported by storage", args[0])
+		}
+		if args[0] == "check" {
+			return check(ctx, i, w)
+		}
+
+		var prefix string
+		if len(args) > 1 {
+			prefix = strings.ToLower(args[1])
+		}
+		return stats(ctx, i, w, prefix)
+	case "disable", "enable":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: %s <fingerprint>", args[0])
+		}
+		return setOwnerStatus(ctx, s, w, args[0] == "disable", args[1])
+	case "migrate":
+		m, ok := s.(migrator)
+		if !ok {
+			return errors.New("migrations are not supported by storage")
+		}
+		return migrateCmd(m, w, args[1:])
+	case "purge":
+		return purge(ctx, s, w, args[1:])
+	case "read-only":
+		return readOnly(ctx, s, w, args[1:])
+	case "backup", "restore":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: %s <file|->", args[0])
+		}
+		bs, err := withBlobs(s, cfg)
+		if err != nil {
+			return err
+		}
+		if args[0] == "backup" {
+			return backupCmd(ctx, bs, w, args[1])
+		}
+		return restoreCmd(ctx, s, bs, w, args[1])
+	default:
+		return fmt.Errorf("unknown command %q, use -help for more info", args[0])
+	}
+}
+
+func stats(ctx context.Context, s inspector, w io.Writer, prefix string) error {
+	owners, err := s.OwnerStats(ctx)
+	if err != nil {
+		return fmt.Errorf("get stats: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "FINGERPRINT\tENTRIES\tTRASHED\tVERSIONS\tDEVICES\tBYTES\tLAST UPDATE\tSTATUS")
+	for _, o := range owners {
+		fingerprint := storage.FormatFingerprint(o.Fingerprint)
+		if !strings.HasPrefix(fingerprint, prefix) {
+			continue
+		}
+
+		ownerStatus := "active"
+		if o.Disabled {
+			ownerStatus = "disabled"
+		}
+
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%s\t%s\n",
+			fingerprint, o.Entries, o.Trashed, o.Versions, o.Devices, o.Bytes,
+			o.LastUpdateAt.Local().Format(time.DateTime), ownerStatus)
+	}
+
+	return tw.Flush()
+}
+
+func setOwnerStatus(ctx context.Context, s storage.Storage, w io.Writer, disable bool, raw string) error {
+	fingerprint, err := storage.ParseFingerprint(raw)
+	if err != nil {
+		return fmt.Errorf("parse fingerprint: %w", err)
+	}
+
+	if disable {
+		err = s.DisableOwner(ctx, fingerprint)
+		if err != nil {
+			return fmt.Errorf("disable owner: %w", err)
+		}
+		_, _ = fmt.Fprintln(w, "owner disabled")
+		return nil
+	}
+
+	err = s.EnableOwner(ctx, fingerprint)
+	if errors.Is(err, storage.ErrNotFound) {
+		return errors.New("owner is not disabled")
+	}
+	if err != nil {
+		return fmt.Errorf("enable owner: %w", err)
+	}
+	_, _ = fmt.Fprintln(w, "owner enabled")
+
+	return nil
+}
+
+func migrateCmd(s migrator, w io.Writer, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up [n] | down <n> | version")
+	}
+
+	var (
+		n   int
+		err error
+	)
+	if len(args) > 1 {
+		n, err = strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("number of migrations must be a positive integer, got %q", args[1])
+		}
+	}
+
+	switch args[0] {
+	case "up":
+		err = s.MigrateUp(n)
+	case "down":
+		if n == 0 {
+			return errors.New("usage: migrate down <n>")
+		}
+		err = s.MigrateDown(n)
+	case "version":
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+	if errors.Is(err, migrate.ErrNoChange) {
+		_, _ = fmt.Fprintln(w, "no change")
+	} else if err != nil {
+		return fmt.Errorf("migrate %s: %w", args[0], err)
+	}
+
+	version, dirty, err := s.SchemaVersion()
+	if err != nil {
+		return fmt.Errorf("get schema version: %w", err)
+	}
+	latest, err := s.LatestSchemaVersion()
+	if err != nil {
+		return fmt.Errorf("get latest schema version: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(w, "schema version %d of %d", version, latest)
+	if dirty {
+		_, _ = fmt.Fprint(w, " (dirty)")
+	}
+	_, _ = fmt.Fprintln(w)
+
+	return nil
+}
+
+func purge(ctx context.Context, s storage.Storage, w io.Writer, args []string) error {
+	var trashRetention, uploadsRetention, idempotencyRetention, tombstoneRetention time.Duration
+
+	fs := flag.NewFlagSet("purge", flag.ContinueOnError)
+	fs.DurationVar(&trashRetention, "trash", 30*24*time.Hour, "how long deleted entries are kept in trash")
+	fs.DurationVar(&uploadsRetention, "uploads", 24*time.Hour, "how long unfinished uploads are kept")
+	fs.DurationVar(&idempotencyRetention, "idempotency", 24*time.Hour, "how long idempotency keys are kept")
+	fs.DurationVar(&tombstoneRetention, "tombstones", 90*24*time.Hour,
+		"how long tombstones of deleted entries are kept")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	entries, err := s.PurgeTrash(ctx, now.Add(-trashRetention))
+	if err != nil {
+		return fmt.Errorf("purge trash: %w", err)
+	}
+	expired, err := s.PurgeExpired(ctx, now)
+	if err != nil {
+		return fmt.Errorf("purge expired: %w", err)
+	}
+	chunks, err := s.DeleteStaleUploads(ctx, now.Add(-uploadsRetention))
+	if err != nil {
+		return fmt.Errorf("delete stale uploads: %w", err)
+	}
+	challenges, err := s.DeleteExpiredChallenges(ctx, now)
+	if err != nil {
+		return fmt.Errorf("delete expired challenges: %w", err)
+	}
+	keys, err := s.DeleteExpiredIdempotencyKeys(ctx, now.Add(-idempotencyRetention))
+	if err != nil {
+		return fmt.Errorf("delete expired idempotency keys: %w", err)
+	}
+	tombstones, err := s.PurgeTombstones(ctx, now.Add(-tombstoneRetention))
+	if err != nil {
+		return fmt.Errorf("purge tombstones: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(w, "purged %d trashed entries, %d expired entries, %d upload chunks, "+
+		"%d account challenges, %d idempotency keys, %d tombstones\n",
+		entries, expired, chunks, challenges, keys, tombstones)
+
+	return nil
+}
+
+// readOnly - переключить режим обслуживания. Серверы применяют его в течение нескольких секунд.
+func readOnly(ctx context.Context, s storage.Storage, w io.Writer, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: read-only on [-retry-after 1m] | off | status")
+	}
+
+	switch args[0] {
+	case "on":
+		var retryAfter time.Duration
+		fs := flag.NewFlagSet("read-only on", flag.ContinueOnError)
+		fs.DurationVar(&retryAfter, "retry-after", time.Minute, "when clients should retry rejected changes")
+		err := fs.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+		if retryAfter < time.Second {
+			return errors.New("retry-after must be at least 1s")
+		}
+
+		err = s.SetReadOnlyMode(ctx, true, retryAfter)
+		if err != nil {
+			return fmt.Errorf("enable read-only mode: %w", err)
+		}
+	case "off":
+		err := s.SetReadOnlyMode(ctx, false, 0)
+		if err != nil {
+			return fmt.Errorf("disable read-only mode: %w", err)
+		}
+	case "status":
+	default:
+		return fmt.Errorf("unknown read-only command %q", args[0])
+	}
+
+	mode, err := s.GetReadOnlyMode(ctx)
+	if err != nil {
+		return fmt.Errorf("get read-only mode: %w", err)
+	}
+	if !mode.Enabled {
+		_, _ = fmt.Fprintln(w, "read-only mode is off")
+		return nil
+	}
+	_, _ = fmt.Fprintf(w, "read-only mode is on since %s, clients retry after %s\n",
+		mode.Since.Local().Format(time.RFC3339), mode.RetryAfter)
+
+	return nil
+}
+
+func check(ctx context.Context, s inspector, w io.Writer) error {
+	problems, err := s.CheckSchema(ctx)
+	if err != nil {
+		return fmt.Errorf("check schema: %w", err)
+	}
+
+	if len(problems) == 0 {
+		_, _ = fmt.Fprintln(w, "schema ok")
+		return nil
+	}
+
+	for _, p := range problems {
+		_, _ = fmt.Fprintln(w, p)
+	}
+
+	return fmt.Errorf("found %d schema problems", len(problems))
+}
+
+// withBlobs - хранилище, которое подставляет данные из хранилища объектов,
+// если оно задано в конфигурации сервера.
+func withBlobs(s storage.Storage, cfg *config.Config) (storage.Storage, error) {
+	if cfg == nil || cfg.Blob.Kind == "" {
+		return s, nil
+	}
+
+	blobs, err := blobstore.Open(cfg.BlobStoreConfig())
+	if err != nil {
+		return nil, fmt.Errorf("open blob store: %w", err)
+	}
+
+	return storage.NewBlobStorage(s, blobs, cfg.Blob.Threshold), nil
+}
+
+// backupCmd - записать резервную копию в файл path или в w, если path равен "-".
+// Файл сначала пишется во временный рядом и переименовывается только после успешной записи.
+func backupCmd(ctx context.Context, s storage.Storage, w io.Writer, path string) error {
+	if path == "-" {
+		m, err := backup.Write(ctx, s, w)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "backup written: %s\n", m)
+		return nil
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create backup file: %w", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	m, err := backup.Write(ctx, s, f)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("close backup file: %w", err)
+	}
+	err = os.Rename(f.Name(), path)
+	if err != nil {
+		return fmt.Errorf("rename backup file: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(w, "backup written to %s: %s\n", path, m)
+	_, _ = fmt.Fprintf(w, "sha256 %s\n", m.SHA256)
+
+	return nil
+}
+
+// restoreCmd - восстановить резервную копию из файла path или stdin, если path равен "-".
+// Схема базы должна быть последней версии: архив хранит данные, а не схему.
+func restoreCmd(ctx context.Context, base, s storage.Storage, w io.Writer, path string) error {
+	if m, ok := base.(migrator); ok {
+		version, dirty, err := m.SchemaVersion()
+		if err != nil {
+			return fmt.Errorf("get schema version: %w", err)
+		}
+		latest, err := m.LatestSchemaVersion()
+		if err != nil {
+			return fmt.Errorf("get latest schema version: %w", err)
+		}
+		if dirty || version != latest {
+			return fmt.Errorf("schema version %d of %d, run migrate up first", version, latest)
+		}
+	}
+
+	r, closeFn, err := openArchive(path)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	m, err := backup.Restore(ctx, s, r)
+	if errors.Is(err, storage.ErrNotEmpty) {
+		return errors.New("database is not empty, restore is possible only into an empty database")
+	}
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(w, "backup restored: %s\n", m)
+
+	return nil
+}
+
+func verify(w io.Writer, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: verify <file|->")
+	}
+
+	r, closeFn, err := openArchive(args[0])
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	m, err := backup.Verify(r)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(w, "backup ok, created %s: %s\n", m.CreatedAt.Local().Format(time.DateTime), m)
+	_, _ = fmt.Fprintf(w, "sha256 %s\n", m.SHA256)
+
+	return nil
+}
+
+// openArchive - открыть файл архива или stdin, если path равен "-".
+func openArchive(path string) (io.Reader, func(), error) {
+	if path == "-" {
+		return os.Stdin, func() {}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open backup file: %w", err)
+	}
+
+	return f, func() { _ = f.Close() }, nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+)
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage(10)
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "unknown command",
+			args:    []string{"unknown"},
+			wantErr: `unknown command "unknown"`,
+		},
+		{
+			name:    "stats on memory",
+			args:    []string{"stats"},
+			wantErr: "stats is not supported by storage",
+		},
+		{
+			name:    "migrate on memory",
+			args:    []string{"migrate", "up"},
+			wantErr: "migrations are not supported by storage",
+		},
+		{
+			name:    "disable without fingerprint",
+			args:    []string{"disable"},
+			wantErr: "usage: disable <fingerprint>",
+		},
+		{
+			name:    "backup without file",
+			args:    []string{"backup"},
+			wantErr: "usage: backup <file|->",
+		},
+		{
+			name: "read-only status",
+			args: []string{"read-only", "status"},
+			want: "read-only mode is off\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			err := run(ctx, s, nil, w, tt.args)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, w.String())
+		})
+	}
+}
+
+func TestRun_SQLite(t *testing.T) {
+	ctx := context.Background()
+	s, err := storage.NewSQLiteStorage(storage.Config{
+		DSN: storage.SQLiteScheme + filepath.Join(t.TempDir(), "keeper.db"),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	owner := []byte{1, 2, 3}
+	_, err = s.Create(ctx, owner, []byte("data"), time.Time{})
+	require.NoError(t, err)
+
+	w := &bytes.Buffer{}
+	require.NoError(t, run(ctx, s, nil, w, []string{"check"}))
+	assert.Equal(t, "schema ok\n", w.String())
+
+	w.Reset()
+	require.NoError(t, run(ctx, s, nil, w, []string{"stats"}))
+	assert.Contains(t, w.String(), storage.FormatFingerprint(storage.Fingerprint(owner)))
+}
+
+func TestSetOwnerStatus(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage(10)
+	owner := []byte{1, 2, 3}
+	fingerprint := storage.FormatFingerprint(storage.Fingerprint(owner))
+
+	w := &bytes.Buffer{}
+	require.NoError(t, setOwnerStatus(ctx, s, w, true, fingerprint))
+	assert.Equal(t, "owner disabled\n", w.String())
+	disabled, err := s.IsOwnerDisabled(ctx, owner)
+	require.NoError(t, err)
+	assert.True(t, disabled)
+
+	w.Reset()
+	require.NoError(t, setOwnerStatus(ctx, s, w, false, fingerprint))
+	assert.Equal(t, "owner enabled\n", w.String())
+	disabled, err = s.IsOwnerDisabled(ctx, owner)
+	require.NoError(t, err)
+	assert.False(t, disabled)
+
+	assert.EqualError(t, setOwnerStatus(ctx, s, w, false, fingerprint), "owner is not disabled")
+	assert.ErrorContains(t, setOwnerStatus(ctx, s, w, true, "not a fingerprint"), "parse fingerprint")
+}
+
+func TestMigrateCmd(t *testing.T) {
+	s, err := storage.OpenSQLiteStorage(storage.SQLiteScheme + filepath.Join(t.TempDir(), "keeper.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	latest, err := s.LatestSchemaVersion()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "no command",
+			wantErr: "usage: migrate up [n] | down <n> | version",
+		},
+		{
+			name:    "unknown command",
+			args:    []string{"sideways"},
+			wantErr: `unknown migrate command "sideways"`,
+		},
+		{
+			name:    "down without n",
+			args:    []string{"down"},
+			wantErr: "usage: migrate down <n>",
+		},
+		{
+			name:    "bad n",
+			args:    []string{"up", "x"},
+			wantErr: `number of migrations must be a positive integer, got "x"`,
+		},
+		{
+			name:    "negative n",
+			args:    []string{"down", "-1"},
+			wantErr: `number of migrations must be a positive integer, got "-1"`,
+		},
+		{
+			name: "empty database",
+			args: []string{"version"},
+			want: fmt.Sprintf("schema version 0 of %d", latest),
+		},
+		{
+			name: "up",
+			args: []string{"up"},
+			want: fmt.Sprintf("schema version %d of %d", latest, latest),
+		},
+		{
+			name: "up again",
+			args: []string{"up"},
+			want: fmt.Sprintf("no change\nschema version %d of %d", latest, latest),
+		},
+		{
+			name: "down",
+			args: []string{"down", "2"},
+			want: fmt.Sprintf("schema version %d of %d", latest-2, latest),
+		},
+		{
+			name: "up one",
+			args: []string{"up", "1"},
+			want: fmt.Sprintf("schema version %d of %d", latest-1, latest),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			err := migrateCmd(s, w, tt.args)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want+"\n", w.String())
+		})
+	}
+}
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage(10)
+	owner := []byte{1, 2, 3}
+
+	id, err := s.Create(ctx, owner, []byte("trashed"), time.Time{})
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(ctx, id, 1))
+	_, err = s.Create(ctx, owner, []byte("expired"), time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	_, err = s.Create(ctx, owner, []byte("kept"), time.Time{})
+	require.NoError(t, err)
+
+	w := &bytes.Buffer{}
+	require.NoError(t, purge(ctx, s, w, []string{"-trash", "0s", "-tombstones", "1h"}))
+	assert.Equal(t, "purged 1 trashed entries, 1 expired entries, 0 upload chunks, "+
+		"0 account challenges, 0 idempotency keys, 0 tombstones\n", w.String())
+
+	w.Reset()
+	require.NoError(t, purge(ctx, s, w, []string{"-tombstones", "0s"}))
+	assert.Equal(t, "purged 0 trashed entries, 0 expired entries, 0 upload chunks, "+
+		"0 account challenges, 0 idempotency keys, 2 tombstones\n", w.String())
+
+	entries, err := s.GetAll(ctx, owner, uuid.Nil, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, []byte("kept"), entries[0].Payload)
+
+	assert.Error(t, purge(ctx, s, w, []string{"-trash", "week"}))
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/service"
+)
+
+// device - регистрация этой установки клиента на сервере, хранится в файле рядом с ключом.
+type device struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	SessionToken string `json:"session_token"`
+}
+
+// devicePath - путь к файлу устройства для ключа keyPath.
+func devicePath(keyPath string) string {
+	return keyPath + ".device.json"
+}
+
+// setupDevice - загрузить устройство из файла или зарегистрировать новое и передавать его ID в запросах.
+// Если устройство из файла неизвестно серверу, оно регистрируется заново под прежним названием,
+// а отозванное устройство сервер повторно не регистрирует.
+func setupDevice(ctx context.Context, c *keeper.Client, s *service.Service, path string) error {
+	d, err := loadDevice(path)
+	switch {
+	case err == nil:
+		var active bool
+		active, err = s.DeviceActive(ctx, d.ID)
+		if err != nil {
+			return err
+		}
+		if !active {
+			d, err = reregisterDevice(ctx, s, d)
+		}
+	case errors.Is(err, fs.ErrNotExist):
+		d, err = registerDevice(ctx, s)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = saveDevice(path, d)
+	if err != nil {
+		return err
+	}
+
+	c.SetDevice(d.ID, d.SessionToken)
+
+	return nil
+}
+
+// reregisterDevice - зарегистрировать заново устройство d, которого нет среди действующих устройств.
+func reregisterDevice(ctx context.Context, s *service.Service, d device) (device, error) {
+	id, token, err := s.RegisterDevice(ctx, d.ID, d.Name)
+	if errors.Is(err, service.ErrDeviceRevoked) {
+		return device{}, fmt.Errorf("device %s (%s) is revoked by the owner", d.Name, d.ID)
+	}
+	if err != nil {
+		return device{}, err
+	}
+
+	return device{
+		ID:           id,
+		Name:         d.Name,
+		SessionToken: token,
+	}, nil
+}
+
+func registerDevice(ctx context.Context, s *service.Service) (device, error) {
+	name, _ := os.Hostname()
+
+	l.SetPrompt(fmt.Sprintf("Device name [%s]: ", name))
+	input, err := l.Readline()
+	if err != nil {
+		return device{}, fmt.Errorf("readline: %w", err)
+	}
+	if input = strings.TrimSpace(input); input != "" {
+		name = input
+	}
+	if name == "" {
+		name = "unknown"
+	}
+
+	id, token, err := s.RegisterDevice(ctx, "", name)
+	if err != nil {
+		return device{}, err
+	}
+
+	return device{
+		ID:           id,
+		Name:         name,
+		SessionToken: token,
+	}, nil
+}
+
+func loadDevice(path string) (device, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return device{}, fmt.Errorf("read device file: %w", err)
+	}
+
+	var d device
+	err = json.Unmarshal(b, &d)
+	if err != nil {
+		return device{}, fmt.Errorf("parse device file: %w", err)
+	}
+
+	return d, nil
+}
+
+func saveDevice(path string, d device) error {
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal device: %w", err)
+	}
+
+	err = os.WriteFile(path, b, 0o600)
+	if err != nil {
+		return fmt.Errorf("write device file: %w", err)
+	}
+
+	return nil
+}
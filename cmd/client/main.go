@@ -8,6 +8,7 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/chzyer/readline"
 	"go.uber.org/zap"
@@ -16,6 +17,7 @@ import (
 	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
 	"github.com/ImpressionableRaccoon/GophKeeper/internal/keys"
 	"github.com/ImpressionableRaccoon/GophKeeper/internal/service"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
 )
 
 var (
@@ -53,10 +55,12 @@ func main() {
 	var (
 		serverAddress string
 		keyPath       string
+		traceExporter string
 	)
 
 	flag.StringVar(&serverAddress, "a", "", "server address")
 	flag.StringVar(&keyPath, "k", "", "user key path")
+	flag.StringVar(&traceExporter, "t", "", "trace exporter: otlp or file:{path} (disabled if empty)")
 
 	flag.Parse()
 
@@ -67,6 +71,20 @@ func main() {
 		return
 	}
 
+	var shutdownTracing tracing.ShutdownFunc
+	shutdownTracing, err = tracing.Setup(ctx, "gophkeeper-client", traceExporter)
+	if err != nil {
+		logger.Error("setup tracing failed", zap.Error(err))
+		fmt.Println("setup tracing failed, check trace exporter")
+		return
+	}
+	defer func() {
+		shutdownErr := shutdownTracing(context.Background())
+		if shutdownErr != nil {
+			logger.Error("tracing shutdown failed", zap.Error(shutdownErr))
+		}
+	}()
+
 	var c *keeper.Client
 	if serverAddress != "" {
 		c, err = keeper.NewClient(serverAddress)
@@ -75,6 +93,7 @@ func main() {
 			fmt.Printf("error create client, check server address")
 			return
 		}
+		c.SetVersion(buildVersion)
 		logger.Info("server connection established")
 		defer func() {
 			closeErr := c.Close()
@@ -119,6 +138,7 @@ func main() {
 		}
 		logger.Info("key generated successfully", zap.String("file name", fileName))
 		fmt.Printf("key generated successfully, file name: %s\n", fileName)
+		keyPath = fileName
 	} else {
 		privateKey, err = keys.LoadRSAKey(ctx, keyPath)
 		if err != nil {
@@ -132,9 +152,36 @@ func main() {
 	var s *service.Service
 	s, err = service.New(c, privateKey)
 
+	err = setupDevice(ctx, c, s, devicePath(keyPath))
+	if err != nil {
+		logger.Error("device setup failed", zap.Error(err))
+		fmt.Printf("device setup failed: %s\n", err)
+		return
+	}
+
+	go watch(ctx, s)
+
 	work(ctx, s)
 }
 
+// watch - печатать уведомления об изменениях с других устройств, переподключаясь при обрыве потока.
+func watch(ctx context.Context, s *service.Service) {
+	for {
+		watchErr := s.Watch(ctx, func(msg string) {
+			_, _ = fmt.Fprintln(l.Stdout(), msg)
+		})
+		if watchErr != nil {
+			logger.Warn("watch stream failed", zap.Error(watchErr))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
 func work(ctx context.Context, s *service.Service) {
 	for {
 		l.SetPrompt(defaultPrompt)
@@ -158,7 +205,7 @@ func work(ctx context.Context, s *service.Service) {
 			}
 			fmt.Println(strings.TrimSpace(resp))
 		case line == "add":
-			fmt.Print("Usage: add {type}\n\n")
+			fmt.Print("Usage: add {type} [ttl]\n\n")
 			fmt.Println(dataverse.Description)
 		case strings.HasPrefix(line, "add "):
 			resp, err = s.Add(ctx, line[4:], l)
@@ -176,6 +223,14 @@ func work(ctx context.Context, s *service.Service) {
 				continue
 			}
 			fmt.Println(strings.TrimSpace(resp))
+		case line == "changes":
+			resp, err = s.Changes(ctx)
+			if err != nil {
+				logger.Error("changes method returned an error", zap.Error(err))
+				fmt.Printf("error: %s\n", err)
+				continue
+			}
+			fmt.Println(strings.TrimSpace(resp))
 		case line == "delete":
 			fmt.Println("Usage: delete {id}")
 		case strings.HasPrefix(line, "delete "):
@@ -186,8 +241,56 @@ func work(ctx context.Context, s *service.Service) {
 				continue
 			}
 			fmt.Println(strings.TrimSpace(resp))
+		case line == "history":
+			fmt.Println("Usage: history {id}")
+		case strings.HasPrefix(line, "history "):
+			resp, err = s.History(ctx, line[8:])
+			if err != nil {
+				logger.Error("history method returned an error", zap.Error(err))
+				fmt.Printf("error: %s\n", err)
+				continue
+			}
+			fmt.Println(strings.TrimSpace(resp))
+		case line == "revert":
+			fmt.Println("Usage: revert {id} {version}")
+		case strings.HasPrefix(line, "revert "):
+			resp, err = s.Revert(ctx, line[7:])
+			if err != nil {
+				logger.Error("revert method returned an error", zap.Error(err))
+				fmt.Printf("error: %s\n", err)
+				continue
+			}
+			fmt.Println(strings.TrimSpace(resp))
+		case line == "trash":
+			resp, err = s.Trash(ctx)
+			if err != nil {
+				logger.Error("trash method returned an error", zap.Error(err))
+				fmt.Printf("error: %s\n", err)
+				continue
+			}
+			fmt.Println(strings.TrimSpace(resp))
+		case line == "restore":
+			fmt.Println("Usage: restore {id}")
+		case strings.HasPrefix(line, "restore "):
+			resp, err = s.Restore(ctx, line[8:])
+			if err != nil {
+				logger.Error("restore method returned an error", zap.Error(err))
+				fmt.Printf("error: %s\n", err)
+				continue
+			}
+			fmt.Println(strings.TrimSpace(resp))
+		case line == "purge":
+			fmt.Println("Usage: purge {id}")
+		case strings.HasPrefix(line, "purge "):
+			resp, err = s.Purge(ctx, line[6:])
+			if err != nil {
+				logger.Error("purge method returned an error", zap.Error(err))
+				fmt.Printf("error: %s\n", err)
+				continue
+			}
+			fmt.Println(strings.TrimSpace(resp))
 		case line == "update":
-			fmt.Println("Usage: update {id} {type}")
+			fmt.Println("Usage: update {id} {type} [ttl]")
 		case strings.HasPrefix(line, "update "):
 			resp, err = s.Update(ctx, line[7:], l)
 			if err != nil {
@@ -196,6 +299,58 @@ func work(ctx context.Context, s *service.Service) {
 				continue
 			}
 			fmt.Println(strings.TrimSpace(resp))
+		case line == "upload":
+			resp, err = s.Upload(ctx, l)
+			if err != nil {
+				logger.Error("upload method returned an error", zap.Error(err))
+				fmt.Printf("error: %s\n", err)
+				continue
+			}
+			fmt.Println(strings.TrimSpace(resp))
+		case line == "download":
+			fmt.Println("Usage: download {id}")
+		case strings.HasPrefix(line, "download "):
+			resp, err = s.Download(ctx, line[9:])
+			if err != nil {
+				logger.Error("download method returned an error", zap.Error(err))
+				fmt.Printf("error: %s\n", err)
+				continue
+			}
+			fmt.Println(strings.TrimSpace(resp))
+		case line == "audit", line == "audit more":
+			resp, err = s.Audit(ctx, line == "audit more")
+			if err != nil {
+				logger.Error("audit method returned an error", zap.Error(err))
+				fmt.Printf("error: %s\n", err)
+				continue
+			}
+			fmt.Println(strings.TrimSpace(resp))
+		case line == "devices":
+			resp, err = s.Devices(ctx)
+			if err != nil {
+				logger.Error("devices method returned an error", zap.Error(err))
+				fmt.Printf("error: %s\n", err)
+				continue
+			}
+			fmt.Println(strings.TrimSpace(resp))
+		case line == "revoke-device":
+			fmt.Println("Usage: revoke-device {id}")
+		case strings.HasPrefix(line, "revoke-device "):
+			resp, err = s.RevokeDevice(ctx, line[14:])
+			if err != nil {
+				logger.Error("revoke-device method returned an error", zap.Error(err))
+				fmt.Printf("error: %s\n", err)
+				continue
+			}
+			fmt.Println(strings.TrimSpace(resp))
+		case line == "delete-account":
+			resp, err = s.DeleteAccount(ctx, l)
+			if err != nil {
+				logger.Error("delete-account method returned an error", zap.Error(err))
+				fmt.Printf("error: %s\n", err)
+				continue
+			}
+			fmt.Println(strings.TrimSpace(resp))
 		case line == "version":
 			fmt.Printf(versionTemplate, buildVersion, buildDate, buildCommit)
 		default:
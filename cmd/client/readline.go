@@ -25,8 +25,22 @@ var completer = readline.NewPrefixCompleter(
 	readline.PcItem("get"),
 	readline.PcItem("add"),
 	readline.PcItem("all"),
+	readline.PcItem("changes"),
 	readline.PcItem("delete"),
+	readline.PcItem("trash"),
+	readline.PcItem("restore"),
+	readline.PcItem("purge"),
 	readline.PcItem("update"),
+	readline.PcItem("history"),
+	readline.PcItem("revert"),
+	readline.PcItem("upload"),
+	readline.PcItem("download"),
+	readline.PcItem("audit",
+		readline.PcItem("more"),
+	),
+	readline.PcItem("devices"),
+	readline.PcItem("revoke-device"),
+	readline.PcItem("delete-account"),
 	readline.PcItem("version"),
 )
 
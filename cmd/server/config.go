@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/config"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+)
+
+// loadConfig - прочитать конфигурацию из файла и переменных окружения,
+// применить заданные флаги командной строки и проверить результат.
+func loadConfig(path string) (*config.Config, error) {
+	cfg, loadErr := config.Load(path)
+	if loadErr != nil {
+		return nil, loadErr
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		value := f.Value.(flag.Getter).Get()
+		switch f.Name {
+		case "a":
+			cfg.Server.Address = value.(string)
+		case "d":
+			cfg.Storage.DSN = value.(string)
+		case "c":
+			cfg.Server.TLSCert = value.(string)
+		case "k":
+			cfg.Server.TLSKey = value.(string)
+		case "m":
+			cfg.Server.MetricsAddress = value.(string)
+		case "t":
+			cfg.Server.TraceExporter = value.(string)
+		case "g":
+			cfg.Server.GatewayAddress = value.(string)
+		case "r":
+			cfg.Maintenance.TrashRetention = value.(time.Duration)
+		case "n":
+			cfg.Storage.HistoryLimit = value.(int)
+		}
+	})
+
+	validateErr := cfg.Validate()
+	if validateErr != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", validateErr)
+	}
+
+	return cfg, nil
+}
+
+// reloadConfig - перечитывать конфигурацию по SIGHUP. Без перезапуска применяются
+// уровень логирования, время выполнения запросов, настройки пула соединений
+// и режим обслуживания, об остальных изменениях пишется предупреждение. Сроки хранения и интервал
+// фоновой очистки задачи получают при запуске, поэтому их изменение тоже требует перезапуска.
+func reloadConfig(ctx context.Context, path string, current config.Config, s storage.Storage,
+	readOnly *readOnlySwitch,
+) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			next, reloadErr := loadConfig(path)
+			if reloadErr != nil {
+				logger.Error("error reload config, keep current", zap.Error(reloadErr))
+				continue
+			}
+
+			if changed := current.RestartRequired(next); len(changed) > 0 {
+				logger.Warn("config changes require restart", zap.Strings("changed", changed))
+			}
+
+			logLevel.SetLevel(next.LogLevel())
+			current.Log = next.Log
+
+			reloaded := next.Storage
+			reloaded.DSN = current.Storage.DSN
+			reloaded.HistoryLimit = current.Storage.HistoryLimit
+			current.Storage = reloaded
+			if r, ok := s.(interface{ Reconfigure(cfg storage.Config) }); ok {
+				r.Reconfigure(current.StorageConfig())
+			}
+
+			current.Maintenance.ReadOnly = next.Maintenance.ReadOnly
+			current.Maintenance.ReadOnlyRetryAfter = next.Maintenance.ReadOnlyRetryAfter
+			readOnly.setConfig(current.Maintenance)
+
+			logger.Info("config reloaded",
+				zap.String("logLevel", current.Log.Level),
+				zap.Duration("queryTimeout", current.Storage.QueryTimeout),
+				zap.Int("maxOpenConns", current.Storage.MaxOpenConns),
+				zap.Int("maxIdleConns", current.Storage.MaxIdleConns),
+				zap.Bool("readOnly", current.Maintenance.ReadOnly),
+			)
+		}
+	}
+}
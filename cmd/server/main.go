@@ -3,32 +3,47 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
 
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/blobstore"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/config"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/gateway"
 	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/interceptor"
 	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
 	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
 	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
 )
 
 var (
-	logger *zap.Logger
-	err    error
+	logger   *zap.Logger
+	logLevel = zap.NewAtomicLevel()
+	err      error
 )
 
 func init() {
-	logger, err = zap.NewProduction(zap.AddStacktrace(zapcore.PanicLevel))
+	config := zap.NewProductionConfig()
+	config.Level = logLevel
+
+	logger, err = config.Build(zap.AddStacktrace(zapcore.PanicLevel))
 	if err != nil {
 		panic(fmt.Errorf("error create logger: %w", err))
 	}
@@ -38,35 +53,75 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
 	defer cancel()
 
-	var (
-		serverAddress string
-		dsn           string
-		tlsCert       string
-		tlsKey        string
-	)
+	var configPath string
 
-	flag.StringVar(&serverAddress, "a", ":3200", "server address")
-	flag.StringVar(&dsn, "d", "", "postgres dsn")
-	flag.StringVar(&tlsCert, "c", "", "TLS server cert path")
-	flag.StringVar(&tlsKey, "k", "", "TLS server key path")
+	flag.StringVar(&configPath, "f", os.Getenv("GOPHKEEPER_CONFIG"), "config file path (YAML)")
+	flag.String("a", ":3200", "server address")
+	flag.String("d", "", "storage dsn: postgres://, sqlite:// or memory://")
+	flag.String("c", "", "TLS server cert path")
+	flag.String("k", "", "TLS server key path")
+	flag.String("m", "", "prometheus metrics address (disabled if empty)")
+	flag.String("t", "", "trace exporter: otlp or file:{path} (disabled if empty)")
+	flag.String("g", "", "HTTP/JSON gateway address (disabled if empty)")
+	flag.Duration("r", 30*24*time.Hour, "how long deleted entries are kept in trash")
+	flag.Int("n", 10, "number of previous versions kept for each entry")
 	flag.Parse()
 
-	if dsn == "" {
-		dsn = os.Getenv("POSTGRES_DSN")
-	}
-
-	if serverAddress == "" || dsn == "" {
+	var cfg *config.Config
+	cfg, err = loadConfig(configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		flag.PrintDefaults()
 		return
 	}
+	logLevel.SetLevel(cfg.LogLevel())
+
+	var (
+		serverAddress = cfg.Server.Address
+		tlsCert       = cfg.Server.TLSCert
+		tlsKey        = cfg.Server.TLSKey
+		metricsAddr   = cfg.Server.MetricsAddress
+		gatewayAddr   = cfg.Server.GatewayAddress
+	)
 
 	logger.Info("configuration",
+		zap.String("configPath", configPath),
 		zap.String("serverAddress", serverAddress),
-		zap.String("dsn", dsn),
+		zap.String("dsn", cfg.Storage.DSN),
 		zap.String("tlsCert", tlsCert),
 		zap.String("tlsKey", tlsKey),
+		zap.String("metricsAddr", metricsAddr),
+		zap.String("traceExporter", cfg.Server.TraceExporter),
+		zap.String("gatewayAddr", gatewayAddr),
+		zap.String("logLevel", cfg.Log.Level),
+		zap.Int("maxRecvMsgSize", cfg.Server.MaxRecvMsgSize),
+		zap.Int("maxSendMsgSize", cfg.Server.MaxSendMsgSize),
+		zap.Duration("queryTimeout", cfg.Storage.QueryTimeout),
+		zap.Int("maxOpenConns", cfg.Storage.MaxOpenConns),
+		zap.Duration("trashRetention", cfg.Maintenance.TrashRetention),
+		zap.Duration("idempotencyRetention", cfg.Maintenance.IdempotencyRetention),
+		zap.Bool("readOnly", cfg.Maintenance.ReadOnly),
+		zap.Int("historyLimit", cfg.Storage.HistoryLimit),
+		zap.String("blobKind", cfg.Blob.Kind),
+		zap.Int("blobThreshold", cfg.Blob.Threshold),
 	)
 
+	var shutdownTracing tracing.ShutdownFunc
+	shutdownTracing, err = tracing.Setup(ctx, "gophkeeper-server", cfg.Server.TraceExporter)
+	if err != nil {
+		logger.Panic("error setup tracing", zap.Error(err))
+	}
+	defer func() {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer shutdownCancel()
+		shutdownErr := shutdownTracing(shutdownCtx)
+		if shutdownErr != nil {
+			logger.Error("error shutdown tracing", zap.Error(shutdownErr))
+			return
+		}
+		logger.Info("tracing stopped")
+	}()
+
 	var tlsCredentials credentials.TransportCredentials
 	if tlsCert == "" || tlsKey == "" {
 		logger.Warn("TLS certificates is empty, use it for security!")
@@ -77,8 +132,8 @@ func main() {
 		}
 	}
 
-	var s *storage.ServerStorage
-	s, err = storage.NewServerStorage(dsn)
+	var s storage.Storage
+	s, err = storage.Open(cfg.StorageConfig())
 	if err != nil {
 		logger.Panic("error create server storage", zap.Error(err))
 	}
@@ -91,17 +146,94 @@ func main() {
 		logger.Info("storage closed")
 	}()
 
+	if dbs, ok := s.(metrics.DBStatser); ok {
+		err = metrics.RegisterDBStats(dbs)
+		if err != nil {
+			logger.Panic("error register db stats metrics", zap.Error(err))
+		}
+	}
+
+	keeperStorage := s
+	var bs *storage.BlobStorage
+	if cfg.Blob.Kind != "" {
+		var blobs blobstore.Store
+		blobs, err = blobstore.Open(cfg.BlobStoreConfig())
+		if err != nil {
+			logger.Panic("error open blob store", zap.Error(err))
+		}
+
+		bs = storage.NewBlobStorage(s, blobs, cfg.Blob.Threshold)
+		keeperStorage = bs
+	}
+
 	var ln net.Listener
 	ln, err = net.Listen("tcp", serverAddress)
 	if err != nil {
 		logger.Panic("error listen server address", zap.Error(err))
 	}
 
+	ks := keeper.NewServer(keeperStorage, cfg.Maintenance.TrashRetention, cfg.Maintenance.IdempotencyRetention)
+	readOnly := &readOnlySwitch{keeper: ks}
+	readOnly.setConfig(cfg.Maintenance)
+	go watchReadOnly(ctx, s, readOnly)
+	go reloadConfig(ctx, configPath, *cfg, s, readOnly)
+
+	m := cfg.Maintenance
+	go every(ctx, m.CleanupInterval, "delete stale uploads", readOnly, func(ctx context.Context) (int64, error) {
+		return s.DeleteStaleUploads(ctx, time.Now().Add(-m.UploadRetention))
+	})
+	go every(ctx, m.CleanupInterval, "purge trash", readOnly, func(ctx context.Context) (int64, error) {
+		return s.PurgeTrash(ctx, time.Now().Add(-m.TrashRetention))
+	})
+	go every(ctx, m.CleanupInterval, "purge expired entries", readOnly, func(ctx context.Context) (int64, error) {
+		return s.PurgeExpired(ctx, time.Now())
+	})
+	go every(ctx, m.CleanupInterval, "delete expired idempotency keys", readOnly,
+		func(ctx context.Context) (int64, error) {
+			return s.DeleteExpiredIdempotencyKeys(ctx, time.Now().Add(-m.IdempotencyRetention))
+		},
+	)
+	go every(ctx, m.CleanupInterval, "delete expired challenges", readOnly,
+		func(ctx context.Context) (int64, error) {
+			return s.DeleteExpiredChallenges(ctx, time.Now())
+		},
+	)
+	go every(ctx, m.CleanupInterval, "purge tombstones", readOnly, func(ctx context.Context) (int64, error) {
+		return s.PurgeTombstones(ctx, time.Now().Add(-m.TombstoneRetention))
+	})
+	if bs != nil {
+		go every(ctx, m.CleanupInterval, "collect blobs", readOnly, func(ctx context.Context) (int64, error) {
+			return bs.CollectGarbage(ctx, time.Now().Add(-cfg.Blob.GCGrace))
+		})
+	}
+	go func() {
+		listenErr := ks.ListenChanges(ctx)
+		if listenErr != nil {
+			logger.Error("error listen changes, watch notifications disabled", zap.Error(listenErr))
+		}
+	}()
+
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(cfg.Server.MaxRecvMsgSize),
+		grpc.MaxSendMsgSize(cfg.Server.MaxSendMsgSize),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:              cfg.Server.Keepalive.Time,
+			Timeout:           cfg.Server.Keepalive.Timeout,
+			MaxConnectionIdle: cfg.Server.Keepalive.MaxConnectionIdle,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             cfg.Server.Keepalive.MinTime,
+			PermitWithoutStream: cfg.Server.Keepalive.PermitWithoutStream,
+		}),
+	}
+
 	var g *grpc.Server
 	if tlsCredentials != nil {
-		g = grpc.NewServer(
+		g = grpc.NewServer(append(opts,
 			grpc.Creds(tlsCredentials),
 			grpc.ChainUnaryInterceptor(
+				otelgrpc.UnaryServerInterceptor(),
+				interceptor.UnaryMetrics(),
 				logging.UnaryServerInterceptor(
 					interceptor.Logger(logger),
 					logging.WithLogOnEvents(
@@ -109,13 +241,38 @@ func main() {
 						logging.FinishCall,
 					),
 				),
+				ks.UnaryReadOnly(),
+				ks.UnaryDeviceAuth(),
+				ks.UnaryOwnerStatus(),
 			),
-		)
+			grpc.ChainStreamInterceptor(
+				otelgrpc.StreamServerInterceptor(),
+				interceptor.StreamMetrics(),
+				ks.StreamReadOnly(),
+				ks.StreamDeviceAuth(),
+				ks.StreamOwnerStatus(),
+			),
+		)...)
 	} else {
-		g = grpc.NewServer()
+		g = grpc.NewServer(append(opts,
+			grpc.ChainUnaryInterceptor(
+				otelgrpc.UnaryServerInterceptor(),
+				interceptor.UnaryMetrics(),
+				ks.UnaryReadOnly(),
+				ks.UnaryDeviceAuth(),
+				ks.UnaryOwnerStatus(),
+			),
+			grpc.ChainStreamInterceptor(
+				otelgrpc.StreamServerInterceptor(),
+				interceptor.StreamMetrics(),
+				ks.StreamReadOnly(),
+				ks.StreamDeviceAuth(),
+				ks.StreamOwnerStatus(),
+			),
+		)...)
 	}
 
-	pb.RegisterKeeperServer(g, keeper.NewServer(s))
+	pb.RegisterKeeperServer(g, ks)
 	go func() {
 		logger.Info("starting server")
 		serverErr := g.Serve(ln)
@@ -124,11 +281,117 @@ func main() {
 		}
 	}()
 
+	var ms *http.Server
+	if metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		mux.Handle("/healthz", ks.HealthHandler())
+		ms = &http.Server{
+			Addr:              metricsAddr,
+			Handler:           mux,
+			ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		}
+		go func() {
+			logger.Info("starting metrics server")
+			serverErr := ms.ListenAndServe()
+			if serverErr != nil && !errors.Is(serverErr, http.ErrServerClosed) {
+				logger.Panic("metrics server failed", zap.Error(serverErr))
+			}
+		}()
+	}
+
+	var gs *http.Server
+	if gatewayAddr != "" {
+		dialCredentials := insecure.NewCredentials()
+		if tlsCredentials != nil {
+			dialCredentials = credentials.NewTLS(&tls.Config{
+				MinVersion:         tls.VersionTLS13,
+				InsecureSkipVerify: true, //nolint:gosec // loopback connection to our own grpc server
+			})
+		}
+
+		var h http.Handler
+		h, err = gateway.New(ctx, serverAddress, ks.HealthHandler(),
+			grpc.WithTransportCredentials(dialCredentials),
+			grpc.WithDefaultCallOptions(
+				grpc.MaxCallRecvMsgSize(cfg.Server.MaxSendMsgSize),
+				grpc.MaxCallSendMsgSize(cfg.Server.MaxRecvMsgSize),
+			),
+		)
+		if err != nil {
+			logger.Panic("error create gateway", zap.Error(err))
+		}
+
+		gs = &http.Server{
+			Addr:              gatewayAddr,
+			Handler:           h,
+			ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		}
+		go func() {
+			logger.Info("starting gateway server")
+			serverErr := gs.ListenAndServe()
+			if serverErr != nil && !errors.Is(serverErr, http.ErrServerClosed) {
+				logger.Panic("gateway server failed", zap.Error(serverErr))
+			}
+		}()
+	}
+
 	<-ctx.Done()
 	logger.Info("ctx done")
 
+	if gs != nil {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer shutdownCancel()
+		err = gs.Shutdown(shutdownCtx)
+		if err != nil {
+			logger.Error("error shutdown gateway server", zap.Error(err))
+		} else {
+			logger.Info("gateway server stopped")
+		}
+	}
+
 	g.GracefulStop()
 	logger.Info("grpc server stopped")
+
+	if ms != nil {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer shutdownCancel()
+		err = ms.Shutdown(shutdownCtx)
+		if err != nil {
+			logger.Error("error shutdown metrics server", zap.Error(err))
+			return
+		}
+		logger.Info("metrics server stopped")
+	}
+}
+
+// every - выполнять job каждые interval до отмены ctx. job возвращает количество удаленных объектов,
+// name - описание задачи для журнала. В режиме обслуживания job пропускается: база в нем не изменяется.
+func every(ctx context.Context, interval time.Duration, name string, readOnly *readOnlySwitch,
+	job func(ctx context.Context) (int64, error),
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if readOnly.enabled() {
+				logger.Debug(name + " skipped in read-only mode")
+				continue
+			}
+			n, jobErr := job(ctx)
+			if jobErr != nil {
+				logger.Error("error "+name, zap.Error(jobErr))
+				continue
+			}
+			if n > 0 {
+				logger.Info(name+" done", zap.Int64("count", n))
+			}
+		}
+	}
 }
 
 func loadTLSCredentials(cert, key string) (credentials.TransportCredentials, error) {
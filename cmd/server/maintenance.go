@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/config"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+)
+
+// readOnlyPollInterval - как часто сервер проверяет режим обслуживания, включенный утилитой администратора.
+const readOnlyPollInterval = 5 * time.Second
+
+// readOnlySwitch - режим обслуживания из двух источников: конфигурации, которая перечитывается по SIGHUP,
+// и хранилища, где его переключает команда read-only утилиты администратора.
+// Сервер в режиме обслуживания, пока режим включен хотя бы в одном из них.
+type readOnlySwitch struct {
+	mu     sync.Mutex
+	keeper interface {
+		SetReadOnly(mode storage.ReadOnlyMode)
+	}
+	config   storage.ReadOnlyMode
+	operator storage.ReadOnlyMode
+	current  storage.ReadOnlyMode
+}
+
+// setConfig - применить режим обслуживания из конфигурации.
+func (r *readOnlySwitch) setConfig(cfg config.Maintenance) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	since := r.config.Since
+	if !r.config.Enabled {
+		since = time.Now()
+	}
+	r.config = storage.ReadOnlyMode{Enabled: cfg.ReadOnly, RetryAfter: cfg.ReadOnlyRetryAfter, Since: since}
+	r.apply()
+}
+
+// setOperator - применить режим обслуживания из хранилища.
+func (r *readOnlySwitch) setOperator(mode storage.ReadOnlyMode) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.operator = mode
+	r.apply()
+}
+
+// enabled - включен ли сейчас режим обслуживания.
+func (r *readOnlySwitch) enabled() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.current.Enabled
+}
+
+// apply - передать обработчикам итоговый режим, режим из хранилища важнее конфигурации.
+func (r *readOnlySwitch) apply() {
+	mode := storage.ReadOnlyMode{}
+	switch {
+	case r.operator.Enabled:
+		mode = r.operator
+	case r.config.Enabled:
+		mode = r.config
+	}
+	if mode.Enabled == r.current.Enabled && mode.RetryAfter == r.current.RetryAfter &&
+		mode.Since.Equal(r.current.Since) {
+		return
+	}
+	r.current = mode
+	r.keeper.SetReadOnly(mode)
+
+	if mode.Enabled {
+		logger.Warn("read-only maintenance mode enabled", zap.Duration("retryAfter", mode.RetryAfter))
+	} else {
+		logger.Info("read-only maintenance mode disabled")
+	}
+}
+
+// watchReadOnly - периодически читать режим обслуживания из хранилища.
+func watchReadOnly(ctx context.Context, s storage.Storage, r *readOnlySwitch) {
+	ticker := time.NewTicker(readOnlyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		mode, getErr := s.GetReadOnlyMode(ctx)
+		if getErr != nil {
+			logger.Error("error get read-only mode", zap.Error(getErr))
+		} else {
+			r.setOperator(mode)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
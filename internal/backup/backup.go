@@ -0,0 +1,348 @@
+// Package backup пишет и читает переносимую резервную копию хранилища сервера GophKeeper.
+//
+// Архив - gzip с JSON Lines: первая строка - заголовок с форматом и версией,
+// затем записи storage.BackupRecord в порядке Export, последняя строка - манифест
+// с количеством и SHA-256 записей каждого вида и SHA-256 всех строк до манифеста.
+// Архив пишется потоком и не зависит от базы: копию Postgres можно восстановить в SQLite и наоборот.
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+)
+
+const (
+	// Format - название формата в заголовке архива.
+	Format = "gophkeeper-backup"
+	// Version - версия формата архива.
+	Version = 1
+)
+
+// ErrInvalid - архив поврежден, обрезан или имеет неизвестный формат.
+var ErrInvalid = errors.New("invalid backup archive")
+
+// ErrChecksum - записи архива не совпадают с манифестом.
+var ErrChecksum = errors.New("backup checksum mismatch")
+
+// Exporter - хранилище, из которого пишется резервная копия.
+type Exporter interface {
+	Export(ctx context.Context, fn func(storage.BackupRecord) error) error
+}
+
+// Importer - хранилище, в которое восстанавливается резервная копия.
+type Importer interface {
+	Import(ctx context.Context, next func() (storage.BackupRecord, error)) error
+}
+
+// Header - заголовок архива.
+type Header struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Section - количество и SHA-256 строк записей одного вида.
+type Section struct {
+	Count  int64  `json:"count"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest - манифест архива: разделы по видам записей (storage.BackupRecord Kind)
+// и SHA-256 всех строк архива до манифеста, включая заголовок.
+type Manifest struct {
+	CreatedAt time.Time          `json:"-"`
+	Sections  map[string]Section `json:"sections"`
+	SHA256    string             `json:"sha256"`
+}
+
+// String - разделы манифеста в виде "kind=count", отсортированные по виду.
+func (m Manifest) String() string {
+	kinds := make([]string, 0, len(m.Sections))
+	for kind := range m.Sections {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	parts := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		parts = append(parts, fmt.Sprintf("%s=%d", kind, m.Sections[kind].Count))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// line - строка архива: заголовок, запись или манифест.
+type line struct {
+	Header *Header `json:"header,omitempty"`
+	storage.BackupRecord
+	Manifest *Manifest `json:"manifest,omitempty"`
+}
+
+// digest - подсчет манифеста по строкам архива.
+type digest struct {
+	total    hash.Hash
+	counts   map[string]int64
+	sections map[string]hash.Hash
+}
+
+func newDigest() *digest {
+	return &digest{
+		total:    sha256.New(),
+		counts:   make(map[string]int64),
+		sections: make(map[string]hash.Hash),
+	}
+}
+
+// add - учесть строку архива с переводом строки. kind пустой для заголовка.
+func (d *digest) add(kind string, b []byte) {
+	_, _ = d.total.Write(b)
+	if kind == "" {
+		return
+	}
+
+	h, ok := d.sections[kind]
+	if !ok {
+		h = sha256.New()
+		d.sections[kind] = h
+	}
+	_, _ = h.Write(b)
+	d.counts[kind]++
+}
+
+func (d *digest) manifest() Manifest {
+	m := Manifest{
+		Sections: make(map[string]Section, len(d.sections)),
+		SHA256:   hex.EncodeToString(d.total.Sum(nil)),
+	}
+	for kind, h := range d.sections {
+		m.Sections[kind] = Section{Count: d.counts[kind], SHA256: hex.EncodeToString(h.Sum(nil))}
+	}
+
+	return m
+}
+
+// Write - записать в w резервную копию хранилища s и вернуть ее манифест.
+func Write(ctx context.Context, s Exporter, w io.Writer) (Manifest, error) {
+	zw := gzip.NewWriter(w)
+	bw := bufio.NewWriter(zw)
+	d := newDigest()
+
+	writeLine := func(kind string, l line) error {
+		b, err := json.Marshal(l)
+		if err != nil {
+			return fmt.Errorf("marshal %s: %w", kind, err)
+		}
+		b = append(b, '\n')
+		d.add(kind, b)
+
+		_, err = bw.Write(b)
+		return err
+	}
+
+	header := Header{Format: Format, Version: Version, CreatedAt: time.Now().UTC()}
+	err := writeLine("", line{Header: &header})
+	if err != nil {
+		return Manifest{}, fmt.Errorf("backup Write: header: %w", err)
+	}
+
+	err = s.Export(ctx, func(r storage.BackupRecord) error {
+		return writeLine(r.Kind(), line{BackupRecord: r})
+	})
+	if err != nil {
+		return Manifest{}, fmt.Errorf("backup Write: %w", err)
+	}
+
+	m := d.manifest()
+	m.CreatedAt = header.CreatedAt
+	b, err := json.Marshal(line{Manifest: &m})
+	if err != nil {
+		return Manifest{}, fmt.Errorf("backup Write: marshal manifest: %w", err)
+	}
+	_, err = bw.Write(append(b, '\n'))
+	if err != nil {
+		return Manifest{}, fmt.Errorf("backup Write: manifest: %w", err)
+	}
+
+	err = bw.Flush()
+	if err != nil {
+		return Manifest{}, fmt.Errorf("backup Write: flush: %w", err)
+	}
+	err = zw.Close()
+	if err != nil {
+		return Manifest{}, fmt.Errorf("backup Write: close gzip: %w", err)
+	}
+
+	return m, nil
+}
+
+// Reader - последовательное чтение записей архива с проверкой манифеста.
+type Reader struct {
+	zr       *gzip.Reader
+	br       *bufio.Reader
+	digest   *digest
+	header   Header
+	manifest Manifest
+	done     bool
+}
+
+// NewReader - открыть архив и прочитать заголовок.
+func NewReader(r io.Reader) (*Reader, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+
+	rd := &Reader{
+		zr:     zr,
+		br:     bufio.NewReader(zr),
+		digest: newDigest(),
+	}
+
+	l, b, err := rd.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if l.Header == nil || l.Header.Format != Format {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalid)
+	}
+	if l.Header.Version != Version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalid, l.Header.Version)
+	}
+	rd.header = *l.Header
+	rd.digest.add("", b)
+
+	return rd, nil
+}
+
+// readLine - прочитать и разобрать строку архива.
+func (rd *Reader) readLine() (line, []byte, error) {
+	b, err := rd.br.ReadBytes('\n')
+	if errors.Is(err, io.EOF) {
+		return line{}, nil, fmt.Errorf("%w: unexpected end of archive", ErrInvalid)
+	}
+	if err != nil {
+		return line{}, nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+
+	var l line
+	err = json.Unmarshal(b, &l)
+	if err != nil {
+		return line{}, nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+
+	return l, b, nil
+}
+
+// Next - следующая запись архива. После последней записи Next сверяет архив с манифестом
+// и возвращает io.EOF, только если все совпало.
+func (rd *Reader) Next() (storage.BackupRecord, error) {
+	if rd.done {
+		return storage.BackupRecord{}, io.EOF
+	}
+
+	l, b, err := rd.readLine()
+	if err != nil {
+		return storage.BackupRecord{}, err
+	}
+
+	if l.Manifest != nil {
+		err = rd.finish(*l.Manifest)
+		if err != nil {
+			return storage.BackupRecord{}, err
+		}
+		return storage.BackupRecord{}, io.EOF
+	}
+
+	kind := l.Kind()
+	if kind == "" || l.Header != nil {
+		return storage.BackupRecord{}, fmt.Errorf("%w: unexpected line", ErrInvalid)
+	}
+	rd.digest.add(kind, b)
+
+	return l.BackupRecord, nil
+}
+
+// finish - сверить прочитанные записи с манифестом и убедиться, что после него архив заканчивается.
+func (rd *Reader) finish(expected Manifest) error {
+	actual := rd.digest.manifest()
+	if actual.SHA256 != expected.SHA256 || len(actual.Sections) != len(expected.Sections) {
+		return ErrChecksum
+	}
+	for kind, s := range expected.Sections {
+		if actual.Sections[kind] != s {
+			return fmt.Errorf("%w: %s", ErrChecksum, kind)
+		}
+	}
+
+	rest, err := io.ReadAll(rd.br)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	if len(bytes.TrimSpace(rest)) != 0 {
+		return fmt.Errorf("%w: data after manifest", ErrInvalid)
+	}
+	err = rd.zr.Close()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+
+	rd.manifest = actual
+	rd.manifest.CreatedAt = rd.header.CreatedAt
+	rd.done = true
+
+	return nil
+}
+
+// Manifest - манифест архива, заполнен после того, как Next вернул io.EOF.
+func (rd *Reader) Manifest() Manifest {
+	return rd.manifest
+}
+
+// Restore - восстановить резервную копию из r в пустое хранилище s и вернуть ее манифест.
+// Архив сверяется с манифестом до фиксации транзакции Import, поэтому поврежденный
+// или обрезанный архив не оставляет в хранилище частичных данных.
+func Restore(ctx context.Context, s Importer, r io.Reader) (Manifest, error) {
+	rd, err := NewReader(r)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("backup Restore: %w", err)
+	}
+
+	err = s.Import(ctx, rd.Next)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("backup Restore: %w", err)
+	}
+
+	return rd.Manifest(), nil
+}
+
+// Verify - прочитать архив целиком и сверить его с манифестом, ничего не восстанавливая.
+func Verify(r io.Reader) (Manifest, error) {
+	rd, err := NewReader(r)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("backup Verify: %w", err)
+	}
+
+	for {
+		_, err = rd.Next()
+		if errors.Is(err, io.EOF) {
+			return rd.Manifest(), nil
+		}
+		if err != nil {
+			return Manifest{}, fmt.Errorf("backup Verify: %w", err)
+		}
+	}
+}
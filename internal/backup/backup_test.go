@@ -0,0 +1,196 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+)
+
+// seed - хранилище в памяти с записью, историей, корзиной, файлом, аудитом и устройством.
+func seed(t *testing.T) (*storage.MemoryStorage, []byte, uuid.UUID) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage(10)
+	owner := []byte("owner")
+
+	id, err := s.Create(ctx, owner, []byte("v1"), time.Time{})
+	require.NoError(t, err)
+	_, err = s.Update(ctx, id, []byte("v2"), 1, time.Time{})
+	require.NoError(t, err)
+	trashed, err := s.Create(ctx, owner, []byte("trashed"), time.Time{})
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(ctx, trashed, 1))
+	require.NoError(t, s.SaveUploadChunk(ctx, uuid.Nil, owner, 0, []byte("h"), []byte("chunk")))
+	_, err = s.CommitUpload(ctx, uuid.Nil, owner, []byte("file"), []byte("manifest"), [][]byte{[]byte("h")})
+	require.NoError(t, err)
+	require.NoError(t, s.AddAudit(ctx, storage.AuditRecord{PublicKey: owner, Op: storage.AuditCreate, EntryID: id}))
+	_, err = s.RegisterDevice(ctx, owner, "laptop", []byte("token"), "127.0.0.1")
+	require.NoError(t, err)
+
+	return s, owner, id
+}
+
+// archive - резервная копия хранилища s.
+func archive(t *testing.T, s Exporter) ([]byte, Manifest) {
+	var buf bytes.Buffer
+	m, err := Write(context.Background(), s, &buf)
+	require.NoError(t, err)
+	return buf.Bytes(), m
+}
+
+// exportAll - записи Export хранилища s.
+func exportAll(t *testing.T, s Exporter) []storage.BackupRecord {
+	records := make([]storage.BackupRecord, 0)
+	require.NoError(t, s.Export(context.Background(), func(r storage.BackupRecord) error {
+		records = append(records, r)
+		return nil
+	}))
+	return records
+}
+
+// rewrite - распаковать архив, изменить его строки и запаковать обратно.
+func rewrite(t *testing.T, data []byte, fn func(lines [][]byte) [][]byte) []byte {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	raw, err := io.ReadAll(zr)
+	require.NoError(t, err)
+
+	lines := bytes.SplitAfter(raw, []byte("\n"))
+	raw = bytes.Join(fn(lines), nil)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err = zw.Write(raw)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	src, owner, id := seed(t)
+
+	data, m := archive(t, src)
+	assert.Equal(t, int64(3), m.Sections["entry"].Count)
+	assert.Equal(t, int64(1), m.Sections["version"].Count)
+	assert.Equal(t, int64(1), m.Sections["chunk"].Count)
+	assert.Equal(t, int64(1), m.Sections["audit"].Count)
+	assert.Equal(t, int64(1), m.Sections["device"].Count)
+	assert.Contains(t, m.String(), "entry=3")
+
+	verified, err := Verify(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, m.SHA256, verified.SHA256)
+
+	// память -> SQLite -> память
+	lite, err := storage.NewSQLiteStorage(storage.Config{
+		DSN:          storage.SQLiteScheme + filepath.Join(t.TempDir(), "keeper.db"),
+		HistoryLimit: 10,
+	})
+	require.NoError(t, err)
+	defer func() { _ = lite.Close() }()
+
+	restored, err := Restore(ctx, lite, bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, m.Sections, restored.Sections)
+
+	e, err := lite.Get(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v2"), e.Payload)
+	assert.Equal(t, owner, e.PublicKey)
+
+	data, _ = archive(t, lite)
+	dst := storage.NewMemoryStorage(10)
+	_, err = Restore(ctx, dst, bytes.NewReader(data))
+	require.NoError(t, err)
+
+	expected := exportAll(t, src)
+	actual := exportAll(t, dst)
+	require.Len(t, actual, len(expected))
+	for i := range expected {
+		assert.Equal(t, expected[i].Kind(), actual[i].Kind())
+	}
+	history, err := dst.History(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, []byte("v1"), history[0].Payload)
+}
+
+func TestRestore(t *testing.T) {
+	ctx := context.Background()
+	src, _, _ := seed(t)
+	data, _ := archive(t, src)
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{
+			name: "not gzip",
+			data: []byte("plain text"),
+			err:  ErrInvalid,
+		},
+		{
+			name: "truncated",
+			data: rewrite(t, data, func(lines [][]byte) [][]byte { return lines[:len(lines)-3] }),
+			err:  ErrInvalid,
+		},
+		{
+			name: "missing header",
+			data: rewrite(t, data, func(lines [][]byte) [][]byte { return lines[1:] }),
+			err:  ErrInvalid,
+		},
+		{
+			name: "modified record",
+			data: rewrite(t, data, func(lines [][]byte) [][]byte {
+				lines[1] = bytes.Replace(lines[1], []byte(`"version":`), []byte(`"version":1`), 1)
+				return lines
+			}),
+			err: ErrChecksum,
+		},
+		{
+			name: "dropped record",
+			data: rewrite(t, data, func(lines [][]byte) [][]byte {
+				// последняя запись перед манифестом, строки заканчиваются пустой
+				n := len(lines) - 3
+				return append(lines[:n:n], lines[n+1:]...)
+			}),
+			err: ErrChecksum,
+		},
+		{
+			name: "data after manifest",
+			data: rewrite(t, data, func(lines [][]byte) [][]byte { return append(lines, lines[1]) }),
+			err:  ErrInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Verify(bytes.NewReader(tt.data))
+			assert.ErrorIs(t, err, tt.err)
+
+			dst := storage.NewMemoryStorage(10)
+			_, err = Restore(ctx, dst, bytes.NewReader(tt.data))
+			assert.ErrorIs(t, err, tt.err)
+
+			// хранилище осталось пустым, копию можно восстановить заново
+			_, err = Restore(ctx, dst, bytes.NewReader(data))
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Run("not empty", func(t *testing.T) {
+		_, err := Restore(ctx, src, bytes.NewReader(data))
+		assert.ErrorIs(t, err, storage.ErrNotEmpty)
+	})
+}
@@ -0,0 +1,63 @@
+// Package blobstore хранит большие данные записей GophKeeper вне базы данных:
+// в локальном каталоге или в S3-совместимом хранилище (MinIO, AWS S3 и т.п.).
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// KindFS - хранить данные в файлах локального каталога.
+	KindFS = "fs"
+	// KindS3 - хранить данные в бакете S3-совместимого хранилища.
+	KindS3 = "s3"
+)
+
+// ErrNotFound - объект с таким ключом не найден.
+var ErrNotFound = errors.New("blob not found")
+
+// ErrUnknownKind - неизвестный тип хранилища.
+var ErrUnknownKind = errors.New("unknown blob store kind")
+
+// Object - объект хранилища.
+type Object struct {
+	Key     string
+	ModTime time.Time
+}
+
+// Store - хранилище объектов по ключу. Ключи состоят из латинских букв, цифр и дефисов.
+type Store interface {
+	// Put - сохранить объект, существующий объект с тем же ключом перезаписывается.
+	Put(ctx context.Context, key string, data []byte) error
+	// Get - получить объект, ErrNotFound если его нет.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete - удалить объект. Удаление несуществующего объекта не считается ошибкой.
+	Delete(ctx context.Context, key string) error
+	// List - вызвать fn для каждого объекта хранилища, ошибка fn прерывает обход.
+	List(ctx context.Context, fn func(Object) error) error
+}
+
+// Config - настройки хранилища.
+type Config struct {
+	// Kind - тип хранилища: KindFS или KindS3.
+	Kind string
+	// Path - каталог для KindFS.
+	Path string
+	// S3 - настройки для KindS3.
+	S3 S3Config
+}
+
+// Open - открыть хранилище, заданное cfg.
+func Open(cfg Config) (Store, error) {
+	switch cfg.Kind {
+	case KindFS:
+		return NewFS(cfg.Path)
+	case KindS3:
+		return NewS3(cfg.S3)
+	default:
+		return nil, fmt.Errorf("blobstore Open: %w: %q", ErrUnknownKind, cfg.Kind)
+	}
+}
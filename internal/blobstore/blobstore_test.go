@@ -0,0 +1,119 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stores - реализации Store, на которых выполняются тесты.
+// S3 проверяется, если задана переменная окружения TEST_S3_ENDPOINT, например для локального MinIO:
+// TEST_S3_ENDPOINT=localhost:9000 AWS_ACCESS_KEY_ID=minioadmin AWS_SECRET_ACCESS_KEY=minioadmin.
+func stores(t *testing.T) map[string]func(t *testing.T) Store {
+	st := map[string]func(t *testing.T) Store{
+		"fs": func(t *testing.T) Store {
+			s, err := NewFS(filepath.Join(t.TempDir(), "blobs"))
+			require.NoError(t, err)
+			return s
+		},
+	}
+
+	if endpoint := os.Getenv("TEST_S3_ENDPOINT"); endpoint != "" {
+		st["s3"] = func(t *testing.T) Store {
+			bucket := os.Getenv("TEST_S3_BUCKET")
+			if bucket == "" {
+				bucket = "gophkeeper-test"
+			}
+
+			s, err := NewS3(S3Config{
+				Endpoint: endpoint,
+				Bucket:   bucket,
+				Prefix:   uuid.NewString() + "/",
+				Insecure: os.Getenv("TEST_S3_TLS") == "",
+			})
+			require.NoError(t, err)
+
+			ctx := context.Background()
+			exists, err := s.client.BucketExists(ctx, bucket)
+			require.NoError(t, err)
+			if !exists {
+				require.NoError(t, s.client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{}))
+			}
+			return s
+		}
+	}
+
+	return st
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			start := time.Now().Add(-time.Minute)
+
+			_, err := s.Get(ctx, "missing-key")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			require.NoError(t, s.Put(ctx, "key-1", []byte("first")))
+			require.NoError(t, s.Put(ctx, "key-2", []byte("second")))
+			require.NoError(t, s.Put(ctx, "key-1", []byte("first, rewritten")))
+
+			data, err := s.Get(ctx, "key-1")
+			require.NoError(t, err)
+			assert.Equal(t, []byte("first, rewritten"), data)
+
+			keys := make([]string, 0)
+			err = s.List(ctx, func(o Object) error {
+				assert.True(t, o.ModTime.After(start), o.ModTime)
+				keys = append(keys, o.Key)
+				return nil
+			})
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"key-1", "key-2"}, keys)
+
+			stop := errors.New("stop")
+			err = s.List(ctx, func(o Object) error { return stop })
+			assert.ErrorIs(t, err, stop)
+
+			require.NoError(t, s.Delete(ctx, "key-1"))
+			require.NoError(t, s.Delete(ctx, "key-1"))
+			_, err = s.Get(ctx, "key-1")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			require.NoError(t, s.Delete(ctx, "key-2"))
+		})
+	}
+}
+
+func TestFS_InvalidKey(t *testing.T) {
+	s, err := NewFS(t.TempDir())
+	require.NoError(t, err)
+
+	for _, key := range []string{"", "ab", "../etc-passwd", "a/b/c", `a\b\c`} {
+		assert.Error(t, s.Put(context.Background(), key, []byte("x")), key)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	_, err := Open(Config{Kind: "ftp"})
+	assert.ErrorIs(t, err, ErrUnknownKind)
+
+	s, err := Open(Config{Kind: KindFS, Path: t.TempDir()})
+	require.NoError(t, err)
+	assert.IsType(t, &FS{}, s)
+
+	s, err = Open(Config{Kind: KindS3, S3: S3Config{Endpoint: "localhost:9000", Bucket: "b"}})
+	require.NoError(t, err)
+	assert.IsType(t, &S3{}, s)
+}
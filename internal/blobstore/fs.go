@@ -0,0 +1,145 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tmpSuffix - суффикс файлов, которые еще записываются.
+const tmpSuffix = ".tmp"
+
+// FS - хранилище объектов в локальном каталоге. Объекты раскладываются по подкаталогам
+// по первым двум символам ключа, чтобы не держать все файлы в одном каталоге.
+type FS struct {
+	dir string
+}
+
+var _ Store = (*FS)(nil)
+
+// NewFS - открыть хранилище в каталоге dir, каталог создается, если его нет.
+func NewFS(dir string) (*FS, error) {
+	if dir == "" {
+		return nil, errors.New("blobstore NewFS: empty path")
+	}
+
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("blobstore NewFS: mkdir: %w", err)
+	}
+
+	return &FS{dir: dir}, nil
+}
+
+func (s *FS) path(key string) (string, error) {
+	if len(key) < 3 || strings.ContainsAny(key, `/\.`) {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+
+	return filepath.Join(s.dir, key[:2], key), nil
+}
+
+// Put - сохранить объект. Данные пишутся во временный файл и переименовываются,
+// поэтому читатели никогда не видят частично записанный объект.
+func (s *FS) Put(_ context.Context, key string, data []byte) error {
+	p, err := s.path(key)
+	if err != nil {
+		return fmt.Errorf("FS Put: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(p), 0o700)
+	if err != nil {
+		return fmt.Errorf("FS Put: mkdir: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(p), key+"-*"+tmpSuffix)
+	if err != nil {
+		return fmt.Errorf("FS Put: create temp: %w", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("FS Put: write: %w", err)
+	}
+
+	err = os.Rename(f.Name(), p)
+	if err != nil {
+		return fmt.Errorf("FS Put: rename: %w", err)
+	}
+
+	return nil
+}
+
+// Get - получить объект.
+func (s *FS) Get(_ context.Context, key string) ([]byte, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, fmt.Errorf("FS Get: %w", err)
+	}
+
+	data, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("FS Get: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("FS Get: read: %w", err)
+	}
+
+	return data, nil
+}
+
+// Delete - удалить объект.
+func (s *FS) Delete(_ context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return fmt.Errorf("FS Delete: %w", err)
+	}
+
+	err = os.Remove(p)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("FS Delete: remove: %w", err)
+	}
+
+	return nil
+}
+
+// List - обойти объекты каталога. Временные файлы незавершенных записей пропускаются.
+func (s *FS) List(ctx context.Context, fn func(Object) error) error {
+	err := filepath.WalkDir(s.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() || strings.HasSuffix(d.Name(), tmpSuffix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		return fn(Object{Key: d.Name(), ModTime: info.ModTime()})
+	})
+	if err != nil {
+		return fmt.Errorf("FS List: %w", err)
+	}
+
+	return nil
+}
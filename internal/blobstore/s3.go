@@ -0,0 +1,124 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config - настройки S3-совместимого хранилища.
+type S3Config struct {
+	// Endpoint - адрес API без схемы, например localhost:9000 или s3.amazonaws.com.
+	Endpoint string
+	// Bucket - бакет для объектов, должен существовать.
+	Bucket string
+	// Prefix - префикс ключей объектов внутри бакета, например gophkeeper/.
+	Prefix string
+	Region string
+	// AccessKey и SecretKey - ключи доступа. Если не заданы, берутся из
+	// переменных окружения AWS_ACCESS_KEY_ID и AWS_SECRET_ACCESS_KEY.
+	AccessKey string
+	SecretKey string
+	// Insecure - обращаться к API по HTTP без TLS, например к локальному MinIO.
+	Insecure bool
+}
+
+// S3 - хранилище объектов в бакете S3-совместимого хранилища.
+type S3 struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+var _ Store = (*S3)(nil)
+
+// NewS3 - создать клиент хранилища. Соединение не устанавливается до первого запроса.
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("blobstore NewS3: endpoint and bucket are required")
+	}
+
+	creds := credentials.NewEnvAWS()
+	if cfg.AccessKey != "" {
+		creds = credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, "")
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: !cfg.Insecure,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("blobstore NewS3: new client: %w", err)
+	}
+
+	return &S3{client: client, bucket: cfg.Bucket, prefix: cfg.Prefix}, nil
+}
+
+// Put - сохранить объект.
+func (s *S3) Put(ctx context.Context, key string, data []byte) error {
+	_, err := s.client.PutObject(ctx, s.bucket, s.prefix+key, bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: "application/octet-stream"},
+	)
+	if err != nil {
+		return fmt.Errorf("S3 Put: %w", err)
+	}
+
+	return nil
+}
+
+// Get - получить объект.
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, s.prefix+key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("S3 Get: %w", err)
+	}
+	defer func() { _ = obj.Close() }()
+
+	data, err := io.ReadAll(obj)
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return nil, fmt.Errorf("S3 Get: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("S3 Get: read: %w", err)
+	}
+
+	return data, nil
+}
+
+// Delete - удалить объект.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	err := s.client.RemoveObject(ctx, s.bucket, s.prefix+key, minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("S3 Delete: %w", err)
+	}
+
+	return nil
+}
+
+// List - обойти объекты бакета с префиксом хранилища.
+func (s *S3) List(ctx context.Context, fn func(Object) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for info := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.prefix, Recursive: true}) {
+		if info.Err != nil {
+			return fmt.Errorf("S3 List: %w", info.Err)
+		}
+
+		err := fn(Object{Key: info.Key[len(s.prefix):], ModTime: info.LastModified})
+		if err != nil {
+			return fmt.Errorf("S3 List: %w", err)
+		}
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("S3 List: %w", ctx.Err())
+	}
+
+	return nil
+}
@@ -0,0 +1,321 @@
+// Package config содержит конфигурацию сервера GophKeeper: значения по умолчанию,
+// чтение YAML файла, переопределение переменными окружения и проверку.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/blobstore"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+)
+
+// Config - конфигурация сервера.
+type Config struct {
+	Server      Server      `yaml:"server"`
+	Log         Log         `yaml:"log"`
+	Storage     Storage     `yaml:"storage"`
+	Blob        Blob        `yaml:"blob"`
+	Maintenance Maintenance `yaml:"maintenance"`
+}
+
+// Server - настройки grpc сервера, HTTP шлюза и метрик.
+type Server struct {
+	Address           string        `yaml:"address"`
+	TLSCert           string        `yaml:"tls_cert"`
+	TLSKey            string        `yaml:"tls_key"`
+	GatewayAddress    string        `yaml:"gateway_address"`
+	MetricsAddress    string        `yaml:"metrics_address"`
+	TraceExporter     string        `yaml:"trace_exporter"`
+	MaxRecvMsgSize    int           `yaml:"max_recv_msg_size"`
+	MaxSendMsgSize    int           `yaml:"max_send_msg_size"`
+	Keepalive         Keepalive     `yaml:"keepalive"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+}
+
+// Keepalive - настройки keepalive grpc сервера.
+type Keepalive struct {
+	// Time - через сколько без активности сервер пингует клиента.
+	Time time.Duration `yaml:"time"`
+	// Timeout - сколько ждать ответа на пинг, прежде чем закрыть соединение.
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxConnectionIdle - через сколько закрывать соединение без запросов, 0 - не закрывать.
+	MaxConnectionIdle time.Duration `yaml:"max_connection_idle"`
+	// MinTime - как часто клиенту разрешено пинговать сервер.
+	MinTime time.Duration `yaml:"min_time"`
+	// PermitWithoutStream - разрешить пинги клиента без активных запросов.
+	PermitWithoutStream bool `yaml:"permit_without_stream"`
+}
+
+// Log - настройки логирования.
+type Log struct {
+	// Level - уровень логирования: debug, info, warn, error.
+	Level string `yaml:"level"`
+}
+
+// Storage - настройки хранилища.
+type Storage struct {
+	DSN             string        `yaml:"dsn"`
+	QueryTimeout    time.Duration `yaml:"query_timeout"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	HistoryLimit    int           `yaml:"history_limit"`
+}
+
+// Blob - настройки выноса больших записей из базы в хранилище объектов.
+type Blob struct {
+	// Kind - хранилище объектов: fs - локальный каталог, s3 - S3-совместимое API,
+	// пусто - хранить все данные в базе.
+	Kind string `yaml:"kind"`
+	// Threshold - данные записей больше этого размера в байтах выносятся в хранилище объектов.
+	// Части файлов выносятся всегда.
+	Threshold int `yaml:"threshold"`
+	// GCGrace - объекты без ссылок удаляются, только если созданы раньше этого времени.
+	GCGrace time.Duration `yaml:"gc_grace"`
+	// Path - каталог для fs.
+	Path string `yaml:"path"`
+	S3   S3     `yaml:"s3"`
+}
+
+// S3 - настройки S3-совместимого хранилища объектов.
+type S3 struct {
+	Endpoint string `yaml:"endpoint"`
+	Bucket   string `yaml:"bucket"`
+	Prefix   string `yaml:"prefix"`
+	Region   string `yaml:"region"`
+	// AccessKey и SecretKey - ключи доступа, по умолчанию из AWS_ACCESS_KEY_ID и AWS_SECRET_ACCESS_KEY.
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	// Insecure - обращаться к API по HTTP без TLS.
+	Insecure bool `yaml:"insecure"`
+}
+
+// Maintenance - настройки фоновой очистки и режима обслуживания.
+// Сроки хранения и интервал очистки читаются при запуске и применяются только после перезапуска,
+// режим обслуживания применяется по SIGHUP.
+type Maintenance struct {
+	// TrashRetention - сколько удаленные записи хранятся в корзине.
+	TrashRetention time.Duration `yaml:"trash_retention"`
+	// UploadRetention - сколько хранятся незавершенные загрузки файлов.
+	UploadRetention time.Duration `yaml:"upload_retention"`
+	// CleanupInterval - как часто запускается очистка.
+	CleanupInterval time.Duration `yaml:"cleanup_interval"`
+	// IdempotencyRetention - сколько хранятся ключи идемпотентности создания записей.
+	IdempotencyRetention time.Duration `yaml:"idempotency_retention"`
+	// TombstoneRetention - сколько хранятся надгробия удаленных записей. Клиенты,
+	// не синхронизировавшиеся дольше, получают полную синхронизацию.
+	TombstoneRetention time.Duration `yaml:"tombstone_retention"`
+	// ReadOnly - режим обслуживания: чтение работает, изменения отклоняются. Применяется по SIGHUP.
+	ReadOnly bool `yaml:"read_only"`
+	// ReadOnlyRetryAfter - через сколько клиенту повторить изменение в режиме обслуживания.
+	ReadOnlyRetryAfter time.Duration `yaml:"read_only_retry_after"`
+}
+
+// Default - конфигурация по умолчанию.
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Address:        ":3200",
+			MaxRecvMsgSize: 4 << 20,
+			MaxSendMsgSize: 4 << 20,
+			Keepalive: Keepalive{
+				Time:    2 * time.Hour,
+				Timeout: 20 * time.Second,
+				MinTime: 5 * time.Minute,
+			},
+			ReadHeaderTimeout: 5 * time.Second,
+			ShutdownTimeout:   5 * time.Second,
+		},
+		Log: Log{
+			Level: "info",
+		},
+		Storage: Storage{
+			QueryTimeout: storage.DefaultQueryTimeout,
+			HistoryLimit: 10,
+		},
+		Blob: Blob{
+			Threshold: 64 << 10,
+			GCGrace:   time.Hour,
+		},
+		Maintenance: Maintenance{
+			TrashRetention:       30 * 24 * time.Hour,
+			UploadRetention:      24 * time.Hour,
+			CleanupInterval:      time.Hour,
+			IdempotencyRetention: 24 * time.Hour,
+			TombstoneRetention:   90 * 24 * time.Hour,
+			ReadOnlyRetryAfter:   time.Minute,
+		},
+	}
+}
+
+// Load - прочитать конфигурацию: значения по умолчанию, затем файл path (если задан),
+// затем переменные окружения. Проверка не выполняется, чтобы вызывающий мог
+// применить флаги командной строки, после них нужно вызвать Validate.
+func Load(path string) (*Config, error) {
+	c := Default()
+
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("config Load: open: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+
+		d := yaml.NewDecoder(f)
+		d.KnownFields(true)
+		err = d.Decode(c)
+		if err != nil {
+			return nil, fmt.Errorf("config Load: decode %s: %w", path, err)
+		}
+	}
+
+	err := c.applyEnv(os.LookupEnv)
+	if err != nil {
+		return nil, fmt.Errorf("config Load: %w", err)
+	}
+
+	return c, nil
+}
+
+// Validate - проверить конфигурацию и вернуть все найденные ошибки.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Address != "", "server.address is required")
+	check((c.Server.TLSCert == "") == (c.Server.TLSKey == ""), "server.tls_cert and server.tls_key must be set together")
+	check(c.Server.MaxRecvMsgSize > 0, "server.max_recv_msg_size must be positive")
+	check(c.Server.MaxSendMsgSize > 0, "server.max_send_msg_size must be positive")
+	check(c.Server.Keepalive.Time > 0, "server.keepalive.time must be positive")
+	check(c.Server.Keepalive.Timeout > 0, "server.keepalive.timeout must be positive")
+	check(c.Server.Keepalive.MaxConnectionIdle >= 0, "server.keepalive.max_connection_idle must not be negative")
+	check(c.Server.Keepalive.MinTime >= 0, "server.keepalive.min_time must not be negative")
+	check(c.Server.ReadHeaderTimeout > 0, "server.read_header_timeout must be positive")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+
+	_, err := zapcore.ParseLevel(c.Log.Level)
+	check(err == nil, "log.level: unknown level %q", c.Log.Level)
+
+	check(c.Storage.DSN != "", "storage.dsn is required")
+	check(c.Storage.QueryTimeout > 0, "storage.query_timeout must be positive")
+	check(c.Storage.MaxOpenConns >= 0, "storage.max_open_conns must not be negative")
+	check(c.Storage.MaxIdleConns >= 0, "storage.max_idle_conns must not be negative")
+	check(c.Storage.MaxOpenConns == 0 || c.Storage.MaxIdleConns <= c.Storage.MaxOpenConns,
+		"storage.max_idle_conns must not exceed storage.max_open_conns")
+	check(c.Storage.ConnMaxLifetime >= 0, "storage.conn_max_lifetime must not be negative")
+	check(c.Storage.ConnMaxIdleTime >= 0, "storage.conn_max_idle_time must not be negative")
+	check(c.Storage.HistoryLimit >= 0, "storage.history_limit must not be negative")
+
+	switch c.Blob.Kind {
+	case "":
+	case blobstore.KindFS:
+		check(c.Blob.Path != "", "blob.path is required for blob.kind fs")
+	case blobstore.KindS3:
+		check(c.Blob.S3.Endpoint != "", "blob.s3.endpoint is required for blob.kind s3")
+		check(c.Blob.S3.Bucket != "", "blob.s3.bucket is required for blob.kind s3")
+	default:
+		check(false, "blob.kind: unknown kind %q", c.Blob.Kind)
+	}
+	check(c.Blob.Threshold >= 0, "blob.threshold must not be negative")
+	check(c.Blob.GCGrace > c.Storage.QueryTimeout, "blob.gc_grace must exceed storage.query_timeout")
+
+	check(c.Maintenance.TrashRetention > 0, "maintenance.trash_retention must be positive")
+	check(c.Maintenance.UploadRetention > 0, "maintenance.upload_retention must be positive")
+	check(c.Maintenance.CleanupInterval > 0, "maintenance.cleanup_interval must be positive")
+	check(c.Maintenance.IdempotencyRetention > 0, "maintenance.idempotency_retention must be positive")
+	check(c.Maintenance.TombstoneRetention > 0, "maintenance.tombstone_retention must be positive")
+	check(c.Maintenance.ReadOnlyRetryAfter > 0, "maintenance.read_only_retry_after must be positive")
+
+	return errors.Join(errs...)
+}
+
+// LogLevel - уровень логирования. Конфигурация должна быть проверена Validate.
+func (c *Config) LogLevel() zapcore.Level {
+	level, err := zapcore.ParseLevel(c.Log.Level)
+	if err != nil {
+		return zapcore.InfoLevel
+	}
+
+	return level
+}
+
+// StorageConfig - настройки для storage.NewServerStorage.
+func (c *Config) StorageConfig() storage.Config {
+	return storage.Config{
+		DSN:             c.Storage.DSN,
+		HistoryLimit:    c.Storage.HistoryLimit,
+		QueryTimeout:    c.Storage.QueryTimeout,
+		MaxOpenConns:    c.Storage.MaxOpenConns,
+		MaxIdleConns:    c.Storage.MaxIdleConns,
+		ConnMaxLifetime: c.Storage.ConnMaxLifetime,
+		ConnMaxIdleTime: c.Storage.ConnMaxIdleTime,
+	}
+}
+
+// BlobStoreConfig - настройки для blobstore.Open. Хранилище объектов используется, если задан Blob.Kind.
+func (c *Config) BlobStoreConfig() blobstore.Config {
+	return blobstore.Config{
+		Kind: c.Blob.Kind,
+		Path: c.Blob.Path,
+		S3: blobstore.S3Config{
+			Endpoint:  c.Blob.S3.Endpoint,
+			Bucket:    c.Blob.S3.Bucket,
+			Prefix:    c.Blob.S3.Prefix,
+			Region:    c.Blob.S3.Region,
+			AccessKey: c.Blob.S3.AccessKey,
+			SecretKey: c.Blob.S3.SecretKey,
+			Insecure:  c.Blob.S3.Insecure,
+		},
+	}
+}
+
+// RestartRequired - разделы и параметры, изменение которых в next применится только после перезапуска.
+// Без перезапуска применяются уровень логирования, время выполнения запросов, настройки пула соединений
+// и режим обслуживания.
+func (c *Config) RestartRequired(next *Config) []string {
+	changed := make([]string, 0)
+
+	if !reflect.DeepEqual(c.Server, next.Server) {
+		changed = append(changed, "server")
+	}
+	if c.Storage.DSN != next.Storage.DSN {
+		changed = append(changed, "storage.dsn")
+	}
+	if c.Storage.HistoryLimit != next.Storage.HistoryLimit {
+		changed = append(changed, "storage.history_limit")
+	}
+	if !reflect.DeepEqual(c.Blob, next.Blob) {
+		changed = append(changed, "blob")
+	}
+	m, nextM := c.Maintenance, next.Maintenance
+	maintenance := []struct {
+		name          string
+		current, next time.Duration
+	}{
+		{"maintenance.trash_retention", m.TrashRetention, nextM.TrashRetention},
+		{"maintenance.upload_retention", m.UploadRetention, nextM.UploadRetention},
+		{"maintenance.cleanup_interval", m.CleanupInterval, nextM.CleanupInterval},
+		{"maintenance.idempotency_retention", m.IdempotencyRetention, nextM.IdempotencyRetention},
+		{"maintenance.tombstone_retention", m.TombstoneRetention, nextM.TombstoneRetention},
+	}
+	for _, d := range maintenance {
+		if d.current != d.next {
+			changed = append(changed, d.name)
+		}
+	}
+
+	return changed
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		c, err := Load("")
+		require.NoError(t, err)
+		assert.Equal(t, ":3200", c.Server.Address)
+		assert.Equal(t, 10*time.Second, c.Storage.QueryTimeout)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		err := os.WriteFile(path, []byte(`
+server:
+  address: ":4000"
+  keepalive:
+    time: 1m
+log:
+  level: debug
+storage:
+  dsn: postgres://localhost/keeper
+  query_timeout: 3s
+  max_open_conns: 20
+`), 0o600)
+		require.NoError(t, err)
+
+		c, err := Load(path)
+		require.NoError(t, err)
+		assert.Equal(t, ":4000", c.Server.Address)
+		assert.Equal(t, time.Minute, c.Server.Keepalive.Time)
+		assert.Equal(t, 20*time.Second, c.Server.Keepalive.Timeout)
+		assert.Equal(t, "debug", c.Log.Level)
+		assert.Equal(t, 3*time.Second, c.Storage.QueryTimeout)
+		assert.Equal(t, 20, c.Storage.MaxOpenConns)
+		assert.Equal(t, 10, c.Storage.HistoryLimit)
+		assert.NoError(t, c.Validate())
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("storage:\n  query_timeuot: 3s\n"), 0o600))
+
+		_, err := Load(path)
+		assert.Error(t, err)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+		assert.Error(t, err)
+	})
+}
+
+func TestConfig_applyEnv(t *testing.T) {
+	env := map[string]string{
+		"POSTGRES_DSN":                                      "postgres://legacy",
+		"GOPHKEEPER_STORAGE_DSN":                            "postgres://env",
+		"GOPHKEEPER_STORAGE_MAX_IDLE_CONNS":                 "5",
+		"GOPHKEEPER_SERVER_KEEPALIVE_MIN_TIME":              "30s",
+		"GOPHKEEPER_SERVER_KEEPALIVE_PERMIT_WITHOUT_STREAM": "true",
+		"GOPHKEEPER_LOG_LEVEL":                              "warn",
+		"GOPHKEEPER_BLOB_S3_INSECURE":                       "true",
+	}
+	lookup := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+
+	c := Default()
+	require.NoError(t, c.applyEnv(lookup))
+	assert.Equal(t, "postgres://env", c.Storage.DSN)
+	assert.Equal(t, 5, c.Storage.MaxIdleConns)
+	assert.Equal(t, 30*time.Second, c.Server.Keepalive.MinTime)
+	assert.True(t, c.Server.Keepalive.PermitWithoutStream)
+	assert.Equal(t, "warn", c.Log.Level)
+	assert.True(t, c.Blob.S3.Insecure)
+
+	env = map[string]string{"GOPHKEEPER_STORAGE_QUERY_TIMEOUT": "ten seconds"}
+	assert.Error(t, Default().applyEnv(lookup))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := func() *Config {
+		c := Default()
+		c.Storage.DSN = "postgres://localhost/keeper"
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string
+	}{
+		{
+			name:   "missing dsn",
+			modify: func(c *Config) { c.Storage.DSN = "" },
+			want:   "storage.dsn is required",
+		},
+		{
+			name:   "tls key without cert",
+			modify: func(c *Config) { c.Server.TLSKey = "key.pem" },
+			want:   "must be set together",
+		},
+		{
+			name:   "unknown log level",
+			modify: func(c *Config) { c.Log.Level = "verbose" },
+			want:   "log.level",
+		},
+		{
+			name: "idle exceeds open",
+			modify: func(c *Config) {
+				c.Storage.MaxOpenConns = 2
+				c.Storage.MaxIdleConns = 5
+			},
+			want: "storage.max_idle_conns",
+		},
+		{
+			name:   "zero query timeout",
+			modify: func(c *Config) { c.Storage.QueryTimeout = 0 },
+			want:   "storage.query_timeout",
+		},
+		{
+			name:   "fs blob store without path",
+			modify: func(c *Config) { c.Blob.Kind = "fs" },
+			want:   "blob.path",
+		},
+		{
+			name:   "unknown blob store",
+			modify: func(c *Config) { c.Blob.Kind = "ftp" },
+			want:   "blob.kind",
+		},
+		{
+			name:   "blob gc grace below query timeout",
+			modify: func(c *Config) { c.Blob.GCGrace = time.Second },
+			want:   "blob.gc_grace",
+		},
+		{
+			name:   "negative message size",
+			modify: func(c *Config) { c.Server.MaxRecvMsgSize = -1 },
+			want:   "server.max_recv_msg_size",
+		},
+		{
+			name:   "zero read-only retry after",
+			modify: func(c *Config) { c.Maintenance.ReadOnlyRetryAfter = 0 },
+			want:   "maintenance.read_only_retry_after",
+		},
+	}
+
+	require.NoError(t, valid().Validate())
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.modify(c)
+			err := c.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestConfig_RestartRequired(t *testing.T) {
+	current := Default()
+	next := Default()
+
+	next.Log.Level = "debug"
+	next.Storage.QueryTimeout = time.Second
+	next.Storage.MaxOpenConns = 50
+	next.Maintenance.ReadOnly = true
+	next.Maintenance.ReadOnlyRetryAfter = time.Hour
+	assert.Empty(t, current.RestartRequired(next))
+
+	next.Server.MaxRecvMsgSize = 1 << 10
+	next.Storage.HistoryLimit = 3
+	next.Blob.Threshold = 1 << 20
+	next.Maintenance.TrashRetention = time.Hour
+	next.Maintenance.CleanupInterval = time.Minute
+	assert.Equal(t, []string{
+		"server", "storage.history_limit", "blob", "maintenance.trash_retention", "maintenance.cleanup_interval",
+	}, current.RestartRequired(next))
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// envPrefix - префикс переменных окружения, переопределяющих параметры файла конфигурации.
+const envPrefix = "GOPHKEEPER_"
+
+// envVar - переменная окружения и параметр конфигурации, который она переопределяет.
+type envVar struct {
+	name  string
+	value any
+}
+
+// envVars - переменные окружения для всех параметров: GOPHKEEPER_ и путь параметра
+// в верхнем регистре, например GOPHKEEPER_STORAGE_QUERY_TIMEOUT для storage.query_timeout.
+func (c *Config) envVars() []envVar {
+	return []envVar{
+		{"SERVER_ADDRESS", &c.Server.Address},
+		{"SERVER_TLS_CERT", &c.Server.TLSCert},
+		{"SERVER_TLS_KEY", &c.Server.TLSKey},
+		{"SERVER_GATEWAY_ADDRESS", &c.Server.GatewayAddress},
+		{"SERVER_METRICS_ADDRESS", &c.Server.MetricsAddress},
+		{"SERVER_TRACE_EXPORTER", &c.Server.TraceExporter},
+		{"SERVER_MAX_RECV_MSG_SIZE", &c.Server.MaxRecvMsgSize},
+		{"SERVER_MAX_SEND_MSG_SIZE", &c.Server.MaxSendMsgSize},
+		{"SERVER_KEEPALIVE_TIME", &c.Server.Keepalive.Time},
+		{"SERVER_KEEPALIVE_TIMEOUT", &c.Server.Keepalive.Timeout},
+		{"SERVER_KEEPALIVE_MAX_CONNECTION_IDLE", &c.Server.Keepalive.MaxConnectionIdle},
+		{"SERVER_KEEPALIVE_MIN_TIME", &c.Server.Keepalive.MinTime},
+		{"SERVER_KEEPALIVE_PERMIT_WITHOUT_STREAM", &c.Server.Keepalive.PermitWithoutStream},
+		{"SERVER_READ_HEADER_TIMEOUT", &c.Server.ReadHeaderTimeout},
+		{"SERVER_SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout},
+		{"LOG_LEVEL", &c.Log.Level},
+		{"STORAGE_DSN", &c.Storage.DSN},
+		{"STORAGE_QUERY_TIMEOUT", &c.Storage.QueryTimeout},
+		{"STORAGE_MAX_OPEN_CONNS", &c.Storage.MaxOpenConns},
+		{"STORAGE_MAX_IDLE_CONNS", &c.Storage.MaxIdleConns},
+		{"STORAGE_CONN_MAX_LIFETIME", &c.Storage.ConnMaxLifetime},
+		{"STORAGE_CONN_MAX_IDLE_TIME", &c.Storage.ConnMaxIdleTime},
+		{"STORAGE_HISTORY_LIMIT", &c.Storage.HistoryLimit},
+		{"BLOB_KIND", &c.Blob.Kind},
+		{"BLOB_THRESHOLD", &c.Blob.Threshold},
+		{"BLOB_GC_GRACE", &c.Blob.GCGrace},
+		{"BLOB_PATH", &c.Blob.Path},
+		{"BLOB_S3_ENDPOINT", &c.Blob.S3.Endpoint},
+		{"BLOB_S3_BUCKET", &c.Blob.S3.Bucket},
+		{"BLOB_S3_PREFIX", &c.Blob.S3.Prefix},
+		{"BLOB_S3_REGION", &c.Blob.S3.Region},
+		{"BLOB_S3_ACCESS_KEY", &c.Blob.S3.AccessKey},
+		{"BLOB_S3_SECRET_KEY", &c.Blob.S3.SecretKey},
+		{"BLOB_S3_INSECURE", &c.Blob.S3.Insecure},
+		{"MAINTENANCE_TRASH_RETENTION", &c.Maintenance.TrashRetention},
+		{"MAINTENANCE_UPLOAD_RETENTION", &c.Maintenance.UploadRetention},
+		{"MAINTENANCE_CLEANUP_INTERVAL", &c.Maintenance.CleanupInterval},
+		{"MAINTENANCE_IDEMPOTENCY_RETENTION", &c.Maintenance.IdempotencyRetention},
+		{"MAINTENANCE_TOMBSTONE_RETENTION", &c.Maintenance.TombstoneRetention},
+		{"MAINTENANCE_READ_ONLY", &c.Maintenance.ReadOnly},
+		{"MAINTENANCE_READ_ONLY_RETRY_AFTER", &c.Maintenance.ReadOnlyRetryAfter},
+	}
+}
+
+// applyEnv - переопределить параметры заданными переменными окружения.
+// POSTGRES_DSN поддерживается для совместимости и уступает GOPHKEEPER_STORAGE_DSN.
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	if dsn, ok := lookup("POSTGRES_DSN"); ok && dsn != "" {
+		c.Storage.DSN = dsn
+	}
+
+	for _, v := range c.envVars() {
+		name := envPrefix + v.name
+		raw, ok := lookup(name)
+		if !ok {
+			continue
+		}
+
+		err := setValue(v.value, raw)
+		if err != nil {
+			return fmt.Errorf("env %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func setValue(dst any, raw string) error {
+	switch p := dst.(type) {
+	case *string:
+		*p = raw
+	case *int:
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("parse int: %w", err)
+		}
+		*p = v
+	case *bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("parse bool: %w", err)
+		}
+		*p = v
+	case *time.Duration:
+		v, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("parse duration: %w", err)
+		}
+		*p = v
+	default:
+		return fmt.Errorf("unsupported type %T", dst)
+	}
+
+	return nil
+}
@@ -18,6 +18,7 @@ const (
 	authEntry   entryType = "auth"
 	cardEntry   entryType = "card"
 	binaryEntry entryType = "binary"
+	fileEntry   entryType = "file"
 )
 
 // Description - описание с перечислением всех доступных типов.
@@ -25,7 +26,8 @@ const Description = `Available entry types:
 - text: simple text data
 - auth: login/password for website or service
 - card: credit card data
-- binary: small binary file`
+- binary: small binary file
+- file: large file, use commands upload and download {id}`
 
 // Entry - интерфейс типов данных, которые поддерживает GophKeeper.
 type Entry interface {
@@ -59,6 +61,8 @@ func ParseEntry(data []byte) (Entry, error) {
 		return newCard(e.Data)
 	case binaryEntry:
 		return newBinary(e.Data)
+	case fileEntry:
+		return newFile(e.Data)
 	}
 
 	return nil, errors.New("dataverse ParseEntry: unknown entry type")
@@ -332,3 +336,80 @@ func (d binaryData) Marshal() ([]byte, error) {
 }
 
 func (d binaryData) isDataverseEntry() {}
+
+// FileData - метаданные большого файла. Содержимое файла хранится на сервере отдельно
+// по частям, зашифрованным симметричным ключом Key.
+type FileData struct {
+	Name      string `json:"name"`
+	Filename  string `json:"filename"`
+	Size      int64  `json:"size"`
+	ChunkSize int    `json:"chunk_size"`
+	Key       []byte `json:"key"`
+}
+
+func newFile(data []byte) (d FileData, err error) {
+	return d, json.Unmarshal(data, &d)
+}
+
+// GenFile - запрашивает у пользователя название и путь к файлу.
+func GenFile(l *readline.Instance) (d FileData, err error) {
+	l.SetPrompt("Name: ")
+	d.Name, err = l.Readline()
+	if err != nil {
+		return FileData{}, fmt.Errorf("dataverse GenFile: readline: %w", err)
+	}
+	d.Name = strings.TrimSpace(d.Name)
+
+	l.SetPrompt("Filename: ")
+	d.Filename, err = l.Readline()
+	if err != nil {
+		return FileData{}, fmt.Errorf("dataverse GenFile: readline: %w", err)
+	}
+	d.Filename = strings.TrimSpace(d.Filename)
+
+	info, err := os.Stat(d.Filename)
+	if err != nil {
+		return FileData{}, fmt.Errorf("dataverse GenFile: stat file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return FileData{}, errors.New("dataverse GenFile: not a regular file")
+	}
+	d.Size = info.Size()
+
+	return d, nil
+}
+
+// DatabaseEntry - упаковать метаданные файла в DatabaseEntry.
+func (d FileData) DatabaseEntry() (DatabaseEntry, error) {
+	data, err := d.Marshal()
+	if err != nil {
+		return DatabaseEntry{}, fmt.Errorf("dataverse FileData DatabaseEntry: marshal: %w", err)
+	}
+
+	return DatabaseEntry{
+		Type: string(fileEntry),
+		Data: data,
+	}, nil
+}
+
+// GetType - получить текстовое описание типа данных.
+func (d FileData) GetType() string {
+	return "File"
+}
+
+// GetName - получить заголовок записи.
+func (d FileData) GetName() string {
+	return fmt.Sprintf("%s (%s, %d bytes)", d.Name, d.Filename, d.Size)
+}
+
+// GetContent - получить подсказку, как скачать файл.
+func (d FileData) GetContent() string {
+	return fmt.Sprintf("Use `download {id}` to save file `%s`", d.Filename)
+}
+
+// Marshal - запаковать метаданные файла в JSON.
+func (d FileData) Marshal() ([]byte, error) {
+	return json.Marshal(d)
+}
+
+func (d FileData) isDataverseEntry() {}
@@ -6,11 +6,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/chzyer/readline"
-	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -82,6 +82,20 @@ func TestParseEntry(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "file entry",
+			dbEntry: &DatabaseEntry{
+				Type: string(fileEntry),
+			},
+			want: FileData{
+				Name:      "backup",
+				Filename:  "backup.tar",
+				Size:      3 << 20,
+				ChunkSize: 1 << 20,
+				Key:       []byte{1, 2, 3, 4},
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -111,7 +125,7 @@ func TestGenDatabaseEntry(t *testing.T) {
 	})
 
 	t.Run("test all formats", func(t *testing.T) {
-		binaryFileName := fmt.Sprintf("%s.bin", uuid.New().String())
+		binaryFileName := filepath.Join(t.TempDir(), "secret.bin")
 		binaryContent := []byte{1, 2, 3, 4, 5, 100, 101, 102, 103, 104}
 		err := os.WriteFile(binaryFileName, binaryContent, 0o600)
 		assert.NoError(t, err)
@@ -314,8 +328,48 @@ func TestGenDatabaseEntry(t *testing.T) {
 	})
 }
 
+func TestGenFile(t *testing.T) {
+	newReadline := func(t *testing.T, input ...string) *readline.Instance {
+		b := &bytes.Buffer{}
+		for _, line := range input {
+			_, _ = fmt.Fprintf(b, "%s\n", line)
+		}
+
+		l, err := readline.NewEx(&readline.Config{
+			Stdin: io.NopCloser(b),
+		})
+		require.NoError(t, err)
+
+		return l
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		fileName := filepath.Join(t.TempDir(), "large.bin")
+		err := os.WriteFile(fileName, make([]byte, 4096), 0o600)
+		require.NoError(t, err)
+
+		d, err := GenFile(newReadline(t, "large", fileName))
+		require.NoError(t, err)
+		assert.Equal(t, FileData{
+			Name:     "large",
+			Filename: fileName,
+			Size:     4096,
+		}, d)
+	})
+
+	t.Run("file not found", func(t *testing.T) {
+		_, err := GenFile(newReadline(t, "large", "filenotexists"))
+		assert.Error(t, err)
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		_, err := GenFile(newReadline(t, "large", t.TempDir()))
+		assert.Error(t, err)
+	})
+}
+
 func TestEntry(t *testing.T) {
-	binaryFileName := fmt.Sprintf("%s.bin", uuid.New().String())
+	binaryFileName := filepath.Join(t.TempDir(), "security.key")
 
 	//nolint: lll
 	tests := []struct {
@@ -379,6 +433,20 @@ func TestEntry(t *testing.T) {
 				),
 			),
 		},
+		{
+			name: "file entry",
+			entry: FileData{
+				Name:      "backup",
+				Filename:  "backup.tar",
+				Size:      2048,
+				ChunkSize: 1024,
+				Key:       []byte{1, 2, 3},
+			},
+			typeResult:    "File",
+			nameResult:    "backup (backup.tar, 2048 bytes)",
+			contentResult: "Use `download {id}` to save file `backup.tar`",
+			marshalResult: []byte(`{"name":"backup","filename":"backup.tar","size":2048,"chunk_size":1024,"key":"AQID"}`),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -396,11 +464,9 @@ func TestEntry(t *testing.T) {
 }
 
 func TestBinaryGetContent(t *testing.T) {
-	id := uuid.New()
-
 	entry := binaryData{
 		Name:     "security key",
-		Filename: fmt.Sprintf("%s.bin", id),
+		Filename: filepath.Join(t.TempDir(), "security.key"),
 		Content:  []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
 	}
 
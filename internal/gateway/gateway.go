@@ -0,0 +1,59 @@
+// Package gateway содержит HTTP/JSON шлюз к grpc серверу GophKeeper.
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+// OpenAPIPath - путь, по которому шлюз отдает OpenAPI описание Keeper API.
+const OpenAPIPath = "/openapi.json"
+
+// HealthPath - путь, по которому шлюз отдает проверку состояния сервера.
+const HealthPath = "/healthz"
+
+// New - создаем http обработчик, который проксирует REST/JSON запросы в grpc сервер по адресу grpcAddress.
+// Поля типа bytes в запросах и ответах передаются в base64. health отвечает на HealthPath, если задан.
+func New(ctx context.Context, grpcAddress string, health http.Handler, opts ...grpc.DialOption) (http.Handler, error) {
+	gw := runtime.NewServeMux(runtime.WithOutgoingHeaderMatcher(outgoingHeader))
+
+	err := pb.RegisterKeeperHandlerFromEndpoint(ctx, gw, grpcAddress, opts)
+	if err != nil {
+		return nil, fmt.Errorf("gateway New: register handler: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/v1/", gw)
+	mux.HandleFunc(OpenAPIPath, openAPI)
+	if health != nil {
+		mux.Handle(HealthPath, health)
+	}
+
+	return mux, nil
+}
+
+// outgoingHeader - заголовок retry-after из ответа grpc сервера передается как стандартный Retry-After,
+// остальные заголовки - с префиксом Grpc-Metadata-, как по умолчанию.
+func outgoingHeader(key string) (string, bool) {
+	if key == "retry-after" {
+		return "Retry-After", true
+	}
+
+	return runtime.MetadataHeaderPrefix + key, true
+}
+
+func openAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(pb.OpenAPI)
+}
@@ -0,0 +1,165 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+type keeperServer struct {
+	pb.UnimplementedKeeperServer
+}
+
+func (keeperServer) Get(_ context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
+	if req.Id != "known" {
+		return nil, status.Error(codes.NotFound, "entry not found")
+	}
+	return &pb.GetResponse{
+		Data:      []byte{1, 2, 3},
+		Version:   2,
+		CreatedAt: timestamppb.New(time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)),
+		UpdatedAt: timestamppb.New(time.Date(2023, 5, 2, 10, 0, 0, 0, time.UTC)),
+	}, nil
+}
+
+func (keeperServer) Create(ctx context.Context, req *pb.CreateRequest) (*pb.CreateResponse, error) {
+	if string(req.Data) == "busy" {
+		_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", "60"))
+		return nil, status.Error(codes.Unavailable, "server is in read-only maintenance mode")
+	}
+	return &pb.CreateResponse{Id: string(req.Data)}, nil
+}
+
+// GetAll - возвращает в токене страницы адрес клиента, который передал шлюз.
+func (keeperServer) GetAll(ctx context.Context, _ *pb.GetAllRequest) (*pb.GetAllResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return &pb.GetAllResponse{NextPageToken: strings.Join(md.Get("x-forwarded-for"), ";")}, nil
+}
+
+func newGateway(t *testing.T) http.Handler {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	g := grpc.NewServer()
+	pb.RegisterKeeperServer(g, keeperServer{})
+	go func() { _ = g.Serve(ln) }()
+	t.Cleanup(g.Stop)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	health := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	})
+	h, err := New(ctx, ln.Addr().String(), health, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	return h
+}
+
+func TestGateway(t *testing.T) {
+	h := newGateway(t)
+
+	t.Run("get", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/entries/known", nil))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t,
+			`{"data":"AQID","version":"2","createdAt":"2023-05-01T10:00:00Z","updatedAt":"2023-05-02T10:00:00Z",
+			"expiresAt":null}`,
+			w.Body.String(),
+		)
+	})
+
+	t.Run("get not found", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/entries/unknown", nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("create", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		body := strings.NewReader(`{"public_key":"AQID","data":"aWQ=","sign":"AQID"}`)
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/entries", body))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"id":"id"}`, w.Body.String())
+	})
+
+	t.Run("read-only", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		body := strings.NewReader(`{"public_key":"AQID","data":"YnVzeQ==","sign":"AQID"}`)
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/entries", body))
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	})
+
+	t.Run("health", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, HealthPath, nil))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+	})
+
+	t.Run("forwarded for", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/v1/entries:list", strings.NewReader(`{}`))
+		r.RemoteAddr = "192.0.2.7:4321"
+		h.ServeHTTP(w, r)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"entries":[],"nextPageToken":"192.0.2.7"}`, w.Body.String())
+	})
+
+	t.Run("unimplemented", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/entries:changes", strings.NewReader(`{}`)))
+
+		assert.Equal(t, http.StatusNotImplemented, w.Code)
+	})
+
+	t.Run("openapi", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, OpenAPIPath, nil))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var doc struct {
+			Swagger string         `json:"swagger"`
+			Paths   map[string]any `json:"paths"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+		assert.Equal(t, "2.0", doc.Swagger)
+		assert.Contains(t, doc.Paths, "/v1/entries/{id}")
+	})
+
+	t.Run("openapi wrong method", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, OpenAPIPath, nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+}
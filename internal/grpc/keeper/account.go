@@ -0,0 +1,100 @@
+package keeper
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+const (
+	challengeSize = 32
+	challengeTTL  = 5 * time.Minute
+)
+
+// DeleteAccountHash - хеш запроса удаления аккаунта, который подписывает клиент:
+// SHA256("delete-account" || publicKey || challenge).
+func DeleteAccountHash(publicKey, challenge []byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write([]byte("delete-account"))
+	h.Write(publicKey)
+	h.Write(challenge)
+
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// AccountChallenge - обработчик для получения вызова на удаление аккаунта.
+func (s server) AccountChallenge(ctx context.Context, req *pb.AccountChallengeRequest) (*pb.AccountChallengeResponse, error) {
+	if len(req.PublicKey) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "public key is empty")
+	}
+
+	challenge := make([]byte, challengeSize)
+	_, err := rand.Read(challenge)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "generate challenge: %s", err)
+	}
+
+	expiresAt := time.Now().Add(challengeTTL)
+
+	err = s.s.SaveChallenge(ctx, req.PublicKey, challenge, expiresAt)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "storage error: %s", err)
+	}
+
+	return &pb.AccountChallengeResponse{
+		Challenge: challenge,
+		ExpiresAt: timestamppb.New(expiresAt),
+	}, nil
+}
+
+// DeleteAccount - обработчик для удаления всех данных владельца публичного ключа.
+func (s server) DeleteAccount(ctx context.Context, req *pb.DeleteAccountRequest) (*pb.DeleteAccountResponse, error) {
+	publicN := big.Int{}
+	publicN.SetBytes(req.PublicKey)
+	public := rsa.PublicKey{
+		N: &publicN,
+		E: publicE,
+	}
+
+	hash := DeleteAccountHash(req.PublicKey, req.Challenge)
+
+	_, span := tracing.Start(ctx, "verify signature")
+	err := rsa.VerifyPKCS1v15(&public, crypto.SHA256, hash[:], req.Sign)
+	tracing.End(span, err)
+	if err != nil {
+		metrics.SignVerifyFailures.WithLabelValues("delete_account").Inc()
+		return nil, status.Errorf(codes.InvalidArgument, "sign verify failed: %s", err)
+	}
+
+	ids, err := s.s.DeleteAccount(ctx, req.PublicKey, req.Challenge)
+	if errors.Is(err, storage.ErrChallengeNotFound) {
+		return nil, status.Error(codes.FailedPrecondition, "challenge not found or expired, request a new one")
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "storage error: %s", err)
+	}
+
+	for _, id := range ids {
+		s.notify(ctx, storage.ChangeDeleted, id, req.PublicKey)
+	}
+
+	return &pb.DeleteAccountResponse{
+		DeletedEntries: int64(len(ids)),
+	}, nil
+}
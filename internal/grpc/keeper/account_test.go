@@ -0,0 +1,49 @@
+package keeper
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+func TestDeleteAccountHash(t *testing.T) {
+	a := DeleteAccountHash([]byte{1}, []byte{2})
+	assert.Equal(t, a, DeleteAccountHash([]byte{1}, []byte{2}))
+	assert.NotEqual(t, a, DeleteAccountHash([]byte{1}, []byte{3}))
+	assert.NotEqual(t, a, DeleteAccountHash([]byte{2}, []byte{2}))
+}
+
+func TestServer_DeleteAccount(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicKey := key.PublicKey.N.Bytes()
+
+	s := NewServer(nil, 0, 0)
+
+	t.Run("empty public key", func(t *testing.T) {
+		_, err = s.AccountChallenge(context.Background(), &pb.AccountChallengeRequest{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("sign over other challenge", func(t *testing.T) {
+		hash := DeleteAccountHash(publicKey, []byte{1, 2, 3})
+		sign, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+
+		_, err = s.DeleteAccount(context.Background(), &pb.DeleteAccountRequest{
+			PublicKey: publicKey,
+			Challenge: []byte{4, 5, 6},
+			Sign:      sign,
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
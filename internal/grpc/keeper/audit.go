@@ -0,0 +1,173 @@
+package keeper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+// ClientVersionKey - ключ метаданных запроса, в котором клиент передает свою версию.
+const ClientVersionKey = "x-client-version"
+
+// ForwardedForKey - ключ метаданных, в котором HTTP шлюз передает адрес своего клиента (X-Forwarded-For).
+const ForwardedForKey = "x-forwarded-for"
+
+// AuditHash - хеш запроса журнала аудита, который подписывает клиент: SHA256("audit" || publicKey || timestamp).
+func AuditHash(publicKey []byte, timestamp int64) [sha256.Size]byte {
+	return ownerRequestHash("audit", publicKey, timestamp)
+}
+
+// ListAudit - обработчик для получения журнала аудита владельца публичного ключа постранично.
+func (s server) ListAudit(ctx context.Context, req *pb.ListAuditRequest) (*pb.ListAuditResponse, error) {
+	pageSize := int(req.PageSize)
+	switch {
+	case pageSize < 0:
+		return nil, status.Error(codes.InvalidArgument, "page size must not be negative")
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+
+	before, err := parseAuditPageToken(req.PageToken)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "unable to parse page token: %s", err)
+	}
+
+	hash := AuditHash(req.PublicKey, req.Timestamp)
+	err = verifyOwnerRequest(ctx, "audit", req.PublicKey, req.Timestamp, hash, req.Sign)
+	if err != nil {
+		return nil, err
+	}
+
+	// запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+	records, err := s.s.ListAudit(ctx, req.PublicKey, before, pageSize+1)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "storage error: %s", err)
+	}
+
+	var nextPageToken string
+	if len(records) > pageSize {
+		records = records[:pageSize]
+		nextPageToken = auditPageToken(records[pageSize-1].ID)
+	}
+
+	r := make([]*pb.ListAuditResponse_Record, 0, len(records))
+	for _, record := range records {
+		var entryID string
+		if record.EntryID != uuid.Nil {
+			entryID = record.EntryID.String()
+		}
+		r = append(r, &pb.ListAuditResponse_Record{
+			Op:            string(record.Op),
+			EntryId:       entryID,
+			Time:          timestamppb.New(record.CreatedAt),
+			Peer:          record.Peer,
+			ClientVersion: record.ClientVersion,
+		})
+	}
+
+	return &pb.ListAuditResponse{
+		Records:       r,
+		NextPageToken: nextPageToken,
+	}, nil
+}
+
+// auditPageToken - непрозрачный токен страницы журнала: ID последней записи предыдущей страницы.
+func auditPageToken(last int64) string {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(last))
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func parseAuditPageToken(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, fmt.Errorf("decode: %w", err)
+	}
+	if len(b) != 8 {
+		return 0, errors.New("wrong length")
+	}
+
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
+
+// audit - записать операцию в журнал аудита. Операция уже выполнена,
+// поэтому ошибка записи не возвращается клиенту, а только попадает в трассировку.
+func (s server) audit(ctx context.Context, op storage.AuditOp, id uuid.UUID, publicKey []byte) {
+	// в режиме обслуживания изменения отклоняются, а чтение не пишет в журнал
+	if s.ReadOnly().Enabled {
+		return
+	}
+
+	ctx, span := tracing.Start(ctx, "audit")
+	err := s.s.AddAudit(ctx, storage.AuditRecord{
+		PublicKey:     publicKey,
+		Op:            op,
+		EntryID:       id,
+		Peer:          peerAddress(ctx),
+		ClientVersion: clientVersion(ctx),
+	})
+	tracing.End(span, err)
+}
+
+// peerAddress - адрес клиента. HTTP шлюз подключается к серверу с loopback адреса и передает адрес
+// своего клиента в x-forwarded-for: берется последний адрес, его добавил сам шлюз.
+// Удаленный клиент может подделать x-forwarded-for, поэтому для других подключений он не читается.
+func peerAddress(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	addr := p.Addr.String()
+	if tcp, isTCP := p.Addr.(*net.TCPAddr); !isTCP || !tcp.IP.IsLoopback() {
+		return addr
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	forwarded := md.Get(ForwardedForKey)
+	if len(forwarded) == 0 {
+		return addr
+	}
+	hops := strings.Split(forwarded[len(forwarded)-1], ",")
+	if last := strings.TrimSpace(hops[len(hops)-1]); last != "" {
+		return last
+	}
+
+	return addr
+}
+
+func clientVersion(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	v := md.Get(ClientVersionKey)
+	if len(v) == 0 {
+		return ""
+	}
+
+	return v[0]
+}
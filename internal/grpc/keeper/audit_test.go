@@ -0,0 +1,153 @@
+package keeper
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+func TestAuditPageToken(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		got, err := parseAuditPageToken(auditPageToken(12345))
+		require.NoError(t, err)
+		assert.Equal(t, int64(12345), got)
+	})
+
+	t.Run("empty token is first page", func(t *testing.T) {
+		got, err := parseAuditPageToken("")
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), got)
+	})
+
+	t.Run("wrong token", func(t *testing.T) {
+		_, err := parseAuditPageToken("not a token")
+		assert.Error(t, err)
+
+		_, err = parseAuditPageToken("AQID")
+		assert.Error(t, err)
+	})
+}
+
+func TestServer_ListAudit(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicKey := key.PublicKey.N.Bytes()
+
+	s := NewServer(nil, 0, 0)
+
+	t.Run("negative page size", func(t *testing.T) {
+		_, err = s.ListAudit(context.Background(), &pb.ListAuditRequest{PageSize: -1})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("stale timestamp", func(t *testing.T) {
+		ts := time.Now().Add(-time.Hour).Unix()
+		hash := AuditHash(publicKey, ts)
+		sign, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+
+		_, err = s.ListAudit(context.Background(), &pb.ListAuditRequest{
+			PublicKey: publicKey,
+			Timestamp: ts,
+			Sign:      sign,
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("watch sign is not accepted", func(t *testing.T) {
+		ts := time.Now().Unix()
+		hash := WatchHash(publicKey, ts)
+		sign, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+
+		_, err = s.ListAudit(context.Background(), &pb.ListAuditRequest{
+			PublicKey: publicKey,
+			Timestamp: ts,
+			Sign:      sign,
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestAuditContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Empty(t, peerAddress(ctx))
+	assert.Empty(t, clientVersion(ctx))
+
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234}})
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(ClientVersionKey, "1.2.3"))
+	assert.Equal(t, "127.0.0.1:1234", peerAddress(ctx))
+	assert.Equal(t, "1.2.3", clientVersion(ctx))
+
+	// за HTTP шлюзом адрес клиента приходит в x-forwarded-for, последний адрес добавил шлюз
+	gateway := peer.NewContext(context.Background(),
+		&peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234}})
+	forwarded := metadata.NewIncomingContext(gateway, metadata.Pairs(ForwardedForKey, "10.0.0.1, 192.0.2.7"))
+	assert.Equal(t, "192.0.2.7", peerAddress(forwarded))
+	assert.Equal(t, "192.0.2.7", peerIP(forwarded))
+
+	// удаленный клиент не может подменить свой адрес
+	remote := peer.NewContext(context.Background(),
+		&peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(198, 51, 100, 1), Port: 1234}})
+	spoofed := metadata.NewIncomingContext(remote, metadata.Pairs(ForwardedForKey, "192.0.2.7"))
+	assert.Equal(t, "198.51.100.1:1234", peerAddress(spoofed))
+}
+
+func TestServer_ReadAudit(t *testing.T) {
+	ctx := context.Background()
+	ms := storage.NewMemoryStorage(10)
+	s := NewServer(ms, 0, 0)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicKey := key.PublicKey.N.Bytes()
+
+	ops := func() []storage.AuditOp {
+		records, listErr := ms.ListAudit(ctx, publicKey, 0, 10)
+		require.NoError(t, listErr)
+		res := make([]storage.AuditOp, 0, len(records))
+		for _, r := range records {
+			res = append(res, r.Op)
+		}
+		return res
+	}
+
+	// пустые ответы не попадают в журнал
+	_, err = s.GetAll(ctx, &pb.GetAllRequest{PublicKey: publicKey})
+	require.NoError(t, err)
+	_, err = s.GetChanges(ctx, &pb.GetChangesRequest{PublicKey: publicKey})
+	require.NoError(t, err)
+	assert.Empty(t, ops())
+
+	id, err := ms.Create(ctx, publicKey, []byte("data"), time.Time{})
+	require.NoError(t, err)
+
+	_, err = s.GetAll(ctx, &pb.GetAllRequest{PublicKey: publicKey})
+	require.NoError(t, err)
+	_, err = s.GetChanges(ctx, &pb.GetChangesRequest{PublicKey: publicKey})
+	require.NoError(t, err)
+
+	ts := time.Now().Unix()
+	hash := HistoryHash(publicKey, ts, id.String())
+	sign, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	require.NoError(t, err)
+	_, err = s.History(ctx, &pb.HistoryRequest{Id: id.String(), Timestamp: ts, Sign: sign})
+	require.NoError(t, err)
+
+	// новые записи первыми
+	assert.Equal(t, []storage.AuditOp{storage.AuditHistory, storage.AuditSync, storage.AuditList}, ops())
+}
@@ -0,0 +1,66 @@
+package keeper
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
+)
+
+// ownerRequestMaxSkew - допустимое расхождение времени клиента и сервера в подписанных запросах владельца.
+const ownerRequestMaxSkew = 5 * time.Minute
+
+// ownerRequestHash - хеш запроса владельца, не связанного с конкретной записью:
+// SHA256(action || publicKey || timestamp || params...), timestamp - секунды Unix (big-endian, 8 байт).
+func ownerRequestHash(action string, publicKey []byte, timestamp int64, params ...[]byte) [sha256.Size]byte {
+	ts := make([]byte, 8)
+	binary.BigEndian.PutUint64(ts, uint64(timestamp))
+
+	h := sha256.New()
+	h.Write([]byte(action))
+	h.Write(publicKey)
+	h.Write(ts)
+	for _, p := range params {
+		h.Write(p)
+	}
+
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// verifyOwnerRequest - проверить время и подпись запроса владельца публичного ключа.
+func verifyOwnerRequest(ctx context.Context, op string, publicKey []byte, timestamp int64,
+	hash [sha256.Size]byte, sign []byte,
+) error {
+	skew := time.Since(time.Unix(timestamp, 0))
+	if skew > ownerRequestMaxSkew || skew < -ownerRequestMaxSkew {
+		return status.Error(codes.InvalidArgument, "timestamp is too far from server time")
+	}
+
+	publicN := big.Int{}
+	publicN.SetBytes(publicKey)
+	public := rsa.PublicKey{
+		N: &publicN,
+		E: publicE,
+	}
+
+	_, span := tracing.Start(ctx, "verify signature")
+	err := rsa.VerifyPKCS1v15(&public, crypto.SHA256, hash[:], sign)
+	tracing.End(span, err)
+	if err != nil {
+		metrics.SignVerifyFailures.WithLabelValues(op).Inc()
+		return status.Errorf(codes.InvalidArgument, "sign verify failed: %s", err)
+	}
+
+	return nil
+}
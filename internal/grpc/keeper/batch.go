@@ -0,0 +1,296 @@
+package keeper
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+// maxBatchOps - максимальное количество операций в пакете.
+const maxBatchOps = 1000
+
+// batchOp - проверенная операция пакета и владелец записи для уведомления и аудита.
+type batchOp struct {
+	storage.BatchOp
+	owner []byte
+}
+
+// Batch - обработчик для атомарного выполнения операций с записями.
+// Операции проверяются так же, как в Create, Update и Delete, и применяются
+// в одной транзакции хранилища. Если хотя бы одна операция не прошла проверку
+// или не выполнилась, пакет не применяется, а причина возвращается в ее результате.
+func (s server) Batch(ctx context.Context, req *pb.BatchRequest) (*pb.BatchResponse, error) {
+	if len(req.Operations) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "batch is empty")
+	}
+	if len(req.Operations) > maxBatchOps {
+		return nil, status.Errorf(codes.InvalidArgument, "batch has more than %d operations", maxBatchOps)
+	}
+
+	ops := make([]batchOp, len(req.Operations))
+	errs := make([]error, len(req.Operations))
+	seen := make(map[uuid.UUID]bool, len(req.Operations))
+	failed := false
+	for i, o := range req.Operations {
+		ops[i], errs[i] = s.batchOp(ctx, o, seen)
+		if status.Code(errs[i]) == codes.Internal {
+			return nil, errs[i]
+		}
+		failed = failed || errs[i] != nil
+	}
+	if failed {
+		return batchFailed(ops, errs), nil
+	}
+
+	storageOps := make([]storage.BatchOp, 0, len(ops))
+	for _, op := range ops {
+		storageOps = append(storageOps, op.BatchOp)
+	}
+
+	results, err := s.s.Batch(ctx, storageOps)
+	var batchErr *storage.BatchError
+	if errors.As(err, &batchErr) {
+		opErr := storageWriteError("batch", batchErr.Err)
+		if status.Code(opErr) != codes.Internal {
+			errs[batchErr.Index] = opErr
+			return batchFailed(ops, errs), nil
+		}
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "storage error: %s", err)
+	}
+
+	resp := &pb.BatchResponse{
+		Applied: true,
+		Results: make([]*pb.BatchResponse_Result, 0, len(results)),
+	}
+	for i, r := range results {
+		change, audit := storage.ChangeCreated, storage.AuditCreate
+		switch ops[i].Kind {
+		case storage.BatchUpdate:
+			change, audit = storage.ChangeUpdated, storage.AuditUpdate
+		case storage.BatchDelete:
+			change, audit = storage.ChangeDeleted, storage.AuditDelete
+		}
+		s.notify(ctx, change, r.ID, ops[i].owner)
+		s.audit(ctx, audit, r.ID, ops[i].owner)
+
+		resp.Results = append(resp.Results, &pb.BatchResponse_Result{
+			Id:      r.ID.String(),
+			Version: r.Version,
+		})
+	}
+
+	return resp, nil
+}
+
+// batchOp - проверить операцию пакета и подпись ее данных.
+func (s server) batchOp(ctx context.Context, o *pb.BatchOperation, seen map[uuid.UUID]bool) (batchOp, error) {
+	switch op := o.Op.(type) {
+	case *pb.BatchOperation_Create:
+		return s.batchCreate(ctx, op.Create)
+	case *pb.BatchOperation_Update:
+		return s.batchUpdate(ctx, op.Update, seen)
+	case *pb.BatchOperation_Delete:
+		return s.batchDelete(ctx, op.Delete, seen)
+	default:
+		return batchOp{}, status.Error(codes.InvalidArgument, "operation is empty")
+	}
+}
+
+func (s server) batchCreate(ctx context.Context, req *pb.CreateRequest) (batchOp, error) {
+	if req.IdempotencyKey != "" {
+		return batchOp{}, status.Error(codes.InvalidArgument, "idempotency key is not supported in batch")
+	}
+	expiresAt, err := parseExpiresAt(req.ExpiresAt)
+	if err != nil {
+		return batchOp{}, err
+	}
+	if err = s.checkOwner(ctx, req.PublicKey); err != nil {
+		return batchOp{}, err
+	}
+
+	hash := sha256.Sum256(req.Data)
+	err = verifySign(ctx, "create", req.PublicKey, hash[:], req.Sign)
+	if err != nil {
+		return batchOp{}, err
+	}
+
+	return batchOp{
+		BatchOp: storage.BatchOp{
+			Kind:      storage.BatchCreate,
+			PublicKey: req.PublicKey,
+			Data:      req.Data,
+			ExpiresAt: expiresAt,
+		},
+		owner: req.PublicKey,
+	}, nil
+}
+
+func (s server) batchUpdate(ctx context.Context, req *pb.UpdateRequest, seen map[uuid.UUID]bool) (batchOp, error) {
+	entry, err := s.batchEntry(ctx, req.Id, req.ExpectedVersion, seen)
+	if err != nil {
+		return batchOp{}, err
+	}
+
+	expiresAt := entry.ExpiresAt
+	switch {
+	case req.ClearExpiresAt && req.ExpiresAt != nil:
+		return batchOp{}, status.Error(codes.InvalidArgument, "expires_at and clear_expires_at are mutually exclusive")
+	case req.ClearExpiresAt:
+		expiresAt = time.Time{}
+	case req.ExpiresAt != nil:
+		expiresAt, err = parseExpiresAt(req.ExpiresAt)
+		if err != nil {
+			return batchOp{}, err
+		}
+	}
+
+	oldHash := sha256.Sum256(entry.Payload)
+	oldHash2 := sha256.Sum256(oldHash[:])
+	err = verifySign(ctx, "update", entry.PublicKey, oldHash2[:], req.SignOld)
+	if err != nil {
+		return batchOp{}, err
+	}
+	newHash := sha256.Sum256(req.Data)
+	err = verifySign(ctx, "update", entry.PublicKey, newHash[:], req.SignNew)
+	if err != nil {
+		return batchOp{}, err
+	}
+
+	return batchOp{
+		BatchOp: storage.BatchOp{
+			Kind:      storage.BatchUpdate,
+			ID:        entry.ID,
+			Data:      req.Data,
+			Version:   entry.Version,
+			ExpiresAt: expiresAt,
+		},
+		owner: entry.PublicKey,
+	}, nil
+}
+
+func (s server) batchDelete(ctx context.Context, req *pb.DeleteRequest, seen map[uuid.UUID]bool) (batchOp, error) {
+	entry, err := s.batchEntry(ctx, req.Id, req.ExpectedVersion, seen)
+	if err != nil {
+		return batchOp{}, err
+	}
+
+	hash := sha256.Sum256(entry.Payload)
+	hash2 := sha256.Sum256(hash[:])
+	err = verifySign(ctx, "delete", entry.PublicKey, hash2[:], req.Sign)
+	if err != nil {
+		return batchOp{}, err
+	}
+
+	return batchOp{
+		BatchOp: storage.BatchOp{
+			Kind:    storage.BatchDelete,
+			ID:      entry.ID,
+			Version: entry.Version,
+		},
+		owner: entry.PublicKey,
+	}, nil
+}
+
+// batchEntry - получить запись, которую изменяет операция пакета, и проверить ее версию.
+func (s server) batchEntry(ctx context.Context, rawID string, expectedVersion int64,
+	seen map[uuid.UUID]bool,
+) (storage.Entry, error) {
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		return storage.Entry{}, status.Errorf(codes.InvalidArgument, "unable to parse UUID: %s", err)
+	}
+	if seen[id] {
+		return storage.Entry{}, status.Error(codes.InvalidArgument, "entry appears in batch more than once")
+	}
+	seen[id] = true
+
+	entry, err := s.s.Get(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return storage.Entry{}, status.Error(codes.NotFound, "entry not found")
+	}
+	if err != nil {
+		return storage.Entry{}, status.Errorf(codes.Internal, "storage error on get: %s", err)
+	}
+	if err = s.checkOwner(ctx, entry.PublicKey); err != nil {
+		return storage.Entry{}, err
+	}
+	if expectedVersion != 0 && expectedVersion != entry.Version {
+		return storage.Entry{}, versionConflict(entry.Version)
+	}
+
+	return entry, nil
+}
+
+// verifySign - проверить подпись хеша данных записи ключом владельца.
+func verifySign(ctx context.Context, op string, publicKey, hash, sign []byte) error {
+	publicN := big.Int{}
+	publicN.SetBytes(publicKey)
+	public := rsa.PublicKey{
+		N: &publicN,
+		E: publicE,
+	}
+
+	_, span := tracing.Start(ctx, "verify signature")
+	err := rsa.VerifyPKCS1v15(&public, crypto.SHA256, hash, sign)
+	tracing.End(span, err)
+	if err != nil {
+		metrics.SignVerifyFailures.WithLabelValues(op).Inc()
+		return status.Errorf(codes.InvalidArgument, "sign verify failed: %s", err)
+	}
+
+	return nil
+}
+
+// batchFailed - ответ на непримененный пакет: ошибки операций, остальные операции получают ABORTED.
+func batchFailed(ops []batchOp, errs []error) *pb.BatchResponse {
+	first := -1
+	for i, err := range errs {
+		if err != nil {
+			first = i
+			break
+		}
+	}
+
+	resp := &pb.BatchResponse{
+		Results: make([]*pb.BatchResponse_Result, 0, len(ops)),
+	}
+	for i, op := range ops {
+		r := &pb.BatchResponse_Result{
+			Code:    int32(codes.Aborted),
+			Message: fmt.Sprintf("batch not applied: operation %d failed", first),
+		}
+		if op.ID != uuid.Nil {
+			r.Id = op.ID.String()
+		}
+
+		if errs[i] != nil {
+			st := status.Convert(errs[i])
+			r.Code = int32(st.Code())
+			r.Message = st.Message()
+			for _, d := range st.Details() {
+				if conflict, ok := d.(*pb.VersionConflict); ok {
+					r.CurrentVersion = conflict.CurrentVersion
+				}
+			}
+		}
+		resp.Results = append(resp.Results, r)
+	}
+
+	return resp
+}
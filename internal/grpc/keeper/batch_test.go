@@ -0,0 +1,130 @@
+package keeper
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+func TestServer_Batch(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicKey := key.PublicKey.N.Bytes()
+
+	sign := func(data []byte, twice bool) []byte {
+		hash := sha256.Sum256(data)
+		if twice {
+			hash = sha256.Sum256(hash[:])
+		}
+		s, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+		return s
+	}
+	create := func(data []byte) *pb.BatchOperation {
+		return &pb.BatchOperation{Op: &pb.BatchOperation_Create{Create: &pb.CreateRequest{
+			PublicKey: publicKey,
+			Data:      data,
+			Sign:      sign(data, false),
+		}}}
+	}
+	update := func(id string, old, data []byte) *pb.BatchOperation {
+		return &pb.BatchOperation{Op: &pb.BatchOperation_Update{Update: &pb.UpdateRequest{
+			Id:      id,
+			Data:    data,
+			SignOld: sign(old, true),
+			SignNew: sign(data, false),
+		}}}
+	}
+	remove := func(id string, old []byte) *pb.BatchOperation {
+		return &pb.BatchOperation{Op: &pb.BatchOperation_Delete{Delete: &pb.DeleteRequest{
+			Id:   id,
+			Sign: sign(old, true),
+		}}}
+	}
+
+	ctx := context.Background()
+	s := NewServer(storage.NewMemoryStorage(10), 0, 0)
+
+	v1 := &pb.CreateRequest{PublicKey: publicKey, Data: []byte("v1"), Sign: sign([]byte("v1"), false)}
+	first, err := s.Create(ctx, v1)
+	require.NoError(t, err)
+	second, err := s.Create(ctx, v1)
+	require.NoError(t, err)
+
+	t.Run("empty", func(t *testing.T) {
+		_, err = s.Batch(ctx, &pb.BatchRequest{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("applied", func(t *testing.T) {
+		resp, batchErr := s.Batch(ctx, &pb.BatchRequest{Operations: []*pb.BatchOperation{
+			create([]byte("new")),
+			update(first.Id, []byte("v1"), []byte("v2")),
+			remove(second.Id, []byte("v1")),
+		}})
+		require.NoError(t, batchErr)
+		assert.True(t, resp.Applied)
+		require.Len(t, resp.Results, 3)
+		assert.Equal(t, int64(1), resp.Results[0].Version)
+		assert.Equal(t, first.Id, resp.Results[1].Id)
+		assert.Equal(t, int64(2), resp.Results[1].Version)
+		assert.Equal(t, second.Id, resp.Results[2].Id)
+
+		got, getErr := s.Get(ctx, &pb.GetRequest{Id: resp.Results[0].Id})
+		require.NoError(t, getErr)
+		assert.Equal(t, []byte("new"), got.Data)
+		got, getErr = s.Get(ctx, &pb.GetRequest{Id: first.Id})
+		require.NoError(t, getErr)
+		assert.Equal(t, []byte("v2"), got.Data)
+		_, getErr = s.Get(ctx, &pb.GetRequest{Id: second.Id})
+		assert.Equal(t, codes.NotFound, status.Code(getErr))
+	})
+
+	t.Run("not applied", func(t *testing.T) {
+		conflict := update(first.Id, []byte("v2"), []byte("v3"))
+		conflict.GetUpdate().ExpectedVersion = 1
+
+		resp, batchErr := s.Batch(ctx, &pb.BatchRequest{Operations: []*pb.BatchOperation{
+			create([]byte("skipped")),
+			conflict,
+			remove(second.Id, []byte("v1")),
+			{},
+		}})
+		require.NoError(t, batchErr)
+		assert.False(t, resp.Applied)
+		require.Len(t, resp.Results, 4)
+		assert.Equal(t, int32(codes.Aborted), resp.Results[0].Code)
+		assert.Equal(t, int32(codes.Aborted), resp.Results[1].Code)
+		assert.Equal(t, int64(2), resp.Results[1].CurrentVersion)
+		assert.Equal(t, int32(codes.NotFound), resp.Results[2].Code)
+		assert.Equal(t, int32(codes.InvalidArgument), resp.Results[3].Code)
+
+		all, getErr := s.GetAll(ctx, &pb.GetAllRequest{PublicKey: publicKey})
+		require.NoError(t, getErr)
+		assert.Len(t, all.Entries, 2)
+	})
+
+	t.Run("bad signature and duplicate entry", func(t *testing.T) {
+		resp, batchErr := s.Batch(ctx, &pb.BatchRequest{Operations: []*pb.BatchOperation{
+			update(first.Id, []byte("wrong"), []byte("v3")),
+			update(first.Id, []byte("v2"), []byte("v3")),
+		}})
+		require.NoError(t, batchErr)
+		assert.False(t, resp.Applied)
+		assert.Equal(t, int32(codes.InvalidArgument), resp.Results[0].Code)
+		assert.Contains(t, resp.Results[0].Message, "sign verify failed")
+		assert.Equal(t, int32(codes.InvalidArgument), resp.Results[1].Code)
+		assert.Contains(t, resp.Results[1].Message, "more than once")
+	})
+}
@@ -1,24 +1,36 @@
 package keeper
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 
+	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
 
 	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
 )
 
 // Client - клиент для взаимодействия с сервером.
 type Client struct {
-	conn *grpc.ClientConn
+	conn    *grpc.ClientConn
+	id      string
+	version string
+
+	deviceID     string
+	sessionToken string
+
 	pb.KeeperClient
 }
 
 // NewClient - создаем новый grpc клиент.
 func NewClient(serverAddress string) (*Client, error) {
-	c := &Client{}
+	c := &Client{
+		id: uuid.NewString(),
+	}
 
 	config := &tls.Config{
 		MinVersion:         tls.VersionTLS13,
@@ -26,7 +38,11 @@ func NewClient(serverAddress string) (*Client, error) {
 	}
 
 	var err error
-	c.conn, err = grpc.Dial(serverAddress, grpc.WithTransportCredentials(credentials.NewTLS(config)))
+	c.conn, err = grpc.Dial(serverAddress,
+		grpc.WithTransportCredentials(credentials.NewTLS(config)),
+		grpc.WithChainUnaryInterceptor(otelgrpc.UnaryClientInterceptor(), c.unaryClientID),
+		grpc.WithChainStreamInterceptor(otelgrpc.StreamClientInterceptor(), c.streamClientID),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("grpc keeper NewClient: dial: %w", err)
 	}
@@ -36,6 +52,29 @@ func NewClient(serverAddress string) (*Client, error) {
 	return c, nil
 }
 
+// ID - уникальный ID клиента, который передается серверу в метаданных каждого запроса.
+func (s *Client) ID() string {
+	return s.id
+}
+
+// SetVersion - задать версию клиента, которая передается серверу в метаданных запросов
+// и попадает в журнал аудита. Нужно вызывать до первого запроса.
+func (s *Client) SetVersion(version string) {
+	s.version = version
+}
+
+// SetDevice - задать ID устройства и токен сессии, которые передаются серверу в метаданных запросов.
+// Нужно вызывать до первого запроса.
+func (s *Client) SetDevice(deviceID, sessionToken string) {
+	s.deviceID = deviceID
+	s.sessionToken = sessionToken
+}
+
+// DeviceID - ID устройства, пустой если устройство не задано.
+func (s *Client) DeviceID() string {
+	return s.deviceID
+}
+
 // Close - закрываем соединение с сервером.
 func (s *Client) Close() error {
 	err := s.conn.Close()
@@ -45,3 +84,27 @@ func (s *Client) Close() error {
 
 	return nil
 }
+
+func (s *Client) unaryClientID(ctx context.Context, method string, req, reply any,
+	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+) error {
+	return invoker(s.outgoingContext(ctx), method, req, reply, cc, opts...)
+}
+
+func (s *Client) streamClientID(ctx context.Context, desc *grpc.StreamDesc,
+	cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	return streamer(s.outgoingContext(ctx), desc, cc, method, opts...)
+}
+
+func (s *Client) outgoingContext(ctx context.Context) context.Context {
+	kv := []string{ClientIDKey, s.id}
+	if s.version != "" {
+		kv = append(kv, ClientVersionKey, s.version)
+	}
+	if s.deviceID != "" {
+		kv = append(kv, DeviceIDKey, s.deviceID, SessionTokenKey, s.sessionToken)
+	}
+
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
@@ -0,0 +1,299 @@
+package keeper
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+const (
+	// DeviceIDKey - ключ метаданных запроса, в котором клиент передает ID своего устройства.
+	DeviceIDKey = "x-device-id"
+	// SessionTokenKey - ключ метаданных запроса, в котором клиент передает токен сессии устройства.
+	SessionTokenKey = "x-session-token"
+)
+
+const sessionTokenSize = 32
+
+// RegisterDeviceHash - хеш запроса регистрации устройства:
+// SHA256("register-device" || publicKey || timestamp || deviceID || name).
+// Для нового устройства deviceID пустой.
+func RegisterDeviceHash(publicKey []byte, timestamp int64, deviceID, name string) [sha256.Size]byte {
+	return ownerRequestHash("register-device", publicKey, timestamp, []byte(deviceID), []byte(name))
+}
+
+// ListDevicesHash - хеш запроса списка устройств: SHA256("devices" || publicKey || timestamp).
+func ListDevicesHash(publicKey []byte, timestamp int64) [sha256.Size]byte {
+	return ownerRequestHash("devices", publicKey, timestamp)
+}
+
+// RevokeDeviceHash - хеш запроса отзыва устройства:
+// SHA256("revoke-device" || publicKey || timestamp || deviceID).
+func RevokeDeviceHash(publicKey []byte, timestamp int64, deviceID string) [sha256.Size]byte {
+	return ownerRequestHash("revoke-device", publicKey, timestamp, []byte(deviceID))
+}
+
+// RegisterDevice - обработчик для регистрации устройства владельца публичного ключа.
+// Устройство, которое владелец отозвал, заново не регистрируется.
+func (s server) RegisterDevice(ctx context.Context, req *pb.RegisterDeviceRequest) (*pb.RegisterDeviceResponse, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "device name is empty")
+	}
+	var previousID uuid.UUID
+	if req.DeviceId != "" {
+		var err error
+		previousID, err = uuid.Parse(req.DeviceId)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "unable to parse device UUID: %s", err)
+		}
+	}
+
+	hash := RegisterDeviceHash(req.PublicKey, req.Timestamp, req.DeviceId, req.Name)
+	err := verifyOwnerRequest(ctx, "register_device", req.PublicKey, req.Timestamp, hash, req.Sign)
+	if err != nil {
+		return nil, err
+	}
+
+	if previousID != uuid.Nil {
+		err = s.checkNotRevoked(ctx, req.PublicKey, previousID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	token := make([]byte, sessionTokenSize)
+	_, err = rand.Read(token)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "generate session token: %s", err)
+	}
+	sessionToken := base64.RawURLEncoding.EncodeToString(token)
+	tokenHash := sha256.Sum256([]byte(sessionToken))
+
+	id, err := s.s.RegisterDevice(ctx, req.PublicKey, req.Name, tokenHash[:], peerIP(ctx))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "storage error: %s", err)
+	}
+
+	return &pb.RegisterDeviceResponse{
+		DeviceId:     id.String(),
+		SessionToken: sessionToken,
+	}, nil
+}
+
+// checkNotRevoked - проверить, что владелец не отзывал устройство id.
+// Неизвестное серверу устройство (например, после удаления аккаунта) можно зарегистрировать заново.
+func (s server) checkNotRevoked(ctx context.Context, publicKey []byte, id uuid.UUID) error {
+	devices, err := s.s.ListDevices(ctx, publicKey)
+	if err != nil {
+		return status.Errorf(codes.Internal, "storage error on check device: %s", err)
+	}
+
+	for _, d := range devices {
+		if d.ID == id && !d.RevokedAt.IsZero() {
+			return status.Errorf(codes.FailedPrecondition, "device %s is revoked", id)
+		}
+	}
+
+	return nil
+}
+
+// ListDevices - обработчик для получения устройств владельца публичного ключа.
+func (s server) ListDevices(ctx context.Context, req *pb.ListDevicesRequest) (*pb.ListDevicesResponse, error) {
+	hash := ListDevicesHash(req.PublicKey, req.Timestamp)
+	err := verifyOwnerRequest(ctx, "list_devices", req.PublicKey, req.Timestamp, hash, req.Sign)
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := s.s.ListDevices(ctx, req.PublicKey)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "storage error: %s", err)
+	}
+
+	d := make([]*pb.ListDevicesResponse_Device, 0, len(devices))
+	for _, device := range devices {
+		var revokedAt *timestamppb.Timestamp
+		if !device.RevokedAt.IsZero() {
+			revokedAt = timestamppb.New(device.RevokedAt)
+		}
+		d = append(d, &pb.ListDevicesResponse_Device{
+			Id:         device.ID.String(),
+			Name:       device.Name,
+			CreatedAt:  timestamppb.New(device.CreatedAt),
+			LastSeenAt: timestamppb.New(device.LastSeenAt),
+			LastIp:     device.LastIP,
+			RevokedAt:  revokedAt,
+		})
+	}
+
+	return &pb.ListDevicesResponse{
+		Devices: d,
+	}, nil
+}
+
+// RevokeDevice - обработчик для отзыва устройства владельца публичного ключа.
+func (s server) RevokeDevice(ctx context.Context, req *pb.RevokeDeviceRequest) (*emptypb.Empty, error) {
+	id, err := uuid.Parse(req.DeviceId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "unable to parse device UUID: %s", err)
+	}
+
+	hash := RevokeDeviceHash(req.PublicKey, req.Timestamp, req.DeviceId)
+	err = verifyOwnerRequest(ctx, "revoke_device", req.PublicKey, req.Timestamp, hash, req.Sign)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.s.RevokeDevice(ctx, req.PublicKey, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, "device not found or already revoked")
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "storage error: %s", err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// UnaryDeviceAuth - проверять токен сессии устройства в unary запросах.
+// Владелец устройства сохраняется в контексте, и checkOwner сверяет его с владельцем запроса.
+func (s server) UnaryDeviceAuth() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := s.checkDevice(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamDeviceAuth - проверять токен сессии устройства в stream запросах.
+func (s server) StreamDeviceAuth() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := s.checkDevice(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &deviceStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type deviceStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (d *deviceStream) Context() context.Context {
+	return d.ctx
+}
+
+// deviceOwnerKey - ключ контекста с публичным ключом владельца устройства, от которого пришел запрос.
+type deviceOwnerKey struct{}
+
+// deviceTouchInterval - как часто обновляется время последнего запроса устройства.
+const deviceTouchInterval = time.Minute
+
+// checkDevice - проверить токен сессии устройства из метаданных и вернуть контекст с владельцем устройства.
+// Запрос без ID устройства проходит дальше, его проверит checkOwner.
+func (s server) checkDevice(ctx context.Context) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx, nil
+	}
+
+	ids := md.Get(DeviceIDKey)
+	if len(ids) == 0 {
+		return ctx, nil
+	}
+
+	id, err := uuid.Parse(ids[0])
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "unable to parse device UUID: %s", err)
+	}
+
+	var token string
+	if tokens := md.Get(SessionTokenKey); len(tokens) > 0 {
+		token = tokens[0]
+	}
+	tokenHash := sha256.Sum256([]byte(token))
+
+	publicKey, err := s.s.DeviceOwner(ctx, id, tokenHash[:])
+	if errors.Is(err, storage.ErrDeviceRevoked) {
+		return nil, status.Error(codes.PermissionDenied, "device revoked or session token is invalid")
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "storage error on check device: %s", err)
+	}
+
+	// время последнего обращения не обязательно для проверки сессии: его ошибка не отклоняет запрос,
+	// а в режиме обслуживания чтение ничего не записывает
+	if !s.ReadOnly().Enabled {
+		touchCtx, span := tracing.Start(ctx, "touch device")
+		err = s.s.TouchDevice(touchCtx, id, peerIP(ctx), time.Now().Add(-deviceTouchInterval))
+		tracing.End(span, err)
+	}
+
+	return context.WithValue(ctx, deviceOwnerKey{}, publicKey), nil
+}
+
+// deviceMethods - методы управления устройствами. Они подписаны ключом владельца
+// и работают без сессии устройства, иначе новое устройство нельзя было бы зарегистрировать.
+var deviceMethods = map[string]bool{
+	pb.Keeper_RegisterDevice_FullMethodName: true,
+	pb.Keeper_ListDevices_FullMethodName:    true,
+	pb.Keeper_RevokeDevice_FullMethodName:   true,
+}
+
+// checkOwnerDevice - запрос к данным владельца должен прийти от его устройства,
+// если у владельца есть действующие устройства.
+func (s server) checkOwnerDevice(ctx context.Context, publicKey []byte) error {
+	if deviceOwner, ok := ctx.Value(deviceOwnerKey{}).([]byte); ok {
+		if !bytes.Equal(deviceOwner, publicKey) {
+			return status.Error(codes.PermissionDenied, "device belongs to another owner")
+		}
+		return nil
+	}
+
+	if method, ok := grpc.Method(ctx); ok && deviceMethods[method] {
+		return nil
+	}
+
+	has, err := s.s.HasDevices(ctx, publicKey)
+	if err != nil {
+		return status.Errorf(codes.Internal, "storage error on check devices: %s", err)
+	}
+	if has {
+		return status.Error(codes.Unauthenticated, "owner has registered devices, device session is required")
+	}
+
+	return nil
+}
+
+// peerIP - IP адрес клиента без порта.
+func peerIP(ctx context.Context) string {
+	addr := peerAddress(ctx)
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	return host
+}
@@ -0,0 +1,184 @@
+package keeper
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+func TestDeviceHashes(t *testing.T) {
+	publicKey := []byte{1, 2, 3}
+
+	assert.NotEqual(t, RegisterDeviceHash(publicKey, 1, "", "laptop"), RegisterDeviceHash(publicKey, 1, "", "phone"))
+	assert.NotEqual(t, RegisterDeviceHash(publicKey, 1, "", "laptop"), RegisterDeviceHash(publicKey, 1, "a", "laptop"))
+	assert.NotEqual(t, RevokeDeviceHash(publicKey, 1, "a"), RevokeDeviceHash(publicKey, 1, "b"))
+	assert.NotEqual(t, ListDevicesHash(publicKey, 1), AuditHash(publicKey, 1))
+}
+
+// methodStream - транспортный поток, по которому grpc.Method узнает вызванный метод.
+type methodStream struct {
+	method string
+}
+
+func (m methodStream) Method() string               { return m.method }
+func (m methodStream) SetHeader(metadata.MD) error  { return nil }
+func (m methodStream) SendHeader(metadata.MD) error { return nil }
+func (m methodStream) SetTrailer(metadata.MD) error { return nil }
+
+// touchStorage - хранилище, в котором обновление времени последнего обращения устройства
+// завершается ошибкой и считает вызовы.
+type touchStorage struct {
+	*storage.MemoryStorage
+	touches int
+}
+
+func (s *touchStorage) TouchDevice(context.Context, uuid.UUID, string, time.Time) error {
+	s.touches++
+	return errors.New("connection lost")
+}
+
+func TestServer_checkDevice(t *testing.T) {
+	ms := storage.NewMemoryStorage(10)
+	s := NewServer(ms, 0, 0)
+
+	owner := []byte{1, 2, 3}
+	token := "token"
+	tokenHash := sha256.Sum256([]byte(token))
+	id, err := ms.RegisterDevice(context.Background(), owner, "laptop", tokenHash[:], "10.0.0.1")
+	require.NoError(t, err)
+
+	device := func(id, token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(),
+			metadata.Pairs(DeviceIDKey, id, SessionTokenKey, token))
+	}
+
+	t.Run("no metadata", func(t *testing.T) {
+		_, checkErr := s.checkDevice(context.Background())
+		assert.NoError(t, checkErr)
+	})
+
+	t.Run("no device id", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(ClientIDKey, "client"))
+		_, checkErr := s.checkDevice(ctx)
+		assert.NoError(t, checkErr)
+	})
+
+	t.Run("wrong device id", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(DeviceIDKey, "wrong"))
+		_, checkErr := s.checkDevice(ctx)
+		assert.Equal(t, codes.InvalidArgument, status.Code(checkErr))
+	})
+
+	t.Run("wrong token", func(t *testing.T) {
+		_, checkErr := s.checkDevice(device(id.String(), "wrong"))
+		assert.Equal(t, codes.PermissionDenied, status.Code(checkErr))
+	})
+
+	t.Run("owner device", func(t *testing.T) {
+		ctx, checkErr := s.checkDevice(device(id.String(), token))
+		require.NoError(t, checkErr)
+		assert.NoError(t, s.checkOwner(ctx, owner))
+		assert.Equal(t, codes.PermissionDenied, status.Code(s.checkOwner(ctx, []byte{4, 5, 6})))
+	})
+
+	t.Run("device required", func(t *testing.T) {
+		assert.Equal(t, codes.Unauthenticated, status.Code(s.checkOwner(context.Background(), owner)))
+		assert.NoError(t, s.checkOwner(context.Background(), []byte{4, 5, 6}))
+
+		ctx := grpc.NewContextWithServerTransportStream(context.Background(),
+			methodStream{method: pb.Keeper_RegisterDevice_FullMethodName})
+		assert.NoError(t, s.checkOwner(ctx, owner))
+	})
+
+	t.Run("touch failed", func(t *testing.T) {
+		ts := &touchStorage{MemoryStorage: ms}
+		broken := NewServer(ts, 0, 0)
+
+		_, checkErr := broken.checkDevice(device(id.String(), token))
+		require.NoError(t, checkErr)
+		assert.Equal(t, 1, ts.touches)
+
+		// в режиме обслуживания время последнего обращения не записывается
+		broken.SetReadOnly(storage.ReadOnlyMode{Enabled: true, RetryAfter: time.Minute})
+		_, checkErr = broken.checkDevice(device(id.String(), token))
+		require.NoError(t, checkErr)
+		assert.Equal(t, 1, ts.touches)
+	})
+
+	t.Run("revoked device", func(t *testing.T) {
+		require.NoError(t, ms.RevokeDevice(context.Background(), owner, id))
+
+		_, checkErr := s.checkDevice(device(id.String(), token))
+		assert.Equal(t, codes.PermissionDenied, status.Code(checkErr))
+		assert.NoError(t, s.checkOwner(context.Background(), owner))
+	})
+}
+
+func TestServer_RegisterDevice(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicKey := key.PublicKey.N.Bytes()
+
+	ctx := context.Background()
+	s := NewServer(storage.NewMemoryStorage(10), 0, 0)
+
+	register := func(previousID string) (*pb.RegisterDeviceResponse, error) {
+		now := time.Now().Unix()
+		hash := RegisterDeviceHash(publicKey, now, previousID, "laptop")
+		sign, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+		return s.RegisterDevice(ctx, &pb.RegisterDeviceRequest{
+			PublicKey: publicKey,
+			Timestamp: now,
+			Name:      "laptop",
+			Sign:      sign,
+			DeviceId:  previousID,
+		})
+	}
+
+	_, err = s.RegisterDevice(ctx, &pb.RegisterDeviceRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = s.RegisterDevice(ctx, &pb.RegisterDeviceRequest{Name: "laptop", DeviceId: "wrong"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	registered, err := register("")
+	require.NoError(t, err)
+
+	// неизвестное серверу устройство регистрируется заново
+	_, err = register(uuid.NewString())
+	require.NoError(t, err)
+
+	id, err := uuid.Parse(registered.DeviceId)
+	require.NoError(t, err)
+	require.NoError(t, s.s.RevokeDevice(ctx, publicKey, id))
+
+	// отозванное устройство заново не регистрируется
+	_, err = register(registered.DeviceId)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestPeerIP(t *testing.T) {
+	assert.Empty(t, peerIP(context.Background()))
+
+	ctx := peer.NewContext(context.Background(),
+		&peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4321}})
+	assert.Equal(t, "10.0.0.1", peerIP(ctx))
+}
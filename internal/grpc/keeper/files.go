@@ -0,0 +1,212 @@
+package keeper
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+// ManifestHash - хеш манифеста файла, который подписывает клиент: SHA256(SHA256(data) || chunkHashes...).
+func ManifestHash(data []byte, chunkHashes [][]byte) [sha256.Size]byte {
+	dataHash := sha256.Sum256(data)
+
+	h := sha256.New()
+	h.Write(dataHash[:])
+	for _, c := range chunkHashes {
+		h.Write(c)
+	}
+
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// Upload - обработчик для загрузки файла по частям.
+func (s server) Upload(stream pb.Keeper_UploadServer) error {
+	ctx := stream.Context()
+
+	req, err := stream.Recv()
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "unable to receive header: %s", err)
+	}
+	header := req.GetHeader()
+	if header == nil {
+		return status.Error(codes.InvalidArgument, "first message must be a header")
+	}
+
+	uploadID, err := uuid.Parse(header.UploadId)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "unable to parse upload UUID: %s", err)
+	}
+	if err = s.checkOwner(ctx, header.PublicKey); err != nil {
+		return err
+	}
+
+	for {
+		req, err = stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return status.Error(codes.InvalidArgument, "stream closed without manifest")
+		}
+		if err != nil {
+			return err
+		}
+
+		switch p := req.Payload.(type) {
+		case *pb.UploadRequest_Chunk:
+			hash := sha256.Sum256(p.Chunk.Data)
+			err = s.s.SaveUploadChunk(ctx, uploadID, header.PublicKey, p.Chunk.Index, hash[:], p.Chunk.Data)
+			if err != nil {
+				return status.Errorf(codes.Internal, "storage error on save chunk: %s", err)
+			}
+		case *pb.UploadRequest_Manifest:
+			var id uuid.UUID
+			id, err = s.commitUpload(ctx, uploadID, header.PublicKey, p.Manifest)
+			if err != nil {
+				return err
+			}
+			return stream.SendAndClose(&pb.UploadResponse{
+				Id: id.String(),
+			})
+		default:
+			return status.Error(codes.InvalidArgument, "unexpected message, want chunk or manifest")
+		}
+	}
+}
+
+func (s server) commitUpload(ctx context.Context, uploadID uuid.UUID,
+	publicKey []byte, m *pb.Manifest,
+) (uuid.UUID, error) {
+	publicN := big.Int{}
+	publicN.SetBytes(publicKey)
+	public := rsa.PublicKey{
+		N: &publicN,
+		E: publicE,
+	}
+
+	hash := ManifestHash(m.Data, m.ChunkHashes)
+
+	_, span := tracing.Start(ctx, "verify signature")
+	err := rsa.VerifyPKCS1v15(&public, crypto.SHA256, hash[:], m.Sign)
+	tracing.End(span, err)
+	if err != nil {
+		metrics.SignVerifyFailures.WithLabelValues("upload").Inc()
+		return uuid.Nil, status.Errorf(codes.InvalidArgument, "sign verify failed: %s", err)
+	}
+
+	manifest, err := proto.Marshal(m)
+	if err != nil {
+		return uuid.Nil, status.Errorf(codes.Internal, "marshal manifest: %s", err)
+	}
+
+	id, err := s.s.CommitUpload(ctx, uploadID, publicKey, m.Data, manifest, m.ChunkHashes)
+	var mismatch *storage.UploadMismatchError
+	if errors.As(err, &mismatch) {
+		if mismatch.Chunk < 0 {
+			return uuid.Nil, status.Error(codes.FailedPrecondition, mismatch.Error())
+		}
+		return uuid.Nil, status.Error(codes.InvalidArgument, mismatch.Error())
+	}
+	if err != nil {
+		return uuid.Nil, status.Errorf(codes.Internal, "storage error on commit upload: %s", err)
+	}
+
+	s.notify(ctx, storage.ChangeCreated, id, publicKey)
+	s.audit(ctx, storage.AuditUpload, id, publicKey)
+
+	return id, nil
+}
+
+// UploadStatus - обработчик для получения состояния прерванной загрузки владельца публичного ключа.
+func (s server) UploadStatus(ctx context.Context, req *pb.UploadStatusRequest) (*pb.UploadStatusResponse, error) {
+	uploadID, err := uuid.Parse(req.UploadId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "unable to parse upload UUID: %s", err)
+	}
+
+	chunks, err := s.s.UploadChunks(ctx, uploadID, req.PublicKey)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "storage error: %s", err)
+	}
+
+	received := make([]uint32, 0, len(chunks))
+	for _, c := range chunks {
+		received = append(received, c.Index)
+	}
+
+	return &pb.UploadStatusResponse{
+		Received: received,
+	}, nil
+}
+
+// Download - обработчик для скачивания файла по частям.
+func (s server) Download(req *pb.DownloadRequest, stream pb.Keeper_DownloadServer) error {
+	ctx := stream.Context()
+
+	id, err := uuid.Parse(req.Id)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "unable to parse UUID: %s", err)
+	}
+
+	manifest, publicKey, err := s.s.GetManifest(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return status.Error(codes.NotFound, "entry not found")
+	}
+	if errors.Is(err, storage.ErrNotFile) {
+		return status.Error(codes.FailedPrecondition, "entry is not a file")
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "storage error on get manifest: %s", err)
+	}
+	if err = s.checkOwner(ctx, publicKey); err != nil {
+		return err
+	}
+
+	m := &pb.Manifest{}
+	err = proto.Unmarshal(manifest, m)
+	if err != nil {
+		return status.Errorf(codes.Internal, "unmarshal manifest: %s", err)
+	}
+
+	s.audit(ctx, storage.AuditDownload, id, publicKey)
+
+	err = stream.Send(&pb.DownloadResponse{
+		Payload: &pb.DownloadResponse_Manifest{Manifest: m},
+	})
+	if err != nil {
+		return err
+	}
+
+	for idx := req.FromChunk; idx < uint32(len(m.ChunkHashes)); idx++ {
+		var data []byte
+		data, err = s.s.GetChunk(ctx, id, idx)
+		if err != nil {
+			return status.Errorf(codes.Internal, "storage error on get chunk %d: %s", idx, err)
+		}
+
+		err = stream.Send(&pb.DownloadResponse{
+			Payload: &pb.DownloadResponse_Chunk{Chunk: &pb.Chunk{
+				Index: idx,
+				Data:  data,
+			}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
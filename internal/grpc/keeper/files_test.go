@@ -0,0 +1,19 @@
+package keeper
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestHash(t *testing.T) {
+	data := []byte("metadata")
+	chunks := [][]byte{{1, 2, 3}, {4, 5, 6}}
+
+	dataHash := sha256.Sum256(data)
+	want := sha256.Sum256(append(append(dataHash[:], chunks[0]...), chunks[1]...))
+
+	assert.Equal(t, want, ManifestHash(data, chunks))
+	assert.NotEqual(t, want, ManifestHash(data, chunks[:1]))
+}
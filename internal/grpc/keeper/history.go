@@ -0,0 +1,141 @@
+package keeper
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+// HistoryHash - хеш запроса истории версий записи: SHA256("history" || publicKey || timestamp || id).
+func HistoryHash(publicKey []byte, timestamp int64, id string) [sha256.Size]byte {
+	return ownerRequestHash("history", publicKey, timestamp, []byte(id))
+}
+
+// History - обработчик для получения предыдущих версий записи.
+func (s server) History(ctx context.Context, req *pb.HistoryRequest) (*pb.HistoryResponse, error) {
+	id, err := uuid.Parse(req.Id)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "unable to parse UUID: %s", err)
+	}
+
+	entry, err := s.s.Get(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, "entry not found")
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "storage error on get: %s", err)
+	}
+	if err = s.checkOwner(ctx, entry.PublicKey); err != nil {
+		return nil, err
+	}
+
+	hash := HistoryHash(entry.PublicKey, req.Timestamp, req.Id)
+	err = verifyOwnerRequest(ctx, "history", entry.PublicKey, req.Timestamp, hash, req.Sign)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.s.History(ctx, id)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "storage error: %s", err)
+	}
+
+	s.audit(ctx, storage.AuditHistory, id, entry.PublicKey)
+
+	versions := make([]*pb.HistoryResponse_Version, 0, len(entries))
+	for _, entry := range entries {
+		versions = append(versions, &pb.HistoryResponse_Version{
+			Version:   entry.Version,
+			Data:      entry.Payload,
+			UpdatedAt: timestamppb.New(entry.UpdatedAt),
+		})
+	}
+
+	return &pb.HistoryResponse{
+		Versions: versions,
+	}, nil
+}
+
+// Revert - обработчик для возврата записи к предыдущей версии.
+// Подписи такие же, как при обновлении: старые данные - текущие данные записи,
+// новые - данные версии из истории. Возврат создает новую версию записи.
+func (s server) Revert(ctx context.Context, req *pb.RevertRequest) (*emptypb.Empty, error) {
+	id, err := uuid.Parse(req.Id)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "unable to parse UUID: %s", err)
+	}
+
+	entry, err := s.s.Get(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, "entry not found")
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "storage error on get: %s", err)
+	}
+	if err = s.checkOwner(ctx, entry.PublicKey); err != nil {
+		return nil, err
+	}
+	if req.ExpectedVersion != 0 && req.ExpectedVersion != entry.Version {
+		return nil, versionConflict(entry.Version)
+	}
+
+	old, err := s.s.GetVersion(ctx, id, req.Version)
+	if errors.Is(err, storage.ErrVersionNotFound) {
+		return nil, status.Error(codes.NotFound, "entry version not found")
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "storage error on get version: %s", err)
+	}
+
+	publicN := big.Int{}
+	publicN.SetBytes(entry.PublicKey)
+	public := rsa.PublicKey{
+		N: &publicN,
+		E: publicE,
+	}
+
+	oldHash := sha256.Sum256(entry.Payload)
+	oldHash2 := sha256.Sum256(oldHash[:])
+
+	_, span := tracing.Start(ctx, "verify old signature")
+	err = rsa.VerifyPKCS1v15(&public, crypto.SHA256, oldHash2[:], req.SignOld)
+	tracing.End(span, err)
+	if err != nil {
+		metrics.SignVerifyFailures.WithLabelValues("revert").Inc()
+		return nil, status.Errorf(codes.InvalidArgument, "sign verify failed: %s", err)
+	}
+
+	newHash := sha256.Sum256(old.Payload)
+
+	_, span = tracing.Start(ctx, "verify new signature")
+	err = rsa.VerifyPKCS1v15(&public, crypto.SHA256, newHash[:], req.SignNew)
+	tracing.End(span, err)
+	if err != nil {
+		metrics.SignVerifyFailures.WithLabelValues("revert").Inc()
+		return nil, status.Errorf(codes.InvalidArgument, "sign verify failed: %s", err)
+	}
+
+	_, err = s.s.Update(ctx, id, old.Payload, entry.Version, entry.ExpiresAt)
+	if err != nil {
+		return nil, storageWriteError("revert", err)
+	}
+
+	s.notify(ctx, storage.ChangeUpdated, id, entry.PublicKey)
+	s.audit(ctx, storage.AuditRevert, id, entry.PublicKey)
+
+	return &emptypb.Empty{}, nil
+}
@@ -0,0 +1,169 @@
+package keeper
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+// brokenHistoryStorage - хранилище, в котором чтение истории версий завершается ошибкой.
+type brokenHistoryStorage struct {
+	*storage.MemoryStorage
+}
+
+func (brokenHistoryStorage) History(context.Context, uuid.UUID) ([]storage.Entry, error) {
+	return nil, errors.New("connection lost")
+}
+
+func (brokenHistoryStorage) GetVersion(context.Context, uuid.UUID, int64) (storage.Entry, error) {
+	return storage.Entry{}, errors.New("connection lost")
+}
+
+func TestServer_History(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicKey := key.PublicKey.N.Bytes()
+
+	sign := func(data []byte, twice bool) []byte {
+		hash := sha256.Sum256(data)
+		if twice {
+			hash = sha256.Sum256(hash[:])
+		}
+		s, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+		return s
+	}
+
+	ctx := context.Background()
+	ms := storage.NewMemoryStorage(10)
+	s := NewServer(ms, 0, 0)
+
+	created, err := s.Create(ctx, &pb.CreateRequest{PublicKey: publicKey, Data: []byte("v1"), Sign: sign([]byte("v1"), false)})
+	require.NoError(t, err)
+	_, err = s.Update(ctx, &pb.UpdateRequest{
+		Id:      created.Id,
+		Data:    []byte("v2"),
+		SignOld: sign([]byte("v1"), true),
+		SignNew: sign([]byte("v2"), false),
+	})
+	require.NoError(t, err)
+
+	now := time.Now().Unix()
+	history := func(s pb.KeeperServer, id string, signed int64) (*pb.HistoryResponse, error) {
+		hash := HistoryHash(publicKey, signed, id)
+		sig, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+		return s.History(ctx, &pb.HistoryRequest{Id: id, Timestamp: now, Sign: sig})
+	}
+
+	t.Run("history", func(t *testing.T) {
+		resp, historyErr := history(s, created.Id, now)
+		require.NoError(t, historyErr)
+		require.Len(t, resp.Versions, 1)
+		assert.Equal(t, int64(1), resp.Versions[0].Version)
+		assert.Equal(t, []byte("v1"), resp.Versions[0].Data)
+
+		// без подписи владельца история не выдается
+		_, historyErr = s.History(ctx, &pb.HistoryRequest{Id: created.Id})
+		assert.Equal(t, codes.InvalidArgument, status.Code(historyErr))
+		_, historyErr = history(s, created.Id, now+1)
+		assert.Equal(t, codes.InvalidArgument, status.Code(historyErr))
+
+		_, historyErr = s.History(ctx, &pb.HistoryRequest{Id: "bad"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(historyErr))
+
+		_, historyErr = s.History(ctx, &pb.HistoryRequest{Id: uuid.NewString()})
+		assert.Equal(t, codes.NotFound, status.Code(historyErr))
+	})
+
+	t.Run("bad revert", func(t *testing.T) {
+		revert := func(version, expected int64, signOld, signNew []byte) error {
+			_, revertErr := s.Revert(ctx, &pb.RevertRequest{
+				Id:              created.Id,
+				Version:         version,
+				SignOld:         signOld,
+				SignNew:         signNew,
+				ExpectedVersion: expected,
+			})
+			return revertErr
+		}
+
+		assert.Equal(t, codes.Aborted,
+			status.Code(revert(1, 1, sign([]byte("v2"), true), sign([]byte("v1"), false))))
+		assert.Equal(t, codes.NotFound,
+			status.Code(revert(5, 0, sign([]byte("v2"), true), sign([]byte("v1"), false))))
+		// старые данные - текущие данные записи, новые - данные версии из истории
+		assert.Equal(t, codes.InvalidArgument,
+			status.Code(revert(1, 0, sign([]byte("v1"), true), sign([]byte("v1"), false))))
+		assert.Equal(t, codes.InvalidArgument,
+			status.Code(revert(1, 0, sign([]byte("v2"), true), sign([]byte("v2"), false))))
+
+		_, revertErr := s.Revert(ctx, &pb.RevertRequest{Id: "bad"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(revertErr))
+	})
+
+	t.Run("disabled owner", func(t *testing.T) {
+		require.NoError(t, ms.DisableOwner(ctx, storage.Fingerprint(publicKey)))
+		defer func() { require.NoError(t, ms.EnableOwner(ctx, storage.Fingerprint(publicKey))) }()
+
+		_, historyErr := history(s, created.Id, now)
+		assert.Equal(t, codes.PermissionDenied, status.Code(historyErr))
+
+		_, revertErr := s.Revert(ctx, &pb.RevertRequest{
+			Id:      created.Id,
+			Version: 1,
+			SignOld: sign([]byte("v2"), true),
+			SignNew: sign([]byte("v1"), false),
+		})
+		assert.Equal(t, codes.PermissionDenied, status.Code(revertErr))
+	})
+
+	t.Run("revert", func(t *testing.T) {
+		_, revertErr := s.Revert(ctx, &pb.RevertRequest{
+			Id:              created.Id,
+			Version:         1,
+			SignOld:         sign([]byte("v2"), true),
+			SignNew:         sign([]byte("v1"), false),
+			ExpectedVersion: 2,
+		})
+		require.NoError(t, revertErr)
+
+		got, getErr := s.Get(ctx, &pb.GetRequest{Id: created.Id})
+		require.NoError(t, getErr)
+		assert.Equal(t, []byte("v1"), got.Data)
+		assert.Equal(t, int64(3), got.Version)
+
+		resp, historyErr := history(s, created.Id, now)
+		require.NoError(t, historyErr)
+		assert.Len(t, resp.Versions, 2)
+	})
+
+	t.Run("storage error", func(t *testing.T) {
+		broken := NewServer(brokenHistoryStorage{ms}, 0, 0)
+
+		_, historyErr := history(broken, created.Id, now)
+		assert.Equal(t, codes.Internal, status.Code(historyErr))
+
+		_, revertErr := broken.Revert(ctx, &pb.RevertRequest{
+			Id:      created.Id,
+			Version: 1,
+			SignOld: sign([]byte("v1"), true),
+			SignNew: sign([]byte("v1"), false),
+		})
+		assert.Equal(t, codes.Internal, status.Code(revertErr))
+	})
+}
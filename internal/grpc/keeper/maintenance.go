@@ -0,0 +1,129 @@
+package keeper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+// readOnlyMethods - методы, которые изменяют данные и отклоняются в режиме обслуживания.
+var readOnlyMethods = map[string]bool{
+	pb.Keeper_Create_FullMethodName:           true,
+	pb.Keeper_Update_FullMethodName:           true,
+	pb.Keeper_Delete_FullMethodName:           true,
+	pb.Keeper_Batch_FullMethodName:            true,
+	pb.Keeper_Revert_FullMethodName:           true,
+	pb.Keeper_Restore_FullMethodName:          true,
+	pb.Keeper_Purge_FullMethodName:            true,
+	pb.Keeper_AccountChallenge_FullMethodName: true,
+	pb.Keeper_DeleteAccount_FullMethodName:    true,
+	pb.Keeper_RegisterDevice_FullMethodName:   true,
+	pb.Keeper_RevokeDevice_FullMethodName:     true,
+	pb.Keeper_Upload_FullMethodName:           true,
+}
+
+// readOnly - текущий режим обслуживания сервера.
+type readOnly struct {
+	mode atomic.Pointer[storage.ReadOnlyMode]
+}
+
+// SetReadOnly - включить или выключить режим обслуживания. В этом режиме чтение работает,
+// а изменения отклоняются с кодом Unavailable и подсказкой, через сколько повторить запрос.
+func (s server) SetReadOnly(mode storage.ReadOnlyMode) {
+	if !mode.Enabled {
+		mode = storage.ReadOnlyMode{}
+	}
+	s.readOnly.mode.Store(&mode)
+}
+
+// ReadOnly - текущий режим обслуживания.
+func (s server) ReadOnly() storage.ReadOnlyMode {
+	if m := s.readOnly.mode.Load(); m != nil {
+		return *m
+	}
+	return storage.ReadOnlyMode{}
+}
+
+// UnaryReadOnly - отклонять unary запросы на изменение данных в режиме обслуживания.
+func (s server) UnaryReadOnly() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := s.checkReadOnly(info.FullMethod); err != nil {
+			_ = grpc.SetHeader(ctx, retryAfterHeader(s.ReadOnly().RetryAfter))
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamReadOnly - отклонять stream запросы на изменение данных в режиме обслуживания.
+// Загрузка, начатая до включения режима, завершается.
+func (s server) StreamReadOnly() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := s.checkReadOnly(info.FullMethod); err != nil {
+			_ = ss.SetHeader(retryAfterHeader(s.ReadOnly().RetryAfter))
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// checkReadOnly - вернуть Unavailable с RetryInfo, если метод изменяет данные, а сервер в режиме обслуживания.
+func (s server) checkReadOnly(method string) error {
+	mode := s.ReadOnly()
+	if !mode.Enabled || !readOnlyMethods[method] {
+		return nil
+	}
+
+	st := status.Newf(codes.Unavailable, "server is in read-only maintenance mode, retry after %s", mode.RetryAfter)
+	detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(mode.RetryAfter)})
+	if err != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
+// retryAfterHeader - заголовок retry-after в секундах. HTTP шлюз отдает его клиенту как Retry-After.
+func retryAfterHeader(d time.Duration) metadata.MD {
+	return metadata.Pairs("retry-after", strconv.FormatInt(int64(d/time.Second), 10))
+}
+
+// health - ответ проверки состояния сервера.
+type health struct {
+	Status     string     `json:"status"`
+	ReadOnly   bool       `json:"read_only,omitempty"`
+	RetryAfter string     `json:"retry_after,omitempty"`
+	Since      *time.Time `json:"since,omitempty"`
+}
+
+// HealthHandler - проверка состояния сервера. В режиме обслуживания сервер отвечает,
+// но сообщает статус degraded, чтобы балансировщик не считал его недоступным для чтения.
+func (s server) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		h := health{Status: "ok"}
+		if mode := s.ReadOnly(); mode.Enabled {
+			h = health{
+				Status:     "degraded",
+				ReadOnly:   true,
+				RetryAfter: mode.RetryAfter.String(),
+				Since:      &mode.Since,
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(h)
+	})
+}
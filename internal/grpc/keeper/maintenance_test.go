@@ -0,0 +1,97 @@
+package keeper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+func TestServer_UnaryReadOnly(t *testing.T) {
+	s := NewServer(storage.NewMemoryStorage(10), 0, 0)
+	interceptor := s.UnaryReadOnly()
+
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	}
+	create := &grpc.UnaryServerInfo{FullMethod: pb.Keeper_Create_FullMethodName}
+	get := &grpc.UnaryServerInfo{FullMethod: pb.Keeper_Get_FullMethodName}
+
+	resp, err := interceptor(context.Background(), &pb.CreateRequest{}, create, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+
+	s.SetReadOnly(storage.ReadOnlyMode{Enabled: true, RetryAfter: 2 * time.Minute, Since: time.Now()})
+
+	_, err = interceptor(context.Background(), &pb.CreateRequest{}, create, handler)
+	st := status.Convert(err)
+	assert.Equal(t, codes.Unavailable, st.Code())
+	assert.Contains(t, st.Message(), "retry after 2m0s")
+	require.Len(t, st.Details(), 1)
+	retry, ok := st.Details()[0].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	assert.Equal(t, 2*time.Minute, retry.RetryDelay.AsDuration())
+
+	resp, err = interceptor(context.Background(), &pb.GetRequest{}, get, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+
+	s.SetReadOnly(storage.ReadOnlyMode{})
+	_, err = interceptor(context.Background(), &pb.CreateRequest{}, create, handler)
+	assert.NoError(t, err)
+}
+
+func TestServer_ReadOnlyAudit(t *testing.T) {
+	ctx := context.Background()
+	ms := storage.NewMemoryStorage(10)
+	s := NewServer(ms, 0, 0)
+	owner := []byte{1, 2, 3}
+	id, err := ms.Create(ctx, owner, []byte("data"), time.Time{})
+	require.NoError(t, err)
+
+	// в режиме обслуживания чтение не пишет в журнал аудита
+	s.SetReadOnly(storage.ReadOnlyMode{Enabled: true, RetryAfter: time.Minute})
+	_, err = s.Get(ctx, &pb.GetRequest{Id: id.String()})
+	require.NoError(t, err)
+	audit, err := ms.ListAudit(ctx, owner, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, audit)
+
+	s.SetReadOnly(storage.ReadOnlyMode{})
+	_, err = s.Get(ctx, &pb.GetRequest{Id: id.String()})
+	require.NoError(t, err)
+	audit, err = ms.ListAudit(ctx, owner, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, audit, 1)
+	assert.Equal(t, storage.AuditRead, audit[0].Op)
+}
+
+func TestServer_HealthHandler(t *testing.T) {
+	s := NewServer(nil, 0, 0)
+
+	get := func() string {
+		rec := httptest.NewRecorder()
+		s.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		return rec.Body.String()
+	}
+
+	assert.JSONEq(t, `{"status":"ok"}`, get())
+
+	since := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	s.SetReadOnly(storage.ReadOnlyMode{Enabled: true, RetryAfter: time.Minute, Since: since})
+	assert.JSONEq(t,
+		`{"status":"degraded","read_only":true,"retry_after":"1m0s","since":"2023-06-01T12:00:00Z"}`, get())
+}
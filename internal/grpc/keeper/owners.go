@@ -0,0 +1,74 @@
+package keeper
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ownerRequest - запрос, в котором клиент передает публичный ключ владельца.
+type ownerRequest interface {
+	GetPublicKey() []byte
+}
+
+// UnaryOwnerStatus - отклонять unary запросы отключенных оператором владельцев.
+// Запросы к записи по ID проверяются в обработчиках, когда владелец записи уже известен.
+func (s server) UnaryOwnerStatus() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if r, ok := req.(ownerRequest); ok {
+			err := s.checkOwner(ctx, r.GetPublicKey())
+			if err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamOwnerStatus - отклонять stream запросы отключенных владельцев.
+// Владелец проверяется по первому сообщению клиента, если в нем есть публичный ключ.
+func (s server) StreamOwnerStatus() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &ownerStream{ServerStream: ss, s: s})
+	}
+}
+
+type ownerStream struct {
+	grpc.ServerStream
+	s       server
+	checked bool
+}
+
+func (o *ownerStream) RecvMsg(m any) error {
+	err := o.ServerStream.RecvMsg(m)
+	if err != nil || o.checked {
+		return err
+	}
+	o.checked = true
+
+	if r, ok := m.(ownerRequest); ok {
+		return o.s.checkOwner(o.Context(), r.GetPublicKey())
+	}
+
+	return nil
+}
+
+// checkOwner - вернуть PermissionDenied, если владелец публичного ключа отключен
+// или запрос пришел не от его устройства.
+func (s server) checkOwner(ctx context.Context, publicKey []byte) error {
+	if len(publicKey) == 0 {
+		return nil
+	}
+
+	disabled, err := s.s.IsOwnerDisabled(ctx, publicKey)
+	if err != nil {
+		return status.Errorf(codes.Internal, "storage error on check owner: %s", err)
+	}
+	if disabled {
+		return status.Error(codes.PermissionDenied, "owner is disabled")
+	}
+
+	return s.checkOwnerDevice(ctx, publicKey)
+}
@@ -0,0 +1,33 @@
+package keeper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+func TestServer_UnaryOwnerStatus(t *testing.T) {
+	s := NewServer(nil, 0, 0)
+	interceptor := s.UnaryOwnerStatus()
+
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	}
+
+	t.Run("request without public key", func(t *testing.T) {
+		resp, err := interceptor(context.Background(), &pb.GetRequest{}, &grpc.UnaryServerInfo{}, handler)
+		require.NoError(t, err)
+		assert.Equal(t, "ok", resp)
+	})
+
+	t.Run("empty public key", func(t *testing.T) {
+		resp, err := interceptor(context.Background(), &pb.GetAllRequest{}, &grpc.UnaryServerInfo{}, handler)
+		require.NoError(t, err)
+		assert.Equal(t, "ok", resp)
+	})
+}
@@ -5,30 +5,56 @@ import (
 	"crypto"
 	"crypto/rsa"
 	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"math/big"
+	"time"
 
 	"github.com/google/uuid"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
 	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
 	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
 )
 
 const publicE = 65537
 
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// maxIdempotencyKeyLen - максимальная длина ключа идемпотентности.
+const maxIdempotencyKeyLen = 128
+
 type server struct {
 	pb.UnimplementedKeeperServer
 
-	s *storage.ServerStorage
+	s        storage.Storage
+	hub      *hub
+	readOnly *readOnly
+
+	trashRetention       time.Duration
+	idempotencyRetention time.Duration
 }
 
 // NewServer - конструктор для grpc сервера GophKeeper.
-func NewServer(s *storage.ServerStorage) *server {
+// trashRetention - сколько удаленные записи хранятся в корзине,
+// idempotencyRetention - в течение какого времени повтор ключа идемпотентности возвращает созданную запись.
+func NewServer(s storage.Storage, trashRetention, idempotencyRetention time.Duration) *server {
 	return &server{
-		s: s,
+		s:                    s,
+		hub:                  newHub(),
+		readOnly:             &readOnly{},
+		trashRetention:       trashRetention,
+		idempotencyRetention: idempotencyRetention,
 	}
 }
 
@@ -46,33 +72,166 @@ func (s server) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, e
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "storage error: %s", err)
 	}
+	if err = s.checkOwner(ctx, entry.PublicKey); err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, storage.AuditRead, id, entry.PublicKey)
 
 	return &pb.GetResponse{
-		Data: entry.Payload,
+		Data:      entry.Payload,
+		Version:   entry.Version,
+		CreatedAt: timestamppb.New(entry.CreatedAt),
+		UpdatedAt: timestamppb.New(entry.UpdatedAt),
+		ExpiresAt: optionalTimestamp(entry.ExpiresAt),
 	}, nil
 }
 
-// GetAll - обработчик для получения всех данных пользователя.
+// optionalTimestamp - время в ответе, nil для нулевого времени.
+func optionalTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+
+	return timestamppb.New(t)
+}
+
+// parseExpiresAt - время истечения из запроса, нулевое для бессрочной записи.
+// Время истечения должно быть в будущем.
+func parseExpiresAt(ts *timestamppb.Timestamp) (time.Time, error) {
+	if ts == nil {
+		return time.Time{}, nil
+	}
+	if err := ts.CheckValid(); err != nil {
+		return time.Time{}, status.Errorf(codes.InvalidArgument, "invalid expires_at: %s", err)
+	}
+
+	expiresAt := ts.AsTime()
+	if !expiresAt.After(time.Now()) {
+		return time.Time{}, status.Error(codes.InvalidArgument, "expires_at must be in the future")
+	}
+
+	return expiresAt, nil
+}
+
+// GetAll - обработчик для получения всех данных пользователя постранично.
 func (s server) GetAll(ctx context.Context, req *pb.GetAllRequest) (*pb.GetAllResponse, error) {
-	entries, err := s.s.GetAll(ctx, req.PublicKey)
+	pageSize := int(req.PageSize)
+	switch {
+	case pageSize < 0:
+		return nil, status.Error(codes.InvalidArgument, "page size must not be negative")
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+
+	after, err := parsePageToken(req.PageToken)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "unable to parse page token: %s", err)
+	}
+
+	// запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+	entries, err := s.s.GetAll(ctx, req.PublicKey, after, pageSize+1)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "storage error: %s", err)
 	}
 
+	var nextPageToken string
+	if len(entries) > pageSize {
+		entries = entries[:pageSize]
+		nextPageToken = pageToken(entries[pageSize-1].ID)
+	}
+	if len(entries) > 0 {
+		s.audit(ctx, storage.AuditList, uuid.Nil, req.PublicKey)
+	}
+
 	e := make([]*pb.GetAllResponse_Entry, 0, len(entries))
 	for _, entry := range entries {
 		e = append(e, &pb.GetAllResponse_Entry{
-			Id:   entry.ID.String(),
-			Data: entry.Payload,
+			Id:        entry.ID.String(),
+			Data:      entry.Payload,
+			Revision:  entry.Revision,
+			Version:   entry.Version,
+			CreatedAt: timestamppb.New(entry.CreatedAt),
+			UpdatedAt: timestamppb.New(entry.UpdatedAt),
+			ExpiresAt: optionalTimestamp(entry.ExpiresAt),
 		})
 	}
 
 	return &pb.GetAllResponse{
-		Entries: e,
+		Entries:       e,
+		NextPageToken: nextPageToken,
+	}, nil
+}
+
+// pageToken - непрозрачный токен страницы: ID последней записи предыдущей страницы.
+func pageToken(last uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString(last[:])
+}
+
+func parsePageToken(token string) (uuid.UUID, error) {
+	if token == "" {
+		return uuid.Nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("decode: %w", err)
+	}
+
+	id, err := uuid.FromBytes(b)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("uuid: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetChanges - обработчик для получения изменений записей пользователя после ревизии.
+func (s server) GetChanges(ctx context.Context, req *pb.GetChangesRequest) (*pb.GetChangesResponse, error) {
+	if req.SinceRevision < 0 {
+		return nil, status.Error(codes.InvalidArgument, "since revision must not be negative")
+	}
+
+	cs, err := s.s.GetChanges(ctx, req.PublicKey, req.SinceRevision)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "storage error: %s", err)
+	}
+
+	e := make([]*pb.GetAllResponse_Entry, 0, len(cs.Entries))
+	for _, entry := range cs.Entries {
+		e = append(e, &pb.GetAllResponse_Entry{
+			Id:        entry.ID.String(),
+			Data:      entry.Payload,
+			Revision:  entry.Revision,
+			Version:   entry.Version,
+			CreatedAt: timestamppb.New(entry.CreatedAt),
+			UpdatedAt: timestamppb.New(entry.UpdatedAt),
+			ExpiresAt: optionalTimestamp(entry.ExpiresAt),
+		})
+	}
+
+	deleted := make([]string, 0, len(cs.Deleted))
+	for _, id := range cs.Deleted {
+		deleted = append(deleted, id.String())
+	}
+
+	// пустой ответ не раскрывает данных, иначе каждый опрос клиента попадал бы в журнал
+	if len(cs.Entries) > 0 {
+		s.audit(ctx, storage.AuditSync, uuid.Nil, req.PublicKey)
+	}
+
+	return &pb.GetChangesResponse{
+		Entries:    e,
+		Deleted:    deleted,
+		Revision:   cs.Revision,
+		FullResync: cs.FullResync,
 	}, nil
 }
 
 // Create - обработчик для сохранения новой записи.
+// С ключом идемпотентности повтор запроса возвращает ID уже созданной записи.
 func (s server) Create(ctx context.Context, req *pb.CreateRequest) (*pb.CreateResponse, error) {
 	publicN := big.Int{}
 	publicN.SetBytes(req.PublicKey)
@@ -81,24 +240,73 @@ func (s server) Create(ctx context.Context, req *pb.CreateRequest) (*pb.CreateRe
 		E: publicE,
 	}
 
+	expiresAt, err := parseExpiresAt(req.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.IdempotencyKey) > maxIdempotencyKeyLen {
+		return nil, status.Errorf(codes.InvalidArgument, "idempotency key is longer than %d", maxIdempotencyKeyLen)
+	}
+
 	hash := sha256.Sum256(req.Data)
 
-	err := rsa.VerifyPKCS1v15(&public, crypto.SHA256, hash[:], req.Sign)
+	_, span := tracing.Start(ctx, "verify signature")
+	err = rsa.VerifyPKCS1v15(&public, crypto.SHA256, hash[:], req.Sign)
+	tracing.End(span, err)
 	if err != nil {
+		metrics.SignVerifyFailures.WithLabelValues("create").Inc()
 		return nil, status.Errorf(codes.InvalidArgument, "sign verify failed: %s", err)
 	}
 
-	id, err := s.s.Create(ctx, req.PublicKey, req.Data)
+	id, created, err := s.create(ctx, req, hash[:], expiresAt)
+	if errors.Is(err, storage.ErrIdempotencyMismatch) {
+		return nil, status.Error(codes.AlreadyExists, "idempotency key was used for another request")
+	}
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "storage error: %s", err)
 	}
 
+	if created {
+		s.notify(ctx, storage.ChangeCreated, id, req.PublicKey)
+		s.audit(ctx, storage.AuditCreate, id, req.PublicKey)
+	}
+
 	return &pb.CreateResponse{
 		Id: id.String(),
 	}, nil
 }
 
-// Delete - обработчик для удаления записи.
+// create - сохранить запись, по ключу идемпотентности, если он задан.
+// created равен false, если запись уже была создана по этому ключу.
+func (s server) create(ctx context.Context, req *pb.CreateRequest, hash []byte,
+	expiresAt time.Time,
+) (id uuid.UUID, created bool, err error) {
+	if req.IdempotencyKey == "" {
+		id, err = s.s.Create(ctx, req.PublicKey, req.Data, expiresAt)
+		return id, err == nil, err
+	}
+
+	return s.s.CreateIdempotent(ctx, storage.IdempotencyKey{
+		Key:         req.IdempotencyKey,
+		RequestHash: requestHash(hash, expiresAt),
+		Since:       time.Now().Add(-s.idempotencyRetention),
+	}, req.PublicKey, req.Data, expiresAt)
+}
+
+// requestHash - хеш запроса создания для ключа идемпотентности: SHA256(data) и время истечения.
+// Для бессрочной записи это SHA256(data), как до появления времени истечения.
+func requestHash(dataHash []byte, expiresAt time.Time) []byte {
+	if expiresAt.IsZero() {
+		return dataHash
+	}
+
+	h := sha256.New()
+	h.Write(dataHash)
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(expiresAt.UnixMicro())))
+	return h.Sum(nil)
+}
+
+// Delete - обработчик для перемещения записи в корзину.
 func (s server) Delete(ctx context.Context, req *pb.DeleteRequest) (*emptypb.Empty, error) {
 	id, err := uuid.Parse(req.Id)
 	if err != nil {
@@ -112,6 +320,13 @@ func (s server) Delete(ctx context.Context, req *pb.DeleteRequest) (*emptypb.Emp
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "storage error on get: %s", err)
 	}
+	if err = s.checkOwner(ctx, entry.PublicKey); err != nil {
+		return nil, err
+	}
+	// проверяем версию до подписи: подпись старых данных клиента не совпадет с измененной записью
+	if req.ExpectedVersion != 0 && req.ExpectedVersion != entry.Version {
+		return nil, versionConflict(entry.Version)
+	}
 
 	publicN := big.Int{}
 	publicN.SetBytes(entry.PublicKey)
@@ -123,16 +338,22 @@ func (s server) Delete(ctx context.Context, req *pb.DeleteRequest) (*emptypb.Emp
 	hash := sha256.Sum256(entry.Payload)
 	hash2 := sha256.Sum256(hash[:])
 
+	_, span := tracing.Start(ctx, "verify signature")
 	err = rsa.VerifyPKCS1v15(&public, crypto.SHA256, hash2[:], req.Sign)
+	tracing.End(span, err)
 	if err != nil {
+		metrics.SignVerifyFailures.WithLabelValues("delete").Inc()
 		return nil, status.Errorf(codes.InvalidArgument, "sign verify failed: %s", err)
 	}
 
-	err = s.s.Delete(ctx, id)
+	err = s.s.Delete(ctx, id, entry.Version)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "storage error on delete: %s", err)
+		return nil, storageWriteError("delete", err)
 	}
 
+	s.notify(ctx, storage.ChangeDeleted, id, entry.PublicKey)
+	s.audit(ctx, storage.AuditDelete, id, entry.PublicKey)
+
 	return &emptypb.Empty{}, nil
 }
 
@@ -150,6 +371,26 @@ func (s server) Update(ctx context.Context, req *pb.UpdateRequest) (*emptypb.Emp
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "storage error on get: %s", err)
 	}
+	if err = s.checkOwner(ctx, entry.PublicKey); err != nil {
+		return nil, err
+	}
+	// проверяем версию до подписи: подпись старых данных клиента не совпадет с измененной записью
+	if req.ExpectedVersion != 0 && req.ExpectedVersion != entry.Version {
+		return nil, versionConflict(entry.Version)
+	}
+
+	expiresAt := entry.ExpiresAt
+	switch {
+	case req.ClearExpiresAt && req.ExpiresAt != nil:
+		return nil, status.Error(codes.InvalidArgument, "expires_at and clear_expires_at are mutually exclusive")
+	case req.ClearExpiresAt:
+		expiresAt = time.Time{}
+	case req.ExpiresAt != nil:
+		expiresAt, err = parseExpiresAt(req.ExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	publicN := big.Int{}
 	publicN.SetBytes(entry.PublicKey)
@@ -161,22 +402,58 @@ func (s server) Update(ctx context.Context, req *pb.UpdateRequest) (*emptypb.Emp
 	oldHash := sha256.Sum256(entry.Payload)
 	oldHash2 := sha256.Sum256(oldHash[:])
 
+	_, span := tracing.Start(ctx, "verify old signature")
 	err = rsa.VerifyPKCS1v15(&public, crypto.SHA256, oldHash2[:], req.SignOld)
+	tracing.End(span, err)
 	if err != nil {
+		metrics.SignVerifyFailures.WithLabelValues("update").Inc()
 		return nil, status.Errorf(codes.InvalidArgument, "sign verify failed: %s", err)
 	}
 
 	newHash := sha256.Sum256(req.Data)
 
+	_, span = tracing.Start(ctx, "verify new signature")
 	err = rsa.VerifyPKCS1v15(&public, crypto.SHA256, newHash[:], req.SignNew)
+	tracing.End(span, err)
 	if err != nil {
+		metrics.SignVerifyFailures.WithLabelValues("update").Inc()
 		return nil, status.Errorf(codes.InvalidArgument, "sign verify failed: %s", err)
 	}
 
-	err = s.s.Update(ctx, id, req.Data)
+	_, err = s.s.Update(ctx, id, req.Data, entry.Version, expiresAt)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "storage error on update: %s", err)
+		return nil, storageWriteError("update", err)
 	}
 
+	s.notify(ctx, storage.ChangeUpdated, id, entry.PublicKey)
+	s.audit(ctx, storage.AuditUpdate, id, entry.PublicKey)
+
 	return &emptypb.Empty{}, nil
 }
+
+// versionConflict - ошибка Aborted с текущей версией записи в деталях.
+func versionConflict(current int64) error {
+	st := status.Newf(codes.Aborted, "entry was changed, current version %d", current)
+
+	withDetails, err := st.WithDetails(&pb.VersionConflict{CurrentVersion: current})
+	if err != nil {
+		return st.Err()
+	}
+
+	return withDetails.Err()
+}
+
+// storageWriteError - преобразовать ошибку изменения записи в хранилище в grpc статус.
+// Запись проверена по версии, прочитанной вместе с подписанными данными,
+// поэтому конкурентное изменение между чтением и записью тоже дает конфликт.
+func storageWriteError(op string, err error) error {
+	var conflict *storage.VersionConflictError
+	if errors.As(err, &conflict) {
+		return versionConflict(conflict.Current)
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		return status.Error(codes.NotFound, "entry not found")
+	}
+
+	return status.Errorf(codes.Internal, "storage error on %s: %s", op, err)
+}
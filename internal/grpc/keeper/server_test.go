@@ -1 +1,245 @@
 package keeper
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+func TestStorageWriteError(t *testing.T) {
+	t.Run("version conflict", func(t *testing.T) {
+		err := storageWriteError("update", fmt.Errorf("ServerStorage Update: %w", &storage.VersionConflictError{Current: 4}))
+
+		st := status.Convert(err)
+		assert.Equal(t, codes.Aborted, st.Code())
+		require.Len(t, st.Details(), 1)
+		assert.Equal(t, int64(4), st.Details()[0].(*pb.VersionConflict).CurrentVersion)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		err := storageWriteError("delete", fmt.Errorf("ServerStorage Delete: %w", storage.ErrNotFound))
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("internal", func(t *testing.T) {
+		err := storageWriteError("delete", assert.AnError)
+		assert.Equal(t, codes.Internal, status.Code(err))
+	})
+}
+
+func TestPageToken(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		id := uuid.New()
+
+		got, err := parsePageToken(pageToken(id))
+		require.NoError(t, err)
+		assert.Equal(t, id, got)
+	})
+
+	t.Run("first page", func(t *testing.T) {
+		got, err := parsePageToken("")
+		require.NoError(t, err)
+		assert.Equal(t, uuid.Nil, got)
+	})
+
+	t.Run("wrong token", func(t *testing.T) {
+		_, err := parsePageToken("not a token")
+		assert.Error(t, err)
+
+		_, err = parsePageToken("AQID")
+		assert.Error(t, err)
+	})
+}
+
+func TestServer_GetAllPageSize(t *testing.T) {
+	s := NewServer(nil, 0, 0)
+
+	_, err := s.GetAll(context.Background(), &pb.GetAllRequest{PageSize: -1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = s.GetAll(context.Background(), &pb.GetAllRequest{PageToken: "wrong"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_MemoryStorage(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	sign := func(data []byte, twice bool) []byte {
+		hash := sha256.Sum256(data)
+		if twice {
+			hash = sha256.Sum256(hash[:])
+		}
+		s, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+		return s
+	}
+
+	ctx := context.Background()
+	s := NewServer(storage.NewMemoryStorage(10), 0, 0)
+
+	created, err := s.Create(ctx, &pb.CreateRequest{
+		PublicKey: key.PublicKey.N.Bytes(),
+		Data:      []byte("v1"),
+		Sign:      sign([]byte("v1"), false),
+	})
+	require.NoError(t, err)
+
+	_, err = s.Update(ctx, &pb.UpdateRequest{
+		Id:              created.Id,
+		Data:            []byte("v2"),
+		SignOld:         sign([]byte("v1"), true),
+		SignNew:         sign([]byte("v2"), false),
+		ExpectedVersion: 1,
+	})
+	require.NoError(t, err)
+
+	got, err := s.Get(ctx, &pb.GetRequest{Id: created.Id})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v2"), got.Data)
+	assert.Equal(t, int64(2), got.Version)
+
+	_, err = s.Delete(ctx, &pb.DeleteRequest{Id: created.Id, Sign: sign([]byte("v2"), true), ExpectedVersion: 1})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	_, err = s.Delete(ctx, &pb.DeleteRequest{Id: created.Id, Sign: sign([]byte("v2"), true)})
+	require.NoError(t, err)
+
+	_, err = s.Get(ctx, &pb.GetRequest{Id: created.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServer_ExpiresAt(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	sign := func(data []byte, twice bool) []byte {
+		hash := sha256.Sum256(data)
+		if twice {
+			hash = sha256.Sum256(hash[:])
+		}
+		s, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+		return s
+	}
+
+	ctx := context.Background()
+	s := NewServer(storage.NewMemoryStorage(10), 0, 0)
+	expiresAt := time.Now().Add(time.Hour)
+
+	_, err = s.Create(ctx, &pb.CreateRequest{
+		PublicKey: key.PublicKey.N.Bytes(),
+		Data:      []byte("v1"),
+		Sign:      sign([]byte("v1"), false),
+		ExpiresAt: timestamppb.New(time.Now().Add(-time.Minute)),
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	created, err := s.Create(ctx, &pb.CreateRequest{
+		PublicKey: key.PublicKey.N.Bytes(),
+		Data:      []byte("v1"),
+		Sign:      sign([]byte("v1"), false),
+		ExpiresAt: timestamppb.New(expiresAt),
+	})
+	require.NoError(t, err)
+
+	got, err := s.Get(ctx, &pb.GetRequest{Id: created.Id})
+	require.NoError(t, err)
+	assert.WithinDuration(t, expiresAt, got.ExpiresAt.AsTime(), 0)
+
+	update := func(data, old []byte, req *pb.UpdateRequest) error {
+		req.Id = created.Id
+		req.Data = data
+		req.SignOld = sign(old, true)
+		req.SignNew = sign(data, false)
+		_, updateErr := s.Update(ctx, req)
+		return updateErr
+	}
+
+	err = update([]byte("v2"), []byte("v1"), &pb.UpdateRequest{
+		ExpiresAt:      timestamppb.New(expiresAt),
+		ClearExpiresAt: true,
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// без expires_at время истечения не меняется
+	require.NoError(t, update([]byte("v2"), []byte("v1"), &pb.UpdateRequest{}))
+	got, err = s.Get(ctx, &pb.GetRequest{Id: created.Id})
+	require.NoError(t, err)
+	assert.WithinDuration(t, expiresAt, got.ExpiresAt.AsTime(), 0)
+
+	require.NoError(t, update([]byte("v3"), []byte("v2"), &pb.UpdateRequest{ClearExpiresAt: true}))
+	got, err = s.Get(ctx, &pb.GetRequest{Id: created.Id})
+	require.NoError(t, err)
+	assert.Nil(t, got.ExpiresAt)
+}
+
+func TestServer_IdempotencyKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	req := func(data []byte, idempotencyKey string) *pb.CreateRequest {
+		hash := sha256.Sum256(data)
+		sign, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+		return &pb.CreateRequest{
+			PublicKey:      key.PublicKey.N.Bytes(),
+			Data:           data,
+			Sign:           sign,
+			IdempotencyKey: idempotencyKey,
+		}
+	}
+
+	ctx := context.Background()
+	s := NewServer(storage.NewMemoryStorage(10), 0, time.Hour)
+
+	_, err = s.Create(ctx, req([]byte("v1"), strings.Repeat("k", maxIdempotencyKeyLen+1)))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	first, err := s.Create(ctx, req([]byte("v1"), "key"))
+	require.NoError(t, err)
+	again, err := s.Create(ctx, req([]byte("v1"), "key"))
+	require.NoError(t, err)
+	assert.Equal(t, first.Id, again.Id)
+
+	_, err = s.Create(ctx, req([]byte("v2"), "key"))
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	// время истечения входит в запрос: те же данные с другим сроком - другой запрос
+	expiring := req([]byte("v1"), "key")
+	expiring.ExpiresAt = timestamppb.New(time.Now().Add(time.Hour))
+	_, err = s.Create(ctx, expiring)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	expiring.IdempotencyKey = "expiring"
+	first, err = s.Create(ctx, expiring)
+	require.NoError(t, err)
+	again, err = s.Create(ctx, expiring)
+	require.NoError(t, err)
+	assert.Equal(t, first.Id, again.Id)
+
+	// без ключа каждый запрос создает новую запись
+	other, err := s.Create(ctx, req([]byte("v1"), ""))
+	require.NoError(t, err)
+	assert.NotEqual(t, first.Id, other.Id)
+
+	all, err := s.GetAll(ctx, &pb.GetAllRequest{PublicKey: key.PublicKey.N.Bytes()})
+	require.NoError(t, err)
+	assert.Len(t, all.Entries, 3)
+}
@@ -0,0 +1,132 @@
+package keeper
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+// ListTrashHash - хеш запроса записей в корзине: SHA256("trash" || publicKey || timestamp).
+func ListTrashHash(publicKey []byte, timestamp int64) [sha256.Size]byte {
+	return ownerRequestHash("trash", publicKey, timestamp)
+}
+
+// RestoreHash - хеш запроса восстановления записи из корзины:
+// SHA256("restore" || publicKey || timestamp || id || SHA256(data)).
+func RestoreHash(publicKey []byte, timestamp int64, id string, data []byte) [sha256.Size]byte {
+	return trashedHash("restore", publicKey, timestamp, id, data)
+}
+
+// PurgeHash - хеш запроса окончательного удаления записи из корзины:
+// SHA256("purge" || publicKey || timestamp || id || SHA256(data)).
+func PurgeHash(publicKey []byte, timestamp int64, id string, data []byte) [sha256.Size]byte {
+	return trashedHash("purge", publicKey, timestamp, id, data)
+}
+
+// trashedHash - хеш запроса к записи в корзине. Действие входит в хеш, поэтому подпись удаления
+// или другого действия с корзиной нельзя повторить для восстановления или окончательного удаления.
+func trashedHash(action string, publicKey []byte, timestamp int64, id string, data []byte) [sha256.Size]byte {
+	dataHash := sha256.Sum256(data)
+	return ownerRequestHash(action, publicKey, timestamp, []byte(id), dataHash[:])
+}
+
+// ListTrash - обработчик для получения записей пользователя в корзине.
+func (s server) ListTrash(ctx context.Context, req *pb.ListTrashRequest) (*pb.ListTrashResponse, error) {
+	hash := ListTrashHash(req.PublicKey, req.Timestamp)
+	err := verifyOwnerRequest(ctx, "trash", req.PublicKey, req.Timestamp, hash, req.Sign)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.s.ListTrash(ctx, req.PublicKey)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "storage error: %s", err)
+	}
+
+	e := make([]*pb.ListTrashResponse_Entry, 0, len(entries))
+	for _, entry := range entries {
+		e = append(e, &pb.ListTrashResponse_Entry{
+			Id:        entry.ID.String(),
+			Data:      entry.Payload,
+			DeletedAt: timestamppb.New(entry.DeletedAt),
+			PurgeAt:   timestamppb.New(entry.DeletedAt.Add(s.trashRetention)),
+		})
+	}
+
+	return &pb.ListTrashResponse{
+		Entries: e,
+	}, nil
+}
+
+// Restore - обработчик для восстановления записи из корзины.
+func (s server) Restore(ctx context.Context, req *pb.RestoreRequest) (*emptypb.Empty, error) {
+	id, publicKey, err := s.verifyTrashed(ctx, "restore", req.Id, req.Timestamp, req.Sign, RestoreHash)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.s.Restore(ctx, id)
+	if err != nil {
+		return nil, storageWriteError("restore", err)
+	}
+
+	s.notify(ctx, storage.ChangeCreated, id, publicKey)
+	s.audit(ctx, storage.AuditRestore, id, publicKey)
+
+	return &emptypb.Empty{}, nil
+}
+
+// Purge - обработчик для окончательного удаления записи из корзины.
+func (s server) Purge(ctx context.Context, req *pb.PurgeRequest) (*emptypb.Empty, error) {
+	id, publicKey, err := s.verifyTrashed(ctx, "purge", req.Id, req.Timestamp, req.Sign, PurgeHash)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.s.Purge(ctx, id)
+	if err != nil {
+		return nil, storageWriteError("purge", err)
+	}
+
+	s.audit(ctx, storage.AuditPurge, id, publicKey)
+
+	return &emptypb.Empty{}, nil
+}
+
+// verifyTrashed - найти запись в корзине и проверить время и подпись запроса с хешем hash.
+func (s server) verifyTrashed(ctx context.Context, op, rawID string, timestamp int64, sign []byte,
+	hash func(publicKey []byte, timestamp int64, id string, data []byte) [sha256.Size]byte,
+) (uuid.UUID, []byte, error) {
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		return uuid.Nil, nil, status.Errorf(codes.InvalidArgument, "unable to parse UUID: %s", err)
+	}
+
+	entry, err := s.s.GetTrashed(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return uuid.Nil, nil, status.Error(codes.NotFound, "entry not found in trash")
+	}
+	if err != nil {
+		return uuid.Nil, nil, status.Errorf(codes.Internal, "storage error on get: %s", err)
+	}
+	if err = s.checkOwner(ctx, entry.PublicKey); err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	h := hash(entry.PublicKey, timestamp, rawID, entry.Payload)
+	err = verifyOwnerRequest(ctx, op, entry.PublicKey, timestamp, h, sign)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	return id, entry.PublicKey, nil
+}
@@ -0,0 +1,180 @@
+package keeper
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+// brokenTrashStorage - хранилище, в котором чтение корзины завершается ошибкой.
+type brokenTrashStorage struct {
+	*storage.MemoryStorage
+}
+
+func (brokenTrashStorage) ListTrash(context.Context, []byte) ([]storage.Entry, error) {
+	return nil, errors.New("connection lost")
+}
+
+func (brokenTrashStorage) GetTrashed(context.Context, uuid.UUID) (storage.Entry, error) {
+	return storage.Entry{}, errors.New("connection lost")
+}
+
+func TestServer_Trash(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicKey := key.PublicKey.N.Bytes()
+
+	sign := func(data []byte, twice bool) []byte {
+		hash := sha256.Sum256(data)
+		if twice {
+			hash = sha256.Sum256(hash[:])
+		}
+		s, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+		return s
+	}
+
+	ctx := context.Background()
+	ms := storage.NewMemoryStorage(10)
+	s := NewServer(ms, time.Hour, 0)
+
+	trash := func(data []byte) string {
+		created, createErr := s.Create(ctx, &pb.CreateRequest{PublicKey: publicKey, Data: data, Sign: sign(data, false)})
+		require.NoError(t, createErr)
+		_, deleteErr := s.Delete(ctx, &pb.DeleteRequest{Id: created.Id, Sign: sign(data, true)})
+		require.NoError(t, deleteErr)
+		return created.Id
+	}
+
+	restored := trash([]byte("restored"))
+	purged := trash([]byte("purged"))
+
+	now := time.Now().Unix()
+	signHash := func(hash [sha256.Size]byte) []byte {
+		sig, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+		return sig
+	}
+	listTrash := func(s pb.KeeperServer) (*pb.ListTrashResponse, error) {
+		return s.ListTrash(ctx, &pb.ListTrashRequest{
+			PublicKey: publicKey,
+			Timestamp: now,
+			Sign:      signHash(ListTrashHash(publicKey, now)),
+		})
+	}
+	restore := func(s pb.KeeperServer, id string, data []byte) error {
+		_, restoreErr := s.Restore(ctx, &pb.RestoreRequest{
+			Id:        id,
+			Timestamp: now,
+			Sign:      signHash(RestoreHash(publicKey, now, id, data)),
+		})
+		return restoreErr
+	}
+	purge := func(s pb.KeeperServer, id string, data []byte) error {
+		_, purgeErr := s.Purge(ctx, &pb.PurgeRequest{
+			Id:        id,
+			Timestamp: now,
+			Sign:      signHash(PurgeHash(publicKey, now, id, data)),
+		})
+		return purgeErr
+	}
+
+	t.Run("list", func(t *testing.T) {
+		resp, listErr := listTrash(s)
+		require.NoError(t, listErr)
+		require.Len(t, resp.Entries, 2)
+		for _, e := range resp.Entries {
+			assert.Equal(t, time.Hour, e.PurgeAt.AsTime().Sub(e.DeletedAt.AsTime()))
+		}
+	})
+
+	t.Run("bad request", func(t *testing.T) {
+		_, listErr := s.ListTrash(ctx, &pb.ListTrashRequest{PublicKey: publicKey})
+		assert.Equal(t, codes.InvalidArgument, status.Code(listErr))
+		_, listErr = s.ListTrash(ctx, &pb.ListTrashRequest{
+			PublicKey: publicKey,
+			Timestamp: now,
+			Sign:      signHash(ListTrashHash(publicKey, now+1)),
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(listErr))
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(restore(s, "bad", []byte("restored"))))
+		assert.Equal(t, codes.NotFound, status.Code(restore(s, uuid.NewString(), []byte("restored"))))
+
+		// подпись удаления не подходит для восстановления и окончательного удаления
+		_, restoreErr := s.Restore(ctx, &pb.RestoreRequest{Id: restored, Sign: sign([]byte("restored"), true)})
+		assert.Equal(t, codes.InvalidArgument, status.Code(restoreErr))
+		_, purgeErr := s.Purge(ctx, &pb.PurgeRequest{Id: purged, Sign: sign([]byte("purged"), true)})
+		assert.Equal(t, codes.InvalidArgument, status.Code(purgeErr))
+
+		// подпись восстановления не подходит для окончательного удаления
+		_, purgeErr = s.Purge(ctx, &pb.PurgeRequest{
+			Id:        purged,
+			Timestamp: now,
+			Sign:      signHash(RestoreHash(publicKey, now, purged, []byte("purged"))),
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(purgeErr))
+
+		// подпись устаревшего запроса
+		old := now - int64(ownerRequestMaxSkew/time.Second) - 60
+		_, restoreErr = s.Restore(ctx, &pb.RestoreRequest{
+			Id:        restored,
+			Timestamp: old,
+			Sign:      signHash(RestoreHash(publicKey, old, restored, []byte("restored"))),
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(restoreErr))
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(purge(s, purged, []byte("restored"))))
+	})
+
+	t.Run("disabled owner", func(t *testing.T) {
+		require.NoError(t, ms.DisableOwner(ctx, storage.Fingerprint(publicKey)))
+		defer func() { require.NoError(t, ms.EnableOwner(ctx, storage.Fingerprint(publicKey))) }()
+
+		assert.Equal(t, codes.PermissionDenied, status.Code(restore(s, restored, []byte("restored"))))
+		assert.Equal(t, codes.PermissionDenied, status.Code(purge(s, purged, []byte("purged"))))
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		require.NoError(t, restore(s, restored, []byte("restored")))
+
+		got, getErr := s.Get(ctx, &pb.GetRequest{Id: restored})
+		require.NoError(t, getErr)
+		assert.Equal(t, []byte("restored"), got.Data)
+
+		assert.Equal(t, codes.NotFound, status.Code(restore(s, restored, []byte("restored"))))
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		require.NoError(t, purge(s, purged, []byte("purged")))
+
+		resp, listErr := listTrash(s)
+		require.NoError(t, listErr)
+		assert.Empty(t, resp.Entries)
+
+		assert.Equal(t, codes.NotFound, status.Code(purge(s, purged, []byte("purged"))))
+	})
+
+	t.Run("storage error", func(t *testing.T) {
+		broken := NewServer(brokenTrashStorage{ms}, time.Hour, 0)
+
+		_, listErr := listTrash(broken)
+		assert.Equal(t, codes.Internal, status.Code(listErr))
+
+		assert.Equal(t, codes.Internal, status.Code(restore(broken, restored, []byte("restored"))))
+	})
+}
@@ -0,0 +1,145 @@
+package keeper
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+// ClientIDKey - ключ метаданных запроса, в котором клиент передает свой ID.
+const ClientIDKey = "x-client-id"
+
+// watchBufferSize - события сверх буфера медленному подписчику не доставляются.
+const watchBufferSize = 16
+
+var changeTypes = map[storage.ChangeType]pb.WatchEvent_Type{
+	storage.ChangeCreated: pb.WatchEvent_CREATED,
+	storage.ChangeUpdated: pb.WatchEvent_UPDATED,
+	storage.ChangeDeleted: pb.WatchEvent_DELETED,
+}
+
+// WatchHash - хеш запроса подписки, который подписывает клиент: SHA256("watch" || publicKey || timestamp).
+func WatchHash(publicKey []byte, timestamp int64) [sha256.Size]byte {
+	return ownerRequestHash("watch", publicKey, timestamp)
+}
+
+// hub - рассылка событий изменений подписчикам Watch на этом экземпляре сервера.
+type hub struct {
+	mu   sync.Mutex
+	subs map[string]map[chan storage.Change]struct{}
+}
+
+func newHub() *hub {
+	return &hub{
+		subs: make(map[string]map[chan storage.Change]struct{}),
+	}
+}
+
+func (h *hub) subscribe(publicKey []byte) (<-chan storage.Change, func()) {
+	ch := make(chan storage.Change, watchBufferSize)
+	owner := string(publicKey)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subs[owner] == nil {
+		h.subs[owner] = make(map[chan storage.Change]struct{})
+	}
+	h.subs[owner][ch] = struct{}{}
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		delete(h.subs[owner], ch)
+		if len(h.subs[owner]) == 0 {
+			delete(h.subs, owner)
+		}
+	}
+}
+
+func (h *hub) publish(c storage.Change) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs[string(c.PublicKey)] {
+		select {
+		case ch <- c:
+		default:
+		}
+	}
+}
+
+// ListenChanges - получать события изменений от всех экземпляров сервера и рассылать их подписчикам Watch.
+// Блокируется до отмены ctx.
+func (s server) ListenChanges(ctx context.Context) error {
+	return s.s.Listen(ctx, s.hub.publish)
+}
+
+// Watch - обработчик для подписки на изменения записей владельца публичного ключа.
+func (s server) Watch(req *pb.WatchRequest, stream pb.Keeper_WatchServer) error {
+	ctx := stream.Context()
+
+	hash := WatchHash(req.PublicKey, req.Timestamp)
+	err := verifyOwnerRequest(ctx, "watch", req.PublicKey, req.Timestamp, hash, req.Sign)
+	if err != nil {
+		return err
+	}
+
+	changes, unsubscribe := s.hub.subscribe(req.PublicKey)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case c := <-changes:
+			if req.ClientId != "" && c.ClientID == req.ClientId {
+				continue
+			}
+
+			err = stream.Send(&pb.WatchEvent{
+				Type:     changeTypes[c.Type],
+				Id:       c.ID.String(),
+				ClientId: c.ClientID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// notify - разослать событие изменения записи. Изменение уже сохранено,
+// поэтому ошибка рассылки не возвращается клиенту, а только попадает в трассировку.
+func (s server) notify(ctx context.Context, t storage.ChangeType, id uuid.UUID, publicKey []byte) {
+	ctx, span := tracing.Start(ctx, "notify")
+	err := s.s.Notify(ctx, storage.Change{
+		Type:      t,
+		ID:        id,
+		PublicKey: publicKey,
+		ClientID:  clientID(ctx),
+	})
+	tracing.End(span, err)
+}
+
+func clientID(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	v := md.Get(ClientIDKey)
+	if len(v) == 0 {
+		return ""
+	}
+
+	return v[0]
+}
@@ -0,0 +1,145 @@
+package keeper
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/storage"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+type watchStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	events chan *pb.WatchEvent
+}
+
+func (w *watchStream) Context() context.Context {
+	return w.ctx
+}
+
+func (w *watchStream) Send(e *pb.WatchEvent) error {
+	w.events <- e
+	return nil
+}
+
+func TestHub(t *testing.T) {
+	h := newHub()
+	owner := []byte{1, 2, 3}
+
+	ch, unsubscribe := h.subscribe(owner)
+	other, unsubscribeOther := h.subscribe([]byte{4, 5, 6})
+	defer unsubscribeOther()
+
+	c := storage.Change{Type: storage.ChangeCreated, ID: uuid.New(), PublicKey: owner}
+	h.publish(c)
+
+	assert.Equal(t, c, <-ch)
+	assert.Empty(t, other)
+
+	t.Run("slow subscriber does not block", func(t *testing.T) {
+		for i := 0; i < watchBufferSize*2; i++ {
+			h.publish(c)
+		}
+		assert.Len(t, ch, watchBufferSize)
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		unsubscribe()
+		assert.NotContains(t, h.subs, string(owner))
+	})
+}
+
+func TestServer_Watch(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicKey := key.PublicKey.N.Bytes()
+
+	sign := func(t *testing.T, timestamp int64) []byte {
+		hash := WatchHash(publicKey, timestamp)
+		s, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+		return s
+	}
+
+	t.Run("timestamp too old", func(t *testing.T) {
+		ts := time.Now().Add(-time.Hour).Unix()
+		s := NewServer(nil, 0, 0)
+
+		err = s.Watch(&pb.WatchRequest{
+			PublicKey: publicKey,
+			Timestamp: ts,
+			Sign:      sign(t, ts),
+		}, &watchStream{ctx: context.Background()})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("wrong sign", func(t *testing.T) {
+		ts := time.Now().Unix()
+		s := NewServer(nil, 0, 0)
+
+		err = s.Watch(&pb.WatchRequest{
+			PublicKey: publicKey,
+			Timestamp: ts,
+			Sign:      sign(t, ts+1),
+		}, &watchStream{ctx: context.Background()})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("events", func(t *testing.T) {
+		ts := time.Now().Unix()
+		s := NewServer(nil, 0, 0)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		stream := &watchStream{ctx: ctx, events: make(chan *pb.WatchEvent, 1)}
+		done := make(chan error)
+		go func() {
+			done <- s.Watch(&pb.WatchRequest{
+				PublicKey: publicKey,
+				Timestamp: ts,
+				ClientId:  "self",
+				Sign:      sign(t, ts),
+			}, stream)
+		}()
+
+		require.Eventually(t, func() bool {
+			s.hub.mu.Lock()
+			defer s.hub.mu.Unlock()
+			return len(s.hub.subs[string(publicKey)]) == 1
+		}, time.Second, 10*time.Millisecond)
+
+		own := storage.Change{Type: storage.ChangeUpdated, ID: uuid.New(), PublicKey: publicKey, ClientID: "self"}
+		foreign := storage.Change{Type: storage.ChangeDeleted, ID: uuid.New(), PublicKey: publicKey, ClientID: "other"}
+		s.hub.publish(own)
+		s.hub.publish(foreign)
+
+		e := <-stream.events
+		assert.Equal(t, pb.WatchEvent_DELETED, e.Type)
+		assert.Equal(t, foreign.ID.String(), e.Id)
+		assert.Equal(t, "other", e.ClientId)
+
+		cancel()
+		assert.NoError(t, <-done)
+	})
+}
+
+func TestClientID(t *testing.T) {
+	assert.Equal(t, "", clientID(context.Background()))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(ClientIDKey, "abc"))
+	assert.Equal(t, "abc", clientID(ctx))
+}
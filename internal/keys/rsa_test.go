@@ -3,15 +3,25 @@ package keys
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// chdirTemp - перейти во временный каталог теста: GenRSAKey сохраняет ключ в текущий каталог.
+func chdirTemp(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
+
 func TestRSA(t *testing.T) {
+	chdirTemp(t)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -34,6 +44,8 @@ func TestGenRSAKey(t *testing.T) {
 	})
 
 	t.Run("wrong key size", func(t *testing.T) {
+		chdirTemp(t)
+
 		oldKeySize := keySize
 		keySize = 0
 		defer func() { keySize = oldKeySize }()
@@ -67,7 +79,7 @@ func TestLoadRSAKey(t *testing.T) {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
-		fileName := uuid.New().String() + ".pem"
+		fileName := filepath.Join(t.TempDir(), "key.pem")
 		err := os.WriteFile(fileName, []byte{}, 0o600)
 		require.NoError(t, err)
 
@@ -84,7 +96,7 @@ dGVzdA==
 -----END RSA PRIVATE KEY-----
 `
 
-		fileName := uuid.New().String() + ".pem"
+		fileName := filepath.Join(t.TempDir(), "key.pem")
 		err := os.WriteFile(fileName, []byte(content), 0o600)
 		require.NoError(t, err)
 
@@ -0,0 +1,123 @@
+// Package metrics содержит prometheus метрики сервера GophKeeper.
+package metrics
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gophkeeper"
+
+// Registry - реестр, в котором зарегистрированы все метрики сервера.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// RPCRequests - количество обработанных RPC по методу и статус-коду.
+	RPCRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "requests_total",
+		Help:      "Total number of handled RPCs by method and status code.",
+	}, []string{"method", "code"})
+
+	// RPCDuration - время обработки RPC по методу и статус-коду.
+	RPCDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of handled RPCs by method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	// SignVerifyFailures - количество неудачных проверок подписи по методу.
+	SignVerifyFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "keeper",
+		Name:      "sign_verify_failures_total",
+		Help:      "Total number of failed signature verifications by method.",
+	}, []string{"method"})
+
+	// QueryDuration - время выполнения запросов к базе данных по названию запроса.
+	QueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "query_duration_seconds",
+		Help:      "Latency of storage queries by query name.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"query"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RPCRequests,
+		RPCDuration,
+		SignVerifyFailures,
+		QueryDuration,
+	)
+}
+
+// ObserveQuery записывает время выполнения запроса к базе данных, начатого в start.
+func ObserveQuery(query string, start time.Time) {
+	QueryDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+}
+
+// DBStatser - источник статистики пула соединений с базой данных.
+type DBStatser interface {
+	Stats() sql.DBStats
+}
+
+// RegisterDBStats регистрирует метрики пула соединений с базой данных.
+func RegisterDBStats(s DBStatser) error {
+	gauge := func(name, help string, f func(st sql.DBStats) float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "db",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return f(s.Stats()) })
+	}
+	counter := func(name, help string, f func(st sql.DBStats) float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "db",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return f(s.Stats()) })
+	}
+
+	cs := []prometheus.Collector{
+		gauge("max_open_connections", "Maximum number of open connections to the database.",
+			func(st sql.DBStats) float64 { return float64(st.MaxOpenConnections) }),
+		gauge("open_connections", "The number of established connections both in use and idle.",
+			func(st sql.DBStats) float64 { return float64(st.OpenConnections) }),
+		gauge("in_use_connections", "The number of connections currently in use.",
+			func(st sql.DBStats) float64 { return float64(st.InUse) }),
+		gauge("idle_connections", "The number of idle connections.",
+			func(st sql.DBStats) float64 { return float64(st.Idle) }),
+		counter("wait_count_total", "The total number of connections waited for.",
+			func(st sql.DBStats) float64 { return float64(st.WaitCount) }),
+		counter("wait_duration_seconds_total", "The total time blocked waiting for a new connection.",
+			func(st sql.DBStats) float64 { return st.WaitDuration.Seconds() }),
+	}
+
+	for _, c := range cs {
+		if err := Registry.Register(c); err != nil {
+			return fmt.Errorf("metrics RegisterDBStats: register: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Handler - http обработчик, который отдает метрики в формате prometheus.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type statser struct {
+	stats sql.DBStats
+}
+
+func (s statser) Stats() sql.DBStats {
+	return s.stats
+}
+
+func TestObserveQuery(t *testing.T) {
+	before := testutil.CollectAndCount(QueryDuration)
+
+	ObserveQuery("test_query", time.Now())
+
+	assert.Equal(t, before+1, testutil.CollectAndCount(QueryDuration))
+}
+
+func TestRegisterDBStats(t *testing.T) {
+	s := statser{stats: sql.DBStats{MaxOpenConnections: 10, OpenConnections: 3, InUse: 2, Idle: 1}}
+
+	err := RegisterDBStats(s)
+	require.NoError(t, err)
+
+	t.Run("already registered", func(t *testing.T) {
+		err = RegisterDBStats(s)
+		assert.Error(t, err)
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		body := w.Body.String()
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, strings.Contains(body, "gophkeeper_db_max_open_connections 10"))
+		assert.True(t, strings.Contains(body, "gophkeeper_db_in_use_connections 2"))
+	})
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/chzyer/readline"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+// deleteAccountConfirmation - фраза, которую пользователь вводит для подтверждения удаления аккаунта.
+const deleteAccountConfirmation = "delete my account"
+
+// ErrDeleteAccountCancelled - пользователь не подтвердил удаление аккаунта.
+var ErrDeleteAccountCancelled = errors.New("account deletion cancelled")
+
+// DeleteAccount - после подтверждения пользователем удалить все его данные на сервере.
+func (s Service) DeleteAccount(ctx context.Context, l *readline.Instance) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("service Service DeleteAccount: context: %w", err)
+	}
+
+	ctx, span := tracing.Start(ctx, "Service DeleteAccount")
+	defer span.End()
+
+	publicKey := s.key.PublicKey.N.Bytes()
+
+	count, err := s.countEntries(ctx)
+	if err != nil {
+		return "", fmt.Errorf("service Service DeleteAccount: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(l.Stdout(),
+		"All %d entries, files, trash, version history and audit log will be permanently deleted.\n", count)
+	l.SetPrompt(fmt.Sprintf("Type %q to confirm: ", deleteAccountConfirmation))
+	answer, err := l.Readline()
+	if err != nil {
+		return "", fmt.Errorf("service Service DeleteAccount: readline: %w", err)
+	}
+	if strings.TrimSpace(answer) != deleteAccountConfirmation {
+		return "", fmt.Errorf("service Service DeleteAccount: %w", ErrDeleteAccountCancelled)
+	}
+
+	challengeResp, err := s.c.AccountChallenge(ctx, &pb.AccountChallengeRequest{
+		PublicKey: publicKey,
+	})
+	if err != nil {
+		return "", fmt.Errorf("service Service DeleteAccount: client challenge: %w", err)
+	}
+
+	hash := keeper.DeleteAccountHash(publicKey, challengeResp.Challenge)
+
+	_, cryptoSpan := tracing.Start(ctx, "sign")
+	sign, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+	tracing.End(cryptoSpan, err)
+	if err != nil {
+		return "", fmt.Errorf("service Service DeleteAccount: sign: %w", err)
+	}
+
+	resp, err := s.c.DeleteAccount(ctx, &pb.DeleteAccountRequest{
+		PublicKey: publicKey,
+		Challenge: challengeResp.Challenge,
+		Sign:      sign,
+	})
+	if err != nil {
+		return "", fmt.Errorf("service Service DeleteAccount: client delete account: %w", err)
+	}
+
+	return fmt.Sprintf("Account deleted, %d entries removed", resp.DeletedEntries), nil
+}
+
+// countEntries - количество записей пользователя.
+func (s Service) countEntries(ctx context.Context) (int, error) {
+	var count int
+	var pageToken string
+	for {
+		resp, err := s.c.GetAll(ctx, &pb.GetAllRequest{
+			PublicKey: s.key.PublicKey.N.Bytes(),
+			PageSize:  1000,
+			PageToken: pageToken,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("client get all: %w", err)
+		}
+
+		count += len(resp.Entries)
+		pageToken = resp.NextPageToken
+		if pageToken == "" {
+			return count, nil
+		}
+	}
+}
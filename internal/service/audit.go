@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+// auditPageSize - сколько записей журнала аудита показывать за раз.
+const auditPageSize = 20
+
+// ErrNoMoreAudit - в журнале аудита больше нет записей.
+var ErrNoMoreAudit = errors.New("no more audit records")
+
+// auditPager - токен следующей страницы журнала аудита.
+type auditPager struct {
+	mu    sync.Mutex
+	token string
+}
+
+// Audit - получить страницу журнала аудита, новые записи первыми.
+// Если more равен false, возвращается первая страница, иначе следующая после предыдущего вызова.
+func (s Service) Audit(ctx context.Context, more bool) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("service Service Audit: context: %w", err)
+	}
+
+	ctx, span := tracing.Start(ctx, "Service Audit")
+	defer span.End()
+
+	s.audit.mu.Lock()
+	defer s.audit.mu.Unlock()
+
+	if !more {
+		s.audit.token = ""
+	} else if s.audit.token == "" {
+		return "", fmt.Errorf("service Service Audit: %w", ErrNoMoreAudit)
+	}
+
+	publicKey := s.key.PublicKey.N.Bytes()
+	timestamp := time.Now().Unix()
+	hash := keeper.AuditHash(publicKey, timestamp)
+
+	_, cryptoSpan := tracing.Start(ctx, "sign")
+	sign, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+	tracing.End(cryptoSpan, err)
+	if err != nil {
+		return "", fmt.Errorf("service Service Audit: sign: %w", err)
+	}
+
+	resp, err := s.c.ListAudit(ctx, &pb.ListAuditRequest{
+		PublicKey: publicKey,
+		Timestamp: timestamp,
+		Sign:      sign,
+		PageSize:  auditPageSize,
+		PageToken: s.audit.token,
+	})
+	if err != nil {
+		return "", fmt.Errorf("service Service Audit: client: %w", err)
+	}
+
+	s.audit.token = resp.NextPageToken
+
+	b := strings.Builder{}
+	for _, r := range resp.Records {
+		entryID := r.EntryId
+		if entryID == "" {
+			entryID = "-"
+		}
+		clientVersion := r.ClientVersion
+		if clientVersion == "" {
+			clientVersion = "-"
+		}
+		_, _ = fmt.Fprintf(&b, "%s\t%s\t%s\t%s\t%s\n",
+			r.Time.AsTime().Local().Format(time.DateTime), r.Op, entryID, r.Peer, clientVersion)
+	}
+	if s.audit.token != "" {
+		b.WriteString("more records: audit more\n")
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+// ErrDeviceRevoked - владелец отозвал устройство, и сервер не регистрирует его заново.
+var ErrDeviceRevoked = errors.New("device is revoked")
+
+// RegisterDevice - зарегистрировать это устройство и вернуть его ID и токен сессии.
+// previousID - ID прежней регистрации устройства или пустая строка для нового устройства,
+// сервер отказывает, если прежняя регистрация отозвана.
+func (s Service) RegisterDevice(ctx context.Context,
+	previousID, name string,
+) (deviceID, sessionToken string, err error) {
+	if err = ctx.Err(); err != nil {
+		return "", "", fmt.Errorf("service Service RegisterDevice: context: %w", err)
+	}
+
+	ctx, span := tracing.Start(ctx, "Service RegisterDevice")
+	defer span.End()
+
+	publicKey := s.key.PublicKey.N.Bytes()
+	timestamp := time.Now().Unix()
+	hash := keeper.RegisterDeviceHash(publicKey, timestamp, previousID, name)
+
+	sign, err := s.signHash(ctx, hash[:])
+	if err != nil {
+		return "", "", fmt.Errorf("service Service RegisterDevice: %w", err)
+	}
+
+	resp, err := s.c.RegisterDevice(ctx, &pb.RegisterDeviceRequest{
+		PublicKey: publicKey,
+		Timestamp: timestamp,
+		Name:      name,
+		Sign:      sign,
+		DeviceId:  previousID,
+	})
+	if status.Code(err) == codes.FailedPrecondition {
+		return "", "", fmt.Errorf("service Service RegisterDevice: %w: %s", ErrDeviceRevoked, previousID)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("service Service RegisterDevice: client: %w", err)
+	}
+
+	return resp.DeviceId, resp.SessionToken, nil
+}
+
+// Devices - получить устройства пользователя.
+func (s Service) Devices(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("service Service Devices: context: %w", err)
+	}
+
+	ctx, span := tracing.Start(ctx, "Service Devices")
+	defer span.End()
+
+	resp, err := s.listDevices(ctx)
+	if err != nil {
+		return "", fmt.Errorf("service Service Devices: %w", err)
+	}
+
+	b := strings.Builder{}
+	for _, d := range resp.Devices {
+		state := "active"
+		if d.RevokedAt != nil {
+			state = "revoked " + d.RevokedAt.AsTime().Local().Format(time.DateTime)
+		}
+		current := ""
+		if d.Id == s.c.DeviceID() {
+			current = "\t(this device)"
+		}
+		_, _ = fmt.Fprintf(&b, "%s\t%s\tlast seen %s from %s\t%s%s\n",
+			d.Id, d.Name, d.LastSeenAt.AsTime().Local().Format(time.DateTime), d.LastIp, state, current)
+	}
+
+	return b.String(), nil
+}
+
+// DeviceActive - зарегистрировано ли устройство id на сервере и не отозвано ли оно.
+func (s Service) DeviceActive(ctx context.Context, id string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, fmt.Errorf("service Service DeviceActive: context: %w", err)
+	}
+
+	ctx, span := tracing.Start(ctx, "Service DeviceActive")
+	defer span.End()
+
+	resp, err := s.listDevices(ctx)
+	if err != nil {
+		return false, fmt.Errorf("service Service DeviceActive: %w", err)
+	}
+
+	for _, d := range resp.Devices {
+		if d.Id == id {
+			return d.RevokedAt == nil, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (s Service) listDevices(ctx context.Context) (*pb.ListDevicesResponse, error) {
+	publicKey := s.key.PublicKey.N.Bytes()
+	timestamp := time.Now().Unix()
+	hash := keeper.ListDevicesHash(publicKey, timestamp)
+
+	sign, err := s.signHash(ctx, hash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.c.ListDevices(ctx, &pb.ListDevicesRequest{
+		PublicKey: publicKey,
+		Timestamp: timestamp,
+		Sign:      sign,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("client: %w", err)
+	}
+
+	return resp, nil
+}
+
+// RevokeDevice - отозвать устройство пользователя: его токен сессии перестанет действовать.
+func (s Service) RevokeDevice(ctx context.Context, id string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("service Service RevokeDevice: context: %w", err)
+	}
+
+	ctx, span := tracing.Start(ctx, "Service RevokeDevice")
+	defer span.End()
+
+	publicKey := s.key.PublicKey.N.Bytes()
+	timestamp := time.Now().Unix()
+	hash := keeper.RevokeDeviceHash(publicKey, timestamp, id)
+
+	sign, err := s.signHash(ctx, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("service Service RevokeDevice: %w", err)
+	}
+
+	_, err = s.c.RevokeDevice(ctx, &pb.RevokeDeviceRequest{
+		PublicKey: publicKey,
+		Timestamp: timestamp,
+		DeviceId:  id,
+		Sign:      sign,
+	})
+	if err != nil {
+		return "", fmt.Errorf("service Service RevokeDevice: client: %w", err)
+	}
+
+	return fmt.Sprintf("Device %s revoked", id), nil
+}
+
+func (s Service) signHash(ctx context.Context, hash []byte) ([]byte, error) {
+	_, cryptoSpan := tracing.Start(ctx, "sign")
+	sign, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash)
+	tracing.End(cryptoSpan, err)
+	if err != nil {
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+
+	return sign, nil
+}
@@ -0,0 +1,410 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/chzyer/readline"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/dataverse"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+const (
+	fileChunkSize    = 1 << 20 // размер части файла до шифрования
+	fileKeySize      = 32      // AES-256
+	transferAttempts = 3       // количество попыток загрузки или скачивания файла
+)
+
+// Upload - загрузить большой файл на сервер по частям.
+// Если соединение оборвалось, загрузка продолжается с тех частей, которые сервер еще не получил.
+func (s Service) Upload(ctx context.Context, l *readline.Instance) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("service Service Upload: context: %w", err)
+	}
+
+	ctx, span := tracing.Start(ctx, "Service Upload")
+	defer span.End()
+
+	fd, err := dataverse.GenFile(l)
+	if err != nil {
+		return "", fmt.Errorf("service Service Upload: gen file: %w", err)
+	}
+	fd.ChunkSize = fileChunkSize
+	fd.Key = make([]byte, fileKeySize)
+	_, err = rand.Read(fd.Key)
+	if err != nil {
+		return "", fmt.Errorf("service Service Upload: gen key: %w", err)
+	}
+
+	e, err := fd.DatabaseEntry()
+	if err != nil {
+		return "", fmt.Errorf("service Service Upload: database entry: %w", err)
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", fmt.Errorf("service Service Upload: marshal json: %w", err)
+	}
+
+	_, cryptoSpan := tracing.Start(ctx, "encrypt")
+	encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, &s.key.PublicKey, data)
+	tracing.End(cryptoSpan, err)
+	if err != nil {
+		return "", fmt.Errorf("service Service Upload: encrypt: %w", err)
+	}
+
+	uploadID := uuid.New()
+	received := map[uint32]bool{}
+	for attempt := 1; ; attempt++ {
+		var id string
+		id, err = s.uploadOnce(ctx, uploadID, fd, encrypted, received)
+		if err == nil {
+			return fmt.Sprintf("Entry ID: %s", id), nil
+		}
+		if attempt == transferAttempts || !retryable(err) {
+			return "", fmt.Errorf("service Service Upload: %w", err)
+		}
+
+		err = wait(ctx, attempt)
+		if err != nil {
+			return "", fmt.Errorf("service Service Upload: %w", err)
+		}
+
+		var resp *pb.UploadStatusResponse
+		resp, err = s.c.UploadStatus(ctx, &pb.UploadStatusRequest{
+			UploadId:  uploadID.String(),
+			PublicKey: s.key.PublicKey.N.Bytes(),
+		})
+		if err != nil {
+			continue
+		}
+		received = make(map[uint32]bool, len(resp.Received))
+		for _, idx := range resp.Received {
+			received[idx] = true
+		}
+	}
+}
+
+func (s Service) uploadOnce(ctx context.Context, uploadID uuid.UUID, fd dataverse.FileData,
+	data []byte, skip map[uint32]bool,
+) (string, error) {
+	f, err := os.Open(fd.Filename)
+	if err != nil {
+		return "", fmt.Errorf("open file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	aead, err := newFileCipher(fd.Key)
+	if err != nil {
+		return "", err
+	}
+
+	stream, err := s.c.Upload(ctx)
+	if err != nil {
+		return "", fmt.Errorf("client upload: %w", err)
+	}
+
+	send := func(req *pb.UploadRequest) error {
+		sendErr := stream.Send(req)
+		if errors.Is(sendErr, io.EOF) {
+			// сервер закрыл поток, настоящая ошибка придет в ответе
+			_, sendErr = stream.CloseAndRecv()
+		}
+		if sendErr != nil {
+			return fmt.Errorf("client upload send: %w", sendErr)
+		}
+		return nil
+	}
+
+	err = send(&pb.UploadRequest{Payload: &pb.UploadRequest_Header{Header: &pb.UploadHeader{
+		PublicKey: s.key.PublicKey.N.Bytes(),
+		UploadId:  uploadID.String(),
+	}}})
+	if err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, fd.ChunkSize)
+	hashes := make([][]byte, 0, fd.Size/int64(fd.ChunkSize)+1)
+	for idx := uint32(0); ; idx++ {
+		n, readErr := io.ReadFull(f, buf)
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil && !errors.Is(readErr, io.ErrUnexpectedEOF) {
+			return "", fmt.Errorf("read file: %w", readErr)
+		}
+
+		chunk := encryptChunk(aead, idx, buf[:n])
+		hash := sha256.Sum256(chunk)
+		hashes = append(hashes, hash[:])
+
+		if !skip[idx] {
+			err = send(&pb.UploadRequest{Payload: &pb.UploadRequest_Chunk{Chunk: &pb.Chunk{
+				Index: idx,
+				Data:  chunk,
+			}}})
+			if err != nil {
+				return "", err
+			}
+		}
+
+		if readErr != nil {
+			break
+		}
+	}
+
+	hash := keeper.ManifestHash(data, hashes)
+	sign, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("sign manifest: %w", err)
+	}
+
+	err = send(&pb.UploadRequest{Payload: &pb.UploadRequest_Manifest{Manifest: &pb.Manifest{
+		Data:        data,
+		ChunkHashes: hashes,
+		Sign:        sign,
+	}}})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return "", fmt.Errorf("client upload close: %w", err)
+	}
+
+	return resp.Id, nil
+}
+
+type download struct {
+	manifest *pb.Manifest
+	aead     cipher.AEAD
+	out      *os.File
+	file     dataverse.FileData
+	id       string
+	next     uint32
+	written  int64
+}
+
+// Download - скачать большой файл с сервера по частям и сохранить его под исходным именем.
+// Если соединение оборвалось, скачивание продолжается со следующей части.
+func (s Service) Download(ctx context.Context, id string) (_ string, err error) {
+	if err = ctx.Err(); err != nil {
+		return "", fmt.Errorf("service Service Download: context: %w", err)
+	}
+
+	ctx, span := tracing.Start(ctx, "Service Download")
+	defer span.End()
+
+	d := &download{id: id}
+	defer func() {
+		if d.out == nil {
+			return
+		}
+		_ = d.out.Close()
+		if err != nil {
+			_ = os.Remove(d.out.Name())
+		}
+	}()
+
+	for attempt := 1; ; attempt++ {
+		err = s.downloadOnce(ctx, d)
+		if err == nil {
+			break
+		}
+		if attempt == transferAttempts || !retryable(err) {
+			return "", fmt.Errorf("service Service Download: %w", err)
+		}
+
+		err = wait(ctx, attempt)
+		if err != nil {
+			return "", fmt.Errorf("service Service Download: %w", err)
+		}
+	}
+
+	if d.written != d.file.Size {
+		err = fmt.Errorf("service Service Download: size mismatch: got %d, want %d", d.written, d.file.Size)
+		return "", err
+	}
+
+	return fmt.Sprintf("File `%s` successfully saved", d.file.Filename), nil
+}
+
+func (s Service) downloadOnce(ctx context.Context, d *download) error {
+	stream, err := s.c.Download(ctx, &pb.DownloadRequest{
+		Id:        d.id,
+		FromChunk: d.next,
+	})
+	if err != nil {
+		return fmt.Errorf("client download: %w", err)
+	}
+
+	resp, err := stream.Recv()
+	if err != nil {
+		return fmt.Errorf("client download recv manifest: %w", err)
+	}
+	m := resp.GetManifest()
+	if m == nil {
+		return errors.New("first message is not a manifest")
+	}
+
+	if d.manifest == nil {
+		err = s.openDownload(ctx, d, m)
+		if err != nil {
+			return err
+		}
+	} else if !proto.Equal(d.manifest, m) {
+		return errors.New("manifest changed during download")
+	}
+
+	for {
+		resp, err = stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("client download recv chunk: %w", err)
+		}
+
+		c := resp.GetChunk()
+		if c == nil || c.Index != d.next || int(c.Index) >= len(m.ChunkHashes) {
+			return errors.New("unexpected chunk")
+		}
+
+		hash := sha256.Sum256(c.Data)
+		if string(hash[:]) != string(m.ChunkHashes[c.Index]) {
+			return fmt.Errorf("chunk %d hash mismatch", c.Index)
+		}
+
+		plain, decryptErr := decryptChunk(d.aead, c.Index, c.Data)
+		if decryptErr != nil {
+			return fmt.Errorf("chunk %d: %w", c.Index, decryptErr)
+		}
+
+		n, writeErr := d.out.Write(plain)
+		if writeErr != nil {
+			return fmt.Errorf("write file: %w", writeErr)
+		}
+		d.written += int64(n)
+		d.next++
+	}
+
+	if int(d.next) != len(m.ChunkHashes) {
+		return fmt.Errorf("download incomplete: received %d of %d chunks", d.next, len(m.ChunkHashes))
+	}
+
+	return nil
+}
+
+func (s Service) openDownload(ctx context.Context, d *download, m *pb.Manifest) error {
+	hash := keeper.ManifestHash(m.Data, m.ChunkHashes)
+	err := rsa.VerifyPKCS1v15(&s.key.PublicKey, crypto.SHA256, hash[:], m.Sign)
+	if err != nil {
+		return fmt.Errorf("manifest sign verify: %w", err)
+	}
+
+	_, cryptoSpan := tracing.Start(ctx, "decrypt")
+	decrypted, err := rsa.DecryptPKCS1v15(rand.Reader, s.key, m.Data)
+	tracing.End(cryptoSpan, err)
+	if err != nil {
+		return fmt.Errorf("decrypt: %w", err)
+	}
+
+	e, err := dataverse.ParseEntry(decrypted)
+	if err != nil {
+		return fmt.Errorf("parse entry: %w", err)
+	}
+	fd, ok := e.(dataverse.FileData)
+	if !ok {
+		return errors.New("entry is not a file")
+	}
+
+	d.aead, err = newFileCipher(fd.Key)
+	if err != nil {
+		return err
+	}
+
+	d.out, err = os.OpenFile(fd.Filename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+
+	d.manifest = m
+	d.file = fd
+
+	return nil
+}
+
+func newFileCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("new cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("new gcm: %w", err)
+	}
+
+	return aead, nil
+}
+
+// chunkNonce - nonce для части файла. Ключ у каждого файла свой, поэтому индекс части
+// можно использовать как nonce, а повторное шифрование части дает тот же шифротекст.
+func chunkNonce(aead cipher.AEAD, idx uint32) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint32(nonce[len(nonce)-4:], idx)
+	return nonce
+}
+
+func encryptChunk(aead cipher.AEAD, idx uint32, plain []byte) []byte {
+	return aead.Seal(nil, chunkNonce(aead, idx), plain, nil)
+}
+
+func decryptChunk(aead cipher.AEAD, idx uint32, data []byte) ([]byte, error) {
+	plain, err := aead.Open(nil, chunkNonce(aead, idx), data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt chunk: %w", err)
+	}
+	return plain, nil
+}
+
+func retryable(err error) bool {
+	switch status.Code(errors.Unwrap(err)) {
+	case codes.Unavailable, codes.Aborted, codes.Internal, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+func wait(ctx context.Context, attempt int) error {
+	t := time.NewTimer(time.Duration(attempt) * time.Second)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("wait retry: %w", ctx.Err())
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestChunkCrypto(t *testing.T) {
+	aead, err := newFileCipher(make([]byte, fileKeySize))
+	require.NoError(t, err)
+
+	plain := []byte("chunk content")
+
+	t.Run("deterministic", func(t *testing.T) {
+		assert.Equal(t, encryptChunk(aead, 1, plain), encryptChunk(aead, 1, plain))
+		assert.NotEqual(t, encryptChunk(aead, 1, plain), encryptChunk(aead, 2, plain))
+	})
+
+	t.Run("decrypt", func(t *testing.T) {
+		got, err := decryptChunk(aead, 5, encryptChunk(aead, 5, plain))
+		require.NoError(t, err)
+		assert.Equal(t, plain, got)
+	})
+
+	t.Run("wrong index", func(t *testing.T) {
+		_, err := decryptChunk(aead, 6, encryptChunk(aead, 5, plain))
+		assert.Error(t, err)
+	})
+
+	t.Run("wrong key size", func(t *testing.T) {
+		_, err := newFileCipher([]byte{1, 2, 3})
+		assert.Error(t, err)
+	})
+}
+
+func TestRetryable(t *testing.T) {
+	wrap := func(err error) error {
+		return fmt.Errorf("client upload: %w", err)
+	}
+
+	assert.True(t, retryable(wrap(status.Error(codes.Unavailable, "connection lost"))))
+	assert.False(t, retryable(wrap(status.Error(codes.InvalidArgument, "sign verify failed"))))
+	assert.False(t, retryable(wrap(errors.New("open file: not found"))))
+}
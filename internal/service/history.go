@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/dataverse"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+// ErrVersionNotFound - версии нет в истории записи.
+var ErrVersionNotFound = errors.New("version not found in entry history")
+
+// History - получить и расшифровать предыдущие версии записи.
+func (s Service) History(ctx context.Context, id string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("service Service History: context: %w", err)
+	}
+
+	ctx, span := tracing.Start(ctx, "Service History")
+	defer span.End()
+
+	resp, err := s.history(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("service Service History: %w", err)
+	}
+	if len(resp.Versions) == 0 {
+		return "No previous versions", nil
+	}
+
+	_, cryptoSpan := tracing.Start(ctx, "decrypt")
+	defer cryptoSpan.End()
+
+	b := strings.Builder{}
+	for _, v := range resp.Versions {
+		_, _ = fmt.Fprintf(&b, "Version %d (%s)\n", v.Version, v.UpdatedAt.AsTime().Local().Format(time.DateTime))
+
+		var decrypted []byte
+		decrypted, err = rsa.DecryptPKCS1v15(rand.Reader, s.key, v.Data)
+		if err != nil {
+			b.WriteString("decrypt failed\n\n")
+			continue
+		}
+
+		var e dataverse.Entry
+		e, err = dataverse.ParseEntry(decrypted)
+		if err != nil {
+			_, _ = fmt.Fprintf(&b, "parsing failed\t%s\n\n", err)
+			continue
+		}
+
+		_, _ = fmt.Fprintf(&b, "Type: %s\n", e.GetType())
+		_, _ = fmt.Fprintf(&b, "Name: %s\n", e.GetName())
+		b.WriteString(strings.TrimSpace(e.GetContent()))
+		b.WriteString("\n\n")
+	}
+
+	return b.String(), nil
+}
+
+// history - получить предыдущие версии записи подписанным запросом.
+func (s Service) history(ctx context.Context, id string) (*pb.HistoryResponse, error) {
+	timestamp := time.Now().Unix()
+	hash := keeper.HistoryHash(s.key.PublicKey.N.Bytes(), timestamp, id)
+
+	sign, err := s.signHash(ctx, hash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.c.History(ctx, &pb.HistoryRequest{
+		Id:        id,
+		Timestamp: timestamp,
+		Sign:      sign,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("client history: %w", err)
+	}
+
+	return resp, nil
+}
+
+// Revert - вернуть запись к предыдущей версии. line - "{id} {version}".
+func (s Service) Revert(ctx context.Context, line string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("service Service Revert: context: %w", err)
+	}
+
+	ctx, span := tracing.Start(ctx, "Service Revert")
+	defer span.End()
+
+	splitted := strings.Fields(line)
+	if len(splitted) != 2 {
+		return "", errors.New("service Service Revert: wrong line")
+	}
+	id := splitted[0]
+	version, err := strconv.ParseInt(splitted[1], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("service Service Revert: parse version: %w", err)
+	}
+
+	getResp, err := s.c.Get(ctx, &pb.GetRequest{
+		Id: id,
+	})
+	if err != nil {
+		return "", fmt.Errorf("service Service Revert: client get: %w", err)
+	}
+
+	historyResp, err := s.history(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("service Service Revert: %w", err)
+	}
+
+	var data []byte
+	for _, v := range historyResp.Versions {
+		if v.Version == version {
+			data = v.Data
+			break
+		}
+	}
+	if data == nil {
+		return "", fmt.Errorf("service Service Revert: %w", ErrVersionNotFound)
+	}
+
+	oldHash := sha256.Sum256(getResp.Data)
+	oldHash2 := sha256.Sum256(oldHash[:])
+	_, cryptoSpan := tracing.Start(ctx, "sign old")
+	oldSign, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, oldHash2[:])
+	tracing.End(cryptoSpan, err)
+	if err != nil {
+		return "", fmt.Errorf("service Service Revert: sign old: %w", err)
+	}
+
+	newHash := sha256.Sum256(data)
+	_, cryptoSpan = tracing.Start(ctx, "sign new")
+	newSign, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, newHash[:])
+	tracing.End(cryptoSpan, err)
+	if err != nil {
+		return "", fmt.Errorf("service Service Revert: sign new: %w", err)
+	}
+
+	_, err = s.c.Revert(ctx, &pb.RevertRequest{
+		Id:              id,
+		Version:         version,
+		SignOld:         oldSign,
+		SignNew:         newSign,
+		ExpectedVersion: getResp.Version,
+	})
+	if v, ok := currentVersion(err); ok {
+		return "", fmt.Errorf("service Service Revert: %w: current version %d", ErrConflict, v)
+	}
+	if err != nil {
+		return "", fmt.Errorf("service Service Revert: client revert: %w", err)
+	}
+
+	return fmt.Sprintf("Entry %s reverted to version %d", id, version), nil
+}
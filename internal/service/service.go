@@ -10,26 +10,40 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/chzyer/readline"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/ImpressionableRaccoon/GophKeeper/internal/dataverse"
 	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
 	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
 )
 
+// ErrConflict - запись была изменена с другого устройства, пока пользователь ее редактировал.
+var ErrConflict = errors.New("entry was changed on another device, get it again and retry")
+
 // Service - структура, которая обрабатывает действия клиента.
 type Service struct {
-	c   *keeper.Client
-	key *rsa.PrivateKey
+	c      *keeper.Client
+	key    *rsa.PrivateKey
+	cursor *cursor
+	audit  *auditPager
 }
 
 // New - создать новый Service.
 func New(client *keeper.Client, key *rsa.PrivateKey) (*Service, error) {
 	return &Service{
-		c:   client,
-		key: key,
+		c:      client,
+		key:    key,
+		cursor: &cursor{},
+		audit:  &auditPager{},
 	}, nil
 }
 
@@ -39,6 +53,9 @@ func (s Service) Get(ctx context.Context, id string) (string, error) {
 		return "", fmt.Errorf("service Service Get: context: %w", err)
 	}
 
+	ctx, span := tracing.Start(ctx, "Service Get")
+	defer span.End()
+
 	resp, err := s.c.Get(ctx, &pb.GetRequest{
 		Id: id,
 	})
@@ -46,7 +63,9 @@ func (s Service) Get(ctx context.Context, id string) (string, error) {
 		return "", fmt.Errorf("service Service Get: client: %w", err)
 	}
 
+	_, cryptoSpan := tracing.Start(ctx, "decrypt")
 	decrypted, err := rsa.DecryptPKCS1v15(rand.Reader, s.key, resp.Data)
+	tracing.End(cryptoSpan, err)
 	if err != nil {
 		return "", fmt.Errorf("service Service Get: decrypt: %w", err)
 	}
@@ -64,13 +83,30 @@ func (s Service) Get(ctx context.Context, id string) (string, error) {
 	return b.String(), nil
 }
 
-// Add - добавить новую запись.
+// Add - добавить новую запись. Строка: {type} [ttl], без ttl запись бессрочная.
 func (s Service) Add(ctx context.Context, line string, l *readline.Instance) (string, error) {
 	if err := ctx.Err(); err != nil {
 		return "", fmt.Errorf("service Service Add: context: %w", err)
 	}
 
-	t := strings.TrimSpace(line)
+	ctx, span := tracing.Start(ctx, "Service Add")
+	defer span.End()
+
+	var t string
+	var expiresAt *timestamppb.Timestamp
+	fields := strings.Fields(line)
+	if len(fields) > 0 {
+		t = fields[0]
+	}
+	if len(fields) > 1 {
+		ttl, err := parseTTL(fields[1])
+		if err != nil {
+			return "", fmt.Errorf("service Service Add: %w", err)
+		}
+		if ttl > 0 {
+			expiresAt = timestamppb.New(time.Now().Add(ttl))
+		}
+	}
 
 	e, err := dataverse.GenDatabaseEntry(t, l)
 	if err != nil {
@@ -82,45 +118,83 @@ func (s Service) Add(ctx context.Context, line string, l *readline.Instance) (st
 		return "", fmt.Errorf("service Service Add: marshal json: %w", err)
 	}
 
+	_, cryptoSpan := tracing.Start(ctx, "encrypt")
 	encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, &s.key.PublicKey, data)
+	tracing.End(cryptoSpan, err)
 	if err != nil {
 		return "", fmt.Errorf("service Service Add: encrypt: %w", err)
 	}
 
 	hash := sha256.Sum256(encrypted)
 
+	_, cryptoSpan = tracing.Start(ctx, "sign")
 	sign, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+	tracing.End(cryptoSpan, err)
 	if err != nil {
 		return "", fmt.Errorf("service Service Add: sign: %w", err)
 	}
 
-	resp, err := s.c.Create(ctx, &pb.CreateRequest{
-		PublicKey: s.key.PublicKey.N.Bytes(),
-		Data:      encrypted,
-		Sign:      sign,
-	})
-	if err != nil {
-		return "", fmt.Errorf("service Service Add: client: %w", err)
+	// с ключом идемпотентности повтор после обрыва соединения не создаст вторую запись
+	req := &pb.CreateRequest{
+		PublicKey:      s.key.PublicKey.N.Bytes(),
+		Data:           encrypted,
+		Sign:           sign,
+		ExpiresAt:      expiresAt,
+		IdempotencyKey: uuid.NewString(),
 	}
+	for attempt := 1; ; attempt++ {
+		var resp *pb.CreateResponse
+		resp, err = s.c.Create(ctx, req)
+		if err == nil {
+			return fmt.Sprintf("Entry ID: %s", resp.Id), nil
+		}
+		err = fmt.Errorf("client: %w", err)
+		if attempt == transferAttempts || !retryable(err) {
+			return "", fmt.Errorf("service Service Add: %w", err)
+		}
 
-	return fmt.Sprintf("Entry ID: %s", resp.Id), nil
+		err = wait(ctx, attempt)
+		if err != nil {
+			return "", fmt.Errorf("service Service Add: %w", err)
+		}
+	}
 }
 
-// All - получить все записи пользователя.
+// All - получить все записи пользователя, отсортированные по времени изменения.
 func (s Service) All(ctx context.Context) (string, error) {
 	if err := ctx.Err(); err != nil {
 		return "", fmt.Errorf("service Service All: context: %w", err)
 	}
 
-	resp, err := s.c.GetAll(ctx, &pb.GetAllRequest{
-		PublicKey: s.key.PublicKey.N.Bytes(),
-	})
-	if err != nil {
-		return "", fmt.Errorf("service Service All: client: %w", err)
+	ctx, span := tracing.Start(ctx, "Service All")
+	defer span.End()
+
+	var entries []*pb.GetAllResponse_Entry
+	var pageToken string
+	for {
+		resp, err := s.c.GetAll(ctx, &pb.GetAllRequest{
+			PublicKey: s.key.PublicKey.N.Bytes(),
+			PageToken: pageToken,
+		})
+		if err != nil {
+			return "", fmt.Errorf("service Service All: client: %w", err)
+		}
+
+		entries = append(entries, resp.Entries...)
+		pageToken = resp.NextPageToken
+		if pageToken == "" {
+			break
+		}
 	}
 
+	sortByRecency(entries)
+
+	_, cryptoSpan := tracing.Start(ctx, "decrypt")
+	defer cryptoSpan.End()
+
+	var err error
 	b := strings.Builder{}
-	for _, entry := range resp.Entries {
+	for _, entry := range entries {
 		var decrypted []byte
 		decrypted, err = rsa.DecryptPKCS1v15(rand.Reader, s.key, entry.Data)
 		if err != nil {
@@ -135,18 +209,43 @@ func (s Service) All(ctx context.Context) (string, error) {
 			continue
 		}
 
-		_, _ = fmt.Fprintf(&b, "%s\t%s\t%s\n", entry.Id, e.GetType(), e.GetName())
+		_, _ = fmt.Fprintf(&b, "%s\t%s\t%s%s\n", entry.Id, e.GetType(), e.GetName(), expiresIn(entry))
 	}
 
 	return b.String(), nil
 }
 
+// parseTTL - срок жизни записи из команды клиента, например 24h. 0 - бессрочная запись.
+func parseTTL(s string) (time.Duration, error) {
+	ttl, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("parse ttl: %w", err)
+	}
+	if ttl < 0 {
+		return 0, errors.New("ttl must not be negative")
+	}
+
+	return ttl, nil
+}
+
+// expiresIn - сколько осталось до истечения записи для списка записей, пусто для бессрочной записи.
+func expiresIn(entry *pb.GetAllResponse_Entry) string {
+	if entry.ExpiresAt == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("\texpires in %s", time.Until(entry.ExpiresAt.AsTime()).Round(time.Minute))
+}
+
 // Delete - удалить запись по ID.
 func (s Service) Delete(ctx context.Context, id string) (string, error) {
 	if err := ctx.Err(); err != nil {
 		return "", fmt.Errorf("service Service Delete: context: %w", err)
 	}
 
+	ctx, span := tracing.Start(ctx, "Service Delete")
+	defer span.End()
+
 	getResp, err := s.c.Get(ctx, &pb.GetRequest{
 		Id: id,
 	})
@@ -157,15 +256,21 @@ func (s Service) Delete(ctx context.Context, id string) (string, error) {
 	hash := sha256.Sum256(getResp.Data)
 	hash2 := sha256.Sum256(hash[:])
 
+	_, cryptoSpan := tracing.Start(ctx, "sign")
 	sign2, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash2[:])
+	tracing.End(cryptoSpan, err)
 	if err != nil {
 		return "", fmt.Errorf("service Service Delete: sign: %w", err)
 	}
 
 	_, err = s.c.Delete(ctx, &pb.DeleteRequest{
-		Id:   id,
-		Sign: sign2,
+		Id:              id,
+		Sign:            sign2,
+		ExpectedVersion: getResp.Version,
 	})
+	if v, ok := currentVersion(err); ok {
+		return "", fmt.Errorf("service Service Delete: %w: current version %d", ErrConflict, v)
+	}
 	if err != nil {
 		return "", fmt.Errorf("service Service Delete: client delete: %w", err)
 	}
@@ -173,12 +278,16 @@ func (s Service) Delete(ctx context.Context, id string) (string, error) {
 	return fmt.Sprintf("Entry %s successfully deleted", id), nil
 }
 
-// Update - обновить запись по ID.
+// Update - обновить запись по ID. Строка: {id} {type} [ttl], без ttl срок жизни не меняется,
+// ttl 0 делает запись бессрочной.
 func (s Service) Update(ctx context.Context, line string, l *readline.Instance) (string, error) {
 	if err := ctx.Err(); err != nil {
 		return "", fmt.Errorf("service Service Update: context: %w", err)
 	}
 
+	ctx, span := tracing.Start(ctx, "Service Update")
+	defer span.End()
+
 	splitted := strings.Split(strings.TrimSpace(line), " ")
 	if len(splitted) < 2 {
 		return "", errors.New("service Service Update: wrong line")
@@ -186,6 +295,20 @@ func (s Service) Update(ctx context.Context, line string, l *readline.Instance)
 	id := splitted[0]
 	t := splitted[1]
 
+	var expiresAt *timestamppb.Timestamp
+	var clearExpiresAt bool
+	if len(splitted) > 2 {
+		ttl, err := parseTTL(splitted[2])
+		if err != nil {
+			return "", fmt.Errorf("service Service Update: %w", err)
+		}
+		if ttl > 0 {
+			expiresAt = timestamppb.New(time.Now().Add(ttl))
+		} else {
+			clearExpiresAt = true
+		}
+	}
+
 	getResp, err := s.c.Get(ctx, &pb.GetRequest{
 		Id: id,
 	})
@@ -195,7 +318,9 @@ func (s Service) Update(ctx context.Context, line string, l *readline.Instance)
 
 	oldHash := sha256.Sum256(getResp.Data)
 	oldHash2 := sha256.Sum256(oldHash[:])
+	_, cryptoSpan := tracing.Start(ctx, "sign old")
 	oldSign, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, oldHash2[:])
+	tracing.End(cryptoSpan, err)
 	if err != nil {
 		return "", fmt.Errorf("service Service Update: sign old: %w", err)
 	}
@@ -210,26 +335,59 @@ func (s Service) Update(ctx context.Context, line string, l *readline.Instance)
 		return "", fmt.Errorf("service Service Update: marshal json: %w", err)
 	}
 
+	_, cryptoSpan = tracing.Start(ctx, "encrypt")
 	encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, &s.key.PublicKey, data)
+	tracing.End(cryptoSpan, err)
 	if err != nil {
 		return "", fmt.Errorf("service Service Update: encrypt: %w", err)
 	}
 
 	newHash := sha256.Sum256(encrypted)
+	_, cryptoSpan = tracing.Start(ctx, "sign new")
 	newSign, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, newHash[:])
+	tracing.End(cryptoSpan, err)
 	if err != nil {
 		return "", fmt.Errorf("service Service Update: sign new: %w", err)
 	}
 
 	_, err = s.c.Update(ctx, &pb.UpdateRequest{
-		Id:      id,
-		Data:    encrypted,
-		SignOld: oldSign,
-		SignNew: newSign,
+		Id:              id,
+		Data:            encrypted,
+		SignOld:         oldSign,
+		SignNew:         newSign,
+		ExpectedVersion: getResp.Version,
+		ExpiresAt:       expiresAt,
+		ClearExpiresAt:  clearExpiresAt,
 	})
+	if v, ok := currentVersion(err); ok {
+		return "", fmt.Errorf("service Service Update: %w: current version %d", ErrConflict, v)
+	}
 	if err != nil {
 		return "", fmt.Errorf("service Service Update: client update: %w", err)
 	}
 
 	return "update ok", nil
 }
+
+// currentVersion - текущая версия записи из ошибки конфликта версий.
+func currentVersion(err error) (int64, bool) {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.Aborted {
+		return 0, false
+	}
+
+	for _, d := range st.Details() {
+		if c, isConflict := d.(*pb.VersionConflict); isConflict {
+			return c.CurrentVersion, true
+		}
+	}
+
+	return 0, false
+}
+
+// sortByRecency - отсортировать записи так, чтобы недавно измененные были первыми.
+func sortByRecency(entries []*pb.GetAllResponse_Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].UpdatedAt.AsTime().After(entries[j].UpdatedAt.AsTime())
+	})
+}
@@ -1 +1,81 @@
 package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+func TestCurrentVersion(t *testing.T) {
+	t.Run("conflict", func(t *testing.T) {
+		st, err := status.New(codes.Aborted, "conflict").WithDetails(&pb.VersionConflict{CurrentVersion: 3})
+		require.NoError(t, err)
+
+		v, ok := currentVersion(st.Err())
+		assert.True(t, ok)
+		assert.Equal(t, int64(3), v)
+	})
+
+	t.Run("aborted without details", func(t *testing.T) {
+		_, ok := currentVersion(status.Error(codes.Aborted, "aborted"))
+		assert.False(t, ok)
+	})
+
+	t.Run("other error", func(t *testing.T) {
+		_, ok := currentVersion(status.Error(codes.NotFound, "not found"))
+		assert.False(t, ok)
+	})
+
+	t.Run("no error", func(t *testing.T) {
+		_, ok := currentVersion(nil)
+		assert.False(t, ok)
+	})
+}
+
+func TestSortByRecency(t *testing.T) {
+	now := time.Now()
+	entries := []*pb.GetAllResponse_Entry{
+		{Id: "old", UpdatedAt: timestamppb.New(now.Add(-time.Hour))},
+		{Id: "new", UpdatedAt: timestamppb.New(now)},
+		{Id: "unknown"},
+		{Id: "middle", UpdatedAt: timestamppb.New(now.Add(-time.Minute))},
+	}
+
+	sortByRecency(entries)
+
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.Id)
+	}
+	assert.Equal(t, []string{"new", "middle", "old", "unknown"}, ids)
+}
+
+func TestParseTTL(t *testing.T) {
+	ttl, err := parseTTL("24h")
+	require.NoError(t, err)
+	assert.Equal(t, 24*time.Hour, ttl)
+
+	ttl, err = parseTTL("0")
+	require.NoError(t, err)
+	assert.Zero(t, ttl)
+
+	_, err = parseTTL("-1h")
+	assert.Error(t, err)
+
+	_, err = parseTTL("tomorrow")
+	assert.Error(t, err)
+}
+
+func TestExpiresIn(t *testing.T) {
+	assert.Empty(t, expiresIn(&pb.GetAllResponse_Entry{}))
+
+	e := &pb.GetAllResponse_Entry{ExpiresAt: timestamppb.New(time.Now().Add(2*time.Hour + 10*time.Second))}
+	assert.Equal(t, "\texpires in 2h0m0s", expiresIn(e))
+}
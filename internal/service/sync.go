@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/dataverse"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+// cursor - ревизия, до которой клиент уже получил изменения.
+type cursor struct {
+	mu       sync.Mutex
+	revision int64
+}
+
+// Revision - ревизия, до которой клиент уже получил изменения.
+func (s Service) Revision() int64 {
+	s.cursor.mu.Lock()
+	defer s.cursor.mu.Unlock()
+
+	return s.cursor.revision
+}
+
+// SetRevision - продолжить получение изменений с сохраненной ревизии.
+func (s Service) SetRevision(revision int64) {
+	s.cursor.mu.Lock()
+	defer s.cursor.mu.Unlock()
+
+	s.cursor.revision = revision
+}
+
+// Changes - получить записи, измененные и удаленные с прошлого вызова.
+// При первом вызове возвращаются все записи, как и при полной синхронизации,
+// если сервер уже удалил надгробия после сохраненной ревизии.
+func (s Service) Changes(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("service Service Changes: context: %w", err)
+	}
+
+	ctx, span := tracing.Start(ctx, "Service Changes")
+	defer span.End()
+
+	s.cursor.mu.Lock()
+	defer s.cursor.mu.Unlock()
+
+	resp, err := s.c.GetChanges(ctx, &pb.GetChangesRequest{
+		PublicKey:     s.key.PublicKey.N.Bytes(),
+		SinceRevision: s.cursor.revision,
+	})
+	if err != nil {
+		return "", fmt.Errorf("service Service Changes: client: %w", err)
+	}
+
+	_, cryptoSpan := tracing.Start(ctx, "decrypt")
+	defer cryptoSpan.End()
+
+	b := strings.Builder{}
+	if resp.FullResync {
+		b.WriteString("Full resync: the server no longer keeps deletions since the last sync, all entries follow\n")
+	}
+	for _, entry := range resp.Entries {
+		var decrypted []byte
+		decrypted, err = rsa.DecryptPKCS1v15(rand.Reader, s.key, entry.Data)
+		if err != nil {
+			_, _ = fmt.Fprintf(&b, "%s\tdecrypt failed\n", entry.Id)
+			continue
+		}
+
+		var e dataverse.Entry
+		e, err = dataverse.ParseEntry(decrypted)
+		if err != nil {
+			_, _ = fmt.Fprintf(&b, "%s\tparsing failed\t%s\n", entry.Id, err)
+			continue
+		}
+
+		_, _ = fmt.Fprintf(&b, "%s\t%s\t%s\n", entry.Id, e.GetType(), e.GetName())
+	}
+	for _, id := range resp.Deleted {
+		_, _ = fmt.Fprintf(&b, "%s\tdeleted\n", id)
+	}
+	if b.Len() == 0 {
+		b.WriteString("No changes")
+	}
+
+	s.cursor.revision = resp.Revision
+
+	return b.String(), nil
+}
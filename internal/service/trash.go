@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/dataverse"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+// ErrNotInTrash - записи с таким ID нет в корзине.
+var ErrNotInTrash = errors.New("entry not found in trash")
+
+// Trash - получить записи пользователя в корзине.
+func (s Service) Trash(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("service Service Trash: context: %w", err)
+	}
+
+	ctx, span := tracing.Start(ctx, "Service Trash")
+	defer span.End()
+
+	resp, err := s.listTrash(ctx)
+	if err != nil {
+		return "", fmt.Errorf("service Service Trash: %w", err)
+	}
+
+	_, cryptoSpan := tracing.Start(ctx, "decrypt")
+	defer cryptoSpan.End()
+
+	b := strings.Builder{}
+	for _, entry := range resp.Entries {
+		purgeIn := time.Until(entry.PurgeAt.AsTime()).Round(time.Minute)
+
+		var decrypted []byte
+		decrypted, err = rsa.DecryptPKCS1v15(rand.Reader, s.key, entry.Data)
+		if err != nil {
+			_, _ = fmt.Fprintf(&b, "%s\tdecrypt failed\tpurge in %s\n", entry.Id, purgeIn)
+			continue
+		}
+
+		var e dataverse.Entry
+		e, err = dataverse.ParseEntry(decrypted)
+		if err != nil {
+			_, _ = fmt.Fprintf(&b, "%s\tparsing failed\tpurge in %s\n", entry.Id, purgeIn)
+			continue
+		}
+
+		_, _ = fmt.Fprintf(&b, "%s\t%s\t%s\tpurge in %s\n", entry.Id, e.GetType(), e.GetName(), purgeIn)
+	}
+
+	return b.String(), nil
+}
+
+// Restore - восстановить запись из корзины.
+func (s Service) Restore(ctx context.Context, id string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("service Service Restore: context: %w", err)
+	}
+
+	ctx, span := tracing.Start(ctx, "Service Restore")
+	defer span.End()
+
+	timestamp := time.Now().Unix()
+	sign, err := s.signTrashed(ctx, id, func(publicKey, data []byte) [sha256.Size]byte {
+		return keeper.RestoreHash(publicKey, timestamp, id, data)
+	})
+	if err != nil {
+		return "", fmt.Errorf("service Service Restore: %w", err)
+	}
+
+	_, err = s.c.Restore(ctx, &pb.RestoreRequest{
+		Id:        id,
+		Sign:      sign,
+		Timestamp: timestamp,
+	})
+	if err != nil {
+		return "", fmt.Errorf("service Service Restore: client restore: %w", err)
+	}
+
+	return fmt.Sprintf("Entry %s successfully restored", id), nil
+}
+
+// Purge - окончательно удалить запись из корзины.
+func (s Service) Purge(ctx context.Context, id string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("service Service Purge: context: %w", err)
+	}
+
+	ctx, span := tracing.Start(ctx, "Service Purge")
+	defer span.End()
+
+	timestamp := time.Now().Unix()
+	sign, err := s.signTrashed(ctx, id, func(publicKey, data []byte) [sha256.Size]byte {
+		return keeper.PurgeHash(publicKey, timestamp, id, data)
+	})
+	if err != nil {
+		return "", fmt.Errorf("service Service Purge: %w", err)
+	}
+
+	_, err = s.c.Purge(ctx, &pb.PurgeRequest{
+		Id:        id,
+		Sign:      sign,
+		Timestamp: timestamp,
+	})
+	if err != nil {
+		return "", fmt.Errorf("service Service Purge: client purge: %w", err)
+	}
+
+	return fmt.Sprintf("Entry %s permanently deleted", id), nil
+}
+
+// listTrash - получить записи пользователя в корзине подписанным запросом.
+func (s Service) listTrash(ctx context.Context) (*pb.ListTrashResponse, error) {
+	publicKey := s.key.PublicKey.N.Bytes()
+	timestamp := time.Now().Unix()
+	hash := keeper.ListTrashHash(publicKey, timestamp)
+
+	sign, err := s.signHash(ctx, hash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.c.ListTrash(ctx, &pb.ListTrashRequest{
+		PublicKey: publicKey,
+		Timestamp: timestamp,
+		Sign:      sign,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("client list trash: %w", err)
+	}
+
+	return resp, nil
+}
+
+// signTrashed - найти запись в корзине и подписать хеш запроса к ней, который строит hash.
+func (s Service) signTrashed(ctx context.Context, id string,
+	hash func(publicKey, data []byte) [sha256.Size]byte,
+) ([]byte, error) {
+	resp, err := s.listTrash(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	for _, entry := range resp.Entries {
+		if entry.Id == id {
+			data = entry.Data
+			break
+		}
+	}
+	if data == nil {
+		return nil, ErrNotInTrash
+	}
+
+	h := hash(s.key.PublicKey.N.Bytes(), data)
+	return s.signHash(ctx, h[:])
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+var eventActions = map[pb.WatchEvent_Type]string{
+	pb.WatchEvent_CREATED: "created",
+	pb.WatchEvent_UPDATED: "updated",
+	pb.WatchEvent_DELETED: "deleted",
+}
+
+// Watch - подписаться на изменения записей, сделанные с других устройств,
+// и передавать уведомления о них в notice. Блокируется, пока поток не оборвется или не отменится ctx.
+func (s Service) Watch(ctx context.Context, notice func(string)) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("service Service Watch: context: %w", err)
+	}
+
+	publicKey := s.key.PublicKey.N.Bytes()
+	timestamp := time.Now().Unix()
+	hash := keeper.WatchHash(publicKey, timestamp)
+
+	sign, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return fmt.Errorf("service Service Watch: sign: %w", err)
+	}
+
+	stream, err := s.c.Watch(ctx, &pb.WatchRequest{
+		PublicKey: publicKey,
+		Timestamp: timestamp,
+		ClientId:  s.c.ID(),
+		Sign:      sign,
+	})
+	if err != nil {
+		return fmt.Errorf("service Service Watch: client: %w", err)
+	}
+
+	for {
+		var e *pb.WatchEvent
+		e, err = stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("service Service Watch: recv: %w", err)
+		}
+
+		action, ok := eventActions[e.Type]
+		if !ok {
+			continue
+		}
+		notice(fmt.Sprintf("Entry %s was %s on another device", e.Id, action))
+	}
+}
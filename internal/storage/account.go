@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// ErrChallengeNotFound - вызов не найден, уже использован или истек.
+var ErrChallengeNotFound = errors.New("challenge not found or expired")
+
+// SaveChallenge - сохранить вызов для подтверждения удаления аккаунта.
+// У владельца может быть только один вызов, новый заменяет предыдущий.
+func (s *ServerStorage) SaveChallenge(ctx context.Context, publicKey, challenge []byte, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("save_challenge", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage SaveChallenge", "INSERT")
+	defer span.End()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO account_challenges (public_key, challenge, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (public_key) DO UPDATE SET challenge = excluded.challenge, expires_at = excluded.expires_at`,
+		publicKey, challenge, expiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("ServerStorage SaveChallenge: exec: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAccount - использовать вызов и в одной транзакции удалить все данные владельца:
+// записи вместе с частями файлов и историей версий, незавершенные загрузки, ключи идемпотентности,
+// надгробия, ревизию, журнал аудита и устройства.
+// Возвращает ID удаленных записей.
+func (s *ServerStorage) DeleteAccount(ctx context.Context, publicKey, challenge []byte) (ids []uuid.UUID, err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("delete_account", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage DeleteAccount", "DELETE")
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ServerStorage DeleteAccount: begin: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var one int
+	err = tx.QueryRowContext(ctx,
+		`DELETE FROM account_challenges WHERE public_key = $1 AND challenge = $2 AND expires_at > now() RETURNING 1`,
+		publicKey, challenge,
+	).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("ServerStorage DeleteAccount: %w", ErrChallengeNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ServerStorage DeleteAccount: delete challenge: %w", err)
+	}
+
+	// части файлов и история версий удаляются каскадно
+	rows, err := tx.QueryContext(ctx,
+		`DELETE FROM entries WHERE owner_hash = $1 RETURNING id`,
+		Fingerprint(publicKey),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ServerStorage DeleteAccount: delete entries: %w", err)
+	}
+	ids = make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		err = rows.Scan(&id)
+		if err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("ServerStorage DeleteAccount: delete entries scan: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ServerStorage DeleteAccount: delete entries rows: %w", err)
+	}
+	_ = rows.Close()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE owner_hash = $1`, Fingerprint(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("ServerStorage DeleteAccount: delete idempotency keys: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM uploads WHERE owner_hash = $1`, Fingerprint(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("ServerStorage DeleteAccount: delete uploads: %w", err)
+	}
+
+	// надгробия удаляем после записей: триггер создает их при удалении
+	for _, q := range []string{
+		`DELETE FROM tombstones WHERE public_key = $1`,
+		`DELETE FROM owner_revisions WHERE public_key = $1`,
+		`DELETE FROM audit_log WHERE public_key = $1`,
+		`DELETE FROM devices WHERE public_key = $1`,
+	} {
+		_, err = tx.ExecContext(ctx, q, publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("ServerStorage DeleteAccount: exec %q: %w", q, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("ServerStorage DeleteAccount: commit: %w", err)
+	}
+
+	return ids, nil
+}
+
+// DeleteExpiredChallenges - удалить вызовы удаления аккаунта, истекшие раньше before.
+func (s *ServerStorage) DeleteExpiredChallenges(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("delete_expired_challenges", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage DeleteExpiredChallenges", "DELETE")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM account_challenges WHERE expires_at < $1`,
+		before,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("ServerStorage DeleteExpiredChallenges: exec: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("ServerStorage DeleteExpiredChallenges: rows affected: %w", err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerStorage_SaveChallenge(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	expiresAt := time.Now().Add(time.Minute)
+	mock.ExpectExec("INSERT INTO account_challenges").WithArgs([]byte{9}, []byte{1, 2}, expiresAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	s := ServerStorage{db: db}
+	err = s.SaveChallenge(context.Background(), []byte{9}, []byte{1, 2}, expiresAt)
+	assert.NoError(t, err)
+}
+
+func TestServerStorage_DeleteAccount(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		id := uuid.New()
+		mock.ExpectBegin()
+		mock.ExpectQuery("DELETE FROM account_challenges").WithArgs([]byte{9}, []byte{1, 2}).
+			WillReturnRows(sqlmock.NewRows([]string{"one"}).AddRow(1))
+		mock.ExpectQuery("DELETE FROM entries").WithArgs(Fingerprint([]byte{9})).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+		mock.ExpectExec("DELETE FROM idempotency_keys").WithArgs(Fingerprint([]byte{9})).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM uploads").WithArgs(Fingerprint([]byte{9})).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("DELETE FROM tombstones").WithArgs([]byte{9}).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM owner_revisions").WithArgs([]byte{9}).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM audit_log").WithArgs([]byte{9}).WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec("DELETE FROM devices").WithArgs([]byte{9}).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		s := ServerStorage{db: db}
+		ids, err := s.DeleteAccount(context.Background(), []byte{9}, []byte{1, 2})
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{id}, ids)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("wrong challenge", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("DELETE FROM account_challenges").WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		s := ServerStorage{db: db}
+		_, err = s.DeleteAccount(context.Background(), []byte{9}, []byte{1, 2})
+		assert.ErrorIs(t, err, ErrChallengeNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rollback on error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("DELETE FROM account_challenges").
+			WillReturnRows(sqlmock.NewRows([]string{"one"}).AddRow(1))
+		mock.ExpectQuery("DELETE FROM entries").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("DELETE FROM idempotency_keys").WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		s := ServerStorage{db: db}
+		_, err = s.DeleteAccount(context.Background(), []byte{9}, []byte{1, 2})
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestServerStorage_DeleteExpiredChallenges(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	before := time.Now()
+	mock.ExpectExec("DELETE FROM account_challenges").WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	s := ServerStorage{db: db}
+	n, err := s.DeleteExpiredChallenges(context.Background(), before)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+}
@@ -0,0 +1,288 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// schemaTables - таблицы, которые должны быть в базе после применения всех миграций.
+var schemaTables = []string{
+	"entries",
+	"uploads",
+	"chunks",
+	"owner_revisions",
+	"tombstones",
+	"entry_history",
+	"audit_log",
+	"account_challenges",
+	"devices",
+	"disabled_owners",
+	"read_only_mode",
+	"idempotency_keys",
+}
+
+// schemaChecks - проверки согласованности данных, одинаковые для Postgres и SQLite.
+// Запрос возвращает количество нарушений, problem - описание с этим количеством.
+var schemaChecks = []struct {
+	problem string
+	query   string
+}{
+	{
+		problem: "entries of %d owners have no owner revision",
+		query: `SELECT count(DISTINCT public_key) FROM entries e
+			WHERE NOT EXISTS (SELECT 1 FROM owner_revisions r WHERE r.public_key = e.public_key)`,
+	},
+	{
+		problem: "%d entries have revision greater than owner revision",
+		query: `SELECT count(*) FROM entries e JOIN owner_revisions r ON r.public_key = e.public_key
+			WHERE e.revision > r.revision`,
+	},
+}
+
+// OwnerStats - статистика использования хранилища одним владельцем.
+// Владелец указан отпечатком, ни публичный ключ, ни данные записей не раскрываются.
+type OwnerStats struct {
+	Fingerprint  []byte
+	Entries      int64
+	Trashed      int64
+	Bytes        int64
+	Versions     int64
+	Devices      int64
+	LastUpdateAt time.Time
+	Disabled     bool
+}
+
+// OwnerStats - статистика по всем владельцам, у которых есть записи, по убыванию занятого места.
+// Bytes учитывает зашифрованные данные записей, части файлов и историю версий.
+func (s *ServerStorage) OwnerStats(ctx context.Context) ([]OwnerStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	defer metrics.ObserveQuery("owner_stats", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage OwnerStats", "SELECT")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx,
+		`WITH owners AS (
+			SELECT public_key,
+				count(*) FILTER (WHERE deleted_at IS NULL) AS entries,
+				count(*) FILTER (WHERE deleted_at IS NOT NULL) AS trashed,
+				sum(length(payload)) AS bytes,
+				max(updated_at) AS last_update_at
+			FROM entries GROUP BY public_key
+		),
+		chunk_bytes AS (
+			SELECT e.public_key, sum(length(c.data)) AS bytes
+			FROM chunks c JOIN entries e ON e.id = c.entry_id GROUP BY e.public_key
+		),
+		history AS (
+			SELECT e.public_key, count(*) AS versions, sum(length(h.payload)) AS bytes
+			FROM entry_history h JOIN entries e ON e.id = h.entry_id GROUP BY e.public_key
+		),
+		active_devices AS (
+			SELECT public_key, count(*) AS devices FROM devices WHERE revoked_at IS NULL GROUP BY public_key
+		)
+		SELECT sha256(o.public_key), o.entries, o.trashed,
+			o.bytes + coalesce(c.bytes, 0) + coalesce(h.bytes, 0),
+			coalesce(h.versions, 0), coalesce(d.devices, 0), o.last_update_at,
+			EXISTS (SELECT 1 FROM disabled_owners x WHERE x.fingerprint = sha256(o.public_key))
+		FROM owners o
+		LEFT JOIN chunk_bytes c ON c.public_key = o.public_key
+		LEFT JOIN history h ON h.public_key = o.public_key
+		LEFT JOIN active_devices d ON d.public_key = o.public_key
+		ORDER BY 4 DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ServerStorage OwnerStats: query: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	stats := make([]OwnerStats, 0)
+	for rows.Next() {
+		o := OwnerStats{}
+		err = rows.Scan(&o.Fingerprint, &o.Entries, &o.Trashed, &o.Bytes,
+			&o.Versions, &o.Devices, &o.LastUpdateAt, &o.Disabled)
+		if err != nil {
+			return nil, fmt.Errorf("ServerStorage OwnerStats: query rows scan: %w", err)
+		}
+		stats = append(stats, o)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ServerStorage OwnerStats: query rows: %w", err)
+	}
+
+	return stats, nil
+}
+
+// MigrateUp - применить n следующих миграций, все оставшиеся если n равно 0.
+// Возвращает migrate.ErrNoChange, если применять нечего.
+func (s *ServerStorage) MigrateUp(n int) error {
+	m, err := s.newMigrate()
+	if err != nil {
+		return fmt.Errorf("ServerStorage MigrateUp: %w", err)
+	}
+	defer func() { _, _ = m.Close() }()
+
+	if n == 0 {
+		err = m.Up()
+	} else {
+		err = m.Steps(n)
+	}
+	if err != nil {
+		return fmt.Errorf("ServerStorage MigrateUp: %w", err)
+	}
+
+	return nil
+}
+
+// MigrateDown - откатить n последних миграций.
+func (s *ServerStorage) MigrateDown(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("ServerStorage MigrateDown: number of migrations must be positive, got %d", n)
+	}
+
+	m, err := s.newMigrate()
+	if err != nil {
+		return fmt.Errorf("ServerStorage MigrateDown: %w", err)
+	}
+	defer func() { _, _ = m.Close() }()
+
+	err = m.Steps(-n)
+	if err != nil {
+		return fmt.Errorf("ServerStorage MigrateDown: %w", err)
+	}
+
+	return nil
+}
+
+// SchemaVersion - версия схемы в базе и признак незавершенной миграции.
+// Для пустой базы возвращается версия 0.
+func (s *ServerStorage) SchemaVersion() (version uint, dirty bool, err error) {
+	m, err := s.newMigrate()
+	if err != nil {
+		return 0, false, fmt.Errorf("ServerStorage SchemaVersion: %w", err)
+	}
+	defer func() { _, _ = m.Close() }()
+
+	version, dirty, err = m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("ServerStorage SchemaVersion: %w", err)
+	}
+
+	return version, dirty, nil
+}
+
+// LatestSchemaVersion - версия последней миграции Postgres, встроенной в сервер.
+func LatestSchemaVersion() (uint, error) {
+	return latestSchemaVersion("migrations/server")
+}
+
+// LatestSchemaVersion - версия последней миграции Postgres, встроенной в сервер.
+func (s *ServerStorage) LatestSchemaVersion() (uint, error) {
+	return LatestSchemaVersion()
+}
+
+// latestSchemaVersion - версия последней миграции в каталоге dir встроенных миграций.
+func latestSchemaVersion(dir string) (uint, error) {
+	d, err := iofs.New(migrationsFS, dir)
+	if err != nil {
+		return 0, fmt.Errorf("storage LatestSchemaVersion: iofs: %w", err)
+	}
+	defer func() { _ = d.Close() }()
+
+	version, err := d.First()
+	if err != nil {
+		return 0, fmt.Errorf("storage LatestSchemaVersion: first: %w", err)
+	}
+
+	for {
+		next, nextErr := d.Next(version)
+		if errors.Is(nextErr, fs.ErrNotExist) {
+			return version, nil
+		}
+		if nextErr != nil {
+			return 0, fmt.Errorf("storage LatestSchemaVersion: next: %w", nextErr)
+		}
+		version = next
+	}
+}
+
+// CheckSchema - проверить целостность схемы и вернуть список найденных проблем:
+// версию миграций, наличие таблиц и согласованность ревизий записей и владельцев.
+// Пустой список означает, что проблем нет.
+func (s *ServerStorage) CheckSchema(ctx context.Context) ([]string, error) {
+	problems := make([]string, 0)
+
+	latest, err := LatestSchemaVersion()
+	if err != nil {
+		return nil, fmt.Errorf("ServerStorage CheckSchema: %w", err)
+	}
+
+	version, dirty, err := s.SchemaVersion()
+	if err != nil {
+		return nil, fmt.Errorf("ServerStorage CheckSchema: %w", err)
+	}
+	if dirty {
+		problems = append(problems, fmt.Sprintf("migration %d is dirty, fix the schema and force the version", version))
+	}
+	if version != latest {
+		problems = append(problems, fmt.Sprintf("schema version %d, want %d", version, latest))
+	}
+
+	tableProblems, err := s.checkTables(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ServerStorage CheckSchema: %w", err)
+	}
+
+	return append(problems, tableProblems...), nil
+}
+
+func (s *ServerStorage) checkTables(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	defer metrics.ObserveQuery("check_schema", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage CheckSchema", "SELECT")
+	defer span.End()
+
+	problems := make([]string, 0)
+	for _, table := range schemaTables {
+		var exists bool
+		err := s.db.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, table).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("table %s: query row: %w", table, err)
+		}
+		if !exists {
+			problems = append(problems, fmt.Sprintf("table %s is missing", table))
+		}
+	}
+	if len(problems) > 0 {
+		// без таблиц проверки данных ниже завершатся ошибкой
+		return problems, nil
+	}
+
+	for _, check := range schemaChecks {
+		var n sql.NullInt64
+		err := s.db.QueryRowContext(ctx, check.query).Scan(&n)
+		if err != nil {
+			return nil, fmt.Errorf("check %q: query row: %w", check.problem, err)
+		}
+		if n.Int64 > 0 {
+			problems = append(problems, fmt.Sprintf(check.problem, n.Int64))
+		}
+	}
+
+	return problems, nil
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerStorage_OwnerStats(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	now := time.Now()
+	fingerprint := Fingerprint([]byte{9})
+	rows := sqlmock.NewRows([]string{"fingerprint", "entries", "trashed", "bytes",
+		"versions", "devices", "last_update_at", "disabled"}).
+		AddRow(fingerprint, 3, 1, 4096, 2, 1, now, true)
+	mock.ExpectQuery("FROM owners o").WillReturnRows(rows)
+
+	s := ServerStorage{db: db}
+	stats, err := s.OwnerStats(context.Background())
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.Equal(t, OwnerStats{
+		Fingerprint:  fingerprint,
+		Entries:      3,
+		Trashed:      1,
+		Bytes:        4096,
+		Versions:     2,
+		Devices:      1,
+		LastUpdateAt: now,
+		Disabled:     true,
+	}, stats[0])
+}
+
+func TestLatestSchemaVersion(t *testing.T) {
+	version, err := LatestSchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, uint(20), version)
+
+	// у Postgres и SQLite одинаковые номера миграций
+	sqliteVersion, err := (&SQLiteStorage{}).LatestSchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, version, sqliteVersion)
+}
+
+func TestServerStorage_checkTables(t *testing.T) {
+	t.Run("missing table", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		for _, table := range schemaTables {
+			mock.ExpectQuery("to_regclass").WithArgs(table).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(table != "devices"))
+		}
+
+		s := ServerStorage{db: db}
+		problems, err := s.checkTables(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"table devices is missing"}, problems)
+	})
+
+	t.Run("inconsistent revisions", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		for _, table := range schemaTables {
+			mock.ExpectQuery("to_regclass").WithArgs(table).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		}
+		mock.ExpectQuery("NOT EXISTS").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery("e.revision > r.revision").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+		s := ServerStorage{db: db}
+		problems, err := s.checkTables(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"2 entries have revision greater than owner revision"}, problems)
+	})
+}
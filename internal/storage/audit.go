@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// AuditOp - операция с записью, которая попадает в журнал аудита.
+type AuditOp string
+
+const (
+	AuditCreate   AuditOp = "create"
+	AuditRead     AuditOp = "read"
+	AuditUpdate   AuditOp = "update"
+	AuditDelete   AuditOp = "delete"
+	AuditRestore  AuditOp = "restore"
+	AuditPurge    AuditOp = "purge"
+	AuditRevert   AuditOp = "revert"
+	AuditUpload   AuditOp = "upload"
+	AuditDownload AuditOp = "download"
+	AuditList     AuditOp = "list"
+	AuditSync     AuditOp = "sync"
+	AuditHistory  AuditOp = "history"
+)
+
+// AuditRecord - запись журнала аудита.
+type AuditRecord struct {
+	ID        int64
+	PublicKey []byte
+	Op        AuditOp
+	// EntryID - ID записи, uuid.Nil для операций без записи.
+	EntryID uuid.UUID
+	// Peer - адрес клиента.
+	Peer string
+	// ClientVersion - версия клиента из метаданных запроса.
+	ClientVersion string
+	CreatedAt     time.Time
+}
+
+// AddAudit - добавить запись в журнал аудита.
+func (s *ServerStorage) AddAudit(ctx context.Context, r AuditRecord) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("add_audit", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage AddAudit", "INSERT")
+	defer span.End()
+
+	var entryID *uuid.UUID
+	if r.EntryID != uuid.Nil {
+		entryID = &r.EntryID
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO audit_log (public_key, op, entry_id, peer, client_version) VALUES ($1, $2, $3, $4, $5)`,
+		r.PublicKey, string(r.Op), entryID, r.Peer, r.ClientVersion,
+	)
+	if err != nil {
+		return fmt.Errorf("ServerStorage AddAudit: exec: %w", err)
+	}
+
+	return nil
+}
+
+// ListAudit - получить до limit записей журнала аудита владельца publicKey с ID меньше before,
+// новые первыми. Для первой страницы before равен 0.
+func (s *ServerStorage) ListAudit(ctx context.Context, publicKey []byte, before int64, limit int) ([]AuditRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("list_audit", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage ListAudit", "SELECT")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, op, entry_id, peer, client_version, created_at FROM audit_log
+		WHERE public_key = $1 AND ($2::bigint = 0 OR id < $2) ORDER BY id DESC LIMIT $3`,
+		publicKey, before, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ServerStorage ListAudit: query: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	records := make([]AuditRecord, 0)
+	for rows.Next() {
+		r := AuditRecord{PublicKey: publicKey}
+		var op string
+		var entryID uuid.NullUUID
+		err = rows.Scan(&r.ID, &op, &entryID, &r.Peer, &r.ClientVersion, &r.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("ServerStorage ListAudit: query rows scan: %w", err)
+		}
+		r.Op = AuditOp(op)
+		r.EntryID = entryID.UUID
+		records = append(records, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ServerStorage ListAudit: query rows: %w", err)
+	}
+
+	return records, nil
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerStorage_AddAudit(t *testing.T) {
+	t.Run("with entry", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		id := uuid.New()
+		mock.ExpectExec("INSERT INTO audit_log").
+			WithArgs([]byte{9}, "create", &id, "127.0.0.1:1234", "1.0.0").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		s := ServerStorage{db: db}
+		err = s.AddAudit(context.Background(), AuditRecord{
+			PublicKey:     []byte{9},
+			Op:            AuditCreate,
+			EntryID:       id,
+			Peer:          "127.0.0.1:1234",
+			ClientVersion: "1.0.0",
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnError(sql.ErrConnDone)
+
+		s := ServerStorage{db: db}
+		err = s.AddAudit(context.Background(), AuditRecord{Op: AuditRead})
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+	})
+}
+
+func TestServerStorage_ListAudit(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		id := uuid.New()
+		now := time.Now()
+
+		rows := sqlmock.NewRows([]string{"id", "op", "entry_id", "peer", "client_version", "created_at"}).
+			AddRow(int64(7), "update", id.String(), "peer", "1.0.0", now).
+			AddRow(int64(5), "read", nil, "peer", "", now)
+		mock.ExpectQuery("FROM audit_log").WithArgs([]byte{9}, int64(0), 2).WillReturnRows(rows)
+
+		s := ServerStorage{db: db}
+		records, err := s.ListAudit(context.Background(), []byte{9}, 0, 2)
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, AuditUpdate, records[0].Op)
+		assert.Equal(t, id, records[0].EntryID)
+		assert.Equal(t, uuid.Nil, records[1].EntryID)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectQuery("FROM audit_log").WillReturnError(sql.ErrConnDone)
+
+		s := ServerStorage{db: db}
+		_, err = s.ListAudit(context.Background(), []byte{9}, 0, 10)
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+	})
+}
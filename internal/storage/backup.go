@@ -0,0 +1,444 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// ErrNotEmpty - восстановить резервную копию можно только в пустое хранилище.
+var ErrNotEmpty = errors.New("storage is not empty")
+
+// BackupRecord - одна запись резервной копии хранилища, заполнено ровно одно поле.
+// Export выдает записи в порядке полей, в том же порядке их принимает Import:
+// части файлов и история идут после своих записей.
+type BackupRecord struct {
+	Entry          *BackupEntry          `json:"entry,omitempty"`
+	Chunk          *BackupChunk          `json:"chunk,omitempty"`
+	Version        *BackupVersion        `json:"version,omitempty"`
+	Revision       *BackupRevision       `json:"revision,omitempty"`
+	Tombstone      *BackupTombstone      `json:"tombstone,omitempty"`
+	Audit          *BackupAudit          `json:"audit,omitempty"`
+	Device         *BackupDevice         `json:"device,omitempty"`
+	DisabledOwner  *BackupDisabledOwner  `json:"disabled_owner,omitempty"`
+	IdempotencyKey *BackupIdempotencyKey `json:"idempotency_key,omitempty"`
+}
+
+// Kind - вид записи: имя заполненного поля в JSON, пустая строка для пустой записи.
+func (r BackupRecord) Kind() string {
+	switch {
+	case r.Entry != nil:
+		return "entry"
+	case r.Chunk != nil:
+		return "chunk"
+	case r.Version != nil:
+		return "version"
+	case r.Revision != nil:
+		return "revision"
+	case r.Tombstone != nil:
+		return "tombstone"
+	case r.Audit != nil:
+		return "audit"
+	case r.Device != nil:
+		return "device"
+	case r.DisabledOwner != nil:
+		return "disabled_owner"
+	case r.IdempotencyKey != nil:
+		return "idempotency_key"
+	default:
+		return ""
+	}
+}
+
+// BackupEntry - запись, в том числе из корзины. Manifest заполнен у файлов.
+type BackupEntry struct {
+	ID        uuid.UUID `json:"id"`
+	PublicKey []byte    `json:"public_key"`
+	Payload   []byte    `json:"payload"`
+	Manifest  []byte    `json:"manifest,omitempty"`
+	Revision  int64     `json:"revision"`
+	Version   int64     `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	DeletedAt time.Time `json:"deleted_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// BackupChunk - часть файла.
+type BackupChunk struct {
+	EntryID uuid.UUID `json:"entry_id"`
+	Index   uint32    `json:"idx"`
+	Data    []byte    `json:"data"`
+}
+
+// BackupVersion - предыдущая версия записи из истории.
+type BackupVersion struct {
+	EntryID   uuid.UUID `json:"entry_id"`
+	Version   int64     `json:"version"`
+	Payload   []byte    `json:"payload"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BackupRevision - текущая ревизия владельца, курсор синхронизации его клиентов.
+type BackupRevision struct {
+	PublicKey      []byte `json:"public_key"`
+	Revision       int64  `json:"revision"`
+	PrunedRevision int64  `json:"pruned_revision,omitempty"`
+}
+
+// BackupTombstone - надгробие окончательно удаленной записи.
+type BackupTombstone struct {
+	ID        uuid.UUID `json:"id"`
+	PublicKey []byte    `json:"public_key"`
+	Revision  int64     `json:"revision"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// created - время создания надгробия. В архивах до появления срока хранения надгробий
+// времени нет, такие надгробия считаются созданными при восстановлении.
+func (t BackupTombstone) created() time.Time {
+	if t.CreatedAt.IsZero() {
+		return time.Now()
+	}
+
+	return t.CreatedAt
+}
+
+// BackupAudit - запись журнала аудита.
+type BackupAudit struct {
+	ID            int64     `json:"id"`
+	PublicKey     []byte    `json:"public_key"`
+	Op            AuditOp   `json:"op"`
+	EntryID       uuid.UUID `json:"entry_id"`
+	Peer          string    `json:"peer"`
+	ClientVersion string    `json:"client_version"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// BackupDevice - устройство владельца с хешем токена сессии.
+type BackupDevice struct {
+	ID         uuid.UUID `json:"id"`
+	PublicKey  []byte    `json:"public_key"`
+	Name       string    `json:"name"`
+	TokenHash  []byte    `json:"token_hash"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	LastIP     string    `json:"last_ip"`
+	RevokedAt  time.Time `json:"revoked_at"`
+}
+
+// BackupDisabledOwner - владелец, отключенный оператором.
+type BackupDisabledOwner struct {
+	Fingerprint []byte    `json:"fingerprint"`
+	DisabledAt  time.Time `json:"disabled_at"`
+}
+
+// BackupIdempotencyKey - ключ идемпотентности создания записи.
+type BackupIdempotencyKey struct {
+	OwnerHash   []byte    `json:"owner_hash"`
+	Key         string    `json:"key"`
+	EntryID     uuid.UUID `json:"entry_id"`
+	RequestHash []byte    `json:"request_hash"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// nullTime - время для колонки, в которой нулевое время хранится как NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// nullUUID - ID для колонки, в которой uuid.Nil хранится как NULL.
+func nullUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
+}
+
+// exportQuery - выполнить запрос и передать в fn записи, которые собирает scan.
+func exportQuery(ctx context.Context, tx *sql.Tx, query string,
+	scan func(rows *sql.Rows) (BackupRecord, error), fn func(BackupRecord) error,
+) error {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("query: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		r, scanErr := scan(rows)
+		if scanErr != nil {
+			return fmt.Errorf("query rows scan: %w", scanErr)
+		}
+		err = fn(r)
+		if err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("query rows: %w", err)
+	}
+
+	return nil
+}
+
+// Export - передать в fn все данные хранилища: записи с частями файлов и историей,
+// ревизии и надгробия владельцев, журнал аудита, устройства и отключенных владельцев.
+// Данные читаются из одного снимка базы. Незавершенные загрузки и вызовы удаления аккаунта
+// не выгружаются. Время выполнения ограничивает только ctx.
+func (s *ServerStorage) Export(ctx context.Context, fn func(BackupRecord) error) error {
+	defer metrics.ObserveQuery("export", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage Export", "SELECT")
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("ServerStorage Export: begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, q := range []struct {
+		name  string
+		query string
+		scan  func(rows *sql.Rows) (BackupRecord, error)
+	}{
+		{
+			name: "entries",
+			query: `SELECT id, public_key, payload, manifest, revision, version, created_at, updated_at, deleted_at,
+			expires_at FROM entries ORDER BY id`,
+			scan: func(rows *sql.Rows) (BackupRecord, error) {
+				e := &BackupEntry{}
+				var deletedAt, expiresAt sql.NullTime
+				err := rows.Scan(&e.ID, &e.PublicKey, &e.Payload, &e.Manifest, &e.Revision, &e.Version,
+					&e.CreatedAt, &e.UpdatedAt, &deletedAt, &expiresAt)
+				e.DeletedAt = deletedAt.Time
+				e.ExpiresAt = expiresAt.Time
+				return BackupRecord{Entry: e}, err
+			},
+		},
+		{
+			name:  "chunks",
+			query: `SELECT entry_id, idx, data FROM chunks ORDER BY entry_id, idx`,
+			scan: func(rows *sql.Rows) (BackupRecord, error) {
+				c := &BackupChunk{}
+				err := rows.Scan(&c.EntryID, &c.Index, &c.Data)
+				return BackupRecord{Chunk: c}, err
+			},
+		},
+		{
+			name:  "history",
+			query: `SELECT entry_id, version, payload, updated_at FROM entry_history ORDER BY entry_id, version`,
+			scan: func(rows *sql.Rows) (BackupRecord, error) {
+				v := &BackupVersion{}
+				err := rows.Scan(&v.EntryID, &v.Version, &v.Payload, &v.UpdatedAt)
+				return BackupRecord{Version: v}, err
+			},
+		},
+		{
+			name:  "revisions",
+			query: `SELECT public_key, revision, pruned_revision FROM owner_revisions ORDER BY public_key`,
+			scan: func(rows *sql.Rows) (BackupRecord, error) {
+				r := &BackupRevision{}
+				err := rows.Scan(&r.PublicKey, &r.Revision, &r.PrunedRevision)
+				return BackupRecord{Revision: r}, err
+			},
+		},
+		{
+			name:  "tombstones",
+			query: `SELECT id, public_key, revision, created_at FROM tombstones ORDER BY id`,
+			scan: func(rows *sql.Rows) (BackupRecord, error) {
+				t := &BackupTombstone{}
+				err := rows.Scan(&t.ID, &t.PublicKey, &t.Revision, &t.CreatedAt)
+				return BackupRecord{Tombstone: t}, err
+			},
+		},
+		{
+			name:  "audit",
+			query: `SELECT id, public_key, op, entry_id, peer, client_version, created_at FROM audit_log ORDER BY id`,
+			scan: func(rows *sql.Rows) (BackupRecord, error) {
+				a := &BackupAudit{}
+				var entryID uuid.NullUUID
+				err := rows.Scan(&a.ID, &a.PublicKey, &a.Op, &entryID, &a.Peer, &a.ClientVersion, &a.CreatedAt)
+				a.EntryID = entryID.UUID
+				return BackupRecord{Audit: a}, err
+			},
+		},
+		{
+			name: "devices",
+			query: `SELECT id, public_key, name, token_hash, created_at, last_seen_at, last_ip, revoked_at
+			FROM devices ORDER BY id`,
+			scan: func(rows *sql.Rows) (BackupRecord, error) {
+				d := &BackupDevice{}
+				var revokedAt sql.NullTime
+				err := rows.Scan(&d.ID, &d.PublicKey, &d.Name, &d.TokenHash, &d.CreatedAt, &d.LastSeenAt,
+					&d.LastIP, &revokedAt)
+				d.RevokedAt = revokedAt.Time
+				return BackupRecord{Device: d}, err
+			},
+		},
+		{
+			name:  "disabled owners",
+			query: `SELECT fingerprint, disabled_at FROM disabled_owners ORDER BY fingerprint`,
+			scan: func(rows *sql.Rows) (BackupRecord, error) {
+				o := &BackupDisabledOwner{}
+				err := rows.Scan(&o.Fingerprint, &o.DisabledAt)
+				return BackupRecord{DisabledOwner: o}, err
+			},
+		},
+		{
+			name: "idempotency keys",
+			query: `SELECT owner_hash, key, entry_id, request_hash, created_at
+			FROM idempotency_keys ORDER BY owner_hash, key`,
+			scan: func(rows *sql.Rows) (BackupRecord, error) {
+				k := &BackupIdempotencyKey{}
+				err := rows.Scan(&k.OwnerHash, &k.Key, &k.EntryID, &k.RequestHash, &k.CreatedAt)
+				return BackupRecord{IdempotencyKey: k}, err
+			},
+		},
+	} {
+		err = exportQuery(ctx, tx, q.query, q.scan, fn)
+		if err != nil {
+			return fmt.Errorf("ServerStorage Export: %s: %w", q.name, err)
+		}
+	}
+
+	return nil
+}
+
+// Import - загрузить в пустое хранилище записи из next, пока next не вернет io.EOF.
+// Все записи загружаются в одной транзакции: при любой ошибке, в том числе от next,
+// хранилище остается пустым. Если в хранилище уже есть данные, возвращается ErrNotEmpty.
+// Время выполнения ограничивает только ctx.
+func (s *ServerStorage) Import(ctx context.Context, next func() (BackupRecord, error)) error {
+	defer metrics.ObserveQuery("import", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage Import", "INSERT")
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ServerStorage Import: begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var exists bool
+	row := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM entries) OR EXISTS (SELECT 1 FROM owner_revisions)
+		OR EXISTS (SELECT 1 FROM tombstones) OR EXISTS (SELECT 1 FROM audit_log)
+		OR EXISTS (SELECT 1 FROM devices) OR EXISTS (SELECT 1 FROM disabled_owners)
+		OR EXISTS (SELECT 1 FROM idempotency_keys)`)
+	err = row.Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("ServerStorage Import: check empty: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("ServerStorage Import: %w", ErrNotEmpty)
+	}
+
+	for {
+		r, nextErr := next()
+		if errors.Is(nextErr, io.EOF) {
+			break
+		}
+		if nextErr != nil {
+			return fmt.Errorf("ServerStorage Import: %w", nextErr)
+		}
+
+		err = s.importRecord(ctx, tx, r)
+		if err != nil {
+			return fmt.Errorf("ServerStorage Import: %s: %w", r.Kind(), err)
+		}
+	}
+
+	// следующие записи журнала аудита получают ID после восстановленных
+	_, err = tx.ExecContext(ctx,
+		`SELECT setval(pg_get_serial_sequence('audit_log', 'id'), coalesce(max(id), 0) + 1, false) FROM audit_log`,
+	)
+	if err != nil {
+		return fmt.Errorf("ServerStorage Import: reset audit sequence: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("ServerStorage Import: commit: %w", err)
+	}
+
+	return nil
+}
+
+// importRecord - вставить одну запись резервной копии.
+func (s *ServerStorage) importRecord(ctx context.Context, tx *sql.Tx, r BackupRecord) (err error) {
+	switch {
+	case r.Entry != nil:
+		e := r.Entry
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO entries (id, public_key, owner_hash, payload, manifest, version, created_at, updated_at, deleted_at,
+			expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+			e.ID, e.PublicKey, Fingerprint(e.PublicKey), e.Payload, e.Manifest, e.Version,
+			e.CreatedAt, e.UpdatedAt, nullTime(e.DeletedAt), nullTime(e.ExpiresAt),
+		)
+		if err != nil {
+			return err
+		}
+		// триггер выдал записи новую ревизию, возвращаем сохраненную:
+		// изменение только revision триггер не вызывает
+		_, err = tx.ExecContext(ctx, `UPDATE entries SET revision = $1 WHERE id = $2`, e.Revision, e.ID)
+	case r.Chunk != nil:
+		_, err = tx.ExecContext(ctx, `INSERT INTO chunks (entry_id, idx, data) VALUES ($1, $2, $3)`,
+			r.Chunk.EntryID, r.Chunk.Index, r.Chunk.Data,
+		)
+	case r.Version != nil:
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO entry_history (entry_id, version, payload, updated_at) VALUES ($1, $2, $3, $4)`,
+			r.Version.EntryID, r.Version.Version, r.Version.Payload, r.Version.UpdatedAt,
+		)
+	case r.Revision != nil:
+		// ревизии владельцев с записями уже создал триггер при вставке записей
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO owner_revisions (public_key, revision, pruned_revision) VALUES ($1, $2, $3)
+			ON CONFLICT (public_key) DO UPDATE
+			SET revision = excluded.revision, pruned_revision = excluded.pruned_revision`,
+			r.Revision.PublicKey, r.Revision.Revision, r.Revision.PrunedRevision,
+		)
+	case r.Tombstone != nil:
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO tombstones (id, public_key, revision, created_at) VALUES ($1, $2, $3, $4)`,
+			r.Tombstone.ID, r.Tombstone.PublicKey, r.Tombstone.Revision, r.Tombstone.created(),
+		)
+	case r.Audit != nil:
+		a := r.Audit
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO audit_log (id, public_key, op, entry_id, peer, client_version, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			a.ID, a.PublicKey, string(a.Op), nullUUID(a.EntryID), a.Peer, a.ClientVersion, a.CreatedAt,
+		)
+	case r.Device != nil:
+		d := r.Device
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO devices (id, public_key, name, token_hash, created_at, last_seen_at, last_ip, revoked_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			d.ID, d.PublicKey, d.Name, d.TokenHash, d.CreatedAt, d.LastSeenAt, d.LastIP, nullTime(d.RevokedAt),
+		)
+	case r.DisabledOwner != nil:
+		// MemoryStorage не хранит время отключения
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO disabled_owners (fingerprint, disabled_at) VALUES ($1, coalesce($2, now()))`,
+			r.DisabledOwner.Fingerprint, nullTime(r.DisabledOwner.DisabledAt),
+		)
+	case r.IdempotencyKey != nil:
+		k := r.IdempotencyKey
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO idempotency_keys (owner_hash, key, entry_id, request_hash, created_at)
+			VALUES ($1, $2, $3, $4, $5)`,
+			k.OwnerHash, k.Key, k.EntryID, k.RequestHash, k.CreatedAt,
+		)
+	default:
+		err = errors.New("empty record")
+	}
+
+	return err
+}
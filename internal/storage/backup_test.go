@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerStorage_Export(t *testing.T) {
+	t.Run("fail", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("FROM entries").WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		s := ServerStorage{db: db}
+		err = s.Export(context.Background(), func(BackupRecord) error { return nil })
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestServerStorage_Import(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		owner := []byte{1, 2, 3}
+		id := uuid.New()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT EXISTS").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectExec("INSERT INTO entries").
+			WithArgs(id, owner, Fingerprint(owner), []byte("data"), []byte(nil), int64(2),
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE entries SET revision").WithArgs(int64(5), id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO owner_revisions").WithArgs(owner, int64(7), int64(0)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("setval").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		records := []BackupRecord{
+			{Entry: &BackupEntry{ID: id, PublicKey: owner, Payload: []byte("data"), Revision: 5, Version: 2}},
+			{Revision: &BackupRevision{PublicKey: owner, Revision: 7}},
+		}
+		i := 0
+		s := ServerStorage{db: db}
+		err = s.Import(context.Background(), func() (BackupRecord, error) {
+			if i == len(records) {
+				return BackupRecord{}, io.EOF
+			}
+			i++
+			return records[i-1], nil
+		})
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not empty", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT EXISTS").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectRollback()
+
+		s := ServerStorage{db: db}
+		err = s.Import(context.Background(), func() (BackupRecord, error) { return BackupRecord{}, io.EOF })
+		assert.ErrorIs(t, err, ErrNotEmpty)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("next fails", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT EXISTS").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectRollback()
+
+		s := ServerStorage{db: db}
+		err = s.Import(context.Background(), func() (BackupRecord, error) {
+			return BackupRecord{}, io.ErrUnexpectedEOF
+		})
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// BatchOpKind - вид операции пакета.
+type BatchOpKind int
+
+const (
+	// BatchCreate - добавить запись владельца PublicKey.
+	BatchCreate BatchOpKind = iota + 1
+	// BatchUpdate - обновить запись ID, если ее версия равна Version.
+	BatchUpdate
+	// BatchDelete - переместить запись ID в корзину, если ее версия равна Version.
+	BatchDelete
+)
+
+// BatchOp - операция пакета. Поля имеют тот же смысл, что и аргументы Create, Update и Delete.
+type BatchOp struct {
+	Kind      BatchOpKind
+	ID        uuid.UUID
+	PublicKey []byte
+	Data      []byte
+	Version   int64
+	ExpiresAt time.Time
+}
+
+// BatchResult - результат операции пакета: ID записи и ее версия после операции.
+type BatchResult struct {
+	ID      uuid.UUID
+	Version int64
+}
+
+// BatchError - операция пакета с номером Index не выполнена, весь пакет откатан.
+type BatchError struct {
+	// Index - номер операции в пакете.
+	Index int
+	// Err - причина: ErrNotFound, *VersionConflictError или ошибка хранилища.
+	Err error
+}
+
+// Error - текст ошибки.
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operation %d: %s", e.Index, e.Err)
+}
+
+// Unwrap - для errors.Is и errors.As по причине.
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Batch - выполнить операции в одной транзакции: либо все, либо ни одной.
+// Результаты возвращаются в порядке операций, при ошибке операции возвращается *BatchError.
+func (s *ServerStorage) Batch(ctx context.Context, ops []BatchOp) ([]BatchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("batch", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage Batch", "BATCH")
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ServerStorage Batch: begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	results := make([]BatchResult, 0, len(ops))
+	for i, op := range ops {
+		res := BatchResult{ID: op.ID}
+		switch op.Kind {
+		case BatchCreate:
+			res.ID, err = createEntry(ctx, tx, op.PublicKey, op.Data, op.ExpiresAt)
+			res.Version = 1
+		case BatchUpdate:
+			res.Version, err = s.updateEntry(ctx, tx, op.ID, op.Data, op.Version, op.ExpiresAt)
+		case BatchDelete:
+			err = deleteEntry(ctx, tx, op.ID, op.Version)
+			res.Version = op.Version
+		default:
+			err = fmt.Errorf("unknown operation kind %d", op.Kind)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ServerStorage Batch: %w", &BatchError{Index: i, Err: err})
+		}
+		results = append(results, res)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("ServerStorage Batch: commit: %w", err)
+	}
+
+	return results, nil
+}
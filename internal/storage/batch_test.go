@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerStorage_Batch(t *testing.T) {
+	created := uuid.New()
+	updated := uuid.New()
+	deleted := uuid.New()
+	ops := []BatchOp{
+		{Kind: BatchCreate, PublicKey: []byte{9}, Data: []byte{1}},
+		{Kind: BatchUpdate, ID: updated, Data: []byte{2}, Version: 3},
+		{Kind: BatchDelete, ID: deleted, Version: 1},
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO entries").WithArgs([]byte{9}, Fingerprint([]byte{9}), []byte{1}, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(created))
+		mock.ExpectQuery("UPDATE entries SET payload").WithArgs([]byte{2}, updated, int64(3), 0, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"applied", "current"}).AddRow(int64(4), int64(3)))
+		mock.ExpectQuery("UPDATE entries SET deleted_at").WithArgs(deleted, int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"applied", "current"}).AddRow(int64(1), int64(1)))
+		mock.ExpectCommit()
+
+		s := ServerStorage{db: db}
+		results, err := s.Batch(context.Background(), ops)
+		require.NoError(t, err)
+		assert.Equal(t, []BatchResult{
+			{ID: created, Version: 1},
+			{ID: updated, Version: 4},
+			{ID: deleted, Version: 1},
+		}, results)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("conflict rolls back", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO entries").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(created))
+		mock.ExpectQuery("UPDATE entries SET payload").
+			WillReturnRows(sqlmock.NewRows([]string{"applied", "current"}).AddRow(nil, int64(5)))
+		mock.ExpectRollback()
+
+		s := ServerStorage{db: db}
+		_, err = s.Batch(context.Background(), ops)
+		var batchErr *BatchError
+		require.ErrorAs(t, err, &batchErr)
+		assert.Equal(t, 1, batchErr.Index)
+		var conflict *VersionConflictError
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, int64(5), conflict.Current)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("commit error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO entries").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(created))
+		mock.ExpectCommit().WillReturnError(sql.ErrConnDone)
+
+		s := ServerStorage{db: db}
+		_, err = s.Batch(context.Background(), ops[:1])
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+		var batchErr *BatchError
+		assert.False(t, errors.As(err, &batchErr))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,526 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/blobstore"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// ErrBlobCorrupted - данные объекта не совпадают с хешем из ссылки.
+var ErrBlobCorrupted = errors.New("blob hash mismatch")
+
+// blobRefPrefix - начало ссылки на объект, которая хранится в payload вместо данных.
+// За ним следуют ключ объекта (UUID, 16 байт) и SHA-256 данных (32 байта).
+// Миграции 19 и 20 повторяют этот формат в столбцах blob_key, менять его нельзя.
+var blobRefPrefix = []byte("\x00gophkeeper-blob\x00")
+
+// blobRefLen - длина ссылки на объект.
+var blobRefLen = len(blobRefPrefix) + len(uuid.UUID{}) + sha256.Size
+
+// BlobStorage - хранилище, которое выносит данные записей больше threshold байт и все части файлов
+// в blobstore.Store, а в базе оставляет только ссылку: ключ объекта и SHA-256 данных.
+// При чтении данные подставляются обратно и сверяются с хешем, поэтому вынос
+// незаметен для grpc сервера. Каждая версия записи получает новый объект,
+// объекты без ссылок удаляет CollectGarbage.
+type BlobStorage struct {
+	Storage
+	blobs     blobstore.Store
+	threshold int
+}
+
+// NewBlobStorage - обернуть хранилище s: данные записей больше threshold байт хранятся в blobs.
+func NewBlobStorage(s Storage, blobs blobstore.Store, threshold int) *BlobStorage {
+	return &BlobStorage{
+		Storage:   s,
+		blobs:     blobs,
+		threshold: threshold,
+	}
+}
+
+// parseBlobRef - разобрать ссылку на объект, ok == false, если payload содержит сами данные.
+func parseBlobRef(payload []byte) (key uuid.UUID, sum []byte, ok bool) {
+	if len(payload) != blobRefLen || !bytes.HasPrefix(payload, blobRefPrefix) {
+		return uuid.Nil, nil, false
+	}
+
+	rest := payload[len(blobRefPrefix):]
+	copy(key[:], rest)
+
+	return key, rest[len(key):], true
+}
+
+// offload - сохранить большие данные в объект и вернуть ссылку на него, маленькие вернуть как есть.
+// Данные, похожие на ссылку, выносятся всегда, чтобы клиент не мог сослаться на чужой объект.
+func (s *BlobStorage) offload(ctx context.Context, data []byte) (payload []byte, key uuid.UUID, err error) {
+	if len(data) <= s.threshold && !bytes.HasPrefix(data, blobRefPrefix) {
+		return data, uuid.Nil, nil
+	}
+
+	return s.put(ctx, data)
+}
+
+// put - сохранить данные в новый объект и вернуть ссылку на него.
+func (s *BlobStorage) put(ctx context.Context, data []byte) (payload []byte, key uuid.UUID, err error) {
+	defer metrics.ObserveQuery("blob_put", time.Now())
+
+	key = uuid.New()
+	err = s.blobs.Put(ctx, key.String(), data)
+	if err != nil {
+		return nil, uuid.Nil, fmt.Errorf("put blob: %w", err)
+	}
+
+	return blobRef(key, data), key, nil
+}
+
+// blobRef - ссылка на объект key с данными data.
+func blobRef(key uuid.UUID, data []byte) []byte {
+	sum := sha256.Sum256(data)
+	ref := make([]byte, 0, blobRefLen)
+	ref = append(ref, blobRefPrefix...)
+	ref = append(ref, key[:]...)
+	ref = append(ref, sum[:]...)
+
+	return ref
+}
+
+// discard - удалить объект, на который не удалось сослаться. Если удалить не получилось,
+// объект удалит CollectGarbage.
+func (s *BlobStorage) discard(ctx context.Context, key uuid.UUID) {
+	if key != uuid.Nil {
+		_ = s.blobs.Delete(ctx, key.String())
+	}
+}
+
+// load - получить данные по ссылке из payload и проверить их хеш.
+func (s *BlobStorage) load(ctx context.Context, payload []byte) ([]byte, error) {
+	key, sum, ok := parseBlobRef(payload)
+	if !ok {
+		return payload, nil
+	}
+	defer metrics.ObserveQuery("blob_get", time.Now())
+
+	data, err := s.blobs.Get(ctx, key.String())
+	if err != nil {
+		return nil, fmt.Errorf("get blob %s: %w", key, err)
+	}
+
+	actual := sha256.Sum256(data)
+	if !bytes.Equal(actual[:], sum) {
+		return nil, fmt.Errorf("get blob %s: %w", key, ErrBlobCorrupted)
+	}
+
+	return data, nil
+}
+
+func (s *BlobStorage) loadEntries(ctx context.Context, entries []Entry) error {
+	for i := range entries {
+		data, err := s.load(ctx, entries[i].Payload)
+		if err != nil {
+			return err
+		}
+		entries[i].Payload = data
+	}
+
+	return nil
+}
+
+// Get - получить запись по ID.
+func (s *BlobStorage) Get(ctx context.Context, id uuid.UUID) (Entry, error) {
+	e, err := s.Storage.Get(ctx, id)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	e.Payload, err = s.load(ctx, e.Payload)
+	if err != nil {
+		return Entry{}, fmt.Errorf("BlobStorage Get: %w", err)
+	}
+
+	return e, nil
+}
+
+// GetAll - получить страницу записей владельца.
+func (s *BlobStorage) GetAll(ctx context.Context, publicKey []byte, after uuid.UUID, limit int) ([]Entry, error) {
+	entries, err := s.Storage.GetAll(ctx, publicKey, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.loadEntries(ctx, entries)
+	if err != nil {
+		return nil, fmt.Errorf("BlobStorage GetAll: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetChanges - получить изменения владельца после ревизии since.
+func (s *BlobStorage) GetChanges(ctx context.Context, publicKey []byte, since int64) (ChangeSet, error) {
+	cs, err := s.Storage.GetChanges(ctx, publicKey, since)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+
+	err = s.loadEntries(ctx, cs.Entries)
+	if err != nil {
+		return ChangeSet{}, fmt.Errorf("BlobStorage GetChanges: %w", err)
+	}
+
+	return cs, nil
+}
+
+// Create - добавить запись и вернуть ID.
+func (s *BlobStorage) Create(ctx context.Context, publicKey []byte, data []byte,
+	expiresAt time.Time,
+) (uuid.UUID, error) {
+	payload, key, err := s.offload(ctx, data)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("BlobStorage Create: %w", err)
+	}
+
+	id, err := s.Storage.Create(ctx, publicKey, payload, expiresAt)
+	if err != nil {
+		s.discard(ctx, key)
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+// CreateIdempotent - добавить запись по ключу идемпотентности. Если запись уже создана
+// по этому ключу, вынесенные данные повторного запроса удаляются.
+func (s *BlobStorage) CreateIdempotent(ctx context.Context, k IdempotencyKey, publicKey, data []byte,
+	expiresAt time.Time,
+) (uuid.UUID, bool, error) {
+	payload, key, err := s.offload(ctx, data)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("BlobStorage CreateIdempotent: %w", err)
+	}
+
+	id, created, err := s.Storage.CreateIdempotent(ctx, k, publicKey, payload, expiresAt)
+	if err != nil || !created {
+		s.discard(ctx, key)
+	}
+
+	return id, created, err
+}
+
+// Update - обновить запись, если ее версия равна version, и вернуть новую версию.
+// Объект предыдущей версии остается, пока на него ссылается история.
+func (s *BlobStorage) Update(ctx context.Context, id uuid.UUID, data []byte, version int64,
+	expiresAt time.Time,
+) (int64, error) {
+	payload, key, err := s.offload(ctx, data)
+	if err != nil {
+		return 0, fmt.Errorf("BlobStorage Update: %w", err)
+	}
+
+	v, err := s.Storage.Update(ctx, id, payload, version, expiresAt)
+	if err != nil {
+		s.discard(ctx, key)
+		return 0, err
+	}
+
+	return v, nil
+}
+
+// Batch - выполнить операции в одной транзакции. Если пакет не применен,
+// вынесенные данные его операций удаляются.
+func (s *BlobStorage) Batch(ctx context.Context, ops []BatchOp) ([]BatchResult, error) {
+	offloaded := make([]BatchOp, len(ops))
+	keys := make([]uuid.UUID, 0)
+	discardAll := func() {
+		for _, key := range keys {
+			s.discard(ctx, key)
+		}
+	}
+
+	for i, op := range ops {
+		if op.Kind == BatchCreate || op.Kind == BatchUpdate {
+			payload, key, err := s.offload(ctx, op.Data)
+			if err != nil {
+				discardAll()
+				return nil, fmt.Errorf("BlobStorage Batch: %w", &BatchError{Index: i, Err: err})
+			}
+			op.Data = payload
+			keys = append(keys, key)
+		}
+		offloaded[i] = op
+	}
+
+	results, err := s.Storage.Batch(ctx, offloaded)
+	if err != nil {
+		discardAll()
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// History - получить сохраненные предыдущие версии записи.
+func (s *BlobStorage) History(ctx context.Context, id uuid.UUID) ([]Entry, error) {
+	entries, err := s.Storage.History(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.loadEntries(ctx, entries)
+	if err != nil {
+		return nil, fmt.Errorf("BlobStorage History: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetVersion - получить предыдущую версию записи из истории.
+func (s *BlobStorage) GetVersion(ctx context.Context, id uuid.UUID, version int64) (Entry, error) {
+	e, err := s.Storage.GetVersion(ctx, id, version)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	e.Payload, err = s.load(ctx, e.Payload)
+	if err != nil {
+		return Entry{}, fmt.Errorf("BlobStorage GetVersion: %w", err)
+	}
+
+	return e, nil
+}
+
+// ListTrash - получить записи владельца из корзины.
+func (s *BlobStorage) ListTrash(ctx context.Context, publicKey []byte) ([]Entry, error) {
+	entries, err := s.Storage.ListTrash(ctx, publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.loadEntries(ctx, entries)
+	if err != nil {
+		return nil, fmt.Errorf("BlobStorage ListTrash: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetTrashed - получить запись из корзины по ID.
+func (s *BlobStorage) GetTrashed(ctx context.Context, id uuid.UUID) (Entry, error) {
+	e, err := s.Storage.GetTrashed(ctx, id)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	e.Payload, err = s.load(ctx, e.Payload)
+	if err != nil {
+		return Entry{}, fmt.Errorf("BlobStorage GetTrashed: %w", err)
+	}
+
+	return e, nil
+}
+
+// CommitUpload - создать запись из загрузки и вернуть ID записи.
+func (s *BlobStorage) CommitUpload(ctx context.Context, uploadID uuid.UUID,
+	publicKey, data, manifest []byte, chunkHashes [][]byte,
+) (uuid.UUID, error) {
+	payload, key, err := s.offload(ctx, data)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("BlobStorage CommitUpload: %w", err)
+	}
+
+	id, err := s.Storage.CommitUpload(ctx, uploadID, publicKey, payload, manifest, chunkHashes)
+	if err != nil {
+		s.discard(ctx, key)
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+// SaveUploadChunk - сохранить часть загружаемого файла в объект, а в базе оставить ссылку на него.
+// Объект перезаписанной части удалит CollectGarbage.
+func (s *BlobStorage) SaveUploadChunk(ctx context.Context, uploadID uuid.UUID,
+	publicKey []byte, idx uint32, hash, data []byte,
+) error {
+	ref, key, err := s.put(ctx, data)
+	if err != nil {
+		return fmt.Errorf("BlobStorage SaveUploadChunk: %w", err)
+	}
+
+	err = s.Storage.SaveUploadChunk(ctx, uploadID, publicKey, idx, hash, ref)
+	if err != nil {
+		s.discard(ctx, key)
+		return err
+	}
+
+	return nil
+}
+
+// GetChunk - получить часть файла записи.
+func (s *BlobStorage) GetChunk(ctx context.Context, id uuid.UUID, idx uint32) ([]byte, error) {
+	ref, err := s.Storage.GetChunk(ctx, id, idx)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := s.load(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("BlobStorage GetChunk: %w", err)
+	}
+
+	return data, nil
+}
+
+// CollectGarbage - удалить объекты, созданные раньше before, на которые не ссылается
+// ни одна запись, в том числе в корзине и в истории, часть файла или загрузка, и вернуть их количество.
+// Объект сохраняется до того, как на него сошлется запись, поэтому before должен отставать
+// от текущего времени больше, чем выполняется запрос к базе. Ключи объектов не повторяются,
+// поэтому объект, на который перестали ссылаться, больше не понадобится.
+func (s *BlobStorage) CollectGarbage(ctx context.Context, before time.Time) (int64, error) {
+	candidates := make([]string, 0)
+	err := s.blobs.List(ctx, func(o blobstore.Object) error {
+		if o.ModTime.Before(before) {
+			candidates = append(candidates, o.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("BlobStorage CollectGarbage: list blobs: %w", err)
+	}
+	if len(candidates) == 0 {
+		return 0, nil
+	}
+
+	keys, err := s.BlobKeys(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("BlobStorage CollectGarbage: %w", err)
+	}
+
+	used := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		used[key.String()] = struct{}{}
+	}
+
+	var n int64
+	for _, key := range candidates {
+		if _, ok := used[key]; ok {
+			continue
+		}
+
+		err = s.blobs.Delete(ctx, key)
+		if err != nil {
+			return n, fmt.Errorf("BlobStorage CollectGarbage: %w", err)
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// Export - передать в fn все данные хранилища с подставленными вынесенными данными,
+// чтобы резервная копия не зависела от хранилища объектов.
+func (s *BlobStorage) Export(ctx context.Context, fn func(BackupRecord) error) error {
+	return s.Storage.Export(ctx, func(r BackupRecord) error {
+		var err error
+		switch {
+		case r.Entry != nil:
+			r.Entry.Payload, err = s.load(ctx, r.Entry.Payload)
+		case r.Version != nil:
+			r.Version.Payload, err = s.load(ctx, r.Version.Payload)
+		case r.Chunk != nil:
+			r.Chunk.Data, err = s.load(ctx, r.Chunk.Data)
+		}
+		if err != nil {
+			return fmt.Errorf("BlobStorage Export: %w", err)
+		}
+
+		return fn(r)
+	})
+}
+
+// Import - загрузить в пустое хранилище записи из next, вынося большие данные в объекты.
+// Если загрузка не удалась, объекты без ссылок удалит CollectGarbage.
+func (s *BlobStorage) Import(ctx context.Context, next func() (BackupRecord, error)) error {
+	return s.Storage.Import(ctx, func() (BackupRecord, error) {
+		r, err := next()
+		if err != nil {
+			return r, err
+		}
+
+		switch {
+		case r.Entry != nil:
+			r.Entry.Payload, _, err = s.offload(ctx, r.Entry.Payload)
+		case r.Version != nil:
+			r.Version.Payload, _, err = s.offload(ctx, r.Version.Payload)
+		case r.Chunk != nil:
+			r.Chunk.Data, _, err = s.put(ctx, r.Chunk.Data)
+		}
+		if err != nil {
+			return r, fmt.Errorf("BlobStorage Import: %w", err)
+		}
+
+		return r, nil
+	})
+}
+
+// BlobKeys - получить ключи объектов, на которые ссылаются записи, в том числе в корзине и истории,
+// части файлов и незавершенные загрузки.
+// Ключи берутся из индексированного столбца blob_key, который база вычисляет из payload.
+func (s *ServerStorage) BlobKeys(ctx context.Context) ([]uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("blob_keys", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage BlobKeys", "SELECT")
+	defer span.End()
+
+	keys, err := blobKeys(ctx, s.db,
+		`SELECT blob_key FROM entries WHERE blob_key IS NOT NULL
+		UNION
+		SELECT blob_key FROM entry_history WHERE blob_key IS NOT NULL
+		UNION
+		SELECT blob_key FROM chunks WHERE blob_key IS NOT NULL
+		UNION
+		SELECT blob_key FROM uploads WHERE blob_key IS NOT NULL`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ServerStorage BlobKeys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// blobKeys - выполнить запрос ключей объектов из столбцов blob_key.
+func blobKeys(ctx context.Context, db *sql.DB, query string) ([]uuid.UUID, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	keys := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var raw []byte
+		err = rows.Scan(&raw)
+		if err != nil {
+			return nil, fmt.Errorf("query rows scan: %w", err)
+		}
+		key, parseErr := uuid.FromBytes(raw)
+		if parseErr != nil {
+			return nil, fmt.Errorf("parse blob key: %w", parseErr)
+		}
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("query rows: %w", err)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/blobstore"
+)
+
+func newTestBlobStorage(t *testing.T, historyLimit, threshold int) (*BlobStorage, *MemoryStorage, *blobstore.FS) {
+	blobs, err := blobstore.NewFS(t.TempDir())
+	require.NoError(t, err)
+
+	m := NewMemoryStorage(historyLimit)
+	return NewBlobStorage(m, blobs, threshold), m, blobs
+}
+
+func countBlobs(t *testing.T, blobs blobstore.Store) int {
+	n := 0
+	err := blobs.List(context.Background(), func(blobstore.Object) error {
+		n++
+		return nil
+	})
+	require.NoError(t, err)
+	return n
+}
+
+func TestBlobStorage_Offload(t *testing.T) {
+	ctx := context.Background()
+	s, m, blobs := newTestBlobStorage(t, 1, 8)
+	owner := newOwner()
+
+	small, err := s.Create(ctx, owner, []byte("small"), time.Time{})
+	require.NoError(t, err)
+	e, err := m.Get(ctx, small)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("small"), e.Payload)
+
+	large := bytes.Repeat([]byte("x"), 100)
+	id, err := s.Create(ctx, owner, large, time.Time{})
+	require.NoError(t, err)
+	e, err = m.Get(ctx, id)
+	require.NoError(t, err)
+	assert.Len(t, e.Payload, blobRefLen)
+	assert.Equal(t, 1, countBlobs(t, blobs))
+
+	e, err = s.Get(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, large, e.Payload)
+
+	// короткие данные, похожие на ссылку, тоже выносятся
+	id2, err := s.Create(ctx, owner, blobRefPrefix, time.Time{})
+	require.NoError(t, err)
+	e, err = m.Get(ctx, id2)
+	require.NoError(t, err)
+	assert.NotEqual(t, blobRefPrefix, e.Payload)
+	e, err = s.Get(ctx, id2)
+	require.NoError(t, err)
+	assert.Equal(t, blobRefPrefix, e.Payload)
+
+	// при конфликте версий новый объект удаляется сразу
+	_, err = s.Update(ctx, id, bytes.Repeat([]byte("y"), 100), 5, time.Time{})
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	assert.Equal(t, 2, countBlobs(t, blobs))
+
+	_, err = s.Update(ctx, id, bytes.Repeat([]byte("y"), 100), 1, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, countBlobs(t, blobs))
+
+	history, err := s.History(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, large, history[0].Payload)
+
+	all, err := s.GetAll(ctx, owner, uuid.Nil, 10)
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+	for _, e := range all {
+		_, _, isRef := parseBlobRef(e.Payload)
+		assert.False(t, isRef)
+	}
+}
+
+func TestBlobStorage_Chunks(t *testing.T) {
+	ctx := context.Background()
+	s, m, blobs := newTestBlobStorage(t, 1, 1<<20)
+	owner := newOwner()
+
+	// части файлов выносятся независимо от порога, повторная отправка части создает новый объект
+	uploadID := uuid.New()
+	require.NoError(t, s.SaveUploadChunk(ctx, uploadID, owner, 0, []byte("h0"), []byte("chunk 0")))
+	require.NoError(t, s.SaveUploadChunk(ctx, uploadID, owner, 1, []byte("h1"), []byte("stale")))
+	require.NoError(t, s.SaveUploadChunk(ctx, uploadID, owner, 1, []byte("h1"), []byte("chunk 1")))
+	pending := uuid.New()
+	require.NoError(t, s.SaveUploadChunk(ctx, pending, owner, 0, []byte("h0"), []byte("pending")))
+	assert.Equal(t, 4, countBlobs(t, blobs))
+
+	// незавершенные загрузки держат свои объекты, перезаписанная часть удаляется
+	n, err := s.CollectGarbage(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	id, err := s.CommitUpload(ctx, uploadID, owner, []byte("file"), []byte("manifest"),
+		[][]byte{[]byte("h0"), []byte("h1")})
+	require.NoError(t, err)
+
+	ref, err := m.GetChunk(ctx, id, 1)
+	require.NoError(t, err)
+	_, _, isRef := parseBlobRef(ref)
+	assert.True(t, isRef)
+	data, err := s.GetChunk(ctx, id, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("chunk 1"), data)
+
+	n, err = s.CollectGarbage(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	assert.Equal(t, 3, countBlobs(t, blobs))
+
+	// резервная копия содержит сами данные частей
+	var chunks [][]byte
+	require.NoError(t, s.Export(ctx, func(r BackupRecord) error {
+		if r.Chunk != nil {
+			chunks = append(chunks, r.Chunk.Data)
+		}
+		return nil
+	}))
+	assert.ElementsMatch(t, [][]byte{[]byte("chunk 0"), []byte("chunk 1")}, chunks)
+}
+
+func TestBlobStorage_Load(t *testing.T) {
+	ctx := context.Background()
+	s, m, blobs := newTestBlobStorage(t, 1, 0)
+	owner := newOwner()
+
+	id, err := s.Create(ctx, owner, []byte("secret"), time.Time{})
+	require.NoError(t, err)
+	e, err := m.Get(ctx, id)
+	require.NoError(t, err)
+	key, _, ok := parseBlobRef(e.Payload)
+	require.True(t, ok)
+
+	require.NoError(t, blobs.Put(ctx, key.String(), []byte("tampered")))
+	_, err = s.Get(ctx, id)
+	assert.ErrorIs(t, err, ErrBlobCorrupted)
+
+	require.NoError(t, blobs.Delete(ctx, key.String()))
+	_, err = s.Get(ctx, id)
+	assert.ErrorIs(t, err, blobstore.ErrNotFound)
+}
+
+func TestBlobStorage_CollectGarbage(t *testing.T) {
+	ctx := context.Background()
+	s, _, blobs := newTestBlobStorage(t, 1, 0)
+	owner := newOwner()
+
+	id, err := s.Create(ctx, owner, []byte("v1"), time.Time{})
+	require.NoError(t, err)
+	for v := int64(1); v <= 3; v++ {
+		_, err = s.Update(ctx, id, []byte{byte(v)}, v, time.Time{})
+		require.NoError(t, err)
+	}
+	trashed, err := s.Create(ctx, owner, []byte("trashed"), time.Time{})
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(ctx, trashed, 1))
+	purged, err := s.Create(ctx, owner, []byte("purged"), time.Time{})
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(ctx, purged, 1))
+	require.NoError(t, s.Purge(ctx, purged))
+	require.Equal(t, 6, countBlobs(t, blobs))
+
+	// объекты моложе before не трогаем, даже если на них нет ссылок
+	n, err := s.CollectGarbage(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+
+	// остаются текущая версия, одна версия в истории и запись в корзине
+	n, err = s.CollectGarbage(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.Equal(t, 3, countBlobs(t, blobs))
+
+	e, err := s.Get(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{3}, e.Payload)
+	e, err = s.GetVersion(ctx, id, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{2}, e.Payload)
+	e, err = s.GetTrashed(ctx, trashed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("trashed"), e.Payload)
+}
+
+func TestServerStorage_BlobKeys(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		first, second := uuid.New(), uuid.New()
+		rows := sqlmock.NewRows([]string{"blob_key"}).AddRow(first[:]).AddRow(second[:])
+		mock.ExpectQuery("SELECT blob_key FROM entries").WillReturnRows(rows)
+
+		s := ServerStorage{db: db}
+		keys, err := s.BlobKeys(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{first, second}, keys)
+	})
+
+	t.Run("bad key", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		rows := sqlmock.NewRows([]string{"blob_key"}).AddRow([]byte("short"))
+		mock.ExpectQuery("SELECT blob_key FROM entries").WillReturnRows(rows)
+
+		s := ServerStorage{db: db}
+		_, err = s.BlobKeys(context.Background())
+		assert.ErrorContains(t, err, "parse blob key")
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectQuery("FROM entries").WillReturnError(sql.ErrConnDone)
+
+		s := ServerStorage{db: db}
+		_, err = s.BlobKeys(context.Background())
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+	})
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// changesChannel - канал Postgres LISTEN/NOTIFY для событий изменения записей.
+const changesChannel = "gophkeeper_changes"
+
+// ChangeType - тип изменения записи.
+type ChangeType string
+
+const (
+	ChangeCreated ChangeType = "created"
+	ChangeUpdated ChangeType = "updated"
+	ChangeDeleted ChangeType = "deleted"
+)
+
+// Change - событие изменения записи, которое рассылается всем экземплярам сервера.
+type Change struct {
+	Type      ChangeType `json:"type"`
+	ID        uuid.UUID  `json:"id"`
+	PublicKey []byte     `json:"public_key"`
+	ClientID  string     `json:"client_id,omitempty"`
+}
+
+// Notify - разослать событие изменения записи через NOTIFY.
+func (s *ServerStorage) Notify(ctx context.Context, c Change) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("notify", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage Notify", "NOTIFY")
+	defer span.End()
+
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("ServerStorage Notify: marshal: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, changesChannel, string(payload))
+	if err != nil {
+		return fmt.Errorf("ServerStorage Notify: exec: %w", err)
+	}
+
+	return nil
+}
+
+// Listen - слушать события изменения записей через LISTEN и передавать их в handle.
+// Блокируется до отмены ctx. При обрыве соединения переподключается сам,
+// события за время обрыва теряются.
+func (s *ServerStorage) Listen(ctx context.Context, handle func(Change)) error {
+	l := pq.NewListener(s.dsn, time.Second, time.Minute, nil)
+	defer func() { _ = l.Close() }()
+
+	err := l.Listen(changesChannel)
+	if err != nil {
+		return fmt.Errorf("ServerStorage Listen: listen: %w", err)
+	}
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-l.Notify:
+			if n == nil {
+				// соединение было восстановлено
+				continue
+			}
+
+			c := Change{}
+			err = json.Unmarshal([]byte(n.Extra), &c)
+			if err != nil {
+				continue
+			}
+			handle(c)
+		case <-ticker.C:
+			// проверяем, что соединение живо, иначе listener переподключится
+			_ = l.Ping()
+		}
+	}
+}
+
+// localChanges - рассылка событий изменений слушателям внутри процесса
+// для хранилищ, которые не используются несколькими экземплярами сервера.
+type localChanges struct {
+	mu        sync.Mutex
+	listeners map[int]func(Change)
+	nextID    int
+}
+
+func (l *localChanges) notify(c Change) {
+	l.mu.Lock()
+	handlers := make([]func(Change), 0, len(l.listeners))
+	for _, h := range l.listeners {
+		handlers = append(handlers, h)
+	}
+	l.mu.Unlock()
+
+	for _, h := range handlers {
+		h(c)
+	}
+}
+
+// listen - передавать события в handle до отмены ctx.
+func (l *localChanges) listen(ctx context.Context, handle func(Change)) {
+	l.mu.Lock()
+	if l.listeners == nil {
+		l.listeners = make(map[int]func(Change))
+	}
+	id := l.nextID
+	l.nextID++
+	l.listeners[id] = handle
+	l.mu.Unlock()
+
+	<-ctx.Done()
+
+	l.mu.Lock()
+	delete(l.listeners, id)
+	l.mu.Unlock()
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerStorage_Notify(t *testing.T) {
+	c := Change{
+		Type:      ChangeCreated,
+		ID:        uuid.New(),
+		PublicKey: []byte{1, 2, 3},
+		ClientID:  "client",
+	}
+	payload, err := json.Marshal(c)
+	require.NoError(t, err)
+
+	t.Run("ok", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectExec("SELECT pg_notify").
+			WithArgs(changesChannel, string(payload)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		s := ServerStorage{db: db}
+		err = s.Notify(context.Background(), c)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectExec("SELECT pg_notify").WillReturnError(sql.ErrConnDone)
+
+		s := ServerStorage{db: db}
+		err = s.Notify(context.Background(), c)
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,1055 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/blobstore"
+)
+
+// contractHistoryLimit - сколько версий хранят хранилища в контрактных тестах.
+const contractHistoryLimit = 2
+
+// backends - реализации Storage, на которых выполняются контрактные тесты.
+// SQLite проверяется на временном файле, BlobStorage - поверх памяти с выносом всех данных в каталог.
+// Postgres проверяется, если задана переменная окружения TEST_POSTGRES_DSN.
+func backends(t *testing.T) map[string]func(t *testing.T) Storage {
+	b := map[string]func(t *testing.T) Storage{
+		"memory": func(t *testing.T) Storage {
+			return NewMemoryStorage(contractHistoryLimit)
+		},
+		"sqlite": func(t *testing.T) Storage {
+			dsn := SQLiteScheme + filepath.Join(t.TempDir(), "keeper.db")
+			s, err := NewSQLiteStorage(Config{DSN: dsn, HistoryLimit: contractHistoryLimit})
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = s.Close() })
+			return s
+		},
+		"blob": func(t *testing.T) Storage {
+			blobs, err := blobstore.NewFS(t.TempDir())
+			require.NoError(t, err)
+			return NewBlobStorage(NewMemoryStorage(contractHistoryLimit), blobs, 0)
+		},
+	}
+
+	if dsn := os.Getenv("TEST_POSTGRES_DSN"); dsn != "" {
+		b["postgres"] = func(t *testing.T) Storage {
+			s, err := NewServerStorage(Config{DSN: dsn, HistoryLimit: contractHistoryLimit})
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = s.Close() })
+			return s
+		}
+	}
+
+	return b
+}
+
+// newOwner - случайный публичный ключ, чтобы тесты не пересекались на общей базе.
+func newOwner() []byte {
+	id := uuid.New()
+	return id[:]
+}
+
+func TestStorageContract(t *testing.T) {
+	for name, newStorage := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			t.Run("entries", func(t *testing.T) { testEntries(t, newStorage(t)) })
+			t.Run("concurrent updates", func(t *testing.T) { testConcurrentUpdates(t, newStorage(t)) })
+			t.Run("pagination", func(t *testing.T) { testPagination(t, newStorage(t)) })
+			t.Run("changes", func(t *testing.T) { testChanges(t, newStorage(t)) })
+			t.Run("tombstone retention", func(t *testing.T) { testTombstoneRetention(t, newStorage(t)) })
+			t.Run("history", func(t *testing.T) { testHistory(t, newStorage(t)) })
+			t.Run("blob keys", func(t *testing.T) { testBlobKeys(t, newStorage(t)) })
+			t.Run("trash", func(t *testing.T) { testTrash(t, newStorage(t)) })
+			t.Run("expiry", func(t *testing.T) { testExpiry(t, newStorage(t)) })
+			t.Run("idempotency", func(t *testing.T) { testIdempotency(t, newStorage(t)) })
+			t.Run("batch", func(t *testing.T) { testBatch(t, newStorage(t)) })
+			t.Run("files", func(t *testing.T) { testFiles(t, newStorage(t)) })
+			t.Run("notify", func(t *testing.T) { testNotify(t, newStorage(t)) })
+			t.Run("audit", func(t *testing.T) { testAudit(t, newStorage(t)) })
+			t.Run("account", func(t *testing.T) { testAccount(t, newStorage(t)) })
+			t.Run("devices", func(t *testing.T) { testDevices(t, newStorage(t)) })
+			t.Run("owners", func(t *testing.T) { testOwners(t, newStorage(t)) })
+			t.Run("read-only mode", func(t *testing.T) { testReadOnlyMode(t, newStorage(t)) })
+			t.Run("backup", func(t *testing.T) { testBackup(t, newStorage) })
+		})
+	}
+}
+
+func testEntries(t *testing.T, s Storage) {
+	ctx := context.Background()
+	owner := newOwner()
+
+	id, err := s.Create(ctx, owner, []byte("v1"), time.Time{})
+	require.NoError(t, err)
+
+	e, err := s.Get(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, owner, e.PublicKey)
+	assert.Equal(t, []byte("v1"), e.Payload)
+	assert.Equal(t, int64(1), e.Version)
+	assert.False(t, e.CreatedAt.IsZero())
+
+	version, err := s.Update(ctx, id, []byte("v2"), 1, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), version)
+
+	_, err = s.Update(ctx, id, []byte("v3"), 1, time.Time{})
+	var conflict *VersionConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, int64(2), conflict.Current)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+
+	err = s.Delete(ctx, id, 1)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+
+	require.NoError(t, s.Delete(ctx, id, 2))
+
+	_, err = s.Get(ctx, id)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.Update(ctx, id, []byte("v3"), 2, time.Time{})
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, s.Delete(ctx, uuid.New(), 1), ErrNotFound)
+}
+
+func testConcurrentUpdates(t *testing.T, s Storage) {
+	ctx := context.Background()
+	owner := newOwner()
+
+	id, err := s.Create(ctx, owner, []byte("v1"), time.Time{})
+	require.NoError(t, err)
+
+	const writers = 8
+	var (
+		wg      sync.WaitGroup
+		applied atomic.Int32
+	)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, updateErr := s.Update(ctx, id, []byte{byte(i)}, 1, time.Time{})
+			if updateErr == nil {
+				applied.Add(1)
+				return
+			}
+			assert.ErrorIs(t, updateErr, ErrVersionConflict)
+		}(i)
+	}
+	wg.Wait()
+
+	// из обновлений с одной ожидаемой версией применяется ровно одно
+	assert.Equal(t, int32(1), applied.Load())
+
+	e, err := s.Get(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), e.Version)
+
+	cs, err := s.GetChanges(ctx, owner, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cs.Revision)
+}
+
+func testPagination(t *testing.T, s Storage) {
+	ctx := context.Background()
+	owner := newOwner()
+
+	for i := 0; i < 5; i++ {
+		_, err := s.Create(ctx, owner, []byte{byte(i)}, time.Time{})
+		require.NoError(t, err)
+	}
+	_, err := s.Create(ctx, newOwner(), []byte("other"), time.Time{})
+	require.NoError(t, err)
+
+	first, err := s.GetAll(ctx, owner, uuid.Nil, 3)
+	require.NoError(t, err)
+	require.Len(t, first, 3)
+
+	second, err := s.GetAll(ctx, owner, first[2].ID, 3)
+	require.NoError(t, err)
+	require.Len(t, second, 2)
+
+	all := append(first, second...)
+	for i := 1; i < len(all); i++ {
+		assert.Less(t, all[i-1].ID.String(), all[i].ID.String())
+	}
+}
+
+func testChanges(t *testing.T, s Storage) {
+	ctx := context.Background()
+	owner := newOwner()
+
+	cs, err := s.GetChanges(ctx, owner, 0)
+	require.NoError(t, err)
+	assert.Empty(t, cs.Entries)
+	assert.Empty(t, cs.Deleted)
+	assert.Zero(t, cs.Revision)
+
+	kept, err := s.Create(ctx, owner, []byte("kept"), time.Time{})
+	require.NoError(t, err)
+	trashed, err := s.Create(ctx, owner, []byte("trashed"), time.Time{})
+	require.NoError(t, err)
+	purged, err := s.Create(ctx, owner, []byte("purged"), time.Time{})
+	require.NoError(t, err)
+
+	cs, err = s.GetChanges(ctx, owner, 0)
+	require.NoError(t, err)
+	require.Len(t, cs.Entries, 3)
+	assert.Equal(t, int64(3), cs.Revision)
+	since := cs.Revision
+
+	_, err = s.Update(ctx, kept, []byte("kept 2"), 1, time.Time{})
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(ctx, trashed, 1))
+	require.NoError(t, s.Delete(ctx, purged, 1))
+	require.NoError(t, s.Purge(ctx, purged))
+
+	cs, err = s.GetChanges(ctx, owner, since)
+	require.NoError(t, err)
+	require.Len(t, cs.Entries, 1)
+	assert.Equal(t, kept, cs.Entries[0].ID)
+	assert.Equal(t, []byte("kept 2"), cs.Entries[0].Payload)
+	assert.Equal(t, []uuid.UUID{trashed, purged}, cs.Deleted)
+	assert.Equal(t, since+4, cs.Revision)
+
+	require.NoError(t, s.Restore(ctx, trashed))
+	cs, err = s.GetChanges(ctx, owner, since+4)
+	require.NoError(t, err)
+	require.Len(t, cs.Entries, 1)
+	assert.Equal(t, trashed, cs.Entries[0].ID)
+	assert.Empty(t, cs.Deleted)
+}
+
+func testTombstoneRetention(t *testing.T, s Storage) {
+	ctx := context.Background()
+	owner := newOwner()
+
+	kept, err := s.Create(ctx, owner, []byte("kept"), time.Time{})
+	require.NoError(t, err)
+	purged, err := s.Create(ctx, owner, []byte("purged"), time.Time{})
+	require.NoError(t, err)
+	since := int64(2)
+	require.NoError(t, s.Delete(ctx, purged, 1))
+	require.NoError(t, s.Purge(ctx, purged))
+
+	n, err := s.PurgeTombstones(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	cs, err := s.GetChanges(ctx, owner, since)
+	require.NoError(t, err)
+	assert.False(t, cs.FullResync)
+	assert.Equal(t, []uuid.UUID{purged}, cs.Deleted)
+
+	n, err = s.PurgeTombstones(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Positive(t, n)
+
+	// курсор старше удаленного надгробия получает полную синхронизацию
+	cs, err = s.GetChanges(ctx, owner, since)
+	require.NoError(t, err)
+	assert.True(t, cs.FullResync)
+	require.Len(t, cs.Entries, 1)
+	assert.Equal(t, kept, cs.Entries[0].ID)
+	assert.Empty(t, cs.Deleted)
+	assert.Equal(t, int64(4), cs.Revision)
+
+	cs, err = s.GetChanges(ctx, owner, cs.Revision)
+	require.NoError(t, err)
+	assert.False(t, cs.FullResync)
+	assert.Empty(t, cs.Entries)
+
+	cs, err = s.GetChanges(ctx, owner, 0)
+	require.NoError(t, err)
+	assert.False(t, cs.FullResync)
+	require.Len(t, cs.Entries, 1)
+}
+
+func testExpiry(t *testing.T, s Storage) {
+	ctx := context.Background()
+	owner := newOwner()
+
+	permanent, err := s.Create(ctx, owner, []byte("permanent"), time.Time{})
+	require.NoError(t, err)
+	expiresAt := time.Now().Add(time.Hour)
+	expiring, err := s.Create(ctx, owner, []byte("expiring"), expiresAt)
+	require.NoError(t, err)
+	short, err := s.Create(ctx, owner, []byte("short"), time.Now().Add(200*time.Millisecond))
+	require.NoError(t, err)
+	uploadID := uuid.New()
+	require.NoError(t, s.SaveUploadChunk(ctx, uploadID, owner, 0, []byte("h0"), []byte("chunk 0")))
+	file, err := s.CommitUpload(ctx, uploadID, owner, []byte("file"), []byte("manifest"), [][]byte{[]byte("h0")})
+	require.NoError(t, err)
+	_, err = s.Update(ctx, file, []byte("file"), 1, time.Now().Add(200*time.Millisecond))
+	require.NoError(t, err)
+
+	e, err := s.Get(ctx, expiring)
+	require.NoError(t, err)
+	assert.WithinDuration(t, expiresAt, e.ExpiresAt, time.Millisecond)
+	e, err = s.Get(ctx, permanent)
+	require.NoError(t, err)
+	assert.True(t, e.ExpiresAt.IsZero())
+
+	// Update заменяет время истечения: бессрочная запись истекает, истекающая становится бессрочной
+	_, err = s.Update(ctx, permanent, []byte("permanent 2"), 1, expiresAt)
+	require.NoError(t, err)
+	_, err = s.Update(ctx, expiring, []byte("expiring 2"), 1, time.Time{})
+	require.NoError(t, err)
+	e, err = s.Get(ctx, permanent)
+	require.NoError(t, err)
+	assert.WithinDuration(t, expiresAt, e.ExpiresAt, time.Millisecond)
+	e, err = s.Get(ctx, expiring)
+	require.NoError(t, err)
+	assert.True(t, e.ExpiresAt.IsZero())
+
+	time.Sleep(300 * time.Millisecond)
+
+	// истекшая запись скрыта до очистки
+	_, err = s.Get(ctx, short)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, _, err = s.GetManifest(ctx, file)
+	assert.ErrorIs(t, err, ErrNotFound)
+	entries, err := s.GetAll(ctx, owner, uuid.Nil, 10)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+	cs, err := s.GetChanges(ctx, owner, 0)
+	require.NoError(t, err)
+	assert.Len(t, cs.Entries, 2)
+	assert.Empty(t, cs.Deleted)
+
+	n, err := s.PurgeExpired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	cs, err = s.GetChanges(ctx, owner, cs.Revision)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{short, file}, cs.Deleted)
+
+	n, err = s.PurgeExpired(ctx, expiresAt.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = s.Get(ctx, permanent)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.Get(ctx, expiring)
+	assert.NoError(t, err)
+}
+
+func testIdempotency(t *testing.T, s Storage) {
+	ctx := context.Background()
+	owner := newOwner()
+	k := IdempotencyKey{Key: "key", RequestHash: []byte("hash"), Since: time.Now().Add(-time.Hour)}
+
+	id, created, err := s.CreateIdempotent(ctx, k, owner, []byte("data"), time.Time{})
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	// повтор возвращает ту же запись
+	again, created, err := s.CreateIdempotent(ctx, k, owner, []byte("data"), time.Time{})
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, id, again)
+	entries, err := s.GetAll(ctx, owner, uuid.Nil, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, []byte("data"), entries[0].Payload)
+
+	mismatch := k
+	mismatch.RequestHash = []byte("other")
+	_, _, err = s.CreateIdempotent(ctx, mismatch, owner, []byte("other"), time.Time{})
+	assert.ErrorIs(t, err, ErrIdempotencyMismatch)
+
+	// ключ уникален в пределах владельца
+	other, created, err := s.CreateIdempotent(ctx, k, newOwner(), []byte("data"), time.Time{})
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.NotEqual(t, id, other)
+
+	// ключ, сохраненный раньше Since, истек и используется заново
+	expired := k
+	expired.Since = time.Now().Add(time.Hour)
+	fresh, created, err := s.CreateIdempotent(ctx, expired, owner, []byte("data"), time.Time{})
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.NotEqual(t, id, fresh)
+
+	n, err := s.DeleteExpiredIdempotencyKeys(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, n, int64(2))
+	id, created, err = s.CreateIdempotent(ctx, k, owner, []byte("data"), time.Time{})
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.NotEqual(t, fresh, id)
+}
+
+func testBatch(t *testing.T, s Storage) {
+	ctx := context.Background()
+	owner := newOwner()
+
+	first, err := s.Create(ctx, owner, []byte("first"), time.Time{})
+	require.NoError(t, err)
+	second, err := s.Create(ctx, owner, []byte("second"), time.Time{})
+	require.NoError(t, err)
+	cs, err := s.GetChanges(ctx, owner, 0)
+	require.NoError(t, err)
+
+	results, err := s.Batch(ctx, []BatchOp{
+		{Kind: BatchCreate, PublicKey: owner, Data: []byte("third")},
+		{Kind: BatchUpdate, ID: first, Data: []byte("first 2"), Version: 1},
+		{Kind: BatchDelete, ID: second, Version: 1},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, int64(1), results[0].Version)
+	assert.Equal(t, BatchResult{ID: first, Version: 2}, results[1])
+	assert.Equal(t, second, results[2].ID)
+
+	third, err := s.Get(ctx, results[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("third"), third.Payload)
+	e, err := s.Get(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("first 2"), e.Payload)
+	_, err = s.Get(ctx, second)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	changes, err := s.GetChanges(ctx, owner, cs.Revision)
+	require.NoError(t, err)
+	assert.Len(t, changes.Entries, 2)
+	assert.Equal(t, []uuid.UUID{second}, changes.Deleted)
+
+	// операции одной записи применяются по порядку
+	results, err = s.Batch(ctx, []BatchOp{
+		{Kind: BatchUpdate, ID: first, Data: []byte("first 3"), Version: 2},
+		{Kind: BatchDelete, ID: first, Version: 3},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), results[1].Version)
+	_, err = s.Get(ctx, first)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// ошибка одной операции откатывает весь пакет
+	_, err = s.Batch(ctx, []BatchOp{
+		{Kind: BatchCreate, PublicKey: owner, Data: []byte("fourth")},
+		{Kind: BatchUpdate, ID: third.ID, Data: []byte("third 2"), Version: 1},
+		{Kind: BatchDelete, ID: third.ID, Version: 1},
+	})
+	var batchErr *BatchError
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, 2, batchErr.Index)
+	var conflict *VersionConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, int64(2), conflict.Current)
+
+	_, err = s.Batch(ctx, []BatchOp{
+		{Kind: BatchCreate, PublicKey: owner, Data: []byte("fourth")},
+		{Kind: BatchDelete, ID: uuid.New(), Version: 1},
+	})
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, 1, batchErr.Index)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	entries, err := s.GetAll(ctx, owner, uuid.Nil, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, []byte("third"), entries[0].Payload)
+	assert.Equal(t, int64(1), entries[0].Version)
+}
+
+func testHistory(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	id, err := s.Create(ctx, newOwner(), []byte("v1"), time.Time{})
+	require.NoError(t, err)
+
+	for version := int64(1); version <= 3; version++ {
+		_, err = s.Update(ctx, id, []byte{'v', byte('1' + version)}, version, time.Time{})
+		require.NoError(t, err)
+	}
+
+	history, err := s.History(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, history, contractHistoryLimit)
+	assert.Equal(t, int64(3), history[0].Version)
+	assert.Equal(t, []byte("v3"), history[0].Payload)
+	assert.Equal(t, int64(2), history[1].Version)
+
+	v, err := s.GetVersion(ctx, id, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v2"), v.Payload)
+
+	_, err = s.GetVersion(ctx, id, 1)
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+
+	history, err = s.History(ctx, uuid.New())
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func testBlobKeys(t *testing.T, s Storage) {
+	ctx := context.Background()
+	owner := newOwner()
+	keys := []uuid.UUID{uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()}
+	ref := func(i int) []byte { return blobRef(keys[i], []byte{byte(i)}) }
+
+	_, err := s.Create(ctx, owner, []byte("plain"), time.Time{})
+	require.NoError(t, err)
+	// данные, похожие на ссылку, но другой длины, ссылкой не считаются
+	_, err = s.Create(ctx, owner, append(ref(0), 0), time.Time{})
+	require.NoError(t, err)
+	id, err := s.Create(ctx, owner, ref(0), time.Time{})
+	require.NoError(t, err)
+	_, err = s.Update(ctx, id, ref(1), 1, time.Time{})
+	require.NoError(t, err)
+	trashed, err := s.Create(ctx, owner, ref(2), time.Time{})
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(ctx, trashed, 1))
+	require.NoError(t, s.SaveUploadChunk(ctx, uuid.New(), owner, 0, []byte("h"), ref(3)))
+	uploadID := uuid.New()
+	require.NoError(t, s.SaveUploadChunk(ctx, uploadID, owner, 0, []byte("h"), ref(4)))
+	file, err := s.CommitUpload(ctx, uploadID, owner, []byte("file"), []byte("manifest"), [][]byte{[]byte("h")})
+	require.NoError(t, err)
+
+	got, err := s.BlobKeys(ctx)
+	require.NoError(t, err)
+	if _, ok := s.(*BlobStorage); ok {
+		// BlobStorage выносит все эти данные и хранит вместо них собственные ссылки
+		assert.Len(t, got, 8)
+		for _, key := range keys {
+			assert.NotContains(t, got, key)
+		}
+		return
+	}
+	assert.ElementsMatch(t, keys, got)
+
+	// удаление из корзины, вытеснение из истории и удаление загрузок убирают ссылки
+	require.NoError(t, s.Purge(ctx, trashed))
+	require.NoError(t, s.Delete(ctx, file, 1))
+	require.NoError(t, s.Purge(ctx, file))
+	_, err = s.DeleteStaleUploads(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	for v := int64(2); v <= contractHistoryLimit+2; v++ {
+		_, err = s.Update(ctx, id, []byte("plain"), v, time.Time{})
+		require.NoError(t, err)
+	}
+	got, err = s.BlobKeys(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func testTrash(t *testing.T, s Storage) {
+	ctx := context.Background()
+	owner := newOwner()
+
+	first, err := s.Create(ctx, owner, []byte("first"), time.Time{})
+	require.NoError(t, err)
+	second, err := s.Create(ctx, owner, []byte("second"), time.Time{})
+	require.NoError(t, err)
+
+	_, err = s.GetTrashed(ctx, first)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, s.Restore(ctx, first), ErrNotFound)
+	assert.ErrorIs(t, s.Purge(ctx, first), ErrNotFound)
+
+	require.NoError(t, s.Delete(ctx, first, 1))
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, s.Delete(ctx, second, 1))
+
+	trash, err := s.ListTrash(ctx, owner)
+	require.NoError(t, err)
+	require.Len(t, trash, 2)
+	assert.Equal(t, second, trash[0].ID)
+	assert.False(t, trash[0].DeletedAt.IsZero())
+
+	e, err := s.GetTrashed(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("first"), e.Payload)
+
+	require.NoError(t, s.Restore(ctx, first))
+	_, err = s.Get(ctx, first)
+	require.NoError(t, err)
+
+	n, err := s.PurgeTrash(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, n, int64(1))
+
+	_, err = s.GetTrashed(ctx, second)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	trash, err = s.ListTrash(ctx, owner)
+	require.NoError(t, err)
+	assert.Empty(t, trash)
+}
+
+func testFiles(t *testing.T, s Storage) {
+	ctx := context.Background()
+	owner := newOwner()
+	uploadID := uuid.New()
+
+	other := newOwner()
+	hashes := [][]byte{[]byte("h0"), []byte("h1")}
+
+	require.NoError(t, s.SaveUploadChunk(ctx, uploadID, owner, 1, []byte("h1"), []byte("chunk 1")))
+	require.NoError(t, s.SaveUploadChunk(ctx, uploadID, owner, 0, []byte("h0"), []byte("wrong")))
+	require.NoError(t, s.SaveUploadChunk(ctx, uploadID, owner, 0, []byte("h0"), []byte("chunk 0")))
+
+	// загрузка с тем же ID другого владельца не видна и не меняет чужие части
+	require.NoError(t, s.SaveUploadChunk(ctx, uploadID, other, 0, []byte("x"), []byte("foreign")))
+	chunks, err := s.UploadChunks(ctx, uploadID, other)
+	require.NoError(t, err)
+	assert.Equal(t, []UploadChunk{{Index: 0, Hash: []byte("x")}}, chunks)
+
+	chunks, err = s.UploadChunks(ctx, uploadID, owner)
+	require.NoError(t, err)
+	assert.Equal(t, []UploadChunk{{Index: 0, Hash: []byte("h0")}, {Index: 1, Hash: []byte("h1")}}, chunks)
+
+	var mismatch *UploadMismatchError
+	_, err = s.CommitUpload(ctx, uploadID, owner, []byte("data"), []byte("manifest"), append(hashes, []byte("h2")))
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, -1, mismatch.Chunk)
+	wrong := [][]byte{[]byte("h0"), []byte("x")}
+	_, err = s.CommitUpload(ctx, uploadID, owner, []byte("data"), []byte("manifest"), wrong)
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, 1, mismatch.Chunk)
+
+	id, err := s.CommitUpload(ctx, uploadID, owner, []byte("data"), []byte("manifest"), hashes)
+	require.NoError(t, err)
+
+	chunks, err = s.UploadChunks(ctx, uploadID, other)
+	require.NoError(t, err)
+	assert.Len(t, chunks, 1)
+
+	chunks, err = s.UploadChunks(ctx, uploadID, owner)
+	require.NoError(t, err)
+	assert.Empty(t, chunks)
+
+	manifest, publicKey, err := s.GetManifest(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("manifest"), manifest)
+	assert.Equal(t, owner, publicKey)
+
+	data, err := s.GetChunk(ctx, id, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("chunk 0"), data)
+
+	_, err = s.GetChunk(ctx, id, 2)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	plain, err := s.Create(ctx, owner, []byte("plain"), time.Time{})
+	require.NoError(t, err)
+	_, _, err = s.GetManifest(ctx, plain)
+	assert.ErrorIs(t, err, ErrNotFile)
+	_, _, err = s.GetManifest(ctx, uuid.New())
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	stale := uuid.New()
+	require.NoError(t, s.SaveUploadChunk(ctx, stale, owner, 0, []byte("h"), []byte("stale")))
+	n, err := s.DeleteStaleUploads(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, n, int64(1))
+
+	chunks, err = s.UploadChunks(ctx, stale, owner)
+	require.NoError(t, err)
+	assert.Empty(t, chunks)
+}
+
+func testNotify(t *testing.T, s Storage) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	want := Change{Type: ChangeCreated, ID: uuid.New(), PublicKey: newOwner(), ClientID: "client"}
+	got := make(chan Change, 16)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Listen(ctx, func(c Change) { got <- c })
+	}()
+
+	// слушатель подписывается асинхронно, поэтому событие отправляется, пока не будет получено
+	require.Eventually(t, func() bool {
+		require.NoError(t, s.Notify(ctx, want))
+		select {
+		case c := <-got:
+			return assert.Equal(t, want, c)
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func testAudit(t *testing.T, s Storage) {
+	ctx := context.Background()
+	owner := newOwner()
+	entryID := uuid.New()
+
+	ops := []AuditOp{AuditCreate, AuditRead, AuditUpdate}
+	for _, op := range ops {
+		require.NoError(t, s.AddAudit(ctx, AuditRecord{
+			PublicKey:     owner,
+			Op:            op,
+			EntryID:       entryID,
+			Peer:          "127.0.0.1:1234",
+			ClientVersion: "v1",
+		}))
+	}
+	require.NoError(t, s.AddAudit(ctx, AuditRecord{PublicKey: newOwner(), Op: AuditRead}))
+
+	page, err := s.ListAudit(ctx, owner, 0, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, AuditUpdate, page[0].Op)
+	assert.Equal(t, AuditRead, page[1].Op)
+	assert.Equal(t, entryID, page[0].EntryID)
+	assert.Equal(t, "v1", page[0].ClientVersion)
+	assert.False(t, page[0].CreatedAt.IsZero())
+	assert.Greater(t, page[0].ID, page[1].ID)
+
+	page, err = s.ListAudit(ctx, owner, page[1].ID, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, AuditCreate, page[0].Op)
+}
+
+func testAccount(t *testing.T, s Storage) {
+	ctx := context.Background()
+	owner := newOwner()
+	other := newOwner()
+
+	id, err := s.Create(ctx, owner, []byte("data"), time.Time{})
+	require.NoError(t, err)
+	otherID, err := s.Create(ctx, other, []byte("other"), time.Time{})
+	require.NoError(t, err)
+	require.NoError(t, s.AddAudit(ctx, AuditRecord{PublicKey: owner, Op: AuditCreate, EntryID: id}))
+	_, err = s.RegisterDevice(ctx, owner, "laptop", []byte("token"), "127.0.0.1")
+	require.NoError(t, err)
+	uploadID := uuid.New()
+	require.NoError(t, s.SaveUploadChunk(ctx, uploadID, owner, 0, []byte("h0"), []byte("chunk 0")))
+
+	_, err = s.DeleteAccount(ctx, owner, []byte("challenge"))
+	assert.ErrorIs(t, err, ErrChallengeNotFound)
+
+	require.NoError(t, s.SaveChallenge(ctx, owner, []byte("expired"), time.Now().Add(-time.Minute)))
+	_, err = s.DeleteAccount(ctx, owner, []byte("expired"))
+	assert.ErrorIs(t, err, ErrChallengeNotFound)
+
+	require.NoError(t, s.SaveChallenge(ctx, owner, []byte("challenge"), time.Now().Add(time.Minute)))
+	_, err = s.DeleteAccount(ctx, owner, []byte("wrong"))
+	assert.ErrorIs(t, err, ErrChallengeNotFound)
+
+	ids, err := s.DeleteAccount(ctx, owner, []byte("challenge"))
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{id}, ids)
+
+	_, err = s.DeleteAccount(ctx, owner, []byte("challenge"))
+	assert.ErrorIs(t, err, ErrChallengeNotFound)
+
+	_, err = s.Get(ctx, id)
+	assert.ErrorIs(t, err, ErrNotFound)
+	cs, err := s.GetChanges(ctx, owner, 0)
+	require.NoError(t, err)
+	assert.Empty(t, cs.Entries)
+	assert.Empty(t, cs.Deleted)
+	records, err := s.ListAudit(ctx, owner, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+	devices, err := s.ListDevices(ctx, owner)
+	require.NoError(t, err)
+	assert.Empty(t, devices)
+	chunks, err := s.UploadChunks(ctx, uploadID, owner)
+	require.NoError(t, err)
+	assert.Empty(t, chunks)
+
+	_, err = s.Get(ctx, otherID)
+	require.NoError(t, err)
+
+	require.NoError(t, s.SaveChallenge(ctx, other, []byte("expired"), time.Now().Add(-time.Minute)))
+	n, err := s.DeleteExpiredChallenges(ctx, time.Now())
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, n, int64(1))
+}
+
+func testDevices(t *testing.T, s Storage) {
+	ctx := context.Background()
+	owner := newOwner()
+
+	laptop, err := s.RegisterDevice(ctx, owner, "laptop", []byte("laptop token"), "10.0.0.1")
+	require.NoError(t, err)
+	phone, err := s.RegisterDevice(ctx, owner, "phone", []byte("phone token"), "10.0.0.2")
+	require.NoError(t, err)
+
+	publicKey, err := s.DeviceOwner(ctx, laptop, []byte("laptop token"))
+	require.NoError(t, err)
+	assert.Equal(t, owner, publicKey)
+	_, err = s.DeviceOwner(ctx, laptop, []byte("phone token"))
+	assert.ErrorIs(t, err, ErrDeviceRevoked)
+	_, err = s.DeviceOwner(ctx, uuid.New(), []byte("laptop token"))
+	assert.ErrorIs(t, err, ErrDeviceRevoked)
+
+	has, err := s.HasDevices(ctx, owner)
+	require.NoError(t, err)
+	assert.True(t, has)
+	has, err = s.HasDevices(ctx, newOwner())
+	require.NoError(t, err)
+	assert.False(t, has)
+
+	// недавно активное устройство не обновляется
+	require.NoError(t, s.TouchDevice(ctx, phone, "10.0.0.4", time.Now().Add(-time.Minute)))
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, s.TouchDevice(ctx, laptop, "10.0.0.3", time.Now()))
+
+	devices, err := s.ListDevices(ctx, owner)
+	require.NoError(t, err)
+	require.Len(t, devices, 2)
+	assert.Equal(t, laptop, devices[0].ID)
+	assert.Equal(t, "10.0.0.3", devices[0].LastIP)
+	assert.True(t, devices[0].RevokedAt.IsZero())
+
+	assert.ErrorIs(t, s.RevokeDevice(ctx, newOwner(), phone), ErrNotFound)
+	require.NoError(t, s.RevokeDevice(ctx, owner, phone))
+	assert.ErrorIs(t, s.RevokeDevice(ctx, owner, phone), ErrNotFound)
+	_, err = s.DeviceOwner(ctx, phone, []byte("phone token"))
+	assert.ErrorIs(t, err, ErrDeviceRevoked)
+
+	devices, err = s.ListDevices(ctx, owner)
+	require.NoError(t, err)
+	require.Len(t, devices, 2)
+	assert.Equal(t, "10.0.0.2", devices[1].LastIP)
+	assert.False(t, devices[1].RevokedAt.IsZero())
+
+	require.NoError(t, s.RevokeDevice(ctx, owner, laptop))
+	has, err = s.HasDevices(ctx, owner)
+	require.NoError(t, err)
+	assert.False(t, has)
+}
+
+func testOwners(t *testing.T, s Storage) {
+	ctx := context.Background()
+	owner := newOwner()
+
+	disabled, err := s.IsOwnerDisabled(ctx, owner)
+	require.NoError(t, err)
+	assert.False(t, disabled)
+
+	require.NoError(t, s.DisableOwner(ctx, Fingerprint(owner)))
+	require.NoError(t, s.DisableOwner(ctx, Fingerprint(owner)))
+
+	disabled, err = s.IsOwnerDisabled(ctx, owner)
+	require.NoError(t, err)
+	assert.True(t, disabled)
+
+	require.NoError(t, s.EnableOwner(ctx, Fingerprint(owner)))
+	err = s.EnableOwner(ctx, Fingerprint(owner))
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	disabled, err = s.IsOwnerDisabled(ctx, owner)
+	require.NoError(t, err)
+	assert.False(t, disabled)
+}
+
+func testReadOnlyMode(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	m, err := s.GetReadOnlyMode(ctx)
+	require.NoError(t, err)
+	assert.False(t, m.Enabled)
+
+	require.NoError(t, s.SetReadOnlyMode(ctx, true, time.Minute))
+	m, err = s.GetReadOnlyMode(ctx)
+	require.NoError(t, err)
+	assert.True(t, m.Enabled)
+	assert.Equal(t, time.Minute, m.RetryAfter)
+	assert.WithinDuration(t, time.Now(), m.Since, time.Minute)
+	since := m.Since
+
+	// повторное включение меняет только подсказку
+	require.NoError(t, s.SetReadOnlyMode(ctx, true, 5*time.Minute))
+	m, err = s.GetReadOnlyMode(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, m.RetryAfter)
+	assert.True(t, since.Equal(m.Since))
+
+	require.NoError(t, s.SetReadOnlyMode(ctx, false, 0))
+	require.NoError(t, s.SetReadOnlyMode(ctx, false, 0))
+	m, err = s.GetReadOnlyMode(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ReadOnlyMode{}, m)
+}
+
+// collectBackup - все записи Export хранилища s.
+func collectBackup(t *testing.T, s Storage) []BackupRecord {
+	records := make([]BackupRecord, 0)
+	err := s.Export(context.Background(), func(r BackupRecord) error {
+		records = append(records, r)
+		return nil
+	})
+	require.NoError(t, err)
+	return records
+}
+
+// importBackup - загрузить records в s через Import.
+func importBackup(s Storage, records []BackupRecord) error {
+	i := 0
+	return s.Import(context.Background(), func() (BackupRecord, error) {
+		if i == len(records) {
+			return BackupRecord{}, io.EOF
+		}
+		i++
+		return records[i-1], nil
+	})
+}
+
+func testBackup(t *testing.T, newStorage func(t *testing.T) Storage) {
+	ctx := context.Background()
+	s := newStorage(t)
+	owner := newOwner()
+
+	id, err := s.Create(ctx, owner, []byte("v1"), time.Time{})
+	require.NoError(t, err)
+	_, err = s.Update(ctx, id, []byte("v2"), 1, time.Time{})
+	require.NoError(t, err)
+	trashed, err := s.Create(ctx, owner, []byte("trashed"), time.Time{})
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(ctx, trashed, 1))
+	purged, err := s.Create(ctx, owner, []byte("purged"), time.Time{})
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(ctx, purged, 1))
+	require.NoError(t, s.Purge(ctx, purged))
+	uploadID := uuid.New()
+	require.NoError(t, s.SaveUploadChunk(ctx, uploadID, owner, 0, []byte("h0"), []byte("chunk 0")))
+	file, err := s.CommitUpload(ctx, uploadID, owner, []byte("file"), []byte("manifest"), [][]byte{[]byte("h0")})
+	require.NoError(t, err)
+	require.NoError(t, s.AddAudit(ctx, AuditRecord{PublicKey: owner, Op: AuditCreate, EntryID: id, Peer: "peer"}))
+	require.NoError(t, s.AddAudit(ctx, AuditRecord{PublicKey: owner, Op: AuditRead}))
+	deviceID, err := s.RegisterDevice(ctx, owner, "laptop", []byte("token"), "127.0.0.1")
+	require.NoError(t, err)
+	require.NoError(t, s.DisableOwner(ctx, Fingerprint(owner)))
+	idempotencyKey := IdempotencyKey{Key: "create-1", RequestHash: []byte("hash")}
+	idempotent, _, err := s.CreateIdempotent(ctx, idempotencyKey, owner, []byte("idempotent"), time.Time{})
+	require.NoError(t, err)
+
+	expected, err := s.GetChanges(ctx, owner, 0)
+	require.NoError(t, err)
+	records := collectBackup(t, s)
+
+	// восстановить можно только в пустое хранилище
+	assert.ErrorIs(t, importBackup(s, records), ErrNotEmpty)
+
+	check := func(t *testing.T, r Storage) {
+		changes, err := r.GetChanges(ctx, owner, 0)
+		require.NoError(t, err)
+		assert.Equal(t, expected.Revision, changes.Revision)
+		assert.ElementsMatch(t, expected.Deleted, changes.Deleted)
+		require.Len(t, changes.Entries, len(expected.Entries))
+		for i, e := range changes.Entries {
+			assert.Equal(t, expected.Entries[i].ID, e.ID)
+			assert.Equal(t, expected.Entries[i].Payload, e.Payload)
+			assert.Equal(t, expected.Entries[i].Revision, e.Revision)
+			assert.Equal(t, expected.Entries[i].Version, e.Version)
+			assert.Equal(t, expected.Entries[i].UpdatedAt.UnixMicro(), e.UpdatedAt.UnixMicro())
+		}
+
+		history, err := r.History(ctx, id)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, []byte("v1"), history[0].Payload)
+
+		e, err := r.GetTrashed(ctx, trashed)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("trashed"), e.Payload)
+
+		manifest, _, err := r.GetManifest(ctx, file)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("manifest"), manifest)
+		data, err := r.GetChunk(ctx, file, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("chunk 0"), data)
+
+		audit, err := r.ListAudit(ctx, owner, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, audit, 2)
+		assert.Equal(t, AuditRead, audit[0].Op)
+		assert.Equal(t, uuid.Nil, audit[0].EntryID)
+		assert.Equal(t, id, audit[1].EntryID)
+
+		devices, err := r.ListDevices(ctx, owner)
+		require.NoError(t, err)
+		require.Len(t, devices, 1)
+		assert.Equal(t, deviceID, devices[0].ID)
+		_, err = r.DeviceOwner(ctx, deviceID, []byte("token"))
+		require.NoError(t, err)
+
+		disabled, err := r.IsOwnerDisabled(ctx, owner)
+		require.NoError(t, err)
+		assert.True(t, disabled)
+
+		// повтор запроса с ключом идемпотентности не создает запись заново
+		again, created, err := r.CreateIdempotent(ctx, idempotencyKey, owner, []byte("idempotent"), time.Time{})
+		require.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, idempotent, again)
+
+		// новые изменения продолжают ревизии и журнал аудита владельца
+		_, err = r.Update(ctx, id, []byte("v3"), 2, time.Time{})
+		require.NoError(t, err)
+		changes, err = r.GetChanges(ctx, owner, expected.Revision)
+		require.NoError(t, err)
+		require.Len(t, changes.Entries, 1)
+		assert.Equal(t, expected.Revision+1, changes.Revision)
+		require.NoError(t, r.AddAudit(ctx, AuditRecord{PublicKey: owner, Op: AuditUpdate}))
+		audit, err = r.ListAudit(ctx, owner, 0, 1)
+		require.NoError(t, err)
+		for _, rec := range records {
+			if rec.Audit != nil {
+				assert.Greater(t, audit[0].ID, rec.Audit.ID)
+			}
+		}
+	}
+
+	t.Run("memory", func(t *testing.T) {
+		r := NewMemoryStorage(contractHistoryLimit)
+		require.NoError(t, importBackup(r, records))
+		check(t, r)
+	})
+
+	t.Run("same backend", func(t *testing.T) {
+		r := newStorage(t)
+		err := importBackup(r, records)
+		if errors.Is(err, ErrNotEmpty) {
+			t.Skip("storage is shared with other tests")
+		}
+		require.NoError(t, err)
+		check(t, r)
+	})
+
+	t.Run("failed import", func(t *testing.T) {
+		r := newStorage(t)
+		i := 0
+		err := r.Import(ctx, func() (BackupRecord, error) {
+			if i == len(records)/2 {
+				return BackupRecord{}, io.ErrUnexpectedEOF
+			}
+			i++
+			return records[i-1], nil
+		})
+		if errors.Is(err, ErrNotEmpty) {
+			t.Skip("storage is shared with other tests")
+		}
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+		_, err = r.Get(ctx, id)
+		assert.ErrorIs(t, err, ErrNotFound)
+		require.NoError(t, importBackup(r, records))
+	})
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// ErrDeviceRevoked - устройство отозвано владельцем, не зарегистрировано или передан неверный токен.
+var ErrDeviceRevoked = errors.New("device revoked or unknown")
+
+// Device - устройство владельца публичного ключа.
+type Device struct {
+	ID         uuid.UUID
+	Name       string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	LastIP     string
+	// RevokedAt - время отзыва, нулевое для действующего устройства.
+	RevokedAt time.Time
+}
+
+// RegisterDevice - зарегистрировать устройство владельца и вернуть его ID.
+// Хранится только хеш токена сессии устройства.
+func (s *ServerStorage) RegisterDevice(ctx context.Context, publicKey []byte, name string,
+	tokenHash []byte, ip string,
+) (id uuid.UUID, err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("register_device", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage RegisterDevice", "INSERT")
+	defer span.End()
+
+	row := s.db.QueryRowContext(ctx,
+		`INSERT INTO devices (public_key, name, token_hash, last_ip) VALUES ($1, $2, $3, $4) RETURNING id`,
+		publicKey, name, tokenHash, ip,
+	)
+	err = row.Scan(&id)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("ServerStorage RegisterDevice: query row scan: %w", err)
+	}
+
+	return id, nil
+}
+
+// ListDevices - получить устройства владельца, недавно активные первыми.
+func (s *ServerStorage) ListDevices(ctx context.Context, publicKey []byte) ([]Device, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("list_devices", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage ListDevices", "SELECT")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, name, created_at, last_seen_at, last_ip, revoked_at FROM devices
+		WHERE public_key = $1 ORDER BY last_seen_at DESC`,
+		publicKey,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ServerStorage ListDevices: query: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	devices := make([]Device, 0)
+	for rows.Next() {
+		d := Device{}
+		var revokedAt sql.NullTime
+		err = rows.Scan(&d.ID, &d.Name, &d.CreatedAt, &d.LastSeenAt, &d.LastIP, &revokedAt)
+		if err != nil {
+			return nil, fmt.Errorf("ServerStorage ListDevices: query rows scan: %w", err)
+		}
+		d.RevokedAt = revokedAt.Time
+		devices = append(devices, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ServerStorage ListDevices: query rows: %w", err)
+	}
+
+	return devices, nil
+}
+
+// RevokeDevice - отозвать устройство владельца.
+func (s *ServerStorage) RevokeDevice(ctx context.Context, publicKey []byte, id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("revoke_device", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage RevokeDevice", "UPDATE")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE devices SET revoked_at = now() WHERE id = $1 AND public_key = $2 AND revoked_at IS NULL`,
+		id, publicKey,
+	)
+	if err != nil {
+		return fmt.Errorf("ServerStorage RevokeDevice: exec: %w", err)
+	}
+
+	return checkAffected("ServerStorage RevokeDevice", res)
+}
+
+// DeviceOwner - проверить токен сессии устройства и вернуть публичный ключ его владельца.
+// Для отозванного устройства или неверного токена возвращается ErrDeviceRevoked.
+func (s *ServerStorage) DeviceOwner(ctx context.Context, id uuid.UUID, tokenHash []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("device_owner", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage DeviceOwner", "SELECT")
+	defer span.End()
+
+	row := s.db.QueryRowContext(ctx,
+		`SELECT public_key FROM devices WHERE id = $1 AND token_hash = $2 AND revoked_at IS NULL`,
+		id, tokenHash,
+	)
+
+	var publicKey []byte
+	err := row.Scan(&publicKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("ServerStorage DeviceOwner: %w", ErrDeviceRevoked)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ServerStorage DeviceOwner: query row scan: %w", err)
+	}
+
+	return publicKey, nil
+}
+
+// HasDevices - есть ли у владельца действующие устройства.
+func (s *ServerStorage) HasDevices(ctx context.Context, publicKey []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("has_devices", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage HasDevices", "SELECT")
+	defer span.End()
+
+	row := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM devices WHERE public_key = $1 AND revoked_at IS NULL)`,
+		publicKey,
+	)
+
+	var exists bool
+	err := row.Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("ServerStorage HasDevices: query row scan: %w", err)
+	}
+
+	return exists, nil
+}
+
+// TouchDevice - обновить время и адрес последнего запроса устройства, если предыдущий запрос
+// был раньше before. Так активное устройство не превращает каждый запрос в запись в базу.
+func (s *ServerStorage) TouchDevice(ctx context.Context, id uuid.UUID, ip string, before time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("touch_device", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage TouchDevice", "UPDATE")
+	defer span.End()
+
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE devices SET last_seen_at = now(), last_ip = $2 WHERE id = $1 AND last_seen_at < $3`,
+		id, ip, before,
+	)
+	if err != nil {
+		return fmt.Errorf("ServerStorage TouchDevice: exec: %w", err)
+	}
+
+	return nil
+}
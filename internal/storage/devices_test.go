@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerStorage_RegisterDevice(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	id := uuid.New()
+	mock.ExpectQuery("INSERT INTO devices").WithArgs([]byte{9}, "laptop", []byte{1}, "127.0.0.1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+
+	s := ServerStorage{db: db}
+	got, err := s.RegisterDevice(context.Background(), []byte{9}, "laptop", []byte{1}, "127.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, id, got)
+}
+
+func TestServerStorage_ListDevices(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	id := uuid.New()
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "name", "created_at", "last_seen_at", "last_ip", "revoked_at"}).
+		AddRow(id, "laptop", now, now, "127.0.0.1", nil).
+		AddRow(uuid.New(), "phone", now, now, "10.0.0.1", now)
+	mock.ExpectQuery("FROM devices").WithArgs([]byte{9}).WillReturnRows(rows)
+
+	s := ServerStorage{db: db}
+	devices, err := s.ListDevices(context.Background(), []byte{9})
+	require.NoError(t, err)
+	require.Len(t, devices, 2)
+	assert.Equal(t, id, devices[0].ID)
+	assert.True(t, devices[0].RevokedAt.IsZero())
+	assert.Equal(t, now, devices[1].RevokedAt)
+}
+
+func TestServerStorage_RevokeDevice(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected int64
+		wantErr  error
+	}{
+		{
+			name:     "ok",
+			affected: 1,
+		},
+		{
+			name:     "unknown or already revoked",
+			affected: 0,
+			wantErr:  ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = db.Close() }()
+
+			id := uuid.New()
+			mock.ExpectExec("UPDATE devices SET revoked_at").WithArgs(id, []byte{9}).
+				WillReturnResult(sqlmock.NewResult(0, tt.affected))
+
+			s := ServerStorage{db: db}
+			err = s.RevokeDevice(context.Background(), []byte{9}, id)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestServerStorage_DeviceOwner(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    *sqlmock.Rows
+		err     error
+		wantErr error
+	}{
+		{
+			name: "ok",
+			rows: sqlmock.NewRows([]string{"public_key"}).AddRow([]byte{1, 2, 3}),
+		},
+		{
+			name:    "revoked",
+			err:     sql.ErrNoRows,
+			wantErr: ErrDeviceRevoked,
+		},
+		{
+			name:    "fail",
+			err:     sql.ErrConnDone,
+			wantErr: sql.ErrConnDone,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = db.Close() }()
+
+			id := uuid.New()
+			q := mock.ExpectQuery("SELECT public_key FROM devices").WithArgs(id, []byte{1})
+			if tt.err != nil {
+				q.WillReturnError(tt.err)
+			} else {
+				q.WillReturnRows(tt.rows)
+			}
+
+			s := ServerStorage{db: db}
+			publicKey, err := s.DeviceOwner(context.Background(), id, []byte{1})
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				assert.Equal(t, []byte{1, 2, 3}, publicKey)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestServerStorage_HasDevices(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectQuery("SELECT EXISTS").WithArgs([]byte{1}).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs([]byte{2}).WillReturnError(sql.ErrConnDone)
+
+	s := ServerStorage{db: db}
+	has, err := s.HasDevices(context.Background(), []byte{1})
+	assert.NoError(t, err)
+	assert.True(t, has)
+
+	_, err = s.HasDevices(context.Background(), []byte{2})
+	assert.ErrorIs(t, err, sql.ErrConnDone)
+}
+
+func TestServerStorage_TouchDevice(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	id := uuid.New()
+	before := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	mock.ExpectExec("UPDATE devices SET last_seen_at").WithArgs(id, "127.0.0.1", before).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE devices SET last_seen_at").WillReturnError(sql.ErrConnDone)
+
+	s := ServerStorage{db: db}
+	assert.NoError(t, s.TouchDevice(context.Background(), id, "127.0.0.1", before))
+	assert.ErrorIs(t, s.TouchDevice(context.Background(), id, "127.0.0.1", before), sql.ErrConnDone)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// ErrUploadMismatch - полученные части загрузки не совпадают с манифестом.
+var ErrUploadMismatch = errors.New("upload does not match manifest")
+
+// UploadMismatchError - полученные части загрузки не совпадают с хешами частей манифеста.
+type UploadMismatchError struct {
+	// Received - сколько частей получено, Expected - сколько частей в манифесте.
+	Received, Expected int
+	// Chunk - индекс первой несовпавшей части или -1, если получены не все части.
+	Chunk int
+}
+
+// Error - текст ошибки.
+func (e *UploadMismatchError) Error() string {
+	if e.Chunk < 0 {
+		return fmt.Sprintf("upload incomplete: received %d of %d chunks", e.Received, e.Expected)
+	}
+	return fmt.Sprintf("chunk %d does not match manifest", e.Chunk)
+}
+
+// Unwrap - для errors.Is(err, ErrUploadMismatch).
+func (e *UploadMismatchError) Unwrap() error {
+	return ErrUploadMismatch
+}
+
+// UploadChunk - хеш части файла, полученной в рамках загрузки.
+type UploadChunk struct {
+	Index uint32
+	Hash  []byte
+}
+
+// checkUploadChunks - вернуть *UploadMismatchError, если части загрузки, отсортированные по индексу,
+// не совпадают с хешами частей манифеста.
+func checkUploadChunks(chunks []UploadChunk, chunkHashes [][]byte) error {
+	if len(chunks) != len(chunkHashes) {
+		return &UploadMismatchError{Received: len(chunks), Expected: len(chunkHashes), Chunk: -1}
+	}
+	for i, c := range chunks {
+		if c.Index != uint32(i) || !bytes.Equal(c.Hash, chunkHashes[i]) {
+			return &UploadMismatchError{Received: len(chunks), Expected: len(chunkHashes), Chunk: i}
+		}
+	}
+
+	return nil
+}
+
+// SaveUploadChunk - сохранить часть загружаемого файла. Повторная отправка части перезаписывает ее.
+// Загрузки разных владельцев не пересекаются, даже если клиенты выбрали одинаковый uploadID.
+func (s *ServerStorage) SaveUploadChunk(ctx context.Context, uploadID uuid.UUID,
+	publicKey []byte, idx uint32, hash, data []byte,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("save_upload_chunk", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage SaveUploadChunk", "INSERT")
+	defer span.End()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO uploads (owner_hash, upload_id, idx, hash, data) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (owner_hash, upload_id, idx) DO UPDATE SET hash = excluded.hash, data = excluded.data`,
+		Fingerprint(publicKey), uploadID, idx, hash, data,
+	)
+	if err != nil {
+		return fmt.Errorf("ServerStorage SaveUploadChunk: exec: %w", err)
+	}
+
+	return nil
+}
+
+// UploadChunks - получить хеши уже полученных частей загрузки владельца publicKey, отсортированные по индексу.
+func (s *ServerStorage) UploadChunks(ctx context.Context, uploadID uuid.UUID, publicKey []byte) ([]UploadChunk, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("upload_chunks", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage UploadChunks", "SELECT")
+	defer span.End()
+
+	chunks, err := uploadChunks(ctx, s.db,
+		`SELECT idx, hash FROM uploads WHERE owner_hash = $1 AND upload_id = $2 ORDER BY idx`,
+		Fingerprint(publicKey), uploadID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ServerStorage UploadChunks: %w", err)
+	}
+
+	return chunks, nil
+}
+
+// uploadChunks - выполнить запрос хешей частей загрузки (idx, hash).
+func uploadChunks(ctx context.Context, q interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}, query string, args ...any) ([]UploadChunk, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	chunks := make([]UploadChunk, 0)
+	for rows.Next() {
+		c := UploadChunk{}
+		err = rows.Scan(&c.Index, &c.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("query rows scan: %w", err)
+		}
+		chunks = append(chunks, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("query rows: %w", err)
+	}
+
+	return chunks, nil
+}
+
+// CommitUpload - создать запись из загрузки владельца publicKey: сохранить данные и манифест,
+// перенести части файла из загрузки к записи и вернуть ID записи. Хеши частей сверяются
+// с chunkHashes манифеста в той же транзакции, иначе возвращается *UploadMismatchError.
+func (s *ServerStorage) CommitUpload(ctx context.Context, uploadID uuid.UUID,
+	publicKey, data, manifest []byte, chunkHashes [][]byte,
+) (id uuid.UUID, err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("commit_upload", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage CommitUpload", "INSERT")
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("ServerStorage CommitUpload: begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// строки частей блокируются до конца транзакции, чтобы их не перезаписали после проверки
+	ownerHash := Fingerprint(publicKey)
+	chunks, err := uploadChunks(ctx, tx,
+		`SELECT idx, hash FROM uploads WHERE owner_hash = $1 AND upload_id = $2 ORDER BY idx FOR UPDATE`,
+		ownerHash, uploadID,
+	)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("ServerStorage CommitUpload: upload chunks: %w", err)
+	}
+	err = checkUploadChunks(chunks, chunkHashes)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("ServerStorage CommitUpload: %w", err)
+	}
+
+	row := tx.QueryRowContext(ctx,
+		`INSERT INTO entries (public_key, owner_hash, payload, manifest) VALUES ($1, $2, $3, $4) RETURNING id`,
+		publicKey, ownerHash, data, manifest,
+	)
+	err = row.Scan(&id)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("ServerStorage CommitUpload: insert entry: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO chunks (entry_id, idx, data)
+		SELECT $1, idx, data FROM uploads WHERE owner_hash = $2 AND upload_id = $3 AND idx < $4`,
+		id, ownerHash, uploadID, len(chunkHashes),
+	)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("ServerStorage CommitUpload: move chunks: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM uploads WHERE owner_hash = $1 AND upload_id = $2`, ownerHash, uploadID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("ServerStorage CommitUpload: delete upload: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("ServerStorage CommitUpload: commit: %w", err)
+	}
+
+	return id, nil
+}
+
+// DeleteStaleUploads - удалить незавершенные загрузки, начатые раньше before.
+func (s *ServerStorage) DeleteStaleUploads(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("delete_stale_uploads", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage DeleteStaleUploads", "DELETE")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM uploads WHERE (owner_hash, upload_id) IN (
+			SELECT owner_hash, upload_id FROM uploads GROUP BY owner_hash, upload_id HAVING max(created_at) < $1
+		)`,
+		before,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("ServerStorage DeleteStaleUploads: exec: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("ServerStorage DeleteStaleUploads: rows affected: %w", err)
+	}
+
+	return n, nil
+}
+
+// GetManifest - получить манифест файла и публичный ключ владельца по ID записи.
+func (s *ServerStorage) GetManifest(ctx context.Context, id uuid.UUID) (manifest, publicKey []byte, err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("get_manifest", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage GetManifest", "SELECT")
+	defer span.End()
+
+	row := s.db.QueryRowContext(ctx,
+		`SELECT manifest, public_key FROM entries
+		WHERE id = $1 AND deleted_at IS NULL AND (expires_at IS NULL OR expires_at > now())`,
+		id,
+	)
+	err = row.Scan(&manifest, &publicKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, fmt.Errorf("ServerStorage GetManifest: query row: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("ServerStorage GetManifest: query row: %w", err)
+	}
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("ServerStorage GetManifest: %w", ErrNotFile)
+	}
+
+	return manifest, publicKey, nil
+}
+
+// GetChunk - получить часть файла по ID записи и индексу.
+func (s *ServerStorage) GetChunk(ctx context.Context, id uuid.UUID, idx uint32) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("get_chunk", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage GetChunk", "SELECT")
+	defer span.End()
+
+	var data []byte
+	row := s.db.QueryRowContext(ctx, `SELECT data FROM chunks WHERE entry_id = $1 AND idx = $2`, id, idx)
+	err := row.Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("ServerStorage GetChunk: query row: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ServerStorage GetChunk: query row: %w", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerStorage_SaveUploadChunk(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		uploadID := uuid.New()
+		owner := []byte{9}
+		hash := []byte{1, 2, 3}
+		data := []byte{4, 5, 6}
+
+		mock.ExpectExec("INSERT INTO uploads").
+			WithArgs(Fingerprint(owner), uploadID, uint32(2), hash, data).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		s := ServerStorage{db: db}
+		err = s.SaveUploadChunk(context.Background(), uploadID, owner, 2, hash, data)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectExec("INSERT INTO uploads").WillReturnError(sql.ErrConnDone)
+
+		s := ServerStorage{db: db}
+		err = s.SaveUploadChunk(context.Background(), uuid.New(), nil, 0, nil, nil)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestServerStorage_UploadChunks(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		uploadID := uuid.New()
+		want := []UploadChunk{
+			{Index: 0, Hash: []byte{1}},
+			{Index: 1, Hash: []byte{2}},
+		}
+
+		rows := sqlmock.NewRows([]string{"idx", "hash"})
+		for _, c := range want {
+			rows.AddRow(c.Index, c.Hash)
+		}
+		mock.ExpectQuery("SELECT idx, hash FROM uploads").WithArgs(Fingerprint([]byte{9}), uploadID).
+			WillReturnRows(rows)
+
+		s := ServerStorage{db: db}
+		got, err := s.UploadChunks(context.Background(), uploadID, []byte{9})
+
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectQuery("SELECT idx, hash FROM uploads").WillReturnError(sql.ErrConnDone)
+
+		s := ServerStorage{db: db}
+		_, err = s.UploadChunks(context.Background(), uuid.New(), nil)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestServerStorage_CommitUpload(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		uploadID := uuid.New()
+		id := uuid.New()
+		publicKey := []byte{1, 2, 3}
+		data := []byte{4, 5, 6}
+		manifest := []byte{7, 8, 9}
+		chunkHashes := [][]byte{{1}, {2}}
+
+		ownerHash := Fingerprint(publicKey)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT idx, hash FROM uploads .* FOR UPDATE").WithArgs(ownerHash, uploadID).
+			WillReturnRows(sqlmock.NewRows([]string{"idx", "hash"}).AddRow(0, []byte{1}).AddRow(1, []byte{2}))
+		mock.ExpectQuery("INSERT INTO entries").
+			WithArgs(publicKey, ownerHash, data, manifest).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+		mock.ExpectExec("INSERT INTO chunks").WithArgs(id, ownerHash, uploadID, 2).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("DELETE FROM uploads").WithArgs(ownerHash, uploadID).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		s := ServerStorage{db: db}
+		got, err := s.CommitUpload(context.Background(), uploadID, publicKey, data, manifest, chunkHashes)
+
+		assert.NoError(t, err)
+		assert.Equal(t, id, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("move chunks fail", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT idx, hash FROM uploads").
+			WillReturnRows(sqlmock.NewRows([]string{"idx", "hash"}).AddRow(0, []byte{1}))
+		mock.ExpectQuery("INSERT INTO entries").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectExec("INSERT INTO chunks").WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		s := ServerStorage{db: db}
+		_, err = s.CommitUpload(context.Background(), uuid.New(), nil, nil, nil, [][]byte{{1}})
+
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("chunk mismatch", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT idx, hash FROM uploads").
+			WillReturnRows(sqlmock.NewRows([]string{"idx", "hash"}).AddRow(0, []byte{1}).AddRow(1, []byte{3}))
+		mock.ExpectRollback()
+
+		s := ServerStorage{db: db}
+		_, err = s.CommitUpload(context.Background(), uuid.New(), nil, nil, nil, [][]byte{{1}, {2}})
+
+		var mismatch *UploadMismatchError
+		require.ErrorAs(t, err, &mismatch)
+		assert.Equal(t, 1, mismatch.Chunk)
+		assert.ErrorIs(t, err, ErrUploadMismatch)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestServerStorage_DeleteStaleUploads(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	before := time.Now()
+	mock.ExpectExec("DELETE FROM uploads").WithArgs(before).WillReturnResult(sqlmock.NewResult(0, 5))
+
+	s := ServerStorage{db: db}
+	n, err := s.DeleteStaleUploads(context.Background(), before)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+}
+
+func TestServerStorage_GetManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    *sqlmock.Rows
+		err     error
+		want    []byte
+		wantErr error
+	}{
+		{
+			name: "ok",
+			rows: sqlmock.NewRows([]string{"manifest", "public_key"}).AddRow([]byte{1, 2, 3}, []byte{9}),
+			want: []byte{1, 2, 3},
+		},
+		{
+			name:    "not a file",
+			rows:    sqlmock.NewRows([]string{"manifest", "public_key"}).AddRow(nil, []byte{9}),
+			wantErr: ErrNotFile,
+		},
+		{
+			name:    "not found",
+			err:     sql.ErrNoRows,
+			wantErr: ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = db.Close() }()
+
+			id := uuid.New()
+			q := mock.ExpectQuery("SELECT manifest, public_key FROM entries").WithArgs(id)
+			if tt.err != nil {
+				q.WillReturnError(tt.err)
+			} else {
+				q.WillReturnRows(tt.rows)
+			}
+
+			s := ServerStorage{db: db}
+			got, publicKey, err := s.GetManifest(context.Background(), id)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, []byte{9}, publicKey)
+		})
+	}
+}
+
+func TestServerStorage_GetChunk(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		id := uuid.New()
+		mock.ExpectQuery("SELECT data FROM chunks").
+			WithArgs(id, uint32(3)).
+			WillReturnRows(sqlmock.NewRows([]string{"data"}).AddRow([]byte{1, 2}))
+
+		s := ServerStorage{db: db}
+		got, err := s.GetChunk(context.Background(), id, 3)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{1, 2}, got)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectQuery("SELECT data FROM chunks").WillReturnError(sql.ErrNoRows)
+
+		s := ServerStorage{db: db}
+		_, err = s.GetChunk(context.Background(), uuid.New(), 0)
+
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
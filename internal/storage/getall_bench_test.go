@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// benchOwners и benchEntriesPerOwner - размер таблицы в BenchmarkGetAll.
+	benchOwners          = 1000
+	benchEntriesPerOwner = 50
+	// benchKeySize - размер модуля RSA-4096 в байтах, как у настоящих владельцев.
+	benchKeySize = 512
+)
+
+// benchDB - база с таблицей entries на benchOwners*benchEntriesPerOwner записей
+// и публичные ключи владельцев.
+type benchDB struct {
+	db     *sql.DB
+	getAll func(ctx context.Context, publicKey []byte) ([]Entry, error)
+	// legacy - запрос GetAll до миграции 000013: сравнение полного публичного ключа.
+	legacy string
+	// legacyIndex - индекс по публичному ключу, который был до миграции 000013.
+	legacyIndex string
+	owners      [][]byte
+}
+
+// seedBenchDB - заполнить таблицу entries. insert - запрос с параметрами
+// public_key, owner_hash и payload в синтаксисе базы.
+func seedBenchDB(b *testing.B, db *sql.DB, insert string) [][]byte {
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(b, err)
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.PrepareContext(ctx, insert)
+	require.NoError(b, err)
+	defer func() { _ = stmt.Close() }()
+
+	owners := make([][]byte, benchOwners)
+	for i := range owners {
+		owners[i] = make([]byte, benchKeySize)
+		_, err = rand.Read(owners[i])
+		require.NoError(b, err)
+
+		for j := 0; j < benchEntriesPerOwner; j++ {
+			_, err = stmt.ExecContext(ctx, owners[i], Fingerprint(owners[i]), []byte("payload"))
+			require.NoError(b, err)
+		}
+	}
+	require.NoError(b, tx.Commit())
+
+	return owners
+}
+
+// benchDBs - базы для BenchmarkGetAll: SQLite во временном файле
+// и Postgres, если задана переменная окружения TEST_POSTGRES_DSN.
+func benchDBs(b *testing.B) map[string]func(b *testing.B) benchDB {
+	dbs := map[string]func(b *testing.B) benchDB{
+		"sqlite": func(b *testing.B) benchDB {
+			dsn := SQLiteScheme + filepath.Join(b.TempDir(), "keeper.db")
+			s, err := NewSQLiteStorage(Config{DSN: dsn})
+			require.NoError(b, err)
+			b.Cleanup(func() { _ = s.Close() })
+
+			return benchDB{
+				db: s.db,
+				getAll: func(ctx context.Context, publicKey []byte) ([]Entry, error) {
+					return s.GetAll(ctx, publicKey, uuid.Nil, benchEntriesPerOwner)
+				},
+				legacy: `SELECT id, payload, revision, version, created_at, updated_at FROM entries
+				WHERE public_key = ? AND id > ? AND deleted_at IS NULL ORDER BY id LIMIT ?`,
+				legacyIndex: `CREATE INDEX entries_public_key_id_idx ON entries (public_key, id)`,
+				owners: seedBenchDB(b, s.db, `INSERT INTO entries (id, public_key, owner_hash, payload)
+				VALUES (lower(hex(randomblob(16))), ?, ?, ?)`),
+			}
+		},
+	}
+
+	if dsn := os.Getenv("TEST_POSTGRES_DSN"); dsn != "" {
+		dbs["postgres"] = func(b *testing.B) benchDB {
+			s, err := NewServerStorage(Config{DSN: dsn})
+			require.NoError(b, err)
+			b.Cleanup(func() { _ = s.Close() })
+
+			owners := seedBenchDB(b, s.db, `INSERT INTO entries (public_key, owner_hash, payload) VALUES ($1, $2, $3)`)
+			// база может быть общей с другими тестами, поэтому удаляем только своих владельцев
+			b.Cleanup(func() {
+				_, _ = s.db.Exec(`DROP INDEX IF EXISTS entries_public_key_id_idx`)
+				for _, owner := range owners {
+					_, _ = s.db.Exec(`DELETE FROM entries WHERE owner_hash = $1`, Fingerprint(owner))
+					_, _ = s.db.Exec(`DELETE FROM tombstones WHERE public_key = $1`, owner)
+					_, _ = s.db.Exec(`DELETE FROM owner_revisions WHERE public_key = $1`, owner)
+				}
+			})
+			_, err = s.db.Exec(`ANALYZE entries`)
+			require.NoError(b, err)
+
+			return benchDB{
+				db: s.db,
+				getAll: func(ctx context.Context, publicKey []byte) ([]Entry, error) {
+					return s.GetAll(ctx, publicKey, uuid.Nil, benchEntriesPerOwner)
+				},
+				legacy: `SELECT id, payload, revision, version, created_at, updated_at FROM entries
+				WHERE public_key = $1 AND id > $2 AND deleted_at IS NULL ORDER BY id LIMIT $3`,
+				legacyIndex: `CREATE INDEX entries_public_key_id_idx ON entries (public_key, id); ANALYZE entries`,
+				owners:      owners,
+			}
+		}
+	}
+
+	return dbs
+}
+
+// BenchmarkGetAll - страница записей владельца из таблицы на 50 000 записей:
+// поиск по полному ключу без индекса (как в 000001), по полному ключу с индексом (000005)
+// и по owner_hash (000013).
+//
+//	go test -run '^$' -bench GetAll ./internal/storage
+func BenchmarkGetAll(b *testing.B) {
+	ctx := context.Background()
+
+	for name, open := range benchDBs(b) {
+		b.Run(name, func(b *testing.B) {
+			db := open(b)
+
+			legacy := func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					owner := db.owners[i%len(db.owners)]
+					rows, err := db.db.QueryContext(ctx, db.legacy, owner, uuid.Nil, benchEntriesPerOwner)
+					require.NoError(b, err)
+					n := 0
+					for rows.Next() {
+						n++
+					}
+					require.NoError(b, rows.Err())
+					_ = rows.Close()
+					require.Equal(b, benchEntriesPerOwner, n)
+				}
+			}
+
+			b.Run("public_key scan", legacy)
+
+			_, err := db.db.ExecContext(ctx, db.legacyIndex)
+			require.NoError(b, err)
+			b.Run("public_key index", legacy)
+
+			b.Run("owner_hash index", func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					entries, err := db.getAll(ctx, db.owners[i%len(db.owners)])
+					require.NoError(b, err)
+					require.Len(b, entries, benchEntriesPerOwner)
+				}
+			})
+		})
+	}
+}
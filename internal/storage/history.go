@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// ErrVersionNotFound - версии записи нет в истории.
+var ErrVersionNotFound = errors.New("entry version not found")
+
+// History - получить предыдущие версии записи, новые первыми.
+// В entry заполняются Version, Payload и UpdatedAt - время, когда версия была актуальна.
+func (s *ServerStorage) History(ctx context.Context, id uuid.UUID) ([]Entry, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("history", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage History", "SELECT")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT version, payload, updated_at FROM entry_history WHERE entry_id = $1 ORDER BY version DESC`,
+		id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ServerStorage History: query: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	entries := make([]Entry, 0)
+	for rows.Next() {
+		e := Entry{ID: id}
+		err = rows.Scan(&e.Version, &e.Payload, &e.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("ServerStorage History: query rows scan: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ServerStorage History: query rows: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetVersion - получить предыдущую версию записи из истории.
+func (s *ServerStorage) GetVersion(ctx context.Context, id uuid.UUID, version int64) (e Entry, err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("get_version", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage GetVersion", "SELECT")
+	defer span.End()
+
+	e = Entry{
+		ID:      id,
+		Version: version,
+	}
+
+	row := s.db.QueryRowContext(ctx,
+		`SELECT payload, updated_at FROM entry_history WHERE entry_id = $1 AND version = $2`,
+		id, version,
+	)
+	err = row.Scan(&e.Payload, &e.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Entry{}, fmt.Errorf("ServerStorage GetVersion: query row: %w", ErrVersionNotFound)
+	}
+	if err != nil {
+		return Entry{}, fmt.Errorf("ServerStorage GetVersion: query row: %w", err)
+	}
+
+	return e, nil
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerStorage_History(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		id := uuid.New()
+		now := time.Now()
+
+		rows := sqlmock.NewRows([]string{"version", "payload", "updated_at"}).
+			AddRow(int64(2), []byte{2}, now).
+			AddRow(int64(1), []byte{1}, now.Add(-time.Hour))
+		mock.ExpectQuery("FROM entry_history").WithArgs(id).WillReturnRows(rows)
+
+		s := ServerStorage{db: db}
+		entries, err := s.History(context.Background(), id)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, int64(2), entries[0].Version)
+		assert.Equal(t, []byte{1}, entries[1].Payload)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectQuery("FROM entry_history").WillReturnError(sql.ErrConnDone)
+
+		s := ServerStorage{db: db}
+		_, err = s.History(context.Background(), uuid.New())
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+	})
+}
+
+func TestServerStorage_GetVersion(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		id := uuid.New()
+		now := time.Now()
+
+		rows := sqlmock.NewRows([]string{"payload", "updated_at"}).AddRow([]byte{1}, now)
+		mock.ExpectQuery("FROM entry_history").WithArgs(id, int64(1)).WillReturnRows(rows)
+
+		s := ServerStorage{db: db}
+		e, err := s.GetVersion(context.Background(), id, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []byte{1}, e.Payload)
+		assert.Equal(t, int64(1), e.Version)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectQuery("FROM entry_history").WillReturnError(sql.ErrNoRows)
+
+		s := ServerStorage{db: db}
+		_, err = s.GetVersion(context.Background(), uuid.New(), 1)
+		assert.ErrorIs(t, err, ErrVersionNotFound)
+	})
+}
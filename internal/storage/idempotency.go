@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// ErrIdempotencyMismatch - ключ идемпотентности уже использован для запроса с другими данными.
+var ErrIdempotencyMismatch = errors.New("idempotency key reused with different request")
+
+// IdempotencyKey - ключ идемпотентности запроса создания записи.
+type IdempotencyKey struct {
+	// Key - ключ, выбранный клиентом, уникален в пределах владельца.
+	Key string
+	// RequestHash - хеш данных запроса: повтор ключа с другими данными отклоняется.
+	RequestHash []byte
+	// Since - ключи, сохраненные раньше, считаются истекшими и используются заново.
+	Since time.Time
+}
+
+// CreateIdempotent - добавить запись, как Create, и запомнить для нее ключ k владельца publicKey.
+// Если ключ уже сохранен после k.Since, новая запись не создается: возвращается ID записи,
+// созданной по этому ключу, и created = false, даже если запись с тех пор удалена.
+// Если хеш запроса не совпал с сохраненным, возвращается ErrIdempotencyMismatch.
+func (s *ServerStorage) CreateIdempotent(ctx context.Context, k IdempotencyKey, publicKey, data []byte,
+	expiresAt time.Time,
+) (id uuid.UUID, created bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("create_idempotent", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage CreateIdempotent", "INSERT")
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("ServerStorage CreateIdempotent: begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// конкурентный запрос с тем же ключом ждет на вставке ключа, пока эта транзакция не завершится
+	id = uuid.New()
+	ownerHash := Fingerprint(publicKey)
+	var one int
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO idempotency_keys (owner_hash, key, entry_id, request_hash) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (owner_hash, key) DO UPDATE
+		SET entry_id = excluded.entry_id, request_hash = excluded.request_hash, created_at = now()
+		WHERE idempotency_keys.created_at < $5
+		RETURNING 1`,
+		ownerHash, k.Key, id, k.RequestHash, k.Since,
+	).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		id, err = savedIdempotencyKey(tx.QueryRowContext(ctx,
+			`SELECT entry_id, request_hash FROM idempotency_keys WHERE owner_hash = $1 AND key = $2`,
+			ownerHash, k.Key,
+		), k)
+		if err != nil {
+			return uuid.Nil, false, fmt.Errorf("ServerStorage CreateIdempotent: %w", err)
+		}
+		return id, false, nil
+	}
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("ServerStorage CreateIdempotent: save key: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO entries (id, public_key, owner_hash, payload, expires_at) VALUES ($1, $2, $3, $4, $5)`,
+		id, publicKey, ownerHash, data, nullTime(expiresAt),
+	)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("ServerStorage CreateIdempotent: insert entry: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("ServerStorage CreateIdempotent: commit: %w", err)
+	}
+
+	return id, true, nil
+}
+
+// savedIdempotencyKey - прочитать сохраненный ключ и сверить хеш запроса.
+func savedIdempotencyKey(row *sql.Row, k IdempotencyKey) (uuid.UUID, error) {
+	var id uuid.UUID
+	var requestHash []byte
+	err := row.Scan(&id, &requestHash)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("query key: %w", err)
+	}
+	if !bytes.Equal(requestHash, k.RequestHash) {
+		return uuid.Nil, ErrIdempotencyMismatch
+	}
+
+	return id, nil
+}
+
+// DeleteExpiredIdempotencyKeys - удалить ключи идемпотентности, сохраненные раньше before.
+func (s *ServerStorage) DeleteExpiredIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("delete_expired_idempotency_keys", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage DeleteExpiredIdempotencyKeys", "DELETE")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("ServerStorage DeleteExpiredIdempotencyKeys: exec: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("ServerStorage DeleteExpiredIdempotencyKeys: rows affected: %w", err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerStorage_CreateIdempotent(t *testing.T) {
+	k := IdempotencyKey{Key: "key", RequestHash: []byte{1}, Since: time.Now().Add(-time.Hour)}
+
+	t.Run("created", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO idempotency_keys").
+			WithArgs(Fingerprint([]byte{9}), "key", sqlmock.AnyArg(), []byte{1}, k.Since).
+			WillReturnRows(sqlmock.NewRows([]string{"one"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO entries").
+			WithArgs(sqlmock.AnyArg(), []byte{9}, Fingerprint([]byte{9}), []byte{2}, nil).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		s := ServerStorage{db: db}
+		id, created, err := s.CreateIdempotent(context.Background(), k, []byte{9}, []byte{2}, time.Time{})
+		require.NoError(t, err)
+		assert.True(t, created)
+		assert.NotEqual(t, uuid.Nil, id)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		id := uuid.New()
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO idempotency_keys").WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery("SELECT entry_id, request_hash FROM idempotency_keys").
+			WithArgs(Fingerprint([]byte{9}), "key").
+			WillReturnRows(sqlmock.NewRows([]string{"entry_id", "request_hash"}).AddRow(id, []byte{1}))
+		mock.ExpectRollback()
+
+		s := ServerStorage{db: db}
+		got, created, err := s.CreateIdempotent(context.Background(), k, []byte{9}, []byte{2}, time.Time{})
+		require.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, id, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO idempotency_keys").WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery("SELECT entry_id, request_hash FROM idempotency_keys").
+			WillReturnRows(sqlmock.NewRows([]string{"entry_id", "request_hash"}).AddRow(uuid.New(), []byte{3}))
+		mock.ExpectRollback()
+
+		s := ServerStorage{db: db}
+		_, _, err = s.CreateIdempotent(context.Background(), k, []byte{9}, []byte{2}, time.Time{})
+		assert.ErrorIs(t, err, ErrIdempotencyMismatch)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rollback on error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO idempotency_keys").
+			WillReturnRows(sqlmock.NewRows([]string{"one"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO entries").WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		s := ServerStorage{db: db}
+		_, _, err = s.CreateIdempotent(context.Background(), k, []byte{9}, []byte{2}, time.Time{})
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestServerStorage_DeleteExpiredIdempotencyKeys(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	before := time.Now()
+	mock.ExpectExec("DELETE FROM idempotency_keys").WithArgs(before).WillReturnResult(sqlmock.NewResult(0, 2))
+
+	s := ServerStorage{db: db}
+	n, err := s.DeleteExpiredIdempotencyKeys(context.Background(), before)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// ReadOnlyMode - режим обслуживания, включенный оператором: чтение работает, изменения записей отклоняются.
+type ReadOnlyMode struct {
+	// Enabled - режим включен.
+	Enabled bool
+	// RetryAfter - через сколько клиенту повторить отклоненное изменение.
+	RetryAfter time.Duration
+	// Since - когда режим включен.
+	Since time.Time
+}
+
+// GetReadOnlyMode - получить режим обслуживания.
+func (s *ServerStorage) GetReadOnlyMode(ctx context.Context) (ReadOnlyMode, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("get_read_only_mode", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage GetReadOnlyMode", "SELECT")
+	defer span.End()
+
+	var seconds int64
+	m := ReadOnlyMode{Enabled: true}
+	err := s.db.QueryRowContext(ctx, `SELECT retry_after_seconds, since FROM read_only_mode`).Scan(&seconds, &m.Since)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ReadOnlyMode{}, nil
+	}
+	if err != nil {
+		return ReadOnlyMode{}, fmt.Errorf("ServerStorage GetReadOnlyMode: query row: %w", err)
+	}
+	m.RetryAfter = time.Duration(seconds) * time.Second
+
+	return m, nil
+}
+
+// SetReadOnlyMode - включить режим обслуживания с подсказкой retryAfter или выключить его.
+// Повторное включение обновляет подсказку, но не время включения.
+func (s *ServerStorage) SetReadOnlyMode(ctx context.Context, enabled bool, retryAfter time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("set_read_only_mode", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage SetReadOnlyMode", "UPDATE")
+	defer span.End()
+
+	var err error
+	if enabled {
+		_, err = s.db.ExecContext(ctx,
+			`INSERT INTO read_only_mode (retry_after_seconds) VALUES ($1)
+			ON CONFLICT (id) DO UPDATE SET retry_after_seconds = excluded.retry_after_seconds`,
+			int64(retryAfter/time.Second),
+		)
+	} else {
+		_, err = s.db.ExecContext(ctx, `DELETE FROM read_only_mode`)
+	}
+	if err != nil {
+		return fmt.Errorf("ServerStorage SetReadOnlyMode: exec: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerStorage_GetReadOnlyMode(t *testing.T) {
+	since := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name string
+		rows *sqlmock.Rows
+		want ReadOnlyMode
+	}{
+		{
+			name: "enabled",
+			rows: sqlmock.NewRows([]string{"retry_after_seconds", "since"}).AddRow(int64(300), since),
+			want: ReadOnlyMode{Enabled: true, RetryAfter: 5 * time.Minute, Since: since},
+		},
+		{
+			name: "disabled",
+			rows: sqlmock.NewRows([]string{"retry_after_seconds", "since"}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = db.Close() }()
+
+			mock.ExpectQuery("SELECT retry_after_seconds, since FROM read_only_mode").WillReturnRows(tt.rows)
+
+			s := ServerStorage{db: db}
+			got, err := s.GetReadOnlyMode(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestServerStorage_SetReadOnlyMode(t *testing.T) {
+	t.Run("enable", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectExec("INSERT INTO read_only_mode").WithArgs(int64(90)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		s := ServerStorage{db: db}
+		require.NoError(t, s.SetReadOnlyMode(context.Background(), true, 90*time.Second))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("disable", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectExec("DELETE FROM read_only_mode").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		s := ServerStorage{db: db}
+		require.NoError(t, s.SetReadOnlyMode(context.Background(), false, 0))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
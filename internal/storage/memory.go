@@ -0,0 +1,1364 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var _ Storage = (*MemoryStorage)(nil)
+
+// MemoryStorage - хранилище сервера в памяти с той же семантикой, что и ServerStorage:
+// ревизии владельцев, версии записей, корзина, история, файлы, аудит и устройства.
+// Подходит для тестов и запуска без базы данных, данные теряются при остановке.
+type MemoryStorage struct {
+	mu sync.Mutex
+
+	historyLimit int
+
+	entries    map[uuid.UUID]*memoryEntry
+	revisions  map[string]int64
+	pruned     map[string]int64
+	tombstones []memoryTombstone
+	uploads    map[memoryUploadID]map[uint32]memoryUploadChunk
+	audit      []AuditRecord
+	auditSeq   int64
+	challenges map[string]memoryChallenge
+	devices    map[uuid.UUID]*memoryDevice
+	disabled   map[string]struct{}
+
+	idempotency map[memoryIdempotencyID]memoryIdempotencyKey
+	readOnly    ReadOnlyMode
+
+	changes localChanges
+}
+
+type memoryEntry struct {
+	Entry
+	manifest []byte
+	chunks   map[uint32][]byte
+	history  []Entry
+}
+
+type memoryTombstone struct {
+	id        uuid.UUID
+	publicKey string
+	revision  int64
+	createdAt time.Time
+}
+
+type memoryUploadID struct {
+	owner string
+	id    uuid.UUID
+}
+
+type memoryUploadChunk struct {
+	hash      []byte
+	data      []byte
+	createdAt time.Time
+}
+
+type memoryChallenge struct {
+	challenge []byte
+	expiresAt time.Time
+}
+
+// memoryIdempotencyID - ключ идемпотентности; владелец хранится отпечатком, как в ServerStorage.
+type memoryIdempotencyID struct {
+	owner string
+	key   string
+}
+
+type memoryIdempotencyKey struct {
+	entryID     uuid.UUID
+	requestHash []byte
+	createdAt   time.Time
+}
+
+type memoryDevice struct {
+	Device
+	publicKey []byte
+	tokenHash []byte
+}
+
+// NewMemoryStorage - создаем хранилище в памяти.
+// historyLimit - сколько предыдущих версий каждой записи хранить.
+func NewMemoryStorage(historyLimit int) *MemoryStorage {
+	return &MemoryStorage{
+		historyLimit: historyLimit,
+		entries:      make(map[uuid.UUID]*memoryEntry),
+		revisions:    make(map[string]int64),
+		pruned:       make(map[string]int64),
+		uploads:      make(map[memoryUploadID]map[uint32]memoryUploadChunk),
+		challenges:   make(map[string]memoryChallenge),
+		devices:      make(map[uuid.UUID]*memoryDevice),
+		disabled:     make(map[string]struct{}),
+		idempotency:  make(map[memoryIdempotencyID]memoryIdempotencyKey),
+	}
+}
+
+// nextRevision - увеличить ревизию владельца. Вызывается под s.mu.
+func (s *MemoryStorage) nextRevision(publicKey []byte) int64 {
+	s.revisions[string(publicKey)]++
+	return s.revisions[string(publicKey)]
+}
+
+// remove - окончательно удалить запись и оставить надгробие. Вызывается под s.mu.
+func (s *MemoryStorage) remove(e *memoryEntry) {
+	delete(s.entries, e.ID)
+	s.tombstones = append(s.tombstones, memoryTombstone{
+		id:        e.ID,
+		publicKey: string(e.PublicKey),
+		revision:  s.nextRevision(e.PublicKey),
+		createdAt: time.Now(),
+	})
+}
+
+// active - запись не в корзине. Вызывается под s.mu.
+func (s *MemoryStorage) active(id uuid.UUID) (*memoryEntry, bool) {
+	e, ok := s.entries[id]
+	if !ok || !e.DeletedAt.IsZero() {
+		return nil, false
+	}
+
+	return e, true
+}
+
+// trashed - запись в корзине. Вызывается под s.mu.
+func (s *MemoryStorage) trashed(id uuid.UUID) (*memoryEntry, bool) {
+	e, ok := s.entries[id]
+	if !ok || e.DeletedAt.IsZero() {
+		return nil, false
+	}
+
+	return e, true
+}
+
+// compareAndSwap - найти запись и проверить ее версию. Вызывается под s.mu.
+func (s *MemoryStorage) compareAndSwap(id uuid.UUID, version int64) (*memoryEntry, error) {
+	e, ok := s.active(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if e.Version != version {
+		return nil, &VersionConflictError{Current: e.Version}
+	}
+
+	return e, nil
+}
+
+// expired - запись истекла к моменту now.
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !e.ExpiresAt.After(now)
+}
+
+func (e *memoryEntry) copy() Entry {
+	c := e.Entry
+	c.PublicKey = bytes.Clone(e.PublicKey)
+	c.Payload = bytes.Clone(e.Payload)
+	return c
+}
+
+// Get - получить запись по ID.
+func (s *MemoryStorage) Get(_ context.Context, id uuid.UUID) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.active(id)
+	if !ok || e.expired(time.Now()) {
+		return Entry{}, fmt.Errorf("MemoryStorage Get: %w", ErrNotFound)
+	}
+
+	return e.copy(), nil
+}
+
+// GetAll - получить до limit записей по publicKey с ID больше after, отсортированных по ID.
+func (s *MemoryStorage) GetAll(_ context.Context, publicKey []byte, after uuid.UUID, limit int) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	entries := make([]Entry, 0)
+	for _, e := range s.entries {
+		if !bytes.Equal(e.PublicKey, publicKey) || !e.DeletedAt.IsZero() || bytes.Compare(e.ID[:], after[:]) <= 0 {
+			continue
+		}
+		if e.expired(now) {
+			continue
+		}
+		entries = append(entries, e.copy())
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].ID[:], entries[j].ID[:]) < 0
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries, nil
+}
+
+// GetChanges - получить записи владельца, измененные после ревизии since, и ID удаленных записей.
+// Если курсор since старше удаленных надгробий, возвращается полная синхронизация с признаком FullResync.
+func (s *MemoryStorage) GetChanges(_ context.Context, publicKey []byte, since int64) (ChangeSet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revision, ok := s.revisions[string(publicKey)]
+	if !ok {
+		return ChangeSet{Entries: []Entry{}, Deleted: []uuid.UUID{}}, nil
+	}
+
+	type deleted struct {
+		id       uuid.UUID
+		revision int64
+	}
+
+	now := time.Now()
+	cs := ChangeSet{Entries: make([]Entry, 0), Revision: revision}
+	if since > 0 && since < s.pruned[string(publicKey)] {
+		cs.FullResync, since = true, 0
+	}
+	ds := make([]deleted, 0)
+	for _, e := range s.entries {
+		if !bytes.Equal(e.PublicKey, publicKey) || e.Revision <= since {
+			continue
+		}
+		if e.DeletedAt.IsZero() {
+			if e.expired(now) {
+				continue
+			}
+			cs.Entries = append(cs.Entries, e.copy())
+		} else if !cs.FullResync {
+			ds = append(ds, deleted{id: e.ID, revision: e.Revision})
+		}
+	}
+	for _, t := range s.tombstones {
+		if !cs.FullResync && t.publicKey == string(publicKey) && t.revision > since {
+			ds = append(ds, deleted{id: t.id, revision: t.revision})
+		}
+	}
+
+	sort.Slice(cs.Entries, func(i, j int) bool { return cs.Entries[i].Revision < cs.Entries[j].Revision })
+	sort.Slice(ds, func(i, j int) bool { return ds[i].revision < ds[j].revision })
+
+	cs.Deleted = make([]uuid.UUID, 0, len(ds))
+	for _, d := range ds {
+		cs.Deleted = append(cs.Deleted, d.id)
+	}
+
+	return cs, nil
+}
+
+// PurgeTombstones - удалить надгробия, созданные раньше before, и вернуть их количество.
+func (s *MemoryStorage) PurgeTombstones(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	tombstones := s.tombstones[:0]
+	for _, t := range s.tombstones {
+		if !t.createdAt.Before(before) {
+			tombstones = append(tombstones, t)
+			continue
+		}
+		if t.revision > s.pruned[t.publicKey] {
+			s.pruned[t.publicKey] = t.revision
+		}
+		n++
+	}
+	s.tombstones = tombstones
+
+	return n, nil
+}
+
+// Create - добавить запись и вернуть ID. Нулевой expiresAt - бессрочная запись.
+func (s *MemoryStorage) Create(_ context.Context, publicKey []byte, data []byte,
+	expiresAt time.Time,
+) (uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.create(publicKey, data, nil, nil)
+	s.entries[id].ExpiresAt = expiresAt
+
+	return id, nil
+}
+
+// create - добавить запись. Вызывается под s.mu.
+func (s *MemoryStorage) create(publicKey, data, manifest []byte, chunks map[uint32][]byte) uuid.UUID {
+	now := time.Now()
+	e := &memoryEntry{
+		Entry: Entry{
+			ID:        uuid.New(),
+			PublicKey: bytes.Clone(publicKey),
+			Payload:   bytes.Clone(data),
+			Revision:  s.nextRevision(publicKey),
+			Version:   1,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		manifest: bytes.Clone(manifest),
+		chunks:   chunks,
+	}
+	s.entries[e.ID] = e
+
+	return e.ID
+}
+
+// CreateIdempotent - добавить запись и запомнить для нее ключ k, как ServerStorage CreateIdempotent.
+func (s *MemoryStorage) CreateIdempotent(_ context.Context, k IdempotencyKey, publicKey, data []byte,
+	expiresAt time.Time,
+) (uuid.UUID, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := memoryIdempotencyID{owner: string(Fingerprint(publicKey)), key: k.Key}
+	if saved, ok := s.idempotency[key]; ok && !saved.createdAt.Before(k.Since) {
+		if !bytes.Equal(saved.requestHash, k.RequestHash) {
+			return uuid.Nil, false, fmt.Errorf("MemoryStorage CreateIdempotent: %w", ErrIdempotencyMismatch)
+		}
+		return saved.entryID, false, nil
+	}
+
+	id := s.create(publicKey, data, nil, nil)
+	s.entries[id].ExpiresAt = expiresAt
+	s.idempotency[key] = memoryIdempotencyKey{
+		entryID:     id,
+		requestHash: bytes.Clone(k.RequestHash),
+		createdAt:   time.Now(),
+	}
+
+	return id, true, nil
+}
+
+// DeleteExpiredIdempotencyKeys - удалить ключи идемпотентности, сохраненные раньше before.
+func (s *MemoryStorage) DeleteExpiredIdempotencyKeys(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for key, saved := range s.idempotency {
+		if saved.createdAt.Before(before) {
+			delete(s.idempotency, key)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// Delete - переместить запись в корзину, если ее версия равна version.
+// Если версия не совпала, возвращается *VersionConflictError.
+func (s *MemoryStorage) Delete(_ context.Context, id uuid.UUID, version int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.compareAndSwap(id, version)
+	if err != nil {
+		return fmt.Errorf("MemoryStorage Delete: %w", err)
+	}
+
+	s.trash(e)
+
+	return nil
+}
+
+// trash - переместить запись в корзину. Вызывается под s.mu.
+func (s *MemoryStorage) trash(e *memoryEntry) {
+	e.DeletedAt = time.Now()
+	e.Revision = s.nextRevision(e.PublicKey)
+}
+
+// Update - обновить запись по ID, если ее версия равна version, и вернуть новую версию.
+// Время истечения заменяется на expiresAt, нулевой expiresAt делает запись бессрочной.
+// Предыдущие данные сохраняются в историю, в которой остается не больше historyLimit версий.
+func (s *MemoryStorage) Update(_ context.Context, id uuid.UUID, data []byte, version int64,
+	expiresAt time.Time,
+) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.compareAndSwap(id, version)
+	if err != nil {
+		return 0, fmt.Errorf("MemoryStorage Update: %w", err)
+	}
+
+	return s.update(e, data, expiresAt), nil
+}
+
+// update - обновить запись и сохранить предыдущие данные в историю. Вызывается под s.mu.
+func (s *MemoryStorage) update(e *memoryEntry, data []byte, expiresAt time.Time) int64 {
+	if s.historyLimit > 0 {
+		e.history = append(e.history, Entry{
+			ID:        e.ID,
+			Payload:   e.Payload,
+			Version:   e.Version,
+			UpdatedAt: e.UpdatedAt,
+		})
+	}
+
+	e.Payload = bytes.Clone(data)
+	e.Version++
+	e.UpdatedAt = time.Now()
+	e.ExpiresAt = expiresAt
+	e.Revision = s.nextRevision(e.PublicKey)
+
+	kept := e.history[:0]
+	for _, h := range e.history {
+		if h.Version >= e.Version-int64(s.historyLimit) {
+			kept = append(kept, h)
+		}
+	}
+	e.history = kept
+
+	return e.Version
+}
+
+// Batch - выполнить операции атомарно, как ServerStorage Batch:
+// сначала проверяются версии всех записей, затем операции применяются.
+func (s *MemoryStorage) Batch(_ context.Context, ops []BatchOp) ([]BatchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// версии записей после предыдущих операций пакета, 0 - запись удалена
+	versions := make(map[uuid.UUID]int64)
+	for i, op := range ops {
+		var err error
+		switch op.Kind {
+		case BatchCreate:
+			continue
+		case BatchUpdate, BatchDelete:
+			current, ok := versions[op.ID]
+			if !ok {
+				if e, active := s.active(op.ID); active {
+					current = e.Version
+				}
+			}
+			switch {
+			case current == 0:
+				err = ErrNotFound
+			case current != op.Version:
+				err = &VersionConflictError{Current: current}
+			case op.Kind == BatchUpdate:
+				versions[op.ID] = current + 1
+			default:
+				versions[op.ID] = 0
+			}
+		default:
+			err = fmt.Errorf("unknown operation kind %d", op.Kind)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("MemoryStorage Batch: %w", &BatchError{Index: i, Err: err})
+		}
+	}
+
+	results := make([]BatchResult, 0, len(ops))
+	for _, op := range ops {
+		res := BatchResult{ID: op.ID, Version: op.Version}
+		switch op.Kind {
+		case BatchCreate:
+			res.ID = s.create(op.PublicKey, op.Data, nil, nil)
+			s.entries[res.ID].ExpiresAt = op.ExpiresAt
+			res.Version = 1
+		case BatchUpdate:
+			res.Version = s.update(s.entries[op.ID], op.Data, op.ExpiresAt)
+		case BatchDelete:
+			s.trash(s.entries[op.ID])
+		}
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+// History - получить сохраненные предыдущие версии записи, новые первыми.
+func (s *MemoryStorage) History(_ context.Context, id uuid.UUID) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]Entry, 0)
+	e, ok := s.entries[id]
+	if !ok {
+		return entries, nil
+	}
+
+	for i := len(e.history) - 1; i >= 0; i-- {
+		h := e.history[i]
+		h.Payload = bytes.Clone(h.Payload)
+		entries = append(entries, h)
+	}
+
+	return entries, nil
+}
+
+// GetVersion - получить предыдущую версию записи из истории.
+func (s *MemoryStorage) GetVersion(_ context.Context, id uuid.UUID, version int64) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[id]; ok {
+		for _, h := range e.history {
+			if h.Version == version {
+				h.Payload = bytes.Clone(h.Payload)
+				return h, nil
+			}
+		}
+	}
+
+	return Entry{}, fmt.Errorf("MemoryStorage GetVersion: %w", ErrVersionNotFound)
+}
+
+// BlobKeys - получить ключи объектов, на которые ссылаются записи, в том числе в корзине и истории,
+// части файлов и незавершенные загрузки.
+func (s *MemoryStorage) BlobKeys(context.Context) ([]uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	used := make(map[uuid.UUID]struct{})
+	add := func(payload []byte) {
+		if key, _, ok := parseBlobRef(payload); ok {
+			used[key] = struct{}{}
+		}
+	}
+	for _, e := range s.entries {
+		add(e.Payload)
+		for _, h := range e.history {
+			add(h.Payload)
+		}
+		for _, data := range e.chunks {
+			add(data)
+		}
+	}
+	for _, chunks := range s.uploads {
+		for _, c := range chunks {
+			add(c.data)
+		}
+	}
+
+	keys := make([]uuid.UUID, 0, len(used))
+	for key := range used {
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// ListTrash - получить записи владельца из корзины, недавно удаленные первыми.
+func (s *MemoryStorage) ListTrash(_ context.Context, publicKey []byte) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]Entry, 0)
+	for _, e := range s.entries {
+		if bytes.Equal(e.PublicKey, publicKey) && !e.DeletedAt.IsZero() {
+			entries = append(entries, e.copy())
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].DeletedAt.After(entries[j].DeletedAt) })
+
+	return entries, nil
+}
+
+// GetTrashed - получить запись из корзины по ID.
+func (s *MemoryStorage) GetTrashed(_ context.Context, id uuid.UUID) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.trashed(id)
+	if !ok {
+		return Entry{}, fmt.Errorf("MemoryStorage GetTrashed: %w", ErrNotFound)
+	}
+
+	return e.copy(), nil
+}
+
+// Restore - вернуть запись из корзины.
+func (s *MemoryStorage) Restore(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.trashed(id)
+	if !ok {
+		return fmt.Errorf("MemoryStorage Restore: %w", ErrNotFound)
+	}
+
+	e.DeletedAt = time.Time{}
+	e.Revision = s.nextRevision(e.PublicKey)
+
+	return nil
+}
+
+// Purge - окончательно удалить запись из корзины.
+func (s *MemoryStorage) Purge(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.trashed(id)
+	if !ok {
+		return fmt.Errorf("MemoryStorage Purge: %w", ErrNotFound)
+	}
+	s.remove(e)
+
+	return nil
+}
+
+// PurgeTrash - окончательно удалить записи, перемещенные в корзину раньше before.
+func (s *MemoryStorage) PurgeTrash(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for _, e := range s.entries {
+		if !e.DeletedAt.IsZero() && e.DeletedAt.Before(before) {
+			s.remove(e)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// PurgeExpired - окончательно удалить записи, истекшие раньше before, в том числе из корзины.
+func (s *MemoryStorage) PurgeExpired(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for _, e := range s.entries {
+		if e.expired(before) {
+			s.remove(e)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// SaveUploadChunk - сохранить часть загружаемого файла. Повторная отправка части перезаписывает ее.
+func (s *MemoryStorage) SaveUploadChunk(_ context.Context, uploadID uuid.UUID,
+	publicKey []byte, idx uint32, hash, data []byte,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := memoryUploadID{owner: string(publicKey), id: uploadID}
+	chunks, ok := s.uploads[key]
+	if !ok {
+		chunks = make(map[uint32]memoryUploadChunk)
+		s.uploads[key] = chunks
+	}
+
+	createdAt := time.Now()
+	if c, exists := chunks[idx]; exists {
+		createdAt = c.createdAt
+	}
+	chunks[idx] = memoryUploadChunk{
+		hash:      bytes.Clone(hash),
+		data:      bytes.Clone(data),
+		createdAt: createdAt,
+	}
+
+	return nil
+}
+
+// UploadChunks - получить хеши уже полученных частей загрузки владельца publicKey, отсортированные по индексу.
+func (s *MemoryStorage) UploadChunks(_ context.Context, uploadID uuid.UUID, publicKey []byte) ([]UploadChunk, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.uploadChunks(memoryUploadID{owner: string(publicKey), id: uploadID}), nil
+}
+
+func (s *MemoryStorage) uploadChunks(key memoryUploadID) []UploadChunk {
+	chunks := make([]UploadChunk, 0, len(s.uploads[key]))
+	for idx, c := range s.uploads[key] {
+		chunks = append(chunks, UploadChunk{Index: idx, Hash: bytes.Clone(c.hash)})
+	}
+
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].Index < chunks[j].Index })
+
+	return chunks
+}
+
+// CommitUpload - создать запись из загрузки, если ее части совпадают с chunkHashes, и вернуть ID записи.
+func (s *MemoryStorage) CommitUpload(_ context.Context, uploadID uuid.UUID,
+	publicKey, data, manifest []byte, chunkHashes [][]byte,
+) (uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := memoryUploadID{owner: string(publicKey), id: uploadID}
+	err := checkUploadChunks(s.uploadChunks(key), chunkHashes)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("MemoryStorage CommitUpload: %w", err)
+	}
+
+	chunks := make(map[uint32][]byte, len(s.uploads[key]))
+	for idx, c := range s.uploads[key] {
+		chunks[idx] = c.data
+	}
+	delete(s.uploads, key)
+
+	return s.create(publicKey, data, manifest, chunks), nil
+}
+
+// DeleteStaleUploads - удалить незавершенные загрузки, начатые раньше before.
+// Возвращает количество удаленных частей.
+func (s *MemoryStorage) DeleteStaleUploads(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for uploadID, chunks := range s.uploads {
+		stale := true
+		for _, c := range chunks {
+			if !c.createdAt.Before(before) {
+				stale = false
+				break
+			}
+		}
+		if stale {
+			n += int64(len(chunks))
+			delete(s.uploads, uploadID)
+		}
+	}
+
+	return n, nil
+}
+
+// GetManifest - получить манифест файла и публичный ключ владельца по ID записи.
+func (s *MemoryStorage) GetManifest(_ context.Context, id uuid.UUID) (manifest, publicKey []byte, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.active(id)
+	if !ok || e.expired(time.Now()) {
+		return nil, nil, fmt.Errorf("MemoryStorage GetManifest: %w", ErrNotFound)
+	}
+	if e.manifest == nil {
+		return nil, nil, fmt.Errorf("MemoryStorage GetManifest: %w", ErrNotFile)
+	}
+
+	return bytes.Clone(e.manifest), bytes.Clone(e.PublicKey), nil
+}
+
+// GetChunk - получить часть файла по ID записи и индексу.
+func (s *MemoryStorage) GetChunk(_ context.Context, id uuid.UUID, idx uint32) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[id]
+	if !ok {
+		return nil, fmt.Errorf("MemoryStorage GetChunk: %w", ErrNotFound)
+	}
+	data, ok := e.chunks[idx]
+	if !ok {
+		return nil, fmt.Errorf("MemoryStorage GetChunk: %w", ErrNotFound)
+	}
+
+	return bytes.Clone(data), nil
+}
+
+// Notify - разослать событие изменения записи всем слушателям этого хранилища.
+func (s *MemoryStorage) Notify(_ context.Context, c Change) error {
+	s.changes.notify(c)
+	return nil
+}
+
+// Listen - передавать события изменения записей в handle. Блокируется до отмены ctx.
+func (s *MemoryStorage) Listen(ctx context.Context, handle func(Change)) error {
+	s.changes.listen(ctx, handle)
+	return nil
+}
+
+// AddAudit - добавить запись в журнал аудита.
+func (s *MemoryStorage) AddAudit(_ context.Context, r AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.auditSeq++
+	r.ID = s.auditSeq
+	r.PublicKey = bytes.Clone(r.PublicKey)
+	r.CreatedAt = time.Now()
+	s.audit = append(s.audit, r)
+
+	return nil
+}
+
+// ListAudit - получить до limit записей журнала аудита владельца publicKey с ID меньше before,
+// новые первыми. Для первой страницы before равен 0.
+func (s *MemoryStorage) ListAudit(_ context.Context, publicKey []byte, before int64, limit int) ([]AuditRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]AuditRecord, 0)
+	for i := len(s.audit) - 1; i >= 0 && len(records) < limit; i-- {
+		r := s.audit[i]
+		if !bytes.Equal(r.PublicKey, publicKey) || (before != 0 && r.ID >= before) {
+			continue
+		}
+		records = append(records, r)
+	}
+
+	return records, nil
+}
+
+// SaveChallenge - сохранить вызов для подтверждения удаления аккаунта.
+// У владельца может быть только один вызов, новый заменяет предыдущий.
+func (s *MemoryStorage) SaveChallenge(_ context.Context, publicKey, challenge []byte, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.challenges[string(publicKey)] = memoryChallenge{
+		challenge: bytes.Clone(challenge),
+		expiresAt: expiresAt,
+	}
+
+	return nil
+}
+
+// DeleteAccount - использовать вызов и удалить все данные владельца. Возвращает ID удаленных записей.
+func (s *MemoryStorage) DeleteAccount(_ context.Context, publicKey, challenge []byte) ([]uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.challenges[string(publicKey)]
+	if !ok || !bytes.Equal(c.challenge, challenge) || !c.expiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("MemoryStorage DeleteAccount: %w", ErrChallengeNotFound)
+	}
+	delete(s.challenges, string(publicKey))
+
+	ids := make([]uuid.UUID, 0)
+	for id, e := range s.entries {
+		if bytes.Equal(e.PublicKey, publicKey) {
+			delete(s.entries, id)
+			ids = append(ids, id)
+		}
+	}
+
+	tombstones := s.tombstones[:0]
+	for _, t := range s.tombstones {
+		if t.publicKey != string(publicKey) {
+			tombstones = append(tombstones, t)
+		}
+	}
+	s.tombstones = tombstones
+
+	delete(s.revisions, string(publicKey))
+	delete(s.pruned, string(publicKey))
+
+	audit := s.audit[:0]
+	for _, r := range s.audit {
+		if !bytes.Equal(r.PublicKey, publicKey) {
+			audit = append(audit, r)
+		}
+	}
+	s.audit = audit
+
+	for id, d := range s.devices {
+		if bytes.Equal(d.publicKey, publicKey) {
+			delete(s.devices, id)
+		}
+	}
+
+	for key := range s.idempotency {
+		if key.owner == string(Fingerprint(publicKey)) {
+			delete(s.idempotency, key)
+		}
+	}
+
+	for key := range s.uploads {
+		if key.owner == string(publicKey) {
+			delete(s.uploads, key)
+		}
+	}
+
+	return ids, nil
+}
+
+// DeleteExpiredChallenges - удалить вызовы удаления аккаунта, истекшие раньше before.
+func (s *MemoryStorage) DeleteExpiredChallenges(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for publicKey, c := range s.challenges {
+		if c.expiresAt.Before(before) {
+			delete(s.challenges, publicKey)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// RegisterDevice - зарегистрировать устройство владельца и вернуть его ID.
+func (s *MemoryStorage) RegisterDevice(_ context.Context, publicKey []byte, name string,
+	tokenHash []byte, ip string,
+) (uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	d := &memoryDevice{
+		Device: Device{
+			ID:         uuid.New(),
+			Name:       name,
+			CreatedAt:  now,
+			LastSeenAt: now,
+			LastIP:     ip,
+		},
+		publicKey: bytes.Clone(publicKey),
+		tokenHash: bytes.Clone(tokenHash),
+	}
+	s.devices[d.ID] = d
+
+	return d.ID, nil
+}
+
+// ListDevices - получить устройства владельца, недавно активные первыми.
+func (s *MemoryStorage) ListDevices(_ context.Context, publicKey []byte) ([]Device, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	devices := make([]Device, 0)
+	for _, d := range s.devices {
+		if bytes.Equal(d.publicKey, publicKey) {
+			devices = append(devices, d.Device)
+		}
+	}
+
+	sort.Slice(devices, func(i, j int) bool { return devices[i].LastSeenAt.After(devices[j].LastSeenAt) })
+
+	return devices, nil
+}
+
+// RevokeDevice - отозвать устройство владельца.
+func (s *MemoryStorage) RevokeDevice(_ context.Context, publicKey []byte, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.devices[id]
+	if !ok || !bytes.Equal(d.publicKey, publicKey) || !d.RevokedAt.IsZero() {
+		return fmt.Errorf("MemoryStorage RevokeDevice: %w", ErrNotFound)
+	}
+	d.RevokedAt = time.Now()
+
+	return nil
+}
+
+// DeviceOwner - проверить токен сессии устройства и вернуть публичный ключ его владельца.
+func (s *MemoryStorage) DeviceOwner(_ context.Context, id uuid.UUID, tokenHash []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.devices[id]
+	if !ok || !bytes.Equal(d.tokenHash, tokenHash) || !d.RevokedAt.IsZero() {
+		return nil, fmt.Errorf("MemoryStorage DeviceOwner: %w", ErrDeviceRevoked)
+	}
+
+	return bytes.Clone(d.publicKey), nil
+}
+
+// HasDevices - есть ли у владельца действующие устройства.
+func (s *MemoryStorage) HasDevices(_ context.Context, publicKey []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range s.devices {
+		if bytes.Equal(d.publicKey, publicKey) && d.RevokedAt.IsZero() {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// TouchDevice - обновить время и адрес последнего запроса устройства, если предыдущий запрос
+// был раньше before.
+func (s *MemoryStorage) TouchDevice(_ context.Context, id uuid.UUID, ip string, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.devices[id]
+	if ok && d.LastSeenAt.Before(before) {
+		d.LastSeenAt = time.Now()
+		d.LastIP = ip
+	}
+
+	return nil
+}
+
+// DisableOwner - отключить владельца по отпечатку.
+func (s *MemoryStorage) DisableOwner(_ context.Context, fingerprint []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.disabled[string(fingerprint)] = struct{}{}
+
+	return nil
+}
+
+// EnableOwner - снова включить владельца по отпечатку.
+func (s *MemoryStorage) EnableOwner(_ context.Context, fingerprint []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.disabled[string(fingerprint)]; !ok {
+		return fmt.Errorf("MemoryStorage EnableOwner: %w", ErrNotFound)
+	}
+	delete(s.disabled, string(fingerprint))
+
+	return nil
+}
+
+// IsOwnerDisabled - отключен ли владелец публичного ключа.
+func (s *MemoryStorage) IsOwnerDisabled(_ context.Context, publicKey []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.disabled[string(Fingerprint(publicKey))]
+
+	return ok, nil
+}
+
+// GetReadOnlyMode - получить режим обслуживания.
+func (s *MemoryStorage) GetReadOnlyMode(_ context.Context) (ReadOnlyMode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.readOnly, nil
+}
+
+// SetReadOnlyMode - включить режим обслуживания с подсказкой retryAfter или выключить его.
+func (s *MemoryStorage) SetReadOnlyMode(_ context.Context, enabled bool, retryAfter time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !enabled {
+		s.readOnly = ReadOnlyMode{}
+		return nil
+	}
+	if !s.readOnly.Enabled {
+		s.readOnly = ReadOnlyMode{Enabled: true, Since: time.Now()}
+	}
+	// секунды, как в базе
+	s.readOnly.RetryAfter = retryAfter.Truncate(time.Second)
+
+	return nil
+}
+
+// Export - передать в fn все данные хранилища, как ServerStorage Export.
+// Данные копируются под блокировкой, fn вызывается уже без нее.
+func (s *MemoryStorage) Export(_ context.Context, fn func(BackupRecord) error) error {
+	records := s.snapshot()
+	for _, r := range records {
+		err := fn(r)
+		if err != nil {
+			return fmt.Errorf("MemoryStorage Export: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// snapshot - копия всех данных хранилища в порядке BackupRecord.
+func (s *MemoryStorage) snapshot() []BackupRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]*memoryEntry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID.String() < entries[j].ID.String() })
+
+	records := make([]BackupRecord, 0, len(entries))
+	for _, e := range entries {
+		records = append(records, BackupRecord{Entry: &BackupEntry{
+			ID:        e.ID,
+			PublicKey: bytes.Clone(e.PublicKey),
+			Payload:   bytes.Clone(e.Payload),
+			Manifest:  bytes.Clone(e.manifest),
+			Revision:  e.Revision,
+			Version:   e.Version,
+			CreatedAt: e.CreatedAt,
+			UpdatedAt: e.UpdatedAt,
+			DeletedAt: e.DeletedAt,
+			ExpiresAt: e.ExpiresAt,
+		}})
+	}
+	for _, e := range entries {
+		idxs := make([]uint32, 0, len(e.chunks))
+		for idx := range e.chunks {
+			idxs = append(idxs, idx)
+		}
+		sort.Slice(idxs, func(i, j int) bool { return idxs[i] < idxs[j] })
+		for _, idx := range idxs {
+			records = append(records, BackupRecord{Chunk: &BackupChunk{
+				EntryID: e.ID,
+				Index:   idx,
+				Data:    bytes.Clone(e.chunks[idx]),
+			}})
+		}
+	}
+	for _, e := range entries {
+		for _, h := range e.history {
+			records = append(records, BackupRecord{Version: &BackupVersion{
+				EntryID:   e.ID,
+				Version:   h.Version,
+				Payload:   bytes.Clone(h.Payload),
+				UpdatedAt: h.UpdatedAt,
+			}})
+		}
+	}
+
+	owners := make([]string, 0, len(s.revisions))
+	for publicKey := range s.revisions {
+		owners = append(owners, publicKey)
+	}
+	sort.Strings(owners)
+	for _, publicKey := range owners {
+		records = append(records, BackupRecord{Revision: &BackupRevision{
+			PublicKey:      []byte(publicKey),
+			Revision:       s.revisions[publicKey],
+			PrunedRevision: s.pruned[publicKey],
+		}})
+	}
+
+	for _, t := range s.tombstones {
+		records = append(records, BackupRecord{Tombstone: &BackupTombstone{
+			ID:        t.id,
+			PublicKey: []byte(t.publicKey),
+			Revision:  t.revision,
+			CreatedAt: t.createdAt,
+		}})
+	}
+
+	for _, r := range s.audit {
+		records = append(records, BackupRecord{Audit: &BackupAudit{
+			ID:            r.ID,
+			PublicKey:     bytes.Clone(r.PublicKey),
+			Op:            r.Op,
+			EntryID:       r.EntryID,
+			Peer:          r.Peer,
+			ClientVersion: r.ClientVersion,
+			CreatedAt:     r.CreatedAt,
+		}})
+	}
+
+	devices := make([]*memoryDevice, 0, len(s.devices))
+	for _, d := range s.devices {
+		devices = append(devices, d)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].ID.String() < devices[j].ID.String() })
+	for _, d := range devices {
+		records = append(records, BackupRecord{Device: &BackupDevice{
+			ID:         d.ID,
+			PublicKey:  bytes.Clone(d.publicKey),
+			Name:       d.Name,
+			TokenHash:  bytes.Clone(d.tokenHash),
+			CreatedAt:  d.CreatedAt,
+			LastSeenAt: d.LastSeenAt,
+			LastIP:     d.LastIP,
+			RevokedAt:  d.RevokedAt,
+		}})
+	}
+
+	fingerprints := make([]string, 0, len(s.disabled))
+	for fingerprint := range s.disabled {
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	sort.Strings(fingerprints)
+	for _, fingerprint := range fingerprints {
+		records = append(records, BackupRecord{DisabledOwner: &BackupDisabledOwner{Fingerprint: []byte(fingerprint)}})
+	}
+
+	keys := make([]memoryIdempotencyID, 0, len(s.idempotency))
+	for key := range s.idempotency {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].owner != keys[j].owner {
+			return keys[i].owner < keys[j].owner
+		}
+		return keys[i].key < keys[j].key
+	})
+	for _, key := range keys {
+		saved := s.idempotency[key]
+		records = append(records, BackupRecord{IdempotencyKey: &BackupIdempotencyKey{
+			OwnerHash:   []byte(key.owner),
+			Key:         key.key,
+			EntryID:     saved.entryID,
+			RequestHash: bytes.Clone(saved.requestHash),
+			CreatedAt:   saved.createdAt,
+		}})
+	}
+
+	return records
+}
+
+// empty - в хранилище нет данных, которые выгружает Export. Вызывается под s.mu.
+func (s *MemoryStorage) empty() bool {
+	return len(s.entries) == 0 && len(s.revisions) == 0 && len(s.tombstones) == 0 &&
+		len(s.audit) == 0 && len(s.devices) == 0 && len(s.disabled) == 0 && len(s.idempotency) == 0
+}
+
+// Import - загрузить в пустое хранилище записи из next, как ServerStorage Import.
+// Записи собираются отдельно и подменяют данные хранилища только после последней.
+func (s *MemoryStorage) Import(_ context.Context, next func() (BackupRecord, error)) error {
+	s.mu.Lock()
+	empty := s.empty()
+	s.mu.Unlock()
+	if !empty {
+		return fmt.Errorf("MemoryStorage Import: %w", ErrNotEmpty)
+	}
+
+	entries := make(map[uuid.UUID]*memoryEntry)
+	revisions := make(map[string]int64)
+	pruned := make(map[string]int64)
+	tombstones := make([]memoryTombstone, 0)
+	audit := make([]AuditRecord, 0)
+	var auditSeq int64
+	devices := make(map[uuid.UUID]*memoryDevice)
+	disabled := make(map[string]struct{})
+	idempotency := make(map[memoryIdempotencyID]memoryIdempotencyKey)
+
+	for {
+		r, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("MemoryStorage Import: %w", err)
+		}
+
+		switch {
+		case r.Entry != nil:
+			if _, ok := entries[r.Entry.ID]; ok {
+				return fmt.Errorf("MemoryStorage Import: entry %s: duplicate", r.Entry.ID)
+			}
+			entries[r.Entry.ID] = &memoryEntry{
+				Entry: Entry{
+					ID:        r.Entry.ID,
+					PublicKey: r.Entry.PublicKey,
+					Payload:   r.Entry.Payload,
+					Revision:  r.Entry.Revision,
+					Version:   r.Entry.Version,
+					CreatedAt: r.Entry.CreatedAt,
+					UpdatedAt: r.Entry.UpdatedAt,
+					DeletedAt: r.Entry.DeletedAt,
+					ExpiresAt: r.Entry.ExpiresAt,
+				},
+				manifest: r.Entry.Manifest,
+			}
+		case r.Chunk != nil:
+			e, ok := entries[r.Chunk.EntryID]
+			if !ok {
+				return fmt.Errorf("MemoryStorage Import: chunk: %w", ErrNotFound)
+			}
+			if e.chunks == nil {
+				e.chunks = make(map[uint32][]byte)
+			}
+			e.chunks[r.Chunk.Index] = r.Chunk.Data
+		case r.Version != nil:
+			e, ok := entries[r.Version.EntryID]
+			if !ok {
+				return fmt.Errorf("MemoryStorage Import: version: %w", ErrNotFound)
+			}
+			e.history = append(e.history, Entry{
+				ID:        e.ID,
+				Payload:   r.Version.Payload,
+				Version:   r.Version.Version,
+				UpdatedAt: r.Version.UpdatedAt,
+			})
+		case r.Revision != nil:
+			revisions[string(r.Revision.PublicKey)] = r.Revision.Revision
+			if r.Revision.PrunedRevision > 0 {
+				pruned[string(r.Revision.PublicKey)] = r.Revision.PrunedRevision
+			}
+		case r.Tombstone != nil:
+			tombstones = append(tombstones, memoryTombstone{
+				id:        r.Tombstone.ID,
+				publicKey: string(r.Tombstone.PublicKey),
+				revision:  r.Tombstone.Revision,
+				createdAt: r.Tombstone.created(),
+			})
+		case r.Audit != nil:
+			audit = append(audit, AuditRecord{
+				ID:            r.Audit.ID,
+				PublicKey:     r.Audit.PublicKey,
+				Op:            r.Audit.Op,
+				EntryID:       r.Audit.EntryID,
+				Peer:          r.Audit.Peer,
+				ClientVersion: r.Audit.ClientVersion,
+				CreatedAt:     r.Audit.CreatedAt,
+			})
+			if r.Audit.ID > auditSeq {
+				auditSeq = r.Audit.ID
+			}
+		case r.Device != nil:
+			devices[r.Device.ID] = &memoryDevice{
+				Device: Device{
+					ID:         r.Device.ID,
+					Name:       r.Device.Name,
+					CreatedAt:  r.Device.CreatedAt,
+					LastSeenAt: r.Device.LastSeenAt,
+					LastIP:     r.Device.LastIP,
+					RevokedAt:  r.Device.RevokedAt,
+				},
+				publicKey: r.Device.PublicKey,
+				tokenHash: r.Device.TokenHash,
+			}
+		case r.DisabledOwner != nil:
+			disabled[string(r.DisabledOwner.Fingerprint)] = struct{}{}
+		case r.IdempotencyKey != nil:
+			idempotency[memoryIdempotencyID{owner: string(r.IdempotencyKey.OwnerHash), key: r.IdempotencyKey.Key}] =
+				memoryIdempotencyKey{
+					entryID:     r.IdempotencyKey.EntryID,
+					requestHash: r.IdempotencyKey.RequestHash,
+					createdAt:   r.IdempotencyKey.CreatedAt,
+				}
+		default:
+			return errors.New("MemoryStorage Import: empty record")
+		}
+	}
+
+	for _, e := range entries {
+		sort.Slice(e.history, func(i, j int) bool { return e.history[i].Version < e.history[j].Version })
+	}
+	sort.Slice(audit, func(i, j int) bool { return audit[i].ID < audit[j].ID })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.empty() {
+		return fmt.Errorf("MemoryStorage Import: %w", ErrNotEmpty)
+	}
+	s.entries = entries
+	s.revisions = revisions
+	s.pruned = pruned
+	s.tombstones = tombstones
+	s.audit = audit
+	s.auditSeq = auditSeq
+	s.devices = devices
+	s.disabled = disabled
+	s.idempotency = idempotency
+
+	return nil
+}
+
+// Close - ничего не делает, данные в памяти.
+func (s *MemoryStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,20 @@
+CREATE TABLE uploads
+(
+    upload_id  uuid        NOT NULL,
+    idx        integer     NOT NULL,
+    hash       bytea       NOT NULL,
+    data       bytea       NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (upload_id, idx)
+);
+
+ALTER TABLE entries
+    ADD COLUMN manifest bytea;
+
+CREATE TABLE chunks
+(
+    entry_id uuid    NOT NULL REFERENCES entries (id) ON DELETE CASCADE,
+    idx      integer NOT NULL,
+    data     bytea   NOT NULL,
+    PRIMARY KEY (entry_id, idx)
+);
\ No newline at end of file
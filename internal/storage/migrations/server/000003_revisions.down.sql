@@ -0,0 +1,13 @@
+DROP TRIGGER entries_add_tombstone ON entries;
+DROP TRIGGER entries_set_revision ON entries;
+
+DROP FUNCTION entries_add_tombstone();
+DROP FUNCTION entries_set_revision();
+DROP FUNCTION next_revision(bytea);
+
+DROP TABLE tombstones;
+
+ALTER TABLE entries
+    DROP COLUMN revision;
+
+DROP TABLE owner_revisions;
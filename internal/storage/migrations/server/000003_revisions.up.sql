@@ -0,0 +1,65 @@
+CREATE TABLE owner_revisions
+(
+    public_key bytea PRIMARY KEY,
+    revision   bigint NOT NULL
+);
+
+ALTER TABLE entries
+    ADD COLUMN revision bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE tombstones
+(
+    id         uuid PRIMARY KEY,
+    public_key bytea  NOT NULL,
+    revision   bigint NOT NULL
+);
+
+-- существующие записи получают ревизию 1
+UPDATE entries
+SET revision = 1;
+
+INSERT INTO owner_revisions (public_key, revision)
+SELECT DISTINCT public_key, 1
+FROM entries;
+
+CREATE INDEX entries_public_key_revision_idx ON entries (public_key, revision);
+CREATE INDEX tombstones_public_key_revision_idx ON tombstones (public_key, revision);
+
+-- next_revision увеличивает ревизию владельца. Строка владельца блокируется до конца транзакции,
+-- поэтому изменения одного владельца получают ревизии в порядке фиксации.
+CREATE FUNCTION next_revision(owner bytea) RETURNS bigint AS
+$$
+INSERT INTO owner_revisions (public_key, revision)
+VALUES (owner, 1)
+ON CONFLICT (public_key) DO UPDATE SET revision = owner_revisions.revision + 1
+RETURNING revision;
+$$ LANGUAGE sql;
+
+CREATE FUNCTION entries_set_revision() RETURNS trigger AS
+$$
+BEGIN
+    NEW.revision := next_revision(NEW.public_key);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION entries_add_tombstone() RETURNS trigger AS
+$$
+BEGIN
+    INSERT INTO tombstones (id, public_key, revision)
+    VALUES (OLD.id, OLD.public_key, next_revision(OLD.public_key));
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER entries_set_revision
+    BEFORE INSERT OR UPDATE OF payload
+    ON entries
+    FOR EACH ROW
+EXECUTE FUNCTION entries_set_revision();
+
+CREATE TRIGGER entries_add_tombstone
+    AFTER DELETE
+    ON entries
+    FOR EACH ROW
+EXECUTE FUNCTION entries_add_tombstone();
@@ -0,0 +1,2 @@
+ALTER TABLE entries
+    ADD COLUMN version bigint NOT NULL DEFAULT 1;
@@ -0,0 +1 @@
+CREATE INDEX entries_public_key_id_idx ON entries (public_key, id);
@@ -0,0 +1,3 @@
+ALTER TABLE entries
+    DROP COLUMN updated_at,
+    DROP COLUMN created_at;
@@ -0,0 +1,15 @@
+DELETE FROM entries
+WHERE deleted_at IS NOT NULL;
+
+DROP TRIGGER entries_set_revision ON entries;
+
+CREATE TRIGGER entries_set_revision
+    BEFORE INSERT OR UPDATE OF payload
+    ON entries
+    FOR EACH ROW
+EXECUTE FUNCTION entries_set_revision();
+
+DROP INDEX entries_deleted_at_idx;
+
+ALTER TABLE entries
+    DROP COLUMN deleted_at;
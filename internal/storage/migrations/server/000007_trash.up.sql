@@ -0,0 +1,13 @@
+ALTER TABLE entries
+    ADD COLUMN deleted_at timestamptz;
+
+CREATE INDEX entries_deleted_at_idx ON entries (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- перемещение в корзину и восстановление тоже меняют ревизию владельца
+DROP TRIGGER entries_set_revision ON entries;
+
+CREATE TRIGGER entries_set_revision
+    BEFORE INSERT OR UPDATE OF payload, deleted_at
+    ON entries
+    FOR EACH ROW
+EXECUTE FUNCTION entries_set_revision();
@@ -0,0 +1,8 @@
+CREATE TABLE entry_history
+(
+    entry_id   uuid        NOT NULL REFERENCES entries (id) ON DELETE CASCADE,
+    version    bigint      NOT NULL,
+    payload    bytea       NOT NULL,
+    updated_at timestamptz NOT NULL,
+    PRIMARY KEY (entry_id, version)
+);
@@ -0,0 +1,26 @@
+CREATE TABLE audit_log
+(
+    id             bigserial PRIMARY KEY,
+    public_key     bytea       NOT NULL,
+    op             text        NOT NULL,
+    entry_id       uuid,
+    created_at     timestamptz NOT NULL DEFAULT now(),
+    peer           text        NOT NULL,
+    client_version text        NOT NULL
+);
+
+CREATE INDEX audit_log_public_key_id_idx ON audit_log (public_key, id);
+
+-- журнал только дополняется: записи нельзя изменить, удаляются они только вместе с аккаунтом
+CREATE FUNCTION audit_log_forbid_update() RETURNS trigger AS
+$$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_forbid_update
+    BEFORE UPDATE
+    ON audit_log
+    FOR EACH ROW
+EXECUTE FUNCTION audit_log_forbid_update();
@@ -0,0 +1,6 @@
+CREATE TABLE account_challenges
+(
+    public_key bytea PRIMARY KEY,
+    challenge  bytea       NOT NULL,
+    expires_at timestamptz NOT NULL
+);
@@ -0,0 +1,13 @@
+CREATE TABLE devices
+(
+    id           uuid PRIMARY KEY     DEFAULT gen_random_uuid(),
+    public_key   bytea       NOT NULL,
+    name         text        NOT NULL,
+    token_hash   bytea       NOT NULL,
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    last_seen_at timestamptz NOT NULL DEFAULT now(),
+    last_ip      text        NOT NULL DEFAULT '',
+    revoked_at   timestamptz
+);
+
+CREATE INDEX devices_public_key_idx ON devices (public_key);
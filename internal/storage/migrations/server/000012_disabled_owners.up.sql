@@ -0,0 +1,6 @@
+-- владельцы, которых отключил оператор; владелец хранится отпечатком SHA256(public_key)
+CREATE TABLE disabled_owners
+(
+    fingerprint bytea PRIMARY KEY,
+    disabled_at timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,8 @@
+DROP INDEX entries_owner_hash_revision_idx;
+DROP INDEX entries_owner_hash_id_idx;
+
+CREATE INDEX entries_public_key_id_idx ON entries (public_key, id);
+CREATE INDEX entries_public_key_revision_idx ON entries (public_key, revision);
+
+ALTER TABLE entries
+    DROP COLUMN owner_hash;
@@ -0,0 +1,7 @@
+DELETE FROM entries
+WHERE expires_at <= now();
+
+DROP INDEX entries_expires_at_idx;
+
+ALTER TABLE entries
+    DROP COLUMN expires_at;
@@ -0,0 +1,5 @@
+-- expires_at - время, после которого запись скрывается и удаляется фоновой очисткой без корзины
+ALTER TABLE entries
+    ADD COLUMN expires_at timestamptz;
+
+CREATE INDEX entries_expires_at_idx ON entries (expires_at) WHERE expires_at IS NOT NULL;
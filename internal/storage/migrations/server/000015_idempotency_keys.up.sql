@@ -0,0 +1,13 @@
+-- ключи идемпотентности создания записей: повторный запрос с тем же ключом владельца
+-- возвращает ID уже созданной записи; владелец хранится отпечатком SHA256(public_key)
+CREATE TABLE idempotency_keys
+(
+    owner_hash   bytea       NOT NULL,
+    key          text        NOT NULL,
+    entry_id     uuid        NOT NULL,
+    request_hash bytea       NOT NULL,
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (owner_hash, key)
+);
+
+CREATE INDEX idempotency_keys_created_at_idx ON idempotency_keys (created_at);
@@ -0,0 +1,7 @@
+-- режим обслуживания, включенный оператором: пока строка есть, сервер отклоняет изменения записей
+CREATE TABLE read_only_mode
+(
+    id                  boolean PRIMARY KEY DEFAULT true CHECK (id),
+    retry_after_seconds bigint      NOT NULL,
+    since               timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,6 @@
+DELETE FROM uploads;
+
+ALTER TABLE uploads
+    DROP CONSTRAINT uploads_pkey,
+    DROP COLUMN owner_hash,
+    ADD PRIMARY KEY (upload_id, idx);
@@ -0,0 +1,9 @@
+-- незавершенные загрузки без владельца продолжить нельзя
+DELETE FROM uploads;
+
+ALTER TABLE uploads
+    ADD COLUMN owner_hash bytea NOT NULL;
+
+ALTER TABLE uploads
+    DROP CONSTRAINT uploads_pkey,
+    ADD PRIMARY KEY (owner_hash, upload_id, idx);
@@ -0,0 +1,7 @@
+DROP INDEX tombstones_created_at_idx;
+
+ALTER TABLE owner_revisions
+    DROP COLUMN pruned_revision;
+
+ALTER TABLE tombstones
+    DROP COLUMN created_at;
@@ -0,0 +1,9 @@
+-- надгробия старше срока хранения удаляются, клиенты с курсором ниже pruned_revision
+-- получают полную синхронизацию вместо списка удаленных записей
+ALTER TABLE tombstones
+    ADD COLUMN created_at timestamptz NOT NULL DEFAULT now();
+
+ALTER TABLE owner_revisions
+    ADD COLUMN pruned_revision bigint NOT NULL DEFAULT 0;
+
+CREATE INDEX tombstones_created_at_idx ON tombstones (created_at);
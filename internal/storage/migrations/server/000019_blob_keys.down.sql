@@ -0,0 +1,8 @@
+DROP INDEX entry_history_blob_key_idx;
+DROP INDEX entries_blob_key_idx;
+
+ALTER TABLE entry_history
+    DROP COLUMN blob_key;
+
+ALTER TABLE entries
+    DROP COLUMN blob_key;
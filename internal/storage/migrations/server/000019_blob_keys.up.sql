@@ -0,0 +1,20 @@
+-- ключ объекта из ссылки в payload ("\x00gophkeeper-blob\x00" || ключ || SHA-256 данных, 65 байт),
+-- по нему сборка мусора хранилища объектов находит объекты, на которые ссылаются записи
+ALTER TABLE entries
+    ADD COLUMN blob_key bytea GENERATED ALWAYS AS (
+        CASE
+            WHEN length(payload) = 65 AND substring(payload FROM 1 FOR 17) = '\x00676f70686b65657065722d626c6f6200'::bytea
+                THEN substring(payload FROM 18 FOR 16)
+        END
+    ) STORED;
+
+ALTER TABLE entry_history
+    ADD COLUMN blob_key bytea GENERATED ALWAYS AS (
+        CASE
+            WHEN length(payload) = 65 AND substring(payload FROM 1 FOR 17) = '\x00676f70686b65657065722d626c6f6200'::bytea
+                THEN substring(payload FROM 18 FOR 16)
+        END
+    ) STORED;
+
+CREATE INDEX entries_blob_key_idx ON entries (blob_key) WHERE blob_key IS NOT NULL;
+CREATE INDEX entry_history_blob_key_idx ON entry_history (blob_key) WHERE blob_key IS NOT NULL;
@@ -0,0 +1,19 @@
+-- при хранилище объектов части файлов тоже хранятся ссылками, формат тот же, что в миграции 19
+ALTER TABLE uploads
+    ADD COLUMN blob_key bytea GENERATED ALWAYS AS (
+        CASE
+            WHEN length(data) = 65 AND substring(data FROM 1 FOR 17) = '\x00676f70686b65657065722d626c6f6200'::bytea
+                THEN substring(data FROM 18 FOR 16)
+        END
+    ) STORED;
+
+ALTER TABLE chunks
+    ADD COLUMN blob_key bytea GENERATED ALWAYS AS (
+        CASE
+            WHEN length(data) = 65 AND substring(data FROM 1 FOR 17) = '\x00676f70686b65657065722d626c6f6200'::bytea
+                THEN substring(data FROM 18 FOR 16)
+        END
+    ) STORED;
+
+CREATE INDEX uploads_blob_key_idx ON uploads (blob_key) WHERE blob_key IS NOT NULL;
+CREATE INDEX chunks_blob_key_idx ON chunks (blob_key) WHERE blob_key IS NOT NULL;
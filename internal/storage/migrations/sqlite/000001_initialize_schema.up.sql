@@ -0,0 +1,7 @@
+-- ID записей генерирует сервер, время хранится в микросекундах unix
+CREATE TABLE entries
+(
+    id         text PRIMARY KEY NOT NULL,
+    public_key blob             NOT NULL,
+    payload    blob             NOT NULL
+);
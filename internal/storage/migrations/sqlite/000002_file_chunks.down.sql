@@ -0,0 +1,6 @@
+DROP TABLE chunks;
+
+ALTER TABLE entries
+    DROP COLUMN manifest;
+
+DROP TABLE uploads;
@@ -0,0 +1,20 @@
+CREATE TABLE uploads
+(
+    upload_id  text    NOT NULL,
+    idx        integer NOT NULL,
+    hash       blob    NOT NULL,
+    data       blob    NOT NULL,
+    created_at integer NOT NULL,
+    PRIMARY KEY (upload_id, idx)
+);
+
+ALTER TABLE entries
+    ADD COLUMN manifest blob;
+
+CREATE TABLE chunks
+(
+    entry_id text    NOT NULL REFERENCES entries (id) ON DELETE CASCADE,
+    idx      integer NOT NULL,
+    data     blob    NOT NULL,
+    PRIMARY KEY (entry_id, idx)
+);
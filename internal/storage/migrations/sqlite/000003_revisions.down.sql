@@ -0,0 +1,12 @@
+DROP TRIGGER entries_add_tombstone;
+DROP TRIGGER entries_update_revision;
+DROP TRIGGER entries_insert_revision;
+
+DROP TABLE tombstones;
+
+DROP INDEX entries_public_key_revision_idx;
+
+ALTER TABLE entries
+    DROP COLUMN revision;
+
+DROP TABLE owner_revisions;
@@ -0,0 +1,62 @@
+CREATE TABLE owner_revisions
+(
+    public_key blob PRIMARY KEY,
+    revision   integer NOT NULL
+);
+
+ALTER TABLE entries
+    ADD COLUMN revision integer NOT NULL DEFAULT 0;
+
+CREATE TABLE tombstones
+(
+    id         text PRIMARY KEY,
+    public_key blob    NOT NULL,
+    revision   integer NOT NULL
+);
+
+-- существующие записи получают ревизию 1
+UPDATE entries
+SET revision = 1;
+
+INSERT INTO owner_revisions (public_key, revision)
+SELECT DISTINCT public_key, 1
+FROM entries;
+
+CREATE INDEX entries_public_key_revision_idx ON entries (public_key, revision);
+CREATE INDEX tombstones_public_key_revision_idx ON tombstones (public_key, revision);
+
+-- запись в базу одновременно выполняет только одна транзакция,
+-- поэтому изменения одного владельца получают ревизии в порядке фиксации
+CREATE TRIGGER entries_insert_revision
+    AFTER INSERT
+    ON entries
+BEGIN
+    INSERT OR IGNORE INTO owner_revisions (public_key, revision) VALUES (NEW.public_key, 0);
+    UPDATE owner_revisions SET revision = revision + 1 WHERE public_key = NEW.public_key;
+    UPDATE entries
+    SET revision = (SELECT revision FROM owner_revisions WHERE public_key = NEW.public_key)
+    WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER entries_update_revision
+    AFTER UPDATE OF payload
+    ON entries
+BEGIN
+    INSERT OR IGNORE INTO owner_revisions (public_key, revision) VALUES (NEW.public_key, 0);
+    UPDATE owner_revisions SET revision = revision + 1 WHERE public_key = NEW.public_key;
+    UPDATE entries
+    SET revision = (SELECT revision FROM owner_revisions WHERE public_key = NEW.public_key)
+    WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER entries_add_tombstone
+    AFTER DELETE
+    ON entries
+BEGIN
+    INSERT OR IGNORE INTO owner_revisions (public_key, revision) VALUES (OLD.public_key, 0);
+    UPDATE owner_revisions SET revision = revision + 1 WHERE public_key = OLD.public_key;
+    INSERT INTO tombstones (id, public_key, revision)
+    SELECT OLD.id, OLD.public_key, revision
+    FROM owner_revisions
+    WHERE public_key = OLD.public_key;
+END;
@@ -0,0 +1,2 @@
+ALTER TABLE entries
+    ADD COLUMN version integer NOT NULL DEFAULT 1;
@@ -0,0 +1 @@
+DROP INDEX entries_public_key_id_idx;
@@ -0,0 +1,5 @@
+ALTER TABLE entries
+    DROP COLUMN updated_at;
+
+ALTER TABLE entries
+    DROP COLUMN created_at;
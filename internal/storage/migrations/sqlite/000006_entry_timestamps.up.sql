@@ -0,0 +1,11 @@
+-- sqlite не позволяет добавить столбец с вычисляемым значением по умолчанию:
+-- время проставляет сервер, существующие записи получают время миграции
+ALTER TABLE entries
+    ADD COLUMN created_at integer NOT NULL DEFAULT 0;
+
+ALTER TABLE entries
+    ADD COLUMN updated_at integer NOT NULL DEFAULT 0;
+
+UPDATE entries
+SET created_at = CAST(strftime('%s', 'now') AS integer) * 1000000,
+    updated_at = CAST(strftime('%s', 'now') AS integer) * 1000000;
@@ -0,0 +1,20 @@
+DELETE FROM entries
+WHERE deleted_at IS NOT NULL;
+
+DROP TRIGGER entries_update_revision;
+
+CREATE TRIGGER entries_update_revision
+    AFTER UPDATE OF payload
+    ON entries
+BEGIN
+    INSERT OR IGNORE INTO owner_revisions (public_key, revision) VALUES (NEW.public_key, 0);
+    UPDATE owner_revisions SET revision = revision + 1 WHERE public_key = NEW.public_key;
+    UPDATE entries
+    SET revision = (SELECT revision FROM owner_revisions WHERE public_key = NEW.public_key)
+    WHERE id = NEW.id;
+END;
+
+DROP INDEX entries_deleted_at_idx;
+
+ALTER TABLE entries
+    DROP COLUMN deleted_at;
@@ -0,0 +1,18 @@
+ALTER TABLE entries
+    ADD COLUMN deleted_at integer;
+
+CREATE INDEX entries_deleted_at_idx ON entries (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- перемещение в корзину и восстановление тоже меняют ревизию владельца
+DROP TRIGGER entries_update_revision;
+
+CREATE TRIGGER entries_update_revision
+    AFTER UPDATE OF payload, deleted_at
+    ON entries
+BEGIN
+    INSERT OR IGNORE INTO owner_revisions (public_key, revision) VALUES (NEW.public_key, 0);
+    UPDATE owner_revisions SET revision = revision + 1 WHERE public_key = NEW.public_key;
+    UPDATE entries
+    SET revision = (SELECT revision FROM owner_revisions WHERE public_key = NEW.public_key)
+    WHERE id = NEW.id;
+END;
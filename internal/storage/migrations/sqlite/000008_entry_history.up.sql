@@ -0,0 +1,8 @@
+CREATE TABLE entry_history
+(
+    entry_id   text    NOT NULL REFERENCES entries (id) ON DELETE CASCADE,
+    version    integer NOT NULL,
+    payload    blob    NOT NULL,
+    updated_at integer NOT NULL,
+    PRIMARY KEY (entry_id, version)
+);
@@ -0,0 +1,21 @@
+-- AUTOINCREMENT не переиспользует ID удаленных записей, как и bigserial
+CREATE TABLE audit_log
+(
+    id             integer PRIMARY KEY AUTOINCREMENT,
+    public_key     blob    NOT NULL,
+    op             text    NOT NULL,
+    entry_id       text,
+    created_at     integer NOT NULL,
+    peer           text    NOT NULL,
+    client_version text    NOT NULL
+);
+
+CREATE INDEX audit_log_public_key_id_idx ON audit_log (public_key, id);
+
+-- журнал только дополняется: записи нельзя изменить, удаляются они только вместе с аккаунтом
+CREATE TRIGGER audit_log_forbid_update
+    BEFORE UPDATE
+    ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
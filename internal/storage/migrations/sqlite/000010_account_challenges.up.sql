@@ -0,0 +1,6 @@
+CREATE TABLE account_challenges
+(
+    public_key blob PRIMARY KEY,
+    challenge  blob    NOT NULL,
+    expires_at integer NOT NULL
+);
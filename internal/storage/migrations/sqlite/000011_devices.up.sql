@@ -0,0 +1,13 @@
+CREATE TABLE devices
+(
+    id           text PRIMARY KEY,
+    public_key   blob    NOT NULL,
+    name         text    NOT NULL,
+    token_hash   blob    NOT NULL,
+    created_at   integer NOT NULL,
+    last_seen_at integer NOT NULL,
+    last_ip      text    NOT NULL DEFAULT '',
+    revoked_at   integer
+);
+
+CREATE INDEX devices_public_key_idx ON devices (public_key);
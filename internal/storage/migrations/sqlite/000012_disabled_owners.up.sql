@@ -0,0 +1,6 @@
+-- владельцы, которых отключил оператор; владелец хранится отпечатком SHA256(public_key)
+CREATE TABLE disabled_owners
+(
+    fingerprint blob PRIMARY KEY,
+    disabled_at integer NOT NULL
+);
@@ -0,0 +1,13 @@
+-- owner_hash - SHA-256 публичного ключа владельца (отпечаток из Fingerprint): записи владельца
+-- ищутся по 32 байтам вместо 512-байтного модуля. Функцию sha256 регистрирует SQLiteStorage.
+ALTER TABLE entries
+    ADD COLUMN owner_hash blob NOT NULL DEFAULT x'';
+
+UPDATE entries
+SET owner_hash = sha256(public_key);
+
+DROP INDEX entries_public_key_id_idx;
+DROP INDEX entries_public_key_revision_idx;
+
+CREATE INDEX entries_owner_hash_id_idx ON entries (owner_hash, id);
+CREATE INDEX entries_owner_hash_revision_idx ON entries (owner_hash, revision);
@@ -0,0 +1,7 @@
+-- режим обслуживания, включенный оператором: пока строка есть, сервер отклоняет изменения записей
+CREATE TABLE read_only_mode
+(
+    id                  integer PRIMARY KEY CHECK (id = 1),
+    retry_after_seconds integer NOT NULL,
+    since               integer NOT NULL
+);
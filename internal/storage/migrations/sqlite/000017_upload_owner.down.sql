@@ -0,0 +1,11 @@
+DROP TABLE uploads;
+
+CREATE TABLE uploads
+(
+    upload_id  text    NOT NULL,
+    idx        integer NOT NULL,
+    hash       blob    NOT NULL,
+    data       blob    NOT NULL,
+    created_at integer NOT NULL,
+    PRIMARY KEY (upload_id, idx)
+);
@@ -0,0 +1,13 @@
+-- незавершенные загрузки без владельца продолжить нельзя
+DROP TABLE uploads;
+
+CREATE TABLE uploads
+(
+    owner_hash blob    NOT NULL,
+    upload_id  text    NOT NULL,
+    idx        integer NOT NULL,
+    hash       blob    NOT NULL,
+    data       blob    NOT NULL,
+    created_at integer NOT NULL,
+    PRIMARY KEY (owner_hash, upload_id, idx)
+);
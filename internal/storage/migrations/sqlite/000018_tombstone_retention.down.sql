@@ -0,0 +1,21 @@
+DROP TRIGGER entries_add_tombstone;
+
+CREATE TRIGGER entries_add_tombstone
+    AFTER DELETE
+    ON entries
+BEGIN
+    INSERT OR IGNORE INTO owner_revisions (public_key, revision) VALUES (OLD.public_key, 0);
+    UPDATE owner_revisions SET revision = revision + 1 WHERE public_key = OLD.public_key;
+    INSERT INTO tombstones (id, public_key, revision)
+    SELECT OLD.id, OLD.public_key, revision
+    FROM owner_revisions
+    WHERE public_key = OLD.public_key;
+END;
+
+DROP INDEX tombstones_created_at_idx;
+
+ALTER TABLE owner_revisions
+    DROP COLUMN pruned_revision;
+
+ALTER TABLE tombstones
+    DROP COLUMN created_at;
@@ -0,0 +1,27 @@
+-- надгробия старше срока хранения удаляются, клиенты с курсором ниже pruned_revision
+-- получают полную синхронизацию вместо списка удаленных записей
+ALTER TABLE tombstones
+    ADD COLUMN created_at integer NOT NULL DEFAULT 0;
+
+-- время хранится в микросекундах Unix, как и в остальных таблицах
+UPDATE tombstones
+SET created_at = CAST((julianday('now') - 2440587.5) * 86400000000 AS integer);
+
+ALTER TABLE owner_revisions
+    ADD COLUMN pruned_revision integer NOT NULL DEFAULT 0;
+
+CREATE INDEX tombstones_created_at_idx ON tombstones (created_at);
+
+DROP TRIGGER entries_add_tombstone;
+
+CREATE TRIGGER entries_add_tombstone
+    AFTER DELETE
+    ON entries
+BEGIN
+    INSERT OR IGNORE INTO owner_revisions (public_key, revision) VALUES (OLD.public_key, 0);
+    UPDATE owner_revisions SET revision = revision + 1 WHERE public_key = OLD.public_key;
+    INSERT INTO tombstones (id, public_key, revision, created_at)
+    SELECT OLD.id, OLD.public_key, revision, CAST((julianday('now') - 2440587.5) * 86400000000 AS integer)
+    FROM owner_revisions
+    WHERE public_key = OLD.public_key;
+END;
@@ -0,0 +1,20 @@
+-- ключ объекта из ссылки в payload ("\x00gophkeeper-blob\x00" || ключ || SHA-256 данных, 65 байт),
+-- по нему сборка мусора хранилища объектов находит объекты, на которые ссылаются записи
+ALTER TABLE entries
+    ADD COLUMN blob_key blob GENERATED ALWAYS AS (
+        CASE
+            WHEN length(payload) = 65 AND substr(payload, 1, 17) = X'00676f70686b65657065722d626c6f6200'
+                THEN substr(payload, 18, 16)
+        END
+    ) VIRTUAL;
+
+ALTER TABLE entry_history
+    ADD COLUMN blob_key blob GENERATED ALWAYS AS (
+        CASE
+            WHEN length(payload) = 65 AND substr(payload, 1, 17) = X'00676f70686b65657065722d626c6f6200'
+                THEN substr(payload, 18, 16)
+        END
+    ) VIRTUAL;
+
+CREATE INDEX entries_blob_key_idx ON entries (blob_key) WHERE blob_key IS NOT NULL;
+CREATE INDEX entry_history_blob_key_idx ON entry_history (blob_key) WHERE blob_key IS NOT NULL;
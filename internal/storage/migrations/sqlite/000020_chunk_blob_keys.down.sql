@@ -0,0 +1,8 @@
+DROP INDEX chunks_blob_key_idx;
+DROP INDEX uploads_blob_key_idx;
+
+ALTER TABLE chunks
+    DROP COLUMN blob_key;
+
+ALTER TABLE uploads
+    DROP COLUMN blob_key;
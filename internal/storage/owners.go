@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// Fingerprint - отпечаток владельца SHA256(publicKey). По отпечатку оператор
+// различает владельцев, не видя их публичных ключей.
+func Fingerprint(publicKey []byte) []byte {
+	sum := sha256.Sum256(publicKey)
+	return sum[:]
+}
+
+// FormatFingerprint - отпечаток владельца в виде hex строки.
+func FormatFingerprint(fingerprint []byte) string {
+	return hex.EncodeToString(fingerprint)
+}
+
+// ParseFingerprint - разобрать отпечаток владельца из hex строки.
+func ParseFingerprint(s string) ([]byte, error) {
+	fingerprint, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decode hex: %w", err)
+	}
+	if len(fingerprint) != sha256.Size {
+		return nil, fmt.Errorf("fingerprint must be %d bytes, got %d", sha256.Size, len(fingerprint))
+	}
+
+	return fingerprint, nil
+}
+
+// DisableOwner - отключить владельца по отпечатку. Повторное отключение не считается ошибкой.
+func (s *ServerStorage) DisableOwner(ctx context.Context, fingerprint []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("disable_owner", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage DisableOwner", "INSERT")
+	defer span.End()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO disabled_owners (fingerprint) VALUES ($1) ON CONFLICT (fingerprint) DO NOTHING`,
+		fingerprint,
+	)
+	if err != nil {
+		return fmt.Errorf("ServerStorage DisableOwner: exec: %w", err)
+	}
+
+	return nil
+}
+
+// EnableOwner - снова включить владельца по отпечатку.
+// Если владелец не был отключен, возвращается ErrNotFound.
+func (s *ServerStorage) EnableOwner(ctx context.Context, fingerprint []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("enable_owner", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage EnableOwner", "DELETE")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM disabled_owners WHERE fingerprint = $1`,
+		fingerprint,
+	)
+	if err != nil {
+		return fmt.Errorf("ServerStorage EnableOwner: exec: %w", err)
+	}
+
+	return checkAffected("ServerStorage EnableOwner", res)
+}
+
+// IsOwnerDisabled - отключен ли владелец публичного ключа.
+func (s *ServerStorage) IsOwnerDisabled(ctx context.Context, publicKey []byte) (disabled bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("is_owner_disabled", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage IsOwnerDisabled", "SELECT")
+	defer span.End()
+
+	row := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM disabled_owners WHERE fingerprint = $1)`,
+		Fingerprint(publicKey),
+	)
+	err = row.Scan(&disabled)
+	if err != nil {
+		return false, fmt.Errorf("ServerStorage IsOwnerDisabled: query row: %w", err)
+	}
+
+	return disabled, nil
+}
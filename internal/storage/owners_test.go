@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFingerprint(t *testing.T) {
+	fingerprint := Fingerprint([]byte{1, 2, 3})
+
+	got, err := ParseFingerprint(FormatFingerprint(fingerprint))
+	require.NoError(t, err)
+	assert.Equal(t, fingerprint, got)
+
+	_, err = ParseFingerprint("zz")
+	assert.Error(t, err)
+
+	_, err = ParseFingerprint("abcd")
+	assert.Error(t, err)
+}
+
+func TestServerStorage_DisableOwner(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	fingerprint := Fingerprint([]byte{9})
+	mock.ExpectExec("INSERT INTO disabled_owners").WithArgs(fingerprint).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	s := ServerStorage{db: db}
+	require.NoError(t, s.DisableOwner(context.Background(), fingerprint))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestServerStorage_EnableOwner(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected int64
+		wantErr  error
+	}{
+		{
+			name:     "ok",
+			affected: 1,
+		},
+		{
+			name:     "not disabled",
+			affected: 0,
+			wantErr:  ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = db.Close() }()
+
+			fingerprint := Fingerprint([]byte{9})
+			mock.ExpectExec("DELETE FROM disabled_owners").WithArgs(fingerprint).
+				WillReturnResult(sqlmock.NewResult(0, tt.affected))
+
+			s := ServerStorage{db: db}
+			err = s.EnableOwner(context.Background(), fingerprint)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestServerStorage_IsOwnerDisabled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectQuery("FROM disabled_owners").WithArgs(Fingerprint([]byte{9})).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	s := ServerStorage{db: db}
+	disabled, err := s.IsOwnerDisabled(context.Background(), []byte{9})
+	require.NoError(t, err)
+	assert.True(t, disabled)
+}
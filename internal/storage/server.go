@@ -7,42 +7,104 @@ import (
 	"embed"
 	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres" // postgres init for golang-migrate
 	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/google/uuid"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
 )
 
 //go:embed migrations
 var migrationsFS embed.FS
 
-// ErrNotFound - запись не найдена.
-var ErrNotFound = errors.New("entry not found")
+var (
+	// ErrNotFound - запись не найдена.
+	ErrNotFound = errors.New("entry not found")
+	// ErrNotFile - запись не является файлом, загруженным по частям.
+	ErrNotFile = errors.New("entry is not a file")
+	// ErrVersionConflict - версия записи не совпала с ожидаемой.
+	ErrVersionConflict = errors.New("entry version conflict")
+)
+
+// VersionConflictError - запись была изменена: версия не совпала с ожидаемой.
+type VersionConflictError struct {
+	// Current - текущая версия записи.
+	Current int64
+}
+
+// Error - текст ошибки.
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s: current version %d", ErrVersionConflict, e.Current)
+}
 
-type entry struct {
+// Unwrap - для errors.Is(err, ErrVersionConflict).
+func (e *VersionConflictError) Unwrap() error {
+	return ErrVersionConflict
+}
+
+// Entry - запись владельца. Payload зашифрован на клиенте, сервер его не расшифровывает.
+type Entry struct {
 	ID        uuid.UUID
 	PublicKey []byte
 	Payload   []byte
+	Revision  int64
+	Version   int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt time.Time
+	// ExpiresAt - время истечения, нулевое для бессрочной записи.
+	ExpiresAt time.Time
+}
+
+// DefaultQueryTimeout - время выполнения запроса к базе данных, если в Config оно не задано.
+const DefaultQueryTimeout = 10 * time.Second
+
+// defaultMaxIdleConns - значение database/sql по умолчанию.
+const defaultMaxIdleConns = 2
+
+// Config - настройки хранилища сервера.
+type Config struct {
+	// DSN - строка подключения: postgres://, sqlite:// или memory://.
+	DSN string
+	// HistoryLimit - сколько предыдущих версий каждой записи хранить.
+	HistoryLimit int
+	// QueryTimeout - время выполнения одного запроса к базе данных.
+	QueryTimeout time.Duration
+
+	// MaxOpenConns, MaxIdleConns, ConnMaxLifetime и ConnMaxIdleTime - настройки пула соединений,
+	// нулевые значения соответствуют значениям database/sql по умолчанию.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
 }
 
 // ServerStorage - хранилище для сервера.
 type ServerStorage struct {
-	db *sql.DB
-}
+	db  *sql.DB
+	dsn string
 
-// NewServerStorage - создаем новое хранилище для сервера.
-func NewServerStorage(dsn string) (*ServerStorage, error) {
-	s := &ServerStorage{}
+	historyLimit int
+	queryTimeout atomic.Int64
+}
 
-	var err error
-	s.db, err = sql.Open("postgres", dsn)
+// NewServerStorage - создаем новое хранилище для сервера и применяем миграции.
+func NewServerStorage(cfg Config) (*ServerStorage, error) {
+	s, err := OpenServerStorage(cfg.DSN)
 	if err != nil {
-		return nil, fmt.Errorf("storage NewServerStorage: sql open error: %w", err)
+		return nil, fmt.Errorf("storage NewServerStorage: %w", err)
 	}
+	s.historyLimit = cfg.HistoryLimit
+	s.Reconfigure(cfg)
 
-	err = s.doMigrate(dsn)
+	err = s.doMigrate()
 	if err != nil {
 		return nil, fmt.Errorf("storage NewServerStorage: migrate error: %w", err)
 	}
@@ -50,98 +112,267 @@ func NewServerStorage(dsn string) (*ServerStorage, error) {
 	return s, nil
 }
 
-// Get - получить запись по ID.
-func (s *ServerStorage) Get(ctx context.Context, id uuid.UUID) (e entry, err error) {
-	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+// OpenServerStorage - открыть хранилище без применения миграций.
+// Используется для обслуживания базы, когда миграциями управляют явно.
+func OpenServerStorage(dsn string) (*ServerStorage, error) {
+	s := &ServerStorage{
+		dsn: dsn,
+	}
+
+	var err error
+	s.db, err = sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("storage OpenServerStorage: sql open error: %w", err)
+	}
+
+	return s, nil
+}
+
+// Reconfigure - применить настройки, которые можно менять на работающем хранилище:
+// время выполнения запросов и настройки пула соединений. DSN и HistoryLimit не меняются.
+func (s *ServerStorage) Reconfigure(cfg Config) {
+	s.queryTimeout.Store(int64(cfg.QueryTimeout))
+	configurePool(s.db, cfg)
+}
+
+// configurePool - применить настройки пула соединений из cfg.
+func configurePool(db *sql.DB, cfg Config) {
+	maxIdleConns := cfg.MaxIdleConns
+	if maxIdleConns == 0 {
+		maxIdleConns = defaultMaxIdleConns
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(maxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+}
+
+// timeout - время выполнения одного запроса к базе данных.
+func (s *ServerStorage) timeout() time.Duration {
+	if d := time.Duration(s.queryTimeout.Load()); d > 0 {
+		return d
+	}
+
+	return DefaultQueryTimeout
+}
+
+// Get - получить запись по ID. Истекшие записи не возвращаются.
+func (s *ServerStorage) Get(ctx context.Context, id uuid.UUID) (e Entry, err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
 	defer cancel()
+	defer metrics.ObserveQuery("get", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage Get", "SELECT")
+	defer span.End()
 
-	e = entry{
+	e = Entry{
 		ID: id,
 	}
 
-	row := s.db.QueryRowContext(ctx, `SELECT public_key, payload FROM entries WHERE id = $1`, id)
-	err = row.Scan(&e.PublicKey, &e.Payload)
+	var expiresAt sql.NullTime
+	row := s.db.QueryRowContext(ctx,
+		`SELECT public_key, payload, version, created_at, updated_at, expires_at FROM entries
+		WHERE id = $1 AND deleted_at IS NULL AND (expires_at IS NULL OR expires_at > now())`,
+		id,
+	)
+	err = row.Scan(&e.PublicKey, &e.Payload, &e.Version, &e.CreatedAt, &e.UpdatedAt, &expiresAt)
 	if errors.Is(err, sql.ErrNoRows) {
-		return entry{}, fmt.Errorf("ServerStorage Get: query row: %w", ErrNotFound)
+		return Entry{}, fmt.Errorf("ServerStorage Get: query row: %w", ErrNotFound)
 	}
 	if err != nil {
-		return entry{}, fmt.Errorf("ServerStorage Get: query row: %w", err)
+		return Entry{}, fmt.Errorf("ServerStorage Get: query row: %w", err)
 	}
+	e.ExpiresAt = expiresAt.Time
 
 	return e, nil
 }
 
-// GetAll - получить все записи по publicKey.
-func (s *ServerStorage) GetAll(ctx context.Context, publicKey []byte) ([]entry, error) {
-	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+// GetAll - получить до limit записей по publicKey с ID больше after, отсортированных по ID.
+// Для первой страницы after равен uuid.Nil. Истекшие записи не возвращаются.
+func (s *ServerStorage) GetAll(ctx context.Context, publicKey []byte, after uuid.UUID, limit int) ([]Entry, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
 	defer cancel()
+	defer metrics.ObserveQuery("get_all", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage GetAll", "SELECT")
+	defer span.End()
 
-	rows, err := s.db.QueryContext(ctx, `SELECT id, payload FROM entries WHERE public_key = $1`, publicKey)
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, payload, revision, version, created_at, updated_at, expires_at FROM entries
+		WHERE owner_hash = $1 AND id > $2 AND deleted_at IS NULL AND (expires_at IS NULL OR expires_at > now())
+		ORDER BY id LIMIT $3`,
+		Fingerprint(publicKey), after, limit,
+	)
 	if errors.Is(err, sql.ErrNoRows) {
-		return []entry{}, nil
+		return []Entry{}, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("ServerStorage GetAll: query: %w", err)
 	}
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("ServerStorage GetAll: query rows: %w", err)
-	}
 	defer func() { _ = rows.Close() }()
 
-	entries := make([]entry, 0)
+	entries := make([]Entry, 0)
 	for rows.Next() {
-		e := entry{}
-		err = rows.Scan(&e.ID, &e.Payload)
+		e := Entry{}
+		var expiresAt sql.NullTime
+		err = rows.Scan(&e.ID, &e.Payload, &e.Revision, &e.Version, &e.CreatedAt, &e.UpdatedAt, &expiresAt)
 		if err != nil {
 			return nil, fmt.Errorf("ServerStorage GetAll: query rows scan: %w", err)
 		}
+		e.ExpiresAt = expiresAt.Time
 		entries = append(entries, e)
 	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ServerStorage GetAll: query rows: %w", err)
+	}
 
 	return entries, nil
 }
 
-// Create - добавить запись и вернуть ID.
-func (s *ServerStorage) Create(ctx context.Context, publicKey []byte, data []byte) (id uuid.UUID, err error) {
-	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+// Create - добавить запись и вернуть ID. Нулевой expiresAt - бессрочная запись.
+func (s *ServerStorage) Create(ctx context.Context, publicKey []byte, data []byte,
+	expiresAt time.Time,
+) (id uuid.UUID, err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
 	defer cancel()
+	defer metrics.ObserveQuery("create", time.Now())
 
-	row := s.db.QueryRowContext(ctx,
-		`INSERT INTO entries (public_key, payload) VALUES ($1, $2) RETURNING id`,
-		publicKey, data,
+	ctx, span := startSpan(ctx, "ServerStorage Create", "INSERT")
+	defer span.End()
+
+	id, err = createEntry(ctx, s.db, publicKey, data, expiresAt)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("ServerStorage Create: %w", err)
+	}
+
+	return id, nil
+}
+
+// createEntry - добавить запись в базе или транзакции q.
+func createEntry(ctx context.Context, q querier, publicKey []byte, data []byte,
+	expiresAt time.Time,
+) (id uuid.UUID, err error) {
+	row := q.QueryRowContext(ctx,
+		`INSERT INTO entries (public_key, owner_hash, payload, expires_at) VALUES ($1, $2, $3, $4) RETURNING id`,
+		publicKey, Fingerprint(publicKey), data, nullTime(expiresAt),
 	)
 	err = row.Scan(&id)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("ServerStorage Create: query row scan: %w", err)
+		return uuid.Nil, fmt.Errorf("query row scan: %w", err)
 	}
 
 	return id, nil
 }
 
-// Delete - удалить запись по ID.
-func (s *ServerStorage) Delete(ctx context.Context, id uuid.UUID) error {
-	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+// Delete - переместить запись в корзину, если ее версия равна version.
+// Если версия не совпала, возвращается *VersionConflictError.
+func (s *ServerStorage) Delete(ctx context.Context, id uuid.UUID, version int64) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
 	defer cancel()
+	defer metrics.ObserveQuery("delete", time.Now())
 
-	_, err := s.db.ExecContext(ctx, `DELETE FROM entries WHERE id = $1`, id)
+	ctx, span := startSpan(ctx, "ServerStorage Delete", "UPDATE")
+	defer span.End()
+
+	err := deleteEntry(ctx, s.db, id, version)
 	if err != nil {
-		return fmt.Errorf("ServerStorage Delete: exec: %w", err)
+		return fmt.Errorf("ServerStorage Delete: %w", err)
 	}
 
 	return nil
 }
 
-// Update - обновляем запись по ID.
-func (s *ServerStorage) Update(ctx context.Context, id uuid.UUID, data []byte) error {
-	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+// deleteEntry - переместить запись в корзину в базе или транзакции q, если ее версия равна version.
+func deleteEntry(ctx context.Context, q querier, id uuid.UUID, version int64) error {
+	row := q.QueryRowContext(ctx,
+		`WITH current AS (SELECT version FROM entries WHERE id = $1 AND deleted_at IS NULL),
+		deleted AS (
+			UPDATE entries SET deleted_at = now()
+			WHERE id = $1 AND version = $2 AND deleted_at IS NULL RETURNING version
+		)
+		SELECT (SELECT version FROM deleted), (SELECT version FROM current)`,
+		id, version,
+	)
+
+	_, err := scanCAS(row)
+	return err
+}
+
+// Update - обновить запись по ID, если ее версия равна version, и вернуть новую версию.
+// Время истечения заменяется на expiresAt, нулевой expiresAt делает запись бессрочной.
+// Предыдущие данные сохраняются в историю, в которой остается не больше historyLimit версий.
+// Если версия не совпала, возвращается *VersionConflictError.
+func (s *ServerStorage) Update(ctx context.Context, id uuid.UUID, data []byte, version int64,
+	expiresAt time.Time,
+) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
 	defer cancel()
+	defer metrics.ObserveQuery("update", time.Now())
 
-	_, err := s.db.ExecContext(ctx, `UPDATE entries SET payload = $1 WHERE id = $2`, data, id)
+	ctx, span := startSpan(ctx, "ServerStorage Update", "UPDATE")
+	defer span.End()
+
+	newVersion, err := s.updateEntry(ctx, s.db, id, data, version, expiresAt)
 	if err != nil {
-		return fmt.Errorf("ServerStorage Update: exec: %w", err)
+		return 0, fmt.Errorf("ServerStorage Update: %w", err)
 	}
 
-	return nil
+	return newVersion, nil
+}
+
+// updateEntry - обновить запись в базе или транзакции q, если ее версия равна version.
+func (s *ServerStorage) updateEntry(ctx context.Context, q querier, id uuid.UUID, data []byte, version int64,
+	expiresAt time.Time,
+) (int64, error) {
+	row := q.QueryRowContext(ctx,
+		`WITH current AS (SELECT version, payload, updated_at FROM entries WHERE id = $2 AND deleted_at IS NULL),
+		updated AS (
+			UPDATE entries SET payload = $1, version = version + 1, updated_at = now(), expires_at = $5
+			WHERE id = $2 AND version = $3 AND deleted_at IS NULL RETURNING version
+		),
+		saved AS (
+			INSERT INTO entry_history (entry_id, version, payload, updated_at)
+			SELECT $2, version, payload, updated_at FROM current
+			WHERE $4 > 0 AND EXISTS (SELECT 1 FROM updated)
+		),
+		pruned AS (
+			DELETE FROM entry_history WHERE entry_id = $2 AND version < (SELECT version FROM updated) - $4
+		)
+		SELECT (SELECT version FROM updated), (SELECT version FROM current)`,
+		data, id, version, s.historyLimit, nullTime(expiresAt),
+	)
+
+	return scanCAS(row)
+}
+
+// querier - запросы, общие для *sql.DB и *sql.Tx.
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// scanCAS - разобрать результат compare-and-swap запроса: версию после изменения
+// (NULL, если условие не выполнилось) и версию до изменения (NULL, если записи нет).
+func scanCAS(row *sql.Row) (int64, error) {
+	var applied, current sql.NullInt64
+	err := row.Scan(&applied, &current)
+	if err != nil {
+		return 0, fmt.Errorf("query row: %w", err)
+	}
+	if !current.Valid {
+		return 0, ErrNotFound
+	}
+	if !applied.Valid {
+		return 0, &VersionConflictError{Current: current.Int64}
+	}
+
+	return applied.Int64, nil
+}
+
+// Stats - статистика пула соединений с базой данных.
+func (s *ServerStorage) Stats() sql.DBStats {
+	return s.db.Stats()
 }
 
 // Close - закрываем соединение с базой данных.
@@ -154,16 +385,16 @@ func (s *ServerStorage) Close() error {
 	return nil
 }
 
-func (s *ServerStorage) doMigrate(dsn string) error {
-	d, err := iofs.New(migrationsFS, "migrations/server")
-	if err != nil {
-		return fmt.Errorf("ServerStorage doMigrate: iofs: %w", err)
-	}
+func startSpan(ctx context.Context, name, operation string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name, semconv.DBSystemPostgreSQL, semconv.DBOperation(operation))
+}
 
-	m, err := migrate.NewWithSourceInstance("iofs", d, dsn)
+func (s *ServerStorage) doMigrate() error {
+	m, err := s.newMigrate()
 	if err != nil {
-		return fmt.Errorf("ServerStorage doMigrate: new migrate: %w", err)
+		return fmt.Errorf("ServerStorage doMigrate: %w", err)
 	}
+	defer func() { _, _ = m.Close() }()
 
 	err = m.Up()
 	if errors.Is(err, migrate.ErrNoChange) {
@@ -175,3 +406,17 @@ func (s *ServerStorage) doMigrate(dsn string) error {
 
 	return nil
 }
+
+func (s *ServerStorage) newMigrate() (*migrate.Migrate, error) {
+	d, err := iofs.New(migrationsFS, "migrations/server")
+	if err != nil {
+		return nil, fmt.Errorf("iofs: %w", err)
+	}
+
+	m, err := migrate.NewWithSourceInstance("iofs", d, s.dsn)
+	if err != nil {
+		return nil, fmt.Errorf("new migrate: %w", err)
+	}
+
+	return m, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
@@ -13,7 +14,7 @@ import (
 
 func TestNewServerStorage(t *testing.T) {
 	t.Run("wrong dsn", func(t *testing.T) {
-		_, err := NewServerStorage("dsn")
+		_, err := NewServerStorage(Config{DSN: "dsn", HistoryLimit: 10})
 		assert.Error(t, err)
 	})
 }
@@ -24,13 +25,18 @@ func TestServerStorage_Get(t *testing.T) {
 		require.NoError(t, err)
 		defer func() { _ = db.Close() }()
 
-		e := entry{
+		e := Entry{
 			ID:        uuid.New(),
 			PublicKey: []byte{1, 2, 3, 4, 5},
 			Payload:   []byte{6, 7, 8, 9, 0},
+			Version:   3,
+			CreatedAt: time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC),
+			UpdatedAt: time.Date(2023, 5, 2, 10, 0, 0, 0, time.UTC),
+			ExpiresAt: time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC),
 		}
 
-		rows := sqlmock.NewRows([]string{"public_key", "payload"}).AddRow(e.PublicKey, e.Payload)
+		rows := sqlmock.NewRows([]string{"public_key", "payload", "version", "created_at", "updated_at", "expires_at"}).
+			AddRow(e.PublicKey, e.Payload, e.Version, e.CreatedAt, e.UpdatedAt, e.ExpiresAt)
 		mock.ExpectQuery("SELECT public_key, payload").WithArgs(e.ID).WillReturnRows(rows)
 
 		s := ServerStorage{db: db}
@@ -88,16 +94,18 @@ func TestServerStorage_GetAll(t *testing.T) {
 			uuid.New(): {7, 8, 9},
 		}
 
-		rows := sqlmock.NewRows([]string{"id", "payload"})
+		now := time.Now()
+		rows := sqlmock.NewRows([]string{"id", "payload", "revision", "version", "created_at", "updated_at", "expires_at"})
 		for k, v := range data {
-			rows = rows.AddRow(k, v)
+			rows = rows.AddRow(k, v, 1, 1, now, now, nil)
 		}
 
-		mock.ExpectQuery("SELECT id, payload").WithArgs(publicKey).WillReturnRows(rows)
+		after := uuid.New()
+		mock.ExpectQuery("SELECT id, payload").WithArgs(Fingerprint(publicKey), after, 10).WillReturnRows(rows)
 
 		s := ServerStorage{db: db}
 		ctx := context.Background()
-		res, err := s.GetAll(ctx, publicKey)
+		res, err := s.GetAll(ctx, publicKey, after, 10)
 
 		assert.NoError(t, err)
 		assert.Len(t, res, len(data))
@@ -111,11 +119,11 @@ func TestServerStorage_GetAll(t *testing.T) {
 		require.NoError(t, err)
 		defer func() { _ = db.Close() }()
 
-		mock.ExpectQuery("SELECT id, payload").WithArgs(sqlmock.AnyArg()).WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery("SELECT id, payload").WithArgs(sqlmock.AnyArg(), uuid.Nil, 10).WillReturnError(sql.ErrNoRows)
 
 		s := ServerStorage{db: db}
 		ctx := context.Background()
-		res, err := s.GetAll(ctx, nil)
+		res, err := s.GetAll(ctx, nil, uuid.Nil, 10)
 
 		assert.NoError(t, err)
 		assert.Len(t, res, 0)
@@ -126,11 +134,11 @@ func TestServerStorage_GetAll(t *testing.T) {
 		require.NoError(t, err)
 		defer func() { _ = db.Close() }()
 
-		mock.ExpectQuery("SELECT id, payload").WithArgs(sqlmock.AnyArg()).WillReturnError(sql.ErrConnDone)
+		mock.ExpectQuery("SELECT id, payload").WithArgs(sqlmock.AnyArg(), uuid.Nil, 10).WillReturnError(sql.ErrConnDone)
 
 		s := ServerStorage{db: db}
 		ctx := context.Background()
-		_, err = s.GetAll(ctx, nil)
+		_, err = s.GetAll(ctx, nil, uuid.Nil, 10)
 
 		assert.Error(t, err)
 	})
@@ -142,18 +150,18 @@ func TestServerStorage_Create(t *testing.T) {
 		require.NoError(t, err)
 		defer func() { _ = db.Close() }()
 
-		e := entry{
+		e := Entry{
 			ID:        uuid.New(),
 			PublicKey: []byte{1, 2, 3, 4, 5},
 			Payload:   []byte{6, 7, 8, 9, 0},
 		}
 
 		rows := sqlmock.NewRows([]string{"id"}).AddRow(e.ID)
-		mock.ExpectQuery("INSERT").WithArgs(e.PublicKey, e.Payload).WillReturnRows(rows)
+		mock.ExpectQuery("INSERT").WithArgs(e.PublicKey, Fingerprint(e.PublicKey), e.Payload, nil).WillReturnRows(rows)
 
 		s := ServerStorage{db: db}
 		ctx := context.Background()
-		res, err := s.Create(ctx, e.PublicKey, e.Payload)
+		res, err := s.Create(ctx, e.PublicKey, e.Payload, time.Time{})
 
 		assert.NoError(t, err)
 		assert.Equal(t, e.ID, res)
@@ -165,32 +173,79 @@ func TestServerStorage_Create(t *testing.T) {
 		defer func() { _ = db.Close() }()
 
 		mock.ExpectQuery("INSERT").
-			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 			WillReturnError(sql.ErrConnDone)
 
 		s := ServerStorage{db: db}
 		ctx := context.Background()
-		_, err = s.Create(ctx, nil, nil)
+		_, err = s.Create(ctx, nil, nil, time.Time{})
 
 		assert.Error(t, err)
 	})
 }
 
 func TestServerStorage_Delete(t *testing.T) {
-	t.Run("ok", func(t *testing.T) {
+	tests := []struct {
+		name    string
+		applied any
+		current any
+		wantErr error
+	}{
+		{
+			name:    "ok",
+			applied: int64(2),
+			current: int64(2),
+		},
+		{
+			name:    "version conflict",
+			applied: nil,
+			current: int64(3),
+			wantErr: ErrVersionConflict,
+		},
+		{
+			name:    "not found",
+			applied: nil,
+			current: nil,
+			wantErr: ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = db.Close() }()
+
+			id := uuid.New()
+
+			rows := sqlmock.NewRows([]string{"deleted", "current"}).AddRow(tt.applied, tt.current)
+			mock.ExpectQuery("UPDATE entries SET deleted_at").WithArgs(id, int64(2)).WillReturnRows(rows)
+
+			s := ServerStorage{db: db}
+			ctx := context.Background()
+			err = s.Delete(ctx, id, 2)
+
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+
+	t.Run("conflict reports current version", func(t *testing.T) {
 		db, mock, err := sqlmock.New()
 		require.NoError(t, err)
 		defer func() { _ = db.Close() }()
 
-		id := uuid.New()
-
-		mock.ExpectExec("DELETE").WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 1))
+		rows := sqlmock.NewRows([]string{"deleted", "current"}).AddRow(nil, int64(7))
+		mock.ExpectQuery("UPDATE entries SET deleted_at").WillReturnRows(rows)
 
 		s := ServerStorage{db: db}
-		ctx := context.Background()
-		err = s.Delete(ctx, id)
+		err = s.Delete(context.Background(), uuid.New(), 2)
 
-		assert.NoError(t, err)
+		var conflict *VersionConflictError
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, int64(7), conflict.Current)
 	})
 
 	t.Run("fail", func(t *testing.T) {
@@ -198,11 +253,11 @@ func TestServerStorage_Delete(t *testing.T) {
 		require.NoError(t, err)
 		defer func() { _ = db.Close() }()
 
-		mock.ExpectExec("DELETE").WithArgs(sqlmock.AnyArg()).WillReturnError(sql.ErrConnDone)
+		mock.ExpectQuery("UPDATE entries SET deleted_at").WillReturnError(sql.ErrConnDone)
 
 		s := ServerStorage{db: db}
 		ctx := context.Background()
-		err = s.Delete(ctx, uuid.New())
+		err = s.Delete(ctx, uuid.New(), 1)
 
 		assert.Error(t, err)
 	})
@@ -216,14 +271,34 @@ func TestServerStorage_Update(t *testing.T) {
 
 		id := uuid.New()
 		payload := []byte{1, 3, 5, 7, 9}
+		expiresAt := time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC)
 
-		mock.ExpectExec("UPDATE").WithArgs(payload, id).WillReturnResult(sqlmock.NewResult(0, 1))
+		rows := sqlmock.NewRows([]string{"updated", "current"}).AddRow(int64(4), int64(3))
+		mock.ExpectQuery("INSERT INTO entry_history").WithArgs(payload, id, int64(3), 10, expiresAt).WillReturnRows(rows)
 
-		s := ServerStorage{db: db}
+		s := ServerStorage{db: db, historyLimit: 10}
 		ctx := context.Background()
-		err = s.Update(ctx, id, payload)
+		version, err := s.Update(ctx, id, payload, 3, expiresAt)
 
 		assert.NoError(t, err)
+		assert.Equal(t, int64(4), version)
+	})
+
+	t.Run("version conflict", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		rows := sqlmock.NewRows([]string{"updated", "current"}).AddRow(nil, int64(5))
+		mock.ExpectQuery("UPDATE entries").WillReturnRows(rows)
+
+		s := ServerStorage{db: db}
+		_, err = s.Update(context.Background(), uuid.New(), nil, 3, time.Time{})
+
+		var conflict *VersionConflictError
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, int64(5), conflict.Current)
+		assert.ErrorIs(t, err, ErrVersionConflict)
 	})
 
 	t.Run("fail", func(t *testing.T) {
@@ -231,18 +306,28 @@ func TestServerStorage_Update(t *testing.T) {
 		require.NoError(t, err)
 		defer func() { _ = db.Close() }()
 
-		mock.ExpectExec("UPDATE").
-			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
-			WillReturnError(sql.ErrConnDone)
+		mock.ExpectQuery("UPDATE entries").WillReturnError(sql.ErrConnDone)
 
 		s := ServerStorage{db: db}
 		ctx := context.Background()
-		err = s.Update(ctx, uuid.New(), nil)
+		_, err = s.Update(ctx, uuid.New(), nil, 1, time.Time{})
 
 		assert.Error(t, err)
 	})
 }
 
+func TestServerStorage_Stats(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		db, _, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		s := ServerStorage{db: db}
+
+		assert.Equal(t, db.Stats(), s.Stats())
+	})
+}
+
 func TestServerStorage_Close(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
 		db, mock, err := sqlmock.New()
@@ -272,3 +357,16 @@ func TestServerStorage_Close(t *testing.T) {
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 }
+
+func TestServerStorage_Reconfigure(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	s := ServerStorage{db: db}
+	assert.Equal(t, DefaultQueryTimeout, s.timeout())
+
+	s.Reconfigure(Config{QueryTimeout: 3 * time.Second, MaxOpenConns: 7})
+	assert.Equal(t, 3*time.Second, s.timeout())
+	assert.Equal(t, 7, s.Stats().MaxOpenConnections)
+}
@@ -0,0 +1,934 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/google/uuid"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	sqlitedriver "modernc.org/sqlite"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/tracing"
+)
+
+var _ Storage = (*SQLiteStorage)(nil)
+
+// sha256 нужна миграциям SQLite, чтобы заполнить owner_hash у существующих записей.
+func init() {
+	sqlitedriver.MustRegisterDeterministicScalarFunction("sha256", 1, sqliteSHA256)
+}
+
+// sqliteSHA256 - функция SQL sha256(blob), как в Postgres.
+func sqliteSHA256(_ *sqlitedriver.FunctionContext, args []driver.Value) (driver.Value, error) {
+	switch v := args[0].(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return Fingerprint(v), nil
+	case string:
+		return Fingerprint([]byte(v)), nil
+	default:
+		return nil, fmt.Errorf("sha256: unsupported argument type %T", v)
+	}
+}
+
+// SQLiteScheme - схема DSN встроенного хранилища SQLite: sqlite:///var/lib/gophkeeper/keeper.db
+// для абсолютного пути или sqlite://keeper.db для пути относительно рабочей директории.
+const SQLiteScheme = "sqlite://"
+
+// sqlitePragmas - параметры каждого соединения: каскадное удаление, ожидание блокировки вместо
+// ошибки SQLITE_BUSY и журнал WAL, в котором чтение не блокирует запись.
+const sqlitePragmas = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+
+// sqliteImmediateTx - параметр соединений для compare-and-swap: транзакция сразу берет блокировку записи
+// (BEGIN IMMEDIATE), иначе после чтения версии она не смогла бы перейти к записи, пока пишет другая.
+const sqliteImmediateTx = "&_txlock=immediate"
+
+// SQLiteStorage - хранилище сервера во встроенной базе SQLite для запуска без Postgres.
+// Семантика та же, что у ServerStorage: ревизии и надгробия ведут триггеры базы,
+// compare-and-swap выполняется в транзакции. События изменений рассылаются только
+// внутри процесса, поэтому файл базы обслуживает один экземпляр сервера.
+type SQLiteStorage struct {
+	db *sql.DB
+	// casDB - соединения с той же базой, транзакции в них начинаются с BEGIN IMMEDIATE.
+	casDB *sql.DB
+	dsn   string
+
+	historyLimit int
+	queryTimeout atomic.Int64
+
+	changes localChanges
+}
+
+// NewSQLiteStorage - открываем базу SQLite и применяем миграции.
+func NewSQLiteStorage(cfg Config) (*SQLiteStorage, error) {
+	s, err := OpenSQLiteStorage(cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("storage NewSQLiteStorage: %w", err)
+	}
+	s.historyLimit = cfg.HistoryLimit
+	s.Reconfigure(cfg)
+
+	err = s.MigrateUp(0)
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		_ = s.Close()
+		return nil, fmt.Errorf("storage NewSQLiteStorage: migrate error: %w", err)
+	}
+
+	return s, nil
+}
+
+// OpenSQLiteStorage - открыть базу SQLite без применения миграций.
+func OpenSQLiteStorage(dsn string) (*SQLiteStorage, error) {
+	s := &SQLiteStorage{
+		dsn: dsn,
+	}
+
+	var err error
+	s.db, err = sql.Open("sqlite", sqliteDSN(dsn))
+	if err != nil {
+		return nil, fmt.Errorf("storage OpenSQLiteStorage: sql open error: %w", err)
+	}
+	s.casDB, err = sql.Open("sqlite", sqliteDSN(dsn)+sqliteImmediateTx)
+	if err != nil {
+		_ = s.db.Close()
+		return nil, fmt.Errorf("storage OpenSQLiteStorage: sql open error: %w", err)
+	}
+
+	return s, nil
+}
+
+// sqliteDSN - имя файла базы из DSN со схемой sqlite:// и параметры соединения.
+func sqliteDSN(dsn string) string {
+	path := strings.TrimPrefix(dsn, SQLiteScheme)
+
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+
+	return path + sep + sqlitePragmas
+}
+
+// Reconfigure - применить время выполнения запросов и настройки пула соединений.
+func (s *SQLiteStorage) Reconfigure(cfg Config) {
+	s.queryTimeout.Store(int64(cfg.QueryTimeout))
+	configurePool(s.db, cfg)
+	configurePool(s.casDB, cfg)
+}
+
+// beginCAS - начать транзакцию compare-and-swap, которая читает версию и сразу пишет.
+// Остальные транзакции отложенные и не ждут блокировки записи, пока она им не нужна.
+func (s *SQLiteStorage) beginCAS(ctx context.Context) (*sql.Tx, error) {
+	return s.casDB.BeginTx(ctx, nil)
+}
+
+// timeout - время выполнения одного запроса к базе данных.
+func (s *SQLiteStorage) timeout() time.Duration {
+	if d := time.Duration(s.queryTimeout.Load()); d > 0 {
+		return d
+	}
+
+	return DefaultQueryTimeout
+}
+
+// sqliteTime - время в микросекундах unix, в таком виде оно хранится в базе.
+func sqliteTime(t time.Time) int64 {
+	return t.UnixMicro()
+}
+
+// fromSQLiteTime - время из микросекунд unix, для NULL нулевое время.
+func fromSQLiteTime(v sql.NullInt64) time.Time {
+	if !v.Valid {
+		return time.Time{}
+	}
+
+	return time.UnixMicro(v.Int64)
+}
+
+func startSQLiteSpan(ctx context.Context, name, operation string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name, semconv.DBSystemSqlite, semconv.DBOperation(operation))
+}
+
+// Get - получить запись по ID.
+func (s *SQLiteStorage) Get(ctx context.Context, id uuid.UUID) (e Entry, err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("get", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage Get", "SELECT")
+	defer span.End()
+
+	e = Entry{
+		ID: id,
+	}
+
+	var createdAt, updatedAt, expiresAt sql.NullInt64
+	row := s.db.QueryRowContext(ctx,
+		`SELECT public_key, payload, version, created_at, updated_at, expires_at FROM entries
+		WHERE id = ? AND deleted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)`,
+		id, sqliteTime(time.Now()),
+	)
+	err = row.Scan(&e.PublicKey, &e.Payload, &e.Version, &createdAt, &updatedAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Entry{}, fmt.Errorf("SQLiteStorage Get: query row: %w", ErrNotFound)
+	}
+	if err != nil {
+		return Entry{}, fmt.Errorf("SQLiteStorage Get: query row: %w", err)
+	}
+	e.CreatedAt = fromSQLiteTime(createdAt)
+	e.UpdatedAt = fromSQLiteTime(updatedAt)
+	e.ExpiresAt = fromSQLiteTime(expiresAt)
+
+	return e, nil
+}
+
+// GetAll - получить до limit записей по publicKey с ID больше after, отсортированных по ID.
+// ID хранятся строками в нижнем регистре, их порядок совпадает с порядком uuid в Postgres.
+func (s *SQLiteStorage) GetAll(ctx context.Context, publicKey []byte, after uuid.UUID, limit int) ([]Entry, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("get_all", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage GetAll", "SELECT")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, payload, revision, version, created_at, updated_at, expires_at FROM entries
+		WHERE owner_hash = ? AND id > ? AND deleted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY id LIMIT ?`,
+		Fingerprint(publicKey), after, sqliteTime(time.Now()), limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage GetAll: query: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	entries := make([]Entry, 0)
+	for rows.Next() {
+		e := Entry{PublicKey: publicKey}
+		var createdAt, updatedAt, expiresAt sql.NullInt64
+		err = rows.Scan(&e.ID, &e.Payload, &e.Revision, &e.Version, &createdAt, &updatedAt, &expiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("SQLiteStorage GetAll: query rows scan: %w", err)
+		}
+		e.CreatedAt = fromSQLiteTime(createdAt)
+		e.UpdatedAt = fromSQLiteTime(updatedAt)
+		e.ExpiresAt = fromSQLiteTime(expiresAt)
+		entries = append(entries, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("SQLiteStorage GetAll: query rows: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetChanges - получить записи владельца publicKey, измененные после ревизии since, и удаленные ID.
+// Все данные читаются в одной транзакции, поэтому курсор согласован с изменениями.
+// Если курсор since старше удаленных надгробий, возвращается полная синхронизация с признаком FullResync.
+func (s *SQLiteStorage) GetChanges(ctx context.Context, publicKey []byte, since int64) (cs ChangeSet, err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("get_changes", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage GetChanges", "SELECT")
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ChangeSet{}, fmt.Errorf("SQLiteStorage GetChanges: begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var pruned int64
+	row := tx.QueryRowContext(ctx,
+		`SELECT revision, pruned_revision FROM owner_revisions WHERE public_key = ?`,
+		publicKey,
+	)
+	err = row.Scan(&cs.Revision, &pruned)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ChangeSet{Entries: []Entry{}, Deleted: []uuid.UUID{}}, nil
+	}
+	if err != nil {
+		return ChangeSet{}, fmt.Errorf("SQLiteStorage GetChanges: query revision: %w", err)
+	}
+	if since > 0 && since < pruned {
+		cs.FullResync, since = true, 0
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, payload, revision, version, created_at, updated_at, expires_at FROM entries
+		WHERE owner_hash = ? AND revision > ? AND deleted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY revision`,
+		Fingerprint(publicKey), since, sqliteTime(time.Now()),
+	)
+	if err != nil {
+		return ChangeSet{}, fmt.Errorf("SQLiteStorage GetChanges: query entries: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	cs.Entries = make([]Entry, 0)
+	for rows.Next() {
+		e := Entry{PublicKey: publicKey}
+		var createdAt, updatedAt, expiresAt sql.NullInt64
+		err = rows.Scan(&e.ID, &e.Payload, &e.Revision, &e.Version, &createdAt, &updatedAt, &expiresAt)
+		if err != nil {
+			return ChangeSet{}, fmt.Errorf("SQLiteStorage GetChanges: query entries scan: %w", err)
+		}
+		e.CreatedAt = fromSQLiteTime(createdAt)
+		e.UpdatedAt = fromSQLiteTime(updatedAt)
+		e.ExpiresAt = fromSQLiteTime(expiresAt)
+		cs.Entries = append(cs.Entries, e)
+	}
+	if err = rows.Err(); err != nil {
+		return ChangeSet{}, fmt.Errorf("SQLiteStorage GetChanges: query entries rows: %w", err)
+	}
+
+	cs.Deleted = make([]uuid.UUID, 0)
+	if cs.FullResync {
+		return cs, nil
+	}
+
+	rows, err = tx.QueryContext(ctx,
+		`SELECT id FROM (
+			SELECT id, revision FROM tombstones WHERE public_key = ?1 AND revision > ?2
+			UNION ALL
+			SELECT id, revision FROM entries WHERE owner_hash = ?3 AND revision > ?2 AND deleted_at IS NOT NULL
+		) ORDER BY revision`,
+		publicKey, since, Fingerprint(publicKey),
+	)
+	if err != nil {
+		return ChangeSet{}, fmt.Errorf("SQLiteStorage GetChanges: query tombstones: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var id uuid.UUID
+		err = rows.Scan(&id)
+		if err != nil {
+			return ChangeSet{}, fmt.Errorf("SQLiteStorage GetChanges: query tombstones scan: %w", err)
+		}
+		cs.Deleted = append(cs.Deleted, id)
+	}
+	if err = rows.Err(); err != nil {
+		return ChangeSet{}, fmt.Errorf("SQLiteStorage GetChanges: query tombstones rows: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return ChangeSet{}, fmt.Errorf("SQLiteStorage GetChanges: commit: %w", err)
+	}
+
+	return cs, nil
+}
+
+// PurgeTombstones - удалить надгробия, созданные раньше before, и вернуть их количество.
+// Для владельцев запоминается наибольшая удаленная ревизия, чтобы клиенты
+// с более старым курсором получили полную синхронизацию.
+func (s *SQLiteStorage) PurgeTombstones(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("purge_tombstones", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage PurgeTombstones", "DELETE")
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("SQLiteStorage PurgeTombstones: begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE owner_revisions SET pruned_revision = max(pruned_revision, purged.revision)
+		FROM (
+			SELECT public_key, max(revision) AS revision FROM tombstones WHERE created_at < ? GROUP BY public_key
+		) purged
+		WHERE owner_revisions.public_key = purged.public_key`,
+		sqliteTime(before),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("SQLiteStorage PurgeTombstones: update revisions: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM tombstones WHERE created_at < ?`, sqliteTime(before))
+	if err != nil {
+		return 0, fmt.Errorf("SQLiteStorage PurgeTombstones: delete: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("SQLiteStorage PurgeTombstones: rows affected: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("SQLiteStorage PurgeTombstones: commit: %w", err)
+	}
+
+	return n, nil
+}
+
+// Create - добавить запись и вернуть ID. Нулевой expiresAt - бессрочная запись.
+func (s *SQLiteStorage) Create(ctx context.Context, publicKey []byte, data []byte,
+	expiresAt time.Time,
+) (uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("create", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage Create", "INSERT")
+	defer span.End()
+
+	id, err := createSQLiteEntry(ctx, s.db, publicKey, data, expiresAt)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("SQLiteStorage Create: %w", err)
+	}
+
+	return id, nil
+}
+
+// createSQLiteEntry - добавить запись в базе или транзакции q.
+func createSQLiteEntry(ctx context.Context, q querier, publicKey []byte, data []byte,
+	expiresAt time.Time,
+) (uuid.UUID, error) {
+	id := uuid.New()
+	now := sqliteTime(time.Now())
+	_, err := q.ExecContext(ctx,
+		`INSERT INTO entries (id, public_key, owner_hash, payload, created_at, updated_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		id, publicKey, Fingerprint(publicKey), data, now, now, sqliteNullTime(expiresAt),
+	)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("exec: %w", err)
+	}
+
+	return id, nil
+}
+
+// compareAndSwap - проверить в транзакции tx, что запись не в корзине и ее версия равна version.
+func compareAndSwap(ctx context.Context, tx *sql.Tx, id uuid.UUID, version int64) error {
+	var current int64
+	err := tx.QueryRowContext(ctx,
+		`SELECT version FROM entries WHERE id = ? AND deleted_at IS NULL`,
+		id,
+	).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("query version: %w", err)
+	}
+	if current != version {
+		return &VersionConflictError{Current: current}
+	}
+
+	return nil
+}
+
+// Delete - переместить запись в корзину, если ее версия равна version.
+// Если версия не совпала, возвращается *VersionConflictError.
+func (s *SQLiteStorage) Delete(ctx context.Context, id uuid.UUID, version int64) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("delete", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage Delete", "UPDATE")
+	defer span.End()
+
+	tx, err := s.beginCAS(ctx)
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage Delete: begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = deleteSQLiteEntry(ctx, tx, id, version)
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage Delete: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage Delete: commit: %w", err)
+	}
+
+	return nil
+}
+
+// deleteSQLiteEntry - переместить запись в корзину в транзакции tx, если ее версия равна version.
+func deleteSQLiteEntry(ctx context.Context, tx *sql.Tx, id uuid.UUID, version int64) error {
+	err := compareAndSwap(ctx, tx, id, version)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE entries SET deleted_at = ? WHERE id = ?`, sqliteTime(time.Now()), id)
+	if err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+
+	return nil
+}
+
+// Update - обновить запись по ID, если ее версия равна version, и вернуть новую версию.
+// Время истечения заменяется на expiresAt, нулевой expiresAt делает запись бессрочной.
+// Предыдущие данные сохраняются в историю, в которой остается не больше historyLimit версий.
+// Если версия не совпала, возвращается *VersionConflictError.
+func (s *SQLiteStorage) Update(ctx context.Context, id uuid.UUID, data []byte, version int64,
+	expiresAt time.Time,
+) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("update", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage Update", "UPDATE")
+	defer span.End()
+
+	tx, err := s.beginCAS(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("SQLiteStorage Update: begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	newVersion, err := s.updateSQLiteEntry(ctx, tx, id, data, version, expiresAt)
+	if err != nil {
+		return 0, fmt.Errorf("SQLiteStorage Update: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("SQLiteStorage Update: commit: %w", err)
+	}
+
+	return newVersion, nil
+}
+
+// updateSQLiteEntry - обновить запись в транзакции tx, если ее версия равна version, и вернуть новую версию.
+func (s *SQLiteStorage) updateSQLiteEntry(ctx context.Context, tx *sql.Tx, id uuid.UUID, data []byte, version int64,
+	expiresAt time.Time,
+) (int64, error) {
+	err := compareAndSwap(ctx, tx, id, version)
+	if err != nil {
+		return 0, err
+	}
+
+	if s.historyLimit > 0 {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO entry_history (entry_id, version, payload, updated_at)
+			SELECT id, version, payload, updated_at FROM entries WHERE id = ?`,
+			id,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("save history: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE entries SET payload = ?, version = version + 1, updated_at = ?, expires_at = ? WHERE id = ?`,
+		data, sqliteTime(time.Now()), sqliteNullTime(expiresAt), id,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("exec: %w", err)
+	}
+
+	newVersion := version + 1
+	_, err = tx.ExecContext(ctx,
+		`DELETE FROM entry_history WHERE entry_id = ? AND version < ?`,
+		id, newVersion-int64(s.historyLimit),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("prune history: %w", err)
+	}
+
+	return newVersion, nil
+}
+
+// History - получить предыдущие версии записи, новые первыми.
+func (s *SQLiteStorage) History(ctx context.Context, id uuid.UUID) ([]Entry, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("history", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage History", "SELECT")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT version, payload, updated_at FROM entry_history WHERE entry_id = ? ORDER BY version DESC`,
+		id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage History: query: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	entries := make([]Entry, 0)
+	for rows.Next() {
+		e := Entry{ID: id}
+		var updatedAt sql.NullInt64
+		err = rows.Scan(&e.Version, &e.Payload, &updatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("SQLiteStorage History: query rows scan: %w", err)
+		}
+		e.UpdatedAt = fromSQLiteTime(updatedAt)
+		entries = append(entries, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("SQLiteStorage History: query rows: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetVersion - получить предыдущую версию записи из истории.
+func (s *SQLiteStorage) GetVersion(ctx context.Context, id uuid.UUID, version int64) (e Entry, err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("get_version", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage GetVersion", "SELECT")
+	defer span.End()
+
+	e = Entry{
+		ID:      id,
+		Version: version,
+	}
+
+	var updatedAt sql.NullInt64
+	row := s.db.QueryRowContext(ctx,
+		`SELECT payload, updated_at FROM entry_history WHERE entry_id = ? AND version = ?`,
+		id, version,
+	)
+	err = row.Scan(&e.Payload, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Entry{}, fmt.Errorf("SQLiteStorage GetVersion: query row: %w", ErrVersionNotFound)
+	}
+	if err != nil {
+		return Entry{}, fmt.Errorf("SQLiteStorage GetVersion: query row: %w", err)
+	}
+	e.UpdatedAt = fromSQLiteTime(updatedAt)
+
+	return e, nil
+}
+
+// BlobKeys - получить ключи объектов, на которые ссылаются записи, в том числе в корзине и истории,
+// части файлов и незавершенные загрузки.
+// Ключи берутся из индексированного столбца blob_key, который база вычисляет из payload.
+func (s *SQLiteStorage) BlobKeys(ctx context.Context) ([]uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("blob_keys", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage BlobKeys", "SELECT")
+	defer span.End()
+
+	keys, err := blobKeys(ctx, s.db,
+		`SELECT blob_key FROM entries WHERE blob_key IS NOT NULL
+		UNION
+		SELECT blob_key FROM entry_history WHERE blob_key IS NOT NULL
+		UNION
+		SELECT blob_key FROM chunks WHERE blob_key IS NOT NULL
+		UNION
+		SELECT blob_key FROM uploads WHERE blob_key IS NOT NULL`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage BlobKeys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// ListTrash - получить записи владельца publicKey, перемещенные в корзину.
+func (s *SQLiteStorage) ListTrash(ctx context.Context, publicKey []byte) ([]Entry, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("list_trash", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage ListTrash", "SELECT")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, payload, version, created_at, updated_at, deleted_at FROM entries
+		WHERE owner_hash = ? AND deleted_at IS NOT NULL ORDER BY deleted_at DESC`,
+		Fingerprint(publicKey),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage ListTrash: query: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	entries := make([]Entry, 0)
+	for rows.Next() {
+		e := Entry{PublicKey: publicKey}
+		var createdAt, updatedAt, deletedAt sql.NullInt64
+		err = rows.Scan(&e.ID, &e.Payload, &e.Version, &createdAt, &updatedAt, &deletedAt)
+		if err != nil {
+			return nil, fmt.Errorf("SQLiteStorage ListTrash: query rows scan: %w", err)
+		}
+		e.CreatedAt = fromSQLiteTime(createdAt)
+		e.UpdatedAt = fromSQLiteTime(updatedAt)
+		e.DeletedAt = fromSQLiteTime(deletedAt)
+		entries = append(entries, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("SQLiteStorage ListTrash: query rows: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetTrashed - получить запись из корзины по ID.
+func (s *SQLiteStorage) GetTrashed(ctx context.Context, id uuid.UUID) (e Entry, err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("get_trashed", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage GetTrashed", "SELECT")
+	defer span.End()
+
+	e = Entry{
+		ID: id,
+	}
+
+	var createdAt, updatedAt, deletedAt sql.NullInt64
+	row := s.db.QueryRowContext(ctx,
+		`SELECT public_key, payload, version, created_at, updated_at, deleted_at FROM entries
+		WHERE id = ? AND deleted_at IS NOT NULL`,
+		id,
+	)
+	err = row.Scan(&e.PublicKey, &e.Payload, &e.Version, &createdAt, &updatedAt, &deletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Entry{}, fmt.Errorf("SQLiteStorage GetTrashed: query row: %w", ErrNotFound)
+	}
+	if err != nil {
+		return Entry{}, fmt.Errorf("SQLiteStorage GetTrashed: query row: %w", err)
+	}
+	e.CreatedAt = fromSQLiteTime(createdAt)
+	e.UpdatedAt = fromSQLiteTime(updatedAt)
+	e.DeletedAt = fromSQLiteTime(deletedAt)
+
+	return e, nil
+}
+
+// Restore - восстановить запись из корзины.
+func (s *SQLiteStorage) Restore(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("restore", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage Restore", "UPDATE")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE entries SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage Restore: exec: %w", err)
+	}
+
+	return checkAffected("SQLiteStorage Restore", res)
+}
+
+// Purge - окончательно удалить запись из корзины.
+func (s *SQLiteStorage) Purge(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("purge", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage Purge", "DELETE")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM entries WHERE id = ? AND deleted_at IS NOT NULL`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage Purge: exec: %w", err)
+	}
+
+	return checkAffected("SQLiteStorage Purge", res)
+}
+
+// PurgeTrash - окончательно удалить записи, перемещенные в корзину раньше before.
+func (s *SQLiteStorage) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("purge_trash", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage PurgeTrash", "DELETE")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM entries WHERE deleted_at IS NOT NULL AND deleted_at < ?`,
+		sqliteTime(before),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("SQLiteStorage PurgeTrash: exec: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("SQLiteStorage PurgeTrash: rows affected: %w", err)
+	}
+
+	return n, nil
+}
+
+// PurgeExpired - окончательно удалить записи, истекшие раньше before, в том числе из корзины,
+// и вернуть их количество. Вместо записей остаются надгробия, как после Purge.
+func (s *SQLiteStorage) PurgeExpired(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("purge_expired", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage PurgeExpired", "DELETE")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM entries WHERE expires_at IS NOT NULL AND expires_at <= ?`,
+		sqliteTime(before),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("SQLiteStorage PurgeExpired: exec: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("SQLiteStorage PurgeExpired: rows affected: %w", err)
+	}
+
+	return n, nil
+}
+
+// Notify - разослать событие изменения записи слушателям этого процесса.
+func (s *SQLiteStorage) Notify(_ context.Context, c Change) error {
+	s.changes.notify(c)
+	return nil
+}
+
+// Listen - передавать события изменения записей в handle. Блокируется до отмены ctx.
+func (s *SQLiteStorage) Listen(ctx context.Context, handle func(Change)) error {
+	s.changes.listen(ctx, handle)
+	return nil
+}
+
+// Stats - статистика пула соединений с базой данных.
+func (s *SQLiteStorage) Stats() sql.DBStats {
+	return s.db.Stats()
+}
+
+// Close - закрываем базу данных.
+func (s *SQLiteStorage) Close() error {
+	err := errors.Join(s.db.Close(), s.casDB.Close())
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage Close: %w", err)
+	}
+
+	return nil
+}
+
+// MigrateUp - применить n следующих миграций, все оставшиеся если n равно 0.
+// Возвращает migrate.ErrNoChange, если применять нечего.
+func (s *SQLiteStorage) MigrateUp(n int) error {
+	m, err := s.newMigrate()
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage MigrateUp: %w", err)
+	}
+	defer func() { _, _ = m.Close() }()
+
+	if n == 0 {
+		err = m.Up()
+	} else {
+		err = m.Steps(n)
+	}
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage MigrateUp: %w", err)
+	}
+
+	return nil
+}
+
+// MigrateDown - откатить n последних миграций.
+func (s *SQLiteStorage) MigrateDown(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("SQLiteStorage MigrateDown: number of migrations must be positive, got %d", n)
+	}
+
+	m, err := s.newMigrate()
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage MigrateDown: %w", err)
+	}
+	defer func() { _, _ = m.Close() }()
+
+	err = m.Steps(-n)
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage MigrateDown: %w", err)
+	}
+
+	return nil
+}
+
+// SchemaVersion - версия схемы в базе и признак незавершенной миграции.
+// Для пустой базы возвращается версия 0.
+func (s *SQLiteStorage) SchemaVersion() (version uint, dirty bool, err error) {
+	m, err := s.newMigrate()
+	if err != nil {
+		return 0, false, fmt.Errorf("SQLiteStorage SchemaVersion: %w", err)
+	}
+	defer func() { _, _ = m.Close() }()
+
+	version, dirty, err = m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("SQLiteStorage SchemaVersion: %w", err)
+	}
+
+	return version, dirty, nil
+}
+
+// LatestSchemaVersion - версия последней миграции SQLite, встроенной в сервер.
+func (s *SQLiteStorage) LatestSchemaVersion() (uint, error) {
+	return latestSchemaVersion("migrations/sqlite")
+}
+
+// newMigrate - миграции из migrations/sqlite. Для них открывается отдельное соединение,
+// которое закрывается вместе с migrate.Migrate.
+func (s *SQLiteStorage) newMigrate() (*migrate.Migrate, error) {
+	d, err := iofs.New(migrationsFS, "migrations/sqlite")
+	if err != nil {
+		return nil, fmt.Errorf("iofs: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", sqliteDSN(s.dsn))
+	if err != nil {
+		return nil, fmt.Errorf("sql open: %w", err)
+	}
+
+	driver, err := sqlite.WithInstance(db, &sqlite.Config{})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("migrate driver: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", d, "sqlite", driver)
+	if err != nil {
+		_ = driver.Close()
+		return nil, fmt.Errorf("new migrate: %w", err)
+	}
+
+	return m, nil
+}
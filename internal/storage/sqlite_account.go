@@ -0,0 +1,486 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// AddAudit - добавить запись в журнал аудита.
+func (s *SQLiteStorage) AddAudit(ctx context.Context, r AuditRecord) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("add_audit", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage AddAudit", "INSERT")
+	defer span.End()
+
+	var entryID *uuid.UUID
+	if r.EntryID != uuid.Nil {
+		entryID = &r.EntryID
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO audit_log (public_key, op, entry_id, peer, client_version, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		r.PublicKey, string(r.Op), entryID, r.Peer, r.ClientVersion, sqliteTime(time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage AddAudit: exec: %w", err)
+	}
+
+	return nil
+}
+
+// ListAudit - получить до limit записей журнала аудита владельца publicKey с ID меньше before,
+// новые первыми. Для первой страницы before равен 0.
+func (s *SQLiteStorage) ListAudit(ctx context.Context, publicKey []byte, before int64, limit int) ([]AuditRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("list_audit", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage ListAudit", "SELECT")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, op, entry_id, peer, client_version, created_at FROM audit_log
+		WHERE public_key = ?1 AND (?2 = 0 OR id < ?2) ORDER BY id DESC LIMIT ?3`,
+		publicKey, before, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage ListAudit: query: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	records := make([]AuditRecord, 0)
+	for rows.Next() {
+		r := AuditRecord{PublicKey: publicKey}
+		var op string
+		var entryID uuid.NullUUID
+		var createdAt sql.NullInt64
+		err = rows.Scan(&r.ID, &op, &entryID, &r.Peer, &r.ClientVersion, &createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("SQLiteStorage ListAudit: query rows scan: %w", err)
+		}
+		r.Op = AuditOp(op)
+		r.EntryID = entryID.UUID
+		r.CreatedAt = fromSQLiteTime(createdAt)
+		records = append(records, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("SQLiteStorage ListAudit: query rows: %w", err)
+	}
+
+	return records, nil
+}
+
+// SaveChallenge - сохранить вызов для подтверждения удаления аккаунта.
+// У владельца может быть только один вызов, новый заменяет предыдущий.
+func (s *SQLiteStorage) SaveChallenge(ctx context.Context, publicKey, challenge []byte, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("save_challenge", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage SaveChallenge", "INSERT")
+	defer span.End()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO account_challenges (public_key, challenge, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (public_key) DO UPDATE SET challenge = excluded.challenge, expires_at = excluded.expires_at`,
+		publicKey, challenge, sqliteTime(expiresAt),
+	)
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage SaveChallenge: exec: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAccount - использовать вызов и в одной транзакции удалить все данные владельца:
+// записи вместе с частями файлов и историей версий, незавершенные загрузки, ключи идемпотентности,
+// надгробия, ревизию, журнал аудита и устройства.
+// Возвращает ID удаленных записей.
+func (s *SQLiteStorage) DeleteAccount(ctx context.Context, publicKey, challenge []byte) ([]uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("delete_account", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage DeleteAccount", "DELETE")
+	defer span.End()
+
+	tx, err := s.beginCAS(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage DeleteAccount: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`DELETE FROM account_challenges WHERE public_key = ? AND challenge = ? AND expires_at > ?`,
+		publicKey, challenge, sqliteTime(time.Now()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage DeleteAccount: delete challenge: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage DeleteAccount: delete challenge rows affected: %w", err)
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("SQLiteStorage DeleteAccount: %w", ErrChallengeNotFound)
+	}
+
+	ownerHash := Fingerprint(publicKey)
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM entries WHERE owner_hash = ?`, ownerHash)
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage DeleteAccount: query entries: %w", err)
+	}
+	ids := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		err = rows.Scan(&id)
+		if err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("SQLiteStorage DeleteAccount: query entries scan: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		_ = rows.Close()
+		return nil, fmt.Errorf("SQLiteStorage DeleteAccount: query entries rows: %w", err)
+	}
+	_ = rows.Close()
+
+	// части файлов и история версий удаляются каскадно,
+	// надгробия удаляем после записей: триггер создает их при удалении
+	_, err = tx.ExecContext(ctx, `DELETE FROM entries WHERE owner_hash = ?`, ownerHash)
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage DeleteAccount: delete entries: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE owner_hash = ?`, ownerHash)
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage DeleteAccount: delete idempotency keys: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM uploads WHERE owner_hash = ?`, ownerHash)
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage DeleteAccount: delete uploads: %w", err)
+	}
+	for _, q := range []string{
+		`DELETE FROM tombstones WHERE public_key = ?`,
+		`DELETE FROM owner_revisions WHERE public_key = ?`,
+		`DELETE FROM audit_log WHERE public_key = ?`,
+		`DELETE FROM devices WHERE public_key = ?`,
+	} {
+		_, err = tx.ExecContext(ctx, q, publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("SQLiteStorage DeleteAccount: exec %q: %w", q, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage DeleteAccount: commit: %w", err)
+	}
+
+	return ids, nil
+}
+
+// DeleteExpiredChallenges - удалить вызовы удаления аккаунта, истекшие раньше before.
+func (s *SQLiteStorage) DeleteExpiredChallenges(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("delete_expired_challenges", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage DeleteExpiredChallenges", "DELETE")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM account_challenges WHERE expires_at < ?`,
+		sqliteTime(before),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("SQLiteStorage DeleteExpiredChallenges: exec: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("SQLiteStorage DeleteExpiredChallenges: rows affected: %w", err)
+	}
+
+	return n, nil
+}
+
+// RegisterDevice - зарегистрировать устройство владельца и вернуть его ID.
+// Хранится только хеш токена сессии устройства.
+func (s *SQLiteStorage) RegisterDevice(ctx context.Context, publicKey []byte, name string,
+	tokenHash []byte, ip string,
+) (uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("register_device", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage RegisterDevice", "INSERT")
+	defer span.End()
+
+	id := uuid.New()
+	now := sqliteTime(time.Now())
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO devices (id, public_key, name, token_hash, created_at, last_seen_at, last_ip)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		id, publicKey, name, tokenHash, now, now, ip,
+	)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("SQLiteStorage RegisterDevice: exec: %w", err)
+	}
+
+	return id, nil
+}
+
+// ListDevices - получить устройства владельца, недавно активные первыми.
+func (s *SQLiteStorage) ListDevices(ctx context.Context, publicKey []byte) ([]Device, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("list_devices", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage ListDevices", "SELECT")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, name, created_at, last_seen_at, last_ip, revoked_at FROM devices
+		WHERE public_key = ? ORDER BY last_seen_at DESC`,
+		publicKey,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage ListDevices: query: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	devices := make([]Device, 0)
+	for rows.Next() {
+		d := Device{}
+		var createdAt, lastSeenAt, revokedAt sql.NullInt64
+		err = rows.Scan(&d.ID, &d.Name, &createdAt, &lastSeenAt, &d.LastIP, &revokedAt)
+		if err != nil {
+			return nil, fmt.Errorf("SQLiteStorage ListDevices: query rows scan: %w", err)
+		}
+		d.CreatedAt = fromSQLiteTime(createdAt)
+		d.LastSeenAt = fromSQLiteTime(lastSeenAt)
+		d.RevokedAt = fromSQLiteTime(revokedAt)
+		devices = append(devices, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("SQLiteStorage ListDevices: query rows: %w", err)
+	}
+
+	return devices, nil
+}
+
+// RevokeDevice - отозвать устройство владельца.
+func (s *SQLiteStorage) RevokeDevice(ctx context.Context, publicKey []byte, id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("revoke_device", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage RevokeDevice", "UPDATE")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE devices SET revoked_at = ? WHERE id = ? AND public_key = ? AND revoked_at IS NULL`,
+		sqliteTime(time.Now()), id, publicKey,
+	)
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage RevokeDevice: exec: %w", err)
+	}
+
+	return checkAffected("SQLiteStorage RevokeDevice", res)
+}
+
+// DeviceOwner - проверить токен сессии устройства и вернуть публичный ключ его владельца.
+// Для отозванного устройства или неверного токена возвращается ErrDeviceRevoked.
+func (s *SQLiteStorage) DeviceOwner(ctx context.Context, id uuid.UUID, tokenHash []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("device_owner", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage DeviceOwner", "SELECT")
+	defer span.End()
+
+	row := s.db.QueryRowContext(ctx,
+		`SELECT public_key FROM devices WHERE id = ? AND token_hash = ? AND revoked_at IS NULL`,
+		id, tokenHash,
+	)
+
+	var publicKey []byte
+	err := row.Scan(&publicKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("SQLiteStorage DeviceOwner: %w", ErrDeviceRevoked)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage DeviceOwner: query row scan: %w", err)
+	}
+
+	return publicKey, nil
+}
+
+// HasDevices - есть ли у владельца действующие устройства.
+func (s *SQLiteStorage) HasDevices(ctx context.Context, publicKey []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("has_devices", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage HasDevices", "SELECT")
+	defer span.End()
+
+	row := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM devices WHERE public_key = ? AND revoked_at IS NULL)`,
+		publicKey,
+	)
+
+	var exists bool
+	err := row.Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("SQLiteStorage HasDevices: query row scan: %w", err)
+	}
+
+	return exists, nil
+}
+
+// TouchDevice - обновить время и адрес последнего запроса устройства, если предыдущий запрос
+// был раньше before.
+func (s *SQLiteStorage) TouchDevice(ctx context.Context, id uuid.UUID, ip string, before time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("touch_device", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage TouchDevice", "UPDATE")
+	defer span.End()
+
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE devices SET last_seen_at = ?, last_ip = ? WHERE id = ? AND last_seen_at < ?`,
+		sqliteTime(time.Now()), ip, id, sqliteTime(before),
+	)
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage TouchDevice: exec: %w", err)
+	}
+
+	return nil
+}
+
+// DisableOwner - отключить владельца по отпечатку. Повторное отключение не считается ошибкой.
+func (s *SQLiteStorage) DisableOwner(ctx context.Context, fingerprint []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("disable_owner", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage DisableOwner", "INSERT")
+	defer span.End()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO disabled_owners (fingerprint, disabled_at) VALUES (?, ?) ON CONFLICT (fingerprint) DO NOTHING`,
+		fingerprint, sqliteTime(time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage DisableOwner: exec: %w", err)
+	}
+
+	return nil
+}
+
+// EnableOwner - снова включить владельца по отпечатку.
+// Если владелец не был отключен, возвращается ErrNotFound.
+func (s *SQLiteStorage) EnableOwner(ctx context.Context, fingerprint []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("enable_owner", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage EnableOwner", "DELETE")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM disabled_owners WHERE fingerprint = ?`,
+		fingerprint,
+	)
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage EnableOwner: exec: %w", err)
+	}
+
+	return checkAffected("SQLiteStorage EnableOwner", res)
+}
+
+// IsOwnerDisabled - отключен ли владелец публичного ключа.
+func (s *SQLiteStorage) IsOwnerDisabled(ctx context.Context, publicKey []byte) (disabled bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("is_owner_disabled", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage IsOwnerDisabled", "SELECT")
+	defer span.End()
+
+	row := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM disabled_owners WHERE fingerprint = ?)`,
+		Fingerprint(publicKey),
+	)
+	err = row.Scan(&disabled)
+	if err != nil {
+		return false, fmt.Errorf("SQLiteStorage IsOwnerDisabled: query row: %w", err)
+	}
+
+	return disabled, nil
+}
+
+// GetReadOnlyMode - получить режим обслуживания.
+func (s *SQLiteStorage) GetReadOnlyMode(ctx context.Context) (ReadOnlyMode, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("get_read_only_mode", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage GetReadOnlyMode", "SELECT")
+	defer span.End()
+
+	var seconds int64
+	var since sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `SELECT retry_after_seconds, since FROM read_only_mode`).Scan(&seconds, &since)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ReadOnlyMode{}, nil
+	}
+	if err != nil {
+		return ReadOnlyMode{}, fmt.Errorf("SQLiteStorage GetReadOnlyMode: query row: %w", err)
+	}
+
+	return ReadOnlyMode{
+		Enabled:    true,
+		RetryAfter: time.Duration(seconds) * time.Second,
+		Since:      fromSQLiteTime(since),
+	}, nil
+}
+
+// SetReadOnlyMode - включить режим обслуживания с подсказкой retryAfter или выключить его.
+// Повторное включение обновляет подсказку, но не время включения.
+func (s *SQLiteStorage) SetReadOnlyMode(ctx context.Context, enabled bool, retryAfter time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("set_read_only_mode", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage SetReadOnlyMode", "UPDATE")
+	defer span.End()
+
+	var err error
+	if enabled {
+		_, err = s.db.ExecContext(ctx,
+			`INSERT INTO read_only_mode (id, retry_after_seconds, since) VALUES (1, ?, ?)
+			ON CONFLICT (id) DO UPDATE SET retry_after_seconds = excluded.retry_after_seconds`,
+			int64(retryAfter/time.Second), sqliteTime(time.Now()),
+		)
+	} else {
+		_, err = s.db.ExecContext(ctx, `DELETE FROM read_only_mode`)
+	}
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage SetReadOnlyMode: exec: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// OwnerStats - статистика по всем владельцам, у которых есть записи, по убыванию занятого места.
+// Bytes учитывает зашифрованные данные записей, части файлов и историю версий.
+func (s *SQLiteStorage) OwnerStats(ctx context.Context) ([]OwnerStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	defer metrics.ObserveQuery("owner_stats", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage OwnerStats", "SELECT")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx,
+		`WITH owners AS (
+			SELECT public_key,
+				count(*) FILTER (WHERE deleted_at IS NULL) AS entries,
+				count(*) FILTER (WHERE deleted_at IS NOT NULL) AS trashed,
+				sum(length(payload)) AS bytes,
+				max(updated_at) AS last_update_at
+			FROM entries GROUP BY public_key
+		),
+		chunk_bytes AS (
+			SELECT e.public_key, sum(length(c.data)) AS bytes
+			FROM chunks c JOIN entries e ON e.id = c.entry_id GROUP BY e.public_key
+		),
+		history AS (
+			SELECT e.public_key, count(*) AS versions, sum(length(h.payload)) AS bytes
+			FROM entry_history h JOIN entries e ON e.id = h.entry_id GROUP BY e.public_key
+		),
+		active_devices AS (
+			SELECT public_key, count(*) AS devices FROM devices WHERE revoked_at IS NULL GROUP BY public_key
+		)
+		SELECT sha256(o.public_key), o.entries, o.trashed,
+			o.bytes + coalesce(c.bytes, 0) + coalesce(h.bytes, 0),
+			coalesce(h.versions, 0), coalesce(d.devices, 0), o.last_update_at,
+			EXISTS (SELECT 1 FROM disabled_owners x WHERE x.fingerprint = sha256(o.public_key))
+		FROM owners o
+		LEFT JOIN chunk_bytes c ON c.public_key = o.public_key
+		LEFT JOIN history h ON h.public_key = o.public_key
+		LEFT JOIN active_devices d ON d.public_key = o.public_key
+		ORDER BY 4 DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage OwnerStats: query: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	stats := make([]OwnerStats, 0)
+	for rows.Next() {
+		o := OwnerStats{}
+		var lastUpdateAt sql.NullInt64
+		err = rows.Scan(&o.Fingerprint, &o.Entries, &o.Trashed, &o.Bytes,
+			&o.Versions, &o.Devices, &lastUpdateAt, &o.Disabled)
+		if err != nil {
+			return nil, fmt.Errorf("SQLiteStorage OwnerStats: query rows scan: %w", err)
+		}
+		o.LastUpdateAt = fromSQLiteTime(lastUpdateAt)
+		stats = append(stats, o)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("SQLiteStorage OwnerStats: query rows: %w", err)
+	}
+
+	return stats, nil
+}
+
+// CheckSchema - проверить целостность схемы и вернуть список найденных проблем:
+// версию миграций, наличие таблиц, целостность файла базы и согласованность ревизий записей и владельцев.
+// Пустой список означает, что проблем нет.
+func (s *SQLiteStorage) CheckSchema(ctx context.Context) ([]string, error) {
+	problems := make([]string, 0)
+
+	latest, err := s.LatestSchemaVersion()
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage CheckSchema: %w", err)
+	}
+
+	version, dirty, err := s.SchemaVersion()
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage CheckSchema: %w", err)
+	}
+	if dirty {
+		problems = append(problems, fmt.Sprintf("migration %d is dirty, fix the schema and force the version", version))
+	}
+	if version != latest {
+		problems = append(problems, fmt.Sprintf("schema version %d, want %d", version, latest))
+	}
+
+	tableProblems, err := s.checkTables(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage CheckSchema: %w", err)
+	}
+
+	return append(problems, tableProblems...), nil
+}
+
+func (s *SQLiteStorage) checkTables(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	defer metrics.ObserveQuery("check_schema", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage CheckSchema", "SELECT")
+	defer span.End()
+
+	problems := make([]string, 0)
+
+	var integrity string
+	err := s.db.QueryRowContext(ctx, `PRAGMA quick_check`).Scan(&integrity)
+	if err != nil {
+		return nil, fmt.Errorf("quick check: query row: %w", err)
+	}
+	if integrity != "ok" {
+		problems = append(problems, "database file is corrupted: "+integrity)
+	}
+
+	for _, table := range schemaTables {
+		var exists bool
+		err = s.db.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)`, table,
+		).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("table %s: query row: %w", table, err)
+		}
+		if !exists {
+			problems = append(problems, fmt.Sprintf("table %s is missing", table))
+		}
+	}
+	if len(problems) > 0 {
+		// без таблиц проверки данных ниже завершатся ошибкой
+		return problems, nil
+	}
+
+	var orphans int64
+	err = s.db.QueryRowContext(ctx, `SELECT count(*) FROM pragma_foreign_key_check`).Scan(&orphans)
+	if err != nil {
+		return nil, fmt.Errorf("foreign key check: query row: %w", err)
+	}
+	if orphans > 0 {
+		problems = append(problems, fmt.Sprintf("%d rows reference missing rows", orphans))
+	}
+
+	for _, check := range schemaChecks {
+		var n sql.NullInt64
+		err = s.db.QueryRowContext(ctx, check.query).Scan(&n)
+		if err != nil {
+			return nil, fmt.Errorf("check %q: query row: %w", check.problem, err)
+		}
+		if n.Int64 > 0 {
+			problems = append(problems, fmt.Sprintf(check.problem, n.Int64))
+		}
+	}
+
+	return problems, nil
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage_OwnerStats(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewSQLiteStorage(Config{DSN: SQLiteScheme + filepath.Join(t.TempDir(), "keeper.db"), HistoryLimit: 10})
+	require.NoError(t, err)
+	defer func() { _ = s.Close() }()
+
+	owner := []byte{1, 2, 3}
+	id, err := s.Create(ctx, owner, []byte("data"), time.Time{})
+	require.NoError(t, err)
+	_, err = s.Update(ctx, id, []byte("new data"), 1, time.Time{})
+	require.NoError(t, err)
+	trashed, err := s.Create(ctx, owner, []byte("old"), time.Time{})
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(ctx, trashed, 1))
+	_, err = s.RegisterDevice(ctx, owner, "laptop", []byte("token"), "127.0.0.1")
+	require.NoError(t, err)
+	require.NoError(t, s.DisableOwner(ctx, Fingerprint(owner)))
+
+	stats, err := s.OwnerStats(ctx)
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.Equal(t, Fingerprint(owner), stats[0].Fingerprint)
+	assert.Equal(t, int64(1), stats[0].Entries)
+	assert.Equal(t, int64(1), stats[0].Trashed)
+	assert.Equal(t, int64(1), stats[0].Versions)
+	assert.Equal(t, int64(1), stats[0].Devices)
+	assert.Equal(t, int64(len("new data")+len("old")+len("data")), stats[0].Bytes)
+	assert.WithinDuration(t, time.Now(), stats[0].LastUpdateAt, time.Minute)
+	assert.True(t, stats[0].Disabled)
+}
+
+func TestSQLiteStorage_CheckSchema(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewSQLiteStorage(Config{DSN: SQLiteScheme + filepath.Join(t.TempDir(), "keeper.db")})
+	require.NoError(t, err)
+	defer func() { _ = s.Close() }()
+
+	_, err = s.Create(ctx, []byte{1, 2, 3}, []byte("data"), time.Time{})
+	require.NoError(t, err)
+
+	problems, err := s.CheckSchema(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+
+	_, err = s.db.ExecContext(ctx, `DELETE FROM owner_revisions`)
+	require.NoError(t, err)
+	problems, err = s.CheckSchema(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"entries of 1 owners have no owner revision"}, problems)
+
+	_, err = s.db.ExecContext(ctx, `DROP TABLE devices`)
+	require.NoError(t, err)
+	problems, err = s.CheckSchema(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"table devices is missing"}, problems)
+}
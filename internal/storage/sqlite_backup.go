@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// sqliteNullTime - время в микросекундах unix, нулевое время хранится как NULL.
+func sqliteNullTime(t time.Time) sql.NullInt64 {
+	return sql.NullInt64{Int64: sqliteTime(t), Valid: !t.IsZero()}
+}
+
+// Export - передать в fn все данные хранилища, как ServerStorage Export.
+// Транзакция только для чтения не блокирует запись: в режиме WAL она видит один снимок базы.
+func (s *SQLiteStorage) Export(ctx context.Context, fn func(BackupRecord) error) error {
+	defer metrics.ObserveQuery("export", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage Export", "SELECT")
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage Export: begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, q := range []struct {
+		name  string
+		query string
+		scan  func(rows *sql.Rows) (BackupRecord, error)
+	}{
+		{
+			name: "entries",
+			query: `SELECT id, public_key, payload, manifest, revision, version, created_at, updated_at, deleted_at,
+			expires_at FROM entries ORDER BY id`,
+			scan: func(rows *sql.Rows) (BackupRecord, error) {
+				e := &BackupEntry{}
+				var createdAt, updatedAt, deletedAt, expiresAt sql.NullInt64
+				err := rows.Scan(&e.ID, &e.PublicKey, &e.Payload, &e.Manifest, &e.Revision, &e.Version,
+					&createdAt, &updatedAt, &deletedAt, &expiresAt)
+				e.CreatedAt = fromSQLiteTime(createdAt)
+				e.UpdatedAt = fromSQLiteTime(updatedAt)
+				e.DeletedAt = fromSQLiteTime(deletedAt)
+				e.ExpiresAt = fromSQLiteTime(expiresAt)
+				return BackupRecord{Entry: e}, err
+			},
+		},
+		{
+			name:  "chunks",
+			query: `SELECT entry_id, idx, data FROM chunks ORDER BY entry_id, idx`,
+			scan: func(rows *sql.Rows) (BackupRecord, error) {
+				c := &BackupChunk{}
+				err := rows.Scan(&c.EntryID, &c.Index, &c.Data)
+				return BackupRecord{Chunk: c}, err
+			},
+		},
+		{
+			name:  "history",
+			query: `SELECT entry_id, version, payload, updated_at FROM entry_history ORDER BY entry_id, version`,
+			scan: func(rows *sql.Rows) (BackupRecord, error) {
+				v := &BackupVersion{}
+				var updatedAt sql.NullInt64
+				err := rows.Scan(&v.EntryID, &v.Version, &v.Payload, &updatedAt)
+				v.UpdatedAt = fromSQLiteTime(updatedAt)
+				return BackupRecord{Version: v}, err
+			},
+		},
+		{
+			name:  "revisions",
+			query: `SELECT public_key, revision, pruned_revision FROM owner_revisions ORDER BY public_key`,
+			scan: func(rows *sql.Rows) (BackupRecord, error) {
+				r := &BackupRevision{}
+				err := rows.Scan(&r.PublicKey, &r.Revision, &r.PrunedRevision)
+				return BackupRecord{Revision: r}, err
+			},
+		},
+		{
+			name:  "tombstones",
+			query: `SELECT id, public_key, revision, created_at FROM tombstones ORDER BY id`,
+			scan: func(rows *sql.Rows) (BackupRecord, error) {
+				t := &BackupTombstone{}
+				var createdAt sql.NullInt64
+				err := rows.Scan(&t.ID, &t.PublicKey, &t.Revision, &createdAt)
+				t.CreatedAt = fromSQLiteTime(createdAt)
+				return BackupRecord{Tombstone: t}, err
+			},
+		},
+		{
+			name:  "audit",
+			query: `SELECT id, public_key, op, entry_id, peer, client_version, created_at FROM audit_log ORDER BY id`,
+			scan: func(rows *sql.Rows) (BackupRecord, error) {
+				a := &BackupAudit{}
+				var op string
+				var entryID uuid.NullUUID
+				var createdAt sql.NullInt64
+				err := rows.Scan(&a.ID, &a.PublicKey, &op, &entryID, &a.Peer, &a.ClientVersion, &createdAt)
+				a.Op = AuditOp(op)
+				a.EntryID = entryID.UUID
+				a.CreatedAt = fromSQLiteTime(createdAt)
+				return BackupRecord{Audit: a}, err
+			},
+		},
+		{
+			name: "devices",
+			query: `SELECT id, public_key, name, token_hash, created_at, last_seen_at, last_ip, revoked_at
+			FROM devices ORDER BY id`,
+			scan: func(rows *sql.Rows) (BackupRecord, error) {
+				d := &BackupDevice{}
+				var createdAt, lastSeenAt, revokedAt sql.NullInt64
+				err := rows.Scan(&d.ID, &d.PublicKey, &d.Name, &d.TokenHash, &createdAt, &lastSeenAt,
+					&d.LastIP, &revokedAt)
+				d.CreatedAt = fromSQLiteTime(createdAt)
+				d.LastSeenAt = fromSQLiteTime(lastSeenAt)
+				d.RevokedAt = fromSQLiteTime(revokedAt)
+				return BackupRecord{Device: d}, err
+			},
+		},
+		{
+			name:  "disabled owners",
+			query: `SELECT fingerprint, disabled_at FROM disabled_owners ORDER BY fingerprint`,
+			scan: func(rows *sql.Rows) (BackupRecord, error) {
+				o := &BackupDisabledOwner{}
+				var disabledAt sql.NullInt64
+				err := rows.Scan(&o.Fingerprint, &disabledAt)
+				o.DisabledAt = fromSQLiteTime(disabledAt)
+				return BackupRecord{DisabledOwner: o}, err
+			},
+		},
+		{
+			name: "idempotency keys",
+			query: `SELECT owner_hash, key, entry_id, request_hash, created_at
+			FROM idempotency_keys ORDER BY owner_hash, key`,
+			scan: func(rows *sql.Rows) (BackupRecord, error) {
+				k := &BackupIdempotencyKey{}
+				var createdAt sql.NullInt64
+				err := rows.Scan(&k.OwnerHash, &k.Key, &k.EntryID, &k.RequestHash, &createdAt)
+				k.CreatedAt = fromSQLiteTime(createdAt)
+				return BackupRecord{IdempotencyKey: k}, err
+			},
+		},
+	} {
+		err = exportQuery(ctx, tx, q.query, q.scan, fn)
+		if err != nil {
+			return fmt.Errorf("SQLiteStorage Export: %s: %w", q.name, err)
+		}
+	}
+
+	return nil
+}
+
+// Import - загрузить в пустое хранилище записи из next в одной транзакции, как ServerStorage Import.
+func (s *SQLiteStorage) Import(ctx context.Context, next func() (BackupRecord, error)) error {
+	defer metrics.ObserveQuery("import", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage Import", "INSERT")
+	defer span.End()
+
+	tx, err := s.beginCAS(ctx)
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage Import: begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var exists bool
+	row := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM entries) OR EXISTS (SELECT 1 FROM owner_revisions)
+		OR EXISTS (SELECT 1 FROM tombstones) OR EXISTS (SELECT 1 FROM audit_log)
+		OR EXISTS (SELECT 1 FROM devices) OR EXISTS (SELECT 1 FROM disabled_owners)
+		OR EXISTS (SELECT 1 FROM idempotency_keys)`)
+	err = row.Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage Import: check empty: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("SQLiteStorage Import: %w", ErrNotEmpty)
+	}
+
+	for {
+		r, nextErr := next()
+		if errors.Is(nextErr, io.EOF) {
+			break
+		}
+		if nextErr != nil {
+			return fmt.Errorf("SQLiteStorage Import: %w", nextErr)
+		}
+
+		err = s.importRecord(ctx, tx, r)
+		if err != nil {
+			return fmt.Errorf("SQLiteStorage Import: %s: %w", r.Kind(), err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage Import: commit: %w", err)
+	}
+
+	return nil
+}
+
+// importRecord - вставить одну запись резервной копии.
+func (s *SQLiteStorage) importRecord(ctx context.Context, tx *sql.Tx, r BackupRecord) (err error) {
+	switch {
+	case r.Entry != nil:
+		e := r.Entry
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO entries (id, public_key, owner_hash, payload, manifest, version, created_at, updated_at, deleted_at,
+			expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			e.ID, e.PublicKey, Fingerprint(e.PublicKey), e.Payload, e.Manifest, e.Version,
+			sqliteTime(e.CreatedAt), sqliteTime(e.UpdatedAt), sqliteNullTime(e.DeletedAt), sqliteNullTime(e.ExpiresAt),
+		)
+		if err != nil {
+			return err
+		}
+		// триггер выдал записи новую ревизию, возвращаем сохраненную:
+		// изменение только revision триггер не вызывает
+		_, err = tx.ExecContext(ctx, `UPDATE entries SET revision = ? WHERE id = ?`, e.Revision, e.ID)
+	case r.Chunk != nil:
+		_, err = tx.ExecContext(ctx, `INSERT INTO chunks (entry_id, idx, data) VALUES (?, ?, ?)`,
+			r.Chunk.EntryID, r.Chunk.Index, r.Chunk.Data,
+		)
+	case r.Version != nil:
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO entry_history (entry_id, version, payload, updated_at) VALUES (?, ?, ?, ?)`,
+			r.Version.EntryID, r.Version.Version, r.Version.Payload, sqliteTime(r.Version.UpdatedAt),
+		)
+	case r.Revision != nil:
+		// ревизии владельцев с записями уже создал триггер при вставке записей
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO owner_revisions (public_key, revision, pruned_revision) VALUES (?1, ?2, ?3)
+			ON CONFLICT (public_key) DO UPDATE SET revision = ?2, pruned_revision = ?3`,
+			r.Revision.PublicKey, r.Revision.Revision, r.Revision.PrunedRevision,
+		)
+	case r.Tombstone != nil:
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO tombstones (id, public_key, revision, created_at) VALUES (?, ?, ?, ?)`,
+			r.Tombstone.ID, r.Tombstone.PublicKey, r.Tombstone.Revision, sqliteTime(r.Tombstone.created()),
+		)
+	case r.Audit != nil:
+		// AUTOINCREMENT продолжит нумерацию после наибольшего восстановленного ID
+		a := r.Audit
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO audit_log (id, public_key, op, entry_id, peer, client_version, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			a.ID, a.PublicKey, string(a.Op), nullUUID(a.EntryID), a.Peer, a.ClientVersion, sqliteTime(a.CreatedAt),
+		)
+	case r.Device != nil:
+		d := r.Device
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO devices (id, public_key, name, token_hash, created_at, last_seen_at, last_ip, revoked_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			d.ID, d.PublicKey, d.Name, d.TokenHash, sqliteTime(d.CreatedAt), sqliteTime(d.LastSeenAt), d.LastIP,
+			sqliteNullTime(d.RevokedAt),
+		)
+	case r.DisabledOwner != nil:
+		// MemoryStorage не хранит время отключения
+		disabledAt := r.DisabledOwner.DisabledAt
+		if disabledAt.IsZero() {
+			disabledAt = time.Now()
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO disabled_owners (fingerprint, disabled_at) VALUES (?, ?)`,
+			r.DisabledOwner.Fingerprint, sqliteTime(disabledAt),
+		)
+	case r.IdempotencyKey != nil:
+		k := r.IdempotencyKey
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO idempotency_keys (owner_hash, key, entry_id, request_hash, created_at) VALUES (?, ?, ?, ?, ?)`,
+			k.OwnerHash, k.Key, k.EntryID, k.RequestHash, sqliteTime(k.CreatedAt),
+		)
+	default:
+		err = errors.New("empty record")
+	}
+
+	return err
+}
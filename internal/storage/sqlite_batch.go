@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// Batch - выполнить операции в одной транзакции, как ServerStorage Batch.
+func (s *SQLiteStorage) Batch(ctx context.Context, ops []BatchOp) ([]BatchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("batch", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage Batch", "BATCH")
+	defer span.End()
+
+	tx, err := s.beginCAS(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage Batch: begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	results := make([]BatchResult, 0, len(ops))
+	for i, op := range ops {
+		res := BatchResult{ID: op.ID}
+		switch op.Kind {
+		case BatchCreate:
+			res.ID, err = createSQLiteEntry(ctx, tx, op.PublicKey, op.Data, op.ExpiresAt)
+			res.Version = 1
+		case BatchUpdate:
+			res.Version, err = s.updateSQLiteEntry(ctx, tx, op.ID, op.Data, op.Version, op.ExpiresAt)
+		case BatchDelete:
+			err = deleteSQLiteEntry(ctx, tx, op.ID, op.Version)
+			res.Version = op.Version
+		default:
+			err = fmt.Errorf("unknown operation kind %d", op.Kind)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("SQLiteStorage Batch: %w", &BatchError{Index: i, Err: err})
+		}
+		results = append(results, res)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage Batch: commit: %w", err)
+	}
+
+	return results, nil
+}
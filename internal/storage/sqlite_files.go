@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// SaveUploadChunk - сохранить часть загружаемого файла. Повторная отправка части перезаписывает ее.
+func (s *SQLiteStorage) SaveUploadChunk(ctx context.Context, uploadID uuid.UUID,
+	publicKey []byte, idx uint32, hash, data []byte,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("save_upload_chunk", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage SaveUploadChunk", "INSERT")
+	defer span.End()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO uploads (owner_hash, upload_id, idx, hash, data, created_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (owner_hash, upload_id, idx) DO UPDATE SET hash = excluded.hash, data = excluded.data`,
+		Fingerprint(publicKey), uploadID, idx, hash, data, sqliteTime(time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("SQLiteStorage SaveUploadChunk: exec: %w", err)
+	}
+
+	return nil
+}
+
+// UploadChunks - получить хеши уже полученных частей загрузки владельца publicKey, отсортированные по индексу.
+func (s *SQLiteStorage) UploadChunks(ctx context.Context, uploadID uuid.UUID, publicKey []byte) ([]UploadChunk, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("upload_chunks", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage UploadChunks", "SELECT")
+	defer span.End()
+
+	chunks, err := uploadChunks(ctx, s.db,
+		`SELECT idx, hash FROM uploads WHERE owner_hash = ? AND upload_id = ? ORDER BY idx`,
+		Fingerprint(publicKey), uploadID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage UploadChunks: %w", err)
+	}
+
+	return chunks, nil
+}
+
+// CommitUpload - создать запись из загрузки, как ServerStorage CommitUpload.
+func (s *SQLiteStorage) CommitUpload(ctx context.Context, uploadID uuid.UUID,
+	publicKey, data, manifest []byte, chunkHashes [][]byte,
+) (uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("commit_upload", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage CommitUpload", "INSERT")
+	defer span.End()
+
+	tx, err := s.beginCAS(ctx)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("SQLiteStorage CommitUpload: begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	ownerHash := Fingerprint(publicKey)
+	chunks, err := uploadChunks(ctx, tx,
+		`SELECT idx, hash FROM uploads WHERE owner_hash = ? AND upload_id = ? ORDER BY idx`,
+		ownerHash, uploadID,
+	)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("SQLiteStorage CommitUpload: upload chunks: %w", err)
+	}
+	err = checkUploadChunks(chunks, chunkHashes)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("SQLiteStorage CommitUpload: %w", err)
+	}
+
+	id := uuid.New()
+	now := sqliteTime(time.Now())
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO entries (id, public_key, owner_hash, payload, manifest, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		id, publicKey, ownerHash, data, manifest, now, now,
+	)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("SQLiteStorage CommitUpload: insert entry: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO chunks (entry_id, idx, data)
+		SELECT ?, idx, data FROM uploads WHERE owner_hash = ? AND upload_id = ? AND idx < ?`,
+		id, ownerHash, uploadID, len(chunkHashes),
+	)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("SQLiteStorage CommitUpload: move chunks: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM uploads WHERE owner_hash = ? AND upload_id = ?`, ownerHash, uploadID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("SQLiteStorage CommitUpload: delete upload: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("SQLiteStorage CommitUpload: commit: %w", err)
+	}
+
+	return id, nil
+}
+
+// DeleteStaleUploads - удалить незавершенные загрузки, начатые раньше before.
+func (s *SQLiteStorage) DeleteStaleUploads(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("delete_stale_uploads", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage DeleteStaleUploads", "DELETE")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM uploads WHERE (owner_hash, upload_id) IN (
+			SELECT owner_hash, upload_id FROM uploads GROUP BY owner_hash, upload_id HAVING max(created_at) < ?
+		)`,
+		sqliteTime(before),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("SQLiteStorage DeleteStaleUploads: exec: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("SQLiteStorage DeleteStaleUploads: rows affected: %w", err)
+	}
+
+	return n, nil
+}
+
+// GetManifest - получить манифест файла и публичный ключ владельца по ID записи.
+func (s *SQLiteStorage) GetManifest(ctx context.Context, id uuid.UUID) (manifest, publicKey []byte, err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("get_manifest", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage GetManifest", "SELECT")
+	defer span.End()
+
+	row := s.db.QueryRowContext(ctx,
+		`SELECT manifest, public_key FROM entries
+		WHERE id = ? AND deleted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)`,
+		id, sqliteTime(time.Now()),
+	)
+	err = row.Scan(&manifest, &publicKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, fmt.Errorf("SQLiteStorage GetManifest: query row: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("SQLiteStorage GetManifest: query row: %w", err)
+	}
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("SQLiteStorage GetManifest: %w", ErrNotFile)
+	}
+
+	return manifest, publicKey, nil
+}
+
+// GetChunk - получить часть файла по ID записи и индексу.
+func (s *SQLiteStorage) GetChunk(ctx context.Context, id uuid.UUID, idx uint32) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("get_chunk", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage GetChunk", "SELECT")
+	defer span.End()
+
+	var data []byte
+	row := s.db.QueryRowContext(ctx, `SELECT data FROM chunks WHERE entry_id = ? AND idx = ?`, id, idx)
+	err := row.Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("SQLiteStorage GetChunk: query row: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("SQLiteStorage GetChunk: query row: %w", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// CreateIdempotent - добавить запись и запомнить для нее ключ k, как ServerStorage CreateIdempotent.
+// Транзакции SQLite берут блокировку записи сразу, поэтому запросы с одним ключом выполняются по очереди.
+func (s *SQLiteStorage) CreateIdempotent(ctx context.Context, k IdempotencyKey, publicKey, data []byte,
+	expiresAt time.Time,
+) (id uuid.UUID, created bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("create_idempotent", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage CreateIdempotent", "INSERT")
+	defer span.End()
+
+	tx, err := s.beginCAS(ctx)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("SQLiteStorage CreateIdempotent: begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	ownerHash := Fingerprint(publicKey)
+	id, err = savedIdempotencyKey(tx.QueryRowContext(ctx,
+		`SELECT entry_id, request_hash FROM idempotency_keys WHERE owner_hash = ? AND key = ? AND created_at >= ?`,
+		ownerHash, k.Key, sqliteTime(k.Since),
+	), k)
+	if err == nil {
+		return id, false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, false, fmt.Errorf("SQLiteStorage CreateIdempotent: %w", err)
+	}
+
+	id = uuid.New()
+	now := sqliteTime(time.Now())
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO idempotency_keys (owner_hash, key, entry_id, request_hash, created_at) VALUES (?1, ?2, ?3, ?4, ?5)
+		ON CONFLICT (owner_hash, key) DO UPDATE SET entry_id = ?3, request_hash = ?4, created_at = ?5`,
+		ownerHash, k.Key, id, k.RequestHash, now,
+	)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("SQLiteStorage CreateIdempotent: save key: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO entries (id, public_key, owner_hash, payload, created_at, updated_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		id, publicKey, ownerHash, data, now, now, sqliteNullTime(expiresAt),
+	)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("SQLiteStorage CreateIdempotent: insert entry: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("SQLiteStorage CreateIdempotent: commit: %w", err)
+	}
+
+	return id, true, nil
+}
+
+// DeleteExpiredIdempotencyKeys - удалить ключи идемпотентности, сохраненные раньше before.
+func (s *SQLiteStorage) DeleteExpiredIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("delete_expired_idempotency_keys", time.Now())
+
+	ctx, span := startSQLiteSpan(ctx, "SQLiteStorage DeleteExpiredIdempotencyKeys", "DELETE")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < ?`, sqliteTime(before))
+	if err != nil {
+		return 0, fmt.Errorf("SQLiteStorage DeleteExpiredIdempotencyKeys: exec: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("SQLiteStorage DeleteExpiredIdempotencyKeys: rows affected: %w", err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteDSN(t *testing.T) {
+	assert.Equal(t, "/var/lib/keeper.db?"+sqlitePragmas, sqliteDSN("sqlite:///var/lib/keeper.db"))
+	assert.Equal(t, "keeper.db?"+sqlitePragmas, sqliteDSN("sqlite://keeper.db"))
+	assert.Equal(t, "keeper.db?mode=rwc&"+sqlitePragmas, sqliteDSN("sqlite://keeper.db?mode=rwc"))
+}
+
+func TestSQLiteStorage_Transactions(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewSQLiteStorage(Config{DSN: SQLiteScheme + filepath.Join(t.TempDir(), "keeper.db")})
+	require.NoError(t, err)
+	defer func() { _ = s.Close() }()
+
+	owner := []byte{1, 2, 3}
+	id, err := s.Create(ctx, owner, []byte("data"), time.Time{})
+	require.NoError(t, err)
+
+	// compare-and-swap сразу держит блокировку записи
+	cas, err := s.beginCAS(ctx)
+	require.NoError(t, err)
+	defer func() { _ = cas.Rollback() }()
+
+	// отложенная транзакция чтения ее не ждет
+	changes, err := s.GetChanges(ctx, owner, 0)
+	require.NoError(t, err)
+	assert.Len(t, changes.Entries, 1)
+
+	require.NoError(t, cas.Rollback())
+	_, err = s.Update(ctx, id, []byte("new"), 1, time.Time{})
+	require.NoError(t, err)
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	t.Run("same versions as postgres", func(t *testing.T) {
+		server, err := fs.Glob(migrationsFS, "migrations/server/*.sql")
+		require.NoError(t, err)
+		sqlite, err := fs.Glob(migrationsFS, "migrations/sqlite/*.sql")
+		require.NoError(t, err)
+
+		names := func(paths []string) []string {
+			res := make([]string, 0, len(paths))
+			for _, p := range paths {
+				res = append(res, filepath.Base(p))
+			}
+			return res
+		}
+		assert.Equal(t, names(server), names(sqlite))
+	})
+
+	t.Run("down and up", func(t *testing.T) {
+		dsn := SQLiteScheme + filepath.Join(t.TempDir(), "keeper.db")
+		s, err := NewSQLiteStorage(Config{DSN: dsn})
+		require.NoError(t, err)
+		defer func() { _ = s.Close() }()
+
+		latest, err := LatestSchemaVersion()
+		require.NoError(t, err)
+
+		version, dirty, err := s.SchemaVersion()
+		require.NoError(t, err)
+		assert.Equal(t, latest, version)
+		assert.False(t, dirty)
+
+		_, err = s.Create(context.Background(), []byte{1, 2, 3}, []byte("data"), time.Time{})
+		require.NoError(t, err)
+
+		require.NoError(t, s.MigrateDown(int(latest)))
+		version, _, err = s.SchemaVersion()
+		require.NoError(t, err)
+		assert.Zero(t, version)
+
+		require.NoError(t, s.MigrateUp(0))
+		version, _, err = s.SchemaVersion()
+		require.NoError(t, err)
+		assert.Equal(t, latest, version)
+	})
+
+	t.Run("owner hash backfill", func(t *testing.T) {
+		dsn := SQLiteScheme + filepath.Join(t.TempDir(), "keeper.db")
+		s, err := NewSQLiteStorage(Config{DSN: dsn})
+		require.NoError(t, err)
+		defer func() { _ = s.Close() }()
+
+		ctx := context.Background()
+		// откатываемся до миграции owner_hash
+		const ownerHashVersion = 13
+		latest, err := LatestSchemaVersion()
+		require.NoError(t, err)
+		require.NoError(t, s.MigrateDown(int(latest)-ownerHashVersion+1))
+
+		owner := []byte{1, 2, 3}
+		id := uuid.New()
+		_, err = s.db.ExecContext(ctx,
+			`INSERT INTO entries (id, public_key, payload, created_at, updated_at) VALUES (?, ?, ?, 1, 1)`,
+			id, owner, []byte("data"),
+		)
+		require.NoError(t, err)
+
+		require.NoError(t, s.MigrateUp(0))
+
+		var ownerHash []byte
+		require.NoError(t, s.db.QueryRowContext(ctx, `SELECT owner_hash FROM entries WHERE id = ?`, id).Scan(&ownerHash))
+		assert.Equal(t, Fingerprint(owner), ownerHash)
+
+		entries, err := s.GetAll(ctx, owner, uuid.Nil, 10)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, id, entries[0].ID)
+	})
+
+	t.Run("audit log is append-only", func(t *testing.T) {
+		dsn := SQLiteScheme + filepath.Join(t.TempDir(), "keeper.db")
+		s, err := NewSQLiteStorage(Config{DSN: dsn})
+		require.NoError(t, err)
+		defer func() { _ = s.Close() }()
+
+		ctx := context.Background()
+		require.NoError(t, s.AddAudit(ctx, AuditRecord{PublicKey: []byte{1}, Op: AuditRead}))
+
+		_, err = s.db.ExecContext(ctx, `UPDATE audit_log SET op = 'create'`)
+		assert.ErrorContains(t, err, "append-only")
+	})
+}
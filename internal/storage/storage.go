@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Storage - хранилище сервера, от которого зависит grpc сервер GophKeeper.
+// Реализации: ServerStorage (postgres), SQLiteStorage и MemoryStorage. Семантика методов описана
+// у ServerStorage и проверяется общими контрактными тестами для всех реализаций.
+type Storage interface {
+	// записи
+	Get(ctx context.Context, id uuid.UUID) (Entry, error)
+	GetAll(ctx context.Context, publicKey []byte, after uuid.UUID, limit int) ([]Entry, error)
+	GetChanges(ctx context.Context, publicKey []byte, since int64) (ChangeSet, error)
+	PurgeTombstones(ctx context.Context, before time.Time) (int64, error)
+	Create(ctx context.Context, publicKey []byte, data []byte, expiresAt time.Time) (uuid.UUID, error)
+	CreateIdempotent(ctx context.Context, k IdempotencyKey, publicKey, data []byte,
+		expiresAt time.Time) (id uuid.UUID, created bool, err error)
+	Delete(ctx context.Context, id uuid.UUID, version int64) error
+	Update(ctx context.Context, id uuid.UUID, data []byte, version int64, expiresAt time.Time) (int64, error)
+	Batch(ctx context.Context, ops []BatchOp) ([]BatchResult, error)
+	PurgeExpired(ctx context.Context, before time.Time) (int64, error)
+	DeleteExpiredIdempotencyKeys(ctx context.Context, before time.Time) (int64, error)
+
+	// история версий
+	History(ctx context.Context, id uuid.UUID) ([]Entry, error)
+	GetVersion(ctx context.Context, id uuid.UUID, version int64) (Entry, error)
+
+	// ссылки на вынесенные данные
+	BlobKeys(ctx context.Context) ([]uuid.UUID, error)
+
+	// корзина
+	ListTrash(ctx context.Context, publicKey []byte) ([]Entry, error)
+	GetTrashed(ctx context.Context, id uuid.UUID) (Entry, error)
+	Restore(ctx context.Context, id uuid.UUID) error
+	Purge(ctx context.Context, id uuid.UUID) error
+	PurgeTrash(ctx context.Context, before time.Time) (int64, error)
+
+	// файлы
+	SaveUploadChunk(ctx context.Context, uploadID uuid.UUID, publicKey []byte, idx uint32, hash, data []byte) error
+	UploadChunks(ctx context.Context, uploadID uuid.UUID, publicKey []byte) ([]UploadChunk, error)
+	CommitUpload(ctx context.Context, uploadID uuid.UUID,
+		publicKey, data, manifest []byte, chunkHashes [][]byte) (uuid.UUID, error)
+	DeleteStaleUploads(ctx context.Context, before time.Time) (int64, error)
+	GetManifest(ctx context.Context, id uuid.UUID) (manifest, publicKey []byte, err error)
+	GetChunk(ctx context.Context, id uuid.UUID, idx uint32) ([]byte, error)
+
+	// события изменений
+	Notify(ctx context.Context, c Change) error
+	Listen(ctx context.Context, handle func(Change)) error
+
+	// журнал аудита
+	AddAudit(ctx context.Context, r AuditRecord) error
+	ListAudit(ctx context.Context, publicKey []byte, before int64, limit int) ([]AuditRecord, error)
+
+	// аккаунт
+	SaveChallenge(ctx context.Context, publicKey, challenge []byte, expiresAt time.Time) error
+	DeleteAccount(ctx context.Context, publicKey, challenge []byte) ([]uuid.UUID, error)
+	DeleteExpiredChallenges(ctx context.Context, before time.Time) (int64, error)
+
+	// устройства
+	RegisterDevice(ctx context.Context, publicKey []byte, name string, tokenHash []byte, ip string) (uuid.UUID, error)
+	ListDevices(ctx context.Context, publicKey []byte) ([]Device, error)
+	RevokeDevice(ctx context.Context, publicKey []byte, id uuid.UUID) error
+	DeviceOwner(ctx context.Context, id uuid.UUID, tokenHash []byte) ([]byte, error)
+	HasDevices(ctx context.Context, publicKey []byte) (bool, error)
+	TouchDevice(ctx context.Context, id uuid.UUID, ip string, before time.Time) error
+
+	// отключенные владельцы
+	DisableOwner(ctx context.Context, fingerprint []byte) error
+	EnableOwner(ctx context.Context, fingerprint []byte) error
+	IsOwnerDisabled(ctx context.Context, publicKey []byte) (bool, error)
+
+	// режим обслуживания
+	GetReadOnlyMode(ctx context.Context) (ReadOnlyMode, error)
+	SetReadOnlyMode(ctx context.Context, enabled bool, retryAfter time.Duration) error
+
+	// резервная копия
+	Export(ctx context.Context, fn func(BackupRecord) error) error
+	Import(ctx context.Context, next func() (BackupRecord, error)) error
+
+	Close() error
+}
+
+var _ Storage = (*ServerStorage)(nil)
+
+// MemoryDSN - DSN хранилища в памяти: данные теряются при остановке сервера,
+// подходит для тестов и локальной разработки.
+const MemoryDSN = "memory://"
+
+// Open - открыть хранилище по схеме DSN: memory:// - MemoryStorage, sqlite:// - SQLiteStorage,
+// иначе ServerStorage (postgres). Миграции применяются при открытии.
+func Open(cfg Config) (Storage, error) {
+	switch {
+	case strings.HasPrefix(cfg.DSN, MemoryDSN):
+		return NewMemoryStorage(cfg.HistoryLimit), nil
+	case strings.HasPrefix(cfg.DSN, SQLiteScheme):
+		return NewSQLiteStorage(cfg)
+	default:
+		return NewServerStorage(cfg)
+	}
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// ChangeSet - изменения записей владельца после некоторой ревизии.
+type ChangeSet struct {
+	// Entries - созданные или измененные записи.
+	Entries []Entry
+	// Deleted - ID удаленных записей, в том числе перемещенных в корзину.
+	Deleted []uuid.UUID
+	// Revision - текущая ревизия владельца, курсор для следующего запроса.
+	Revision int64
+	// FullResync - надгробия после ревизии since уже удалены: Entries содержит все записи владельца,
+	// Deleted пуст, и клиент должен заменить ими свою копию целиком.
+	FullResync bool
+}
+
+// GetChanges - получить записи владельца publicKey, измененные после ревизии since, и удаленные ID.
+// Все данные читаются из одного снимка базы, поэтому курсор согласован с изменениями.
+// Истекшие записи не возвращаются, их ID попадут в удаленные после фоновой очистки.
+// Если курсор since старше удаленных надгробий, возвращается полная синхронизация с признаком FullResync.
+func (s *ServerStorage) GetChanges(ctx context.Context, publicKey []byte, since int64) (cs ChangeSet, err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("get_changes", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage GetChanges", "SELECT")
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return ChangeSet{}, fmt.Errorf("ServerStorage GetChanges: begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var pruned int64
+	row := tx.QueryRowContext(ctx,
+		`SELECT revision, pruned_revision FROM owner_revisions WHERE public_key = $1`,
+		publicKey,
+	)
+	err = row.Scan(&cs.Revision, &pruned)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ChangeSet{Entries: []Entry{}, Deleted: []uuid.UUID{}}, nil
+	}
+	if err != nil {
+		return ChangeSet{}, fmt.Errorf("ServerStorage GetChanges: query revision: %w", err)
+	}
+	if since > 0 && since < pruned {
+		cs.FullResync, since = true, 0
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, payload, revision, version, created_at, updated_at, expires_at FROM entries
+		WHERE owner_hash = $1 AND revision > $2 AND deleted_at IS NULL AND (expires_at IS NULL OR expires_at > now())
+		ORDER BY revision`,
+		Fingerprint(publicKey), since,
+	)
+	if err != nil {
+		return ChangeSet{}, fmt.Errorf("ServerStorage GetChanges: query entries: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	cs.Entries = make([]Entry, 0)
+	for rows.Next() {
+		e := Entry{PublicKey: publicKey}
+		var expiresAt sql.NullTime
+		err = rows.Scan(&e.ID, &e.Payload, &e.Revision, &e.Version, &e.CreatedAt, &e.UpdatedAt, &expiresAt)
+		if err != nil {
+			return ChangeSet{}, fmt.Errorf("ServerStorage GetChanges: query entries scan: %w", err)
+		}
+		e.ExpiresAt = expiresAt.Time
+		cs.Entries = append(cs.Entries, e)
+	}
+	if err = rows.Err(); err != nil {
+		return ChangeSet{}, fmt.Errorf("ServerStorage GetChanges: query entries rows: %w", err)
+	}
+
+	cs.Deleted = make([]uuid.UUID, 0)
+	if cs.FullResync {
+		return cs, nil
+	}
+
+	rows, err = tx.QueryContext(ctx,
+		`SELECT id FROM (
+			SELECT id, revision FROM tombstones WHERE public_key = $1 AND revision > $2
+			UNION ALL
+			SELECT id, revision FROM entries WHERE owner_hash = $3 AND revision > $2 AND deleted_at IS NOT NULL
+		) deleted ORDER BY revision`,
+		publicKey, since, Fingerprint(publicKey),
+	)
+	if err != nil {
+		return ChangeSet{}, fmt.Errorf("ServerStorage GetChanges: query tombstones: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var id uuid.UUID
+		err = rows.Scan(&id)
+		if err != nil {
+			return ChangeSet{}, fmt.Errorf("ServerStorage GetChanges: query tombstones scan: %w", err)
+		}
+		cs.Deleted = append(cs.Deleted, id)
+	}
+	if err = rows.Err(); err != nil {
+		return ChangeSet{}, fmt.Errorf("ServerStorage GetChanges: query tombstones rows: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return ChangeSet{}, fmt.Errorf("ServerStorage GetChanges: commit: %w", err)
+	}
+
+	return cs, nil
+}
+
+// PurgeTombstones - удалить надгробия, созданные раньше before, и вернуть их количество.
+// Для владельцев запоминается наибольшая удаленная ревизия, чтобы клиенты
+// с более старым курсором получили полную синхронизацию.
+func (s *ServerStorage) PurgeTombstones(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("purge_tombstones", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage PurgeTombstones", "DELETE")
+	defer span.End()
+
+	row := s.db.QueryRowContext(ctx,
+		`WITH purged AS (
+			DELETE FROM tombstones WHERE created_at < $1 RETURNING public_key, revision
+		), owners AS (
+			UPDATE owner_revisions r SET pruned_revision = greatest(r.pruned_revision, p.revision)
+			FROM (SELECT public_key, max(revision) AS revision FROM purged GROUP BY public_key) p
+			WHERE r.public_key = p.public_key
+		)
+		SELECT count(*) FROM purged`,
+		before,
+	)
+
+	var n int64
+	err := row.Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("ServerStorage PurgeTombstones: query: %w", err)
+	}
+
+	return n, nil
+}
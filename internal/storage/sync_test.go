@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerStorage_GetChanges(t *testing.T) {
+	publicKey := []byte{1, 2, 3}
+
+	t.Run("ok", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		changed := Entry{ID: uuid.New(), PublicKey: publicKey, Payload: []byte{4, 5}, Revision: 7, Version: 2}
+		changed.CreatedAt = time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+		changed.UpdatedAt = time.Date(2023, 5, 2, 10, 0, 0, 0, time.UTC)
+		deleted := uuid.New()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT revision, pruned_revision FROM owner_revisions").
+			WithArgs(publicKey).
+			WillReturnRows(sqlmock.NewRows([]string{"revision", "pruned_revision"}).AddRow(8, 0))
+		mock.ExpectQuery("SELECT id, payload, revision, version, created_at, updated_at, expires_at FROM entries").
+			WithArgs(Fingerprint(publicKey), int64(5)).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"id", "payload", "revision", "version", "created_at", "updated_at", "expires_at",
+			}).AddRow(changed.ID, changed.Payload, changed.Revision, changed.Version, changed.CreatedAt,
+				changed.UpdatedAt, nil))
+		mock.ExpectQuery("FROM tombstones").
+			WithArgs(publicKey, int64(5), Fingerprint(publicKey)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(deleted))
+		mock.ExpectCommit()
+
+		s := ServerStorage{db: db}
+		cs, err := s.GetChanges(context.Background(), publicKey, 5)
+
+		assert.NoError(t, err)
+		assert.Equal(t, ChangeSet{
+			Entries:  []Entry{changed},
+			Deleted:  []uuid.UUID{deleted},
+			Revision: 8,
+		}, cs)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT revision, pruned_revision FROM owner_revisions").
+			WithArgs(publicKey).
+			WillReturnRows(sqlmock.NewRows([]string{"revision", "pruned_revision"}).AddRow(8, 6))
+		mock.ExpectQuery("SELECT id, payload, revision, version, created_at, updated_at, expires_at FROM entries").
+			WithArgs(Fingerprint(publicKey), int64(0)).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"id", "payload", "revision", "version", "created_at", "updated_at", "expires_at",
+			}))
+		mock.ExpectRollback()
+
+		s := ServerStorage{db: db}
+		cs, err := s.GetChanges(context.Background(), publicKey, 5)
+
+		assert.NoError(t, err)
+		assert.True(t, cs.FullResync)
+		assert.Empty(t, cs.Deleted)
+		assert.Equal(t, int64(8), cs.Revision)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown owner", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT revision, pruned_revision FROM owner_revisions").WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		s := ServerStorage{db: db}
+		cs, err := s.GetChanges(context.Background(), publicKey, 0)
+
+		assert.NoError(t, err)
+		assert.Empty(t, cs.Entries)
+		assert.Empty(t, cs.Deleted)
+		assert.Zero(t, cs.Revision)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT revision, pruned_revision FROM owner_revisions").
+			WillReturnRows(sqlmock.NewRows([]string{"revision", "pruned_revision"}).AddRow(8, 0))
+		mock.ExpectQuery("SELECT id, payload, revision, version, created_at, updated_at FROM entries").WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		s := ServerStorage{db: db}
+		_, err = s.GetChanges(context.Background(), publicKey, 0)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestServerStorage_PurgeTombstones(t *testing.T) {
+	before := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("ok", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectQuery("DELETE FROM tombstones WHERE created_at < ").
+			WithArgs(before).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+		s := ServerStorage{db: db}
+		n, err := s.PurgeTombstones(context.Background(), before)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), n)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectQuery("DELETE FROM tombstones").WillReturnError(sql.ErrConnDone)
+
+		s := ServerStorage{db: db}
+		_, err = s.PurgeTombstones(context.Background(), before)
+
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+	})
+}
@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/metrics"
+)
+
+// ListTrash - получить записи владельца publicKey, перемещенные в корзину.
+func (s *ServerStorage) ListTrash(ctx context.Context, publicKey []byte) ([]Entry, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("list_trash", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage ListTrash", "SELECT")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, payload, version, created_at, updated_at, deleted_at FROM entries
+		WHERE owner_hash = $1 AND deleted_at IS NOT NULL ORDER BY deleted_at DESC`,
+		Fingerprint(publicKey),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ServerStorage ListTrash: query: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	entries := make([]Entry, 0)
+	for rows.Next() {
+		e := Entry{PublicKey: publicKey}
+		err = rows.Scan(&e.ID, &e.Payload, &e.Version, &e.CreatedAt, &e.UpdatedAt, &e.DeletedAt)
+		if err != nil {
+			return nil, fmt.Errorf("ServerStorage ListTrash: query rows scan: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ServerStorage ListTrash: query rows: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetTrashed - получить запись из корзины по ID.
+func (s *ServerStorage) GetTrashed(ctx context.Context, id uuid.UUID) (e Entry, err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("get_trashed", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage GetTrashed", "SELECT")
+	defer span.End()
+
+	e = Entry{
+		ID: id,
+	}
+
+	row := s.db.QueryRowContext(ctx,
+		`SELECT public_key, payload, version, created_at, updated_at, deleted_at FROM entries
+		WHERE id = $1 AND deleted_at IS NOT NULL`,
+		id,
+	)
+	err = row.Scan(&e.PublicKey, &e.Payload, &e.Version, &e.CreatedAt, &e.UpdatedAt, &e.DeletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Entry{}, fmt.Errorf("ServerStorage GetTrashed: query row: %w", ErrNotFound)
+	}
+	if err != nil {
+		return Entry{}, fmt.Errorf("ServerStorage GetTrashed: query row: %w", err)
+	}
+
+	return e, nil
+}
+
+// Restore - восстановить запись из корзины.
+func (s *ServerStorage) Restore(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("restore", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage Restore", "UPDATE")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE entries SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("ServerStorage Restore: exec: %w", err)
+	}
+
+	return checkAffected("ServerStorage Restore", res)
+}
+
+// Purge - окончательно удалить запись из корзины.
+func (s *ServerStorage) Purge(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("purge", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage Purge", "DELETE")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM entries WHERE id = $1 AND deleted_at IS NOT NULL`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("ServerStorage Purge: exec: %w", err)
+	}
+
+	return checkAffected("ServerStorage Purge", res)
+}
+
+// PurgeTrash - окончательно удалить записи, перемещенные в корзину раньше before.
+func (s *ServerStorage) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("purge_trash", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage PurgeTrash", "DELETE")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM entries WHERE deleted_at IS NOT NULL AND deleted_at < $1`,
+		before,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("ServerStorage PurgeTrash: exec: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("ServerStorage PurgeTrash: rows affected: %w", err)
+	}
+
+	return n, nil
+}
+
+// PurgeExpired - окончательно удалить записи, истекшие раньше before, в том числе из корзины,
+// и вернуть их количество. Вместо записей остаются надгробия, как после Purge.
+func (s *ServerStorage) PurgeExpired(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	defer metrics.ObserveQuery("purge_expired", time.Now())
+
+	ctx, span := startSpan(ctx, "ServerStorage PurgeExpired", "DELETE")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM entries WHERE expires_at IS NOT NULL AND expires_at <= $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("ServerStorage PurgeExpired: exec: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("ServerStorage PurgeExpired: rows affected: %w", err)
+	}
+
+	return n, nil
+}
+
+func checkAffected(name string, res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: rows affected: %w", name, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerStorage_ListTrash(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		id := uuid.New()
+		now := time.Now()
+
+		rows := sqlmock.NewRows([]string{"id", "payload", "version", "created_at", "updated_at", "deleted_at"}).
+			AddRow(id, []byte{1}, int64(3), now, now, now)
+		mock.ExpectQuery("deleted_at IS NOT NULL").WithArgs(Fingerprint([]byte{9})).WillReturnRows(rows)
+
+		s := ServerStorage{db: db}
+		entries, err := s.ListTrash(context.Background(), []byte{9})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, id, entries[0].ID)
+		assert.Equal(t, int64(3), entries[0].Version)
+		assert.Equal(t, now, entries[0].DeletedAt)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectQuery("deleted_at IS NOT NULL").WillReturnError(sql.ErrConnDone)
+
+		s := ServerStorage{db: db}
+		_, err = s.ListTrash(context.Background(), []byte{9})
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+	})
+}
+
+func TestServerStorage_GetTrashed(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		id := uuid.New()
+		now := time.Now()
+
+		rows := sqlmock.NewRows([]string{"public_key", "payload", "version", "created_at", "updated_at", "deleted_at"}).
+			AddRow([]byte{9}, []byte{1}, int64(3), now, now, now)
+		mock.ExpectQuery("deleted_at IS NOT NULL").WithArgs(id).WillReturnRows(rows)
+
+		s := ServerStorage{db: db}
+		e, err := s.GetTrashed(context.Background(), id)
+		require.NoError(t, err)
+		assert.Equal(t, []byte{9}, e.PublicKey)
+		assert.Equal(t, []byte{1}, e.Payload)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		mock.ExpectQuery("deleted_at IS NOT NULL").WillReturnError(sql.ErrNoRows)
+
+		s := ServerStorage{db: db}
+		_, err = s.GetTrashed(context.Background(), uuid.New())
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestServerStorage_Restore(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected int64
+		wantErr  error
+	}{
+		{
+			name:     "ok",
+			affected: 1,
+		},
+		{
+			name:     "not in trash",
+			affected: 0,
+			wantErr:  ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = db.Close() }()
+
+			id := uuid.New()
+			mock.ExpectExec("UPDATE entries SET deleted_at = NULL").WithArgs(id).
+				WillReturnResult(sqlmock.NewResult(0, tt.affected))
+
+			s := ServerStorage{db: db}
+			err = s.Restore(context.Background(), id)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestServerStorage_Purge(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected int64
+		wantErr  error
+	}{
+		{
+			name:     "ok",
+			affected: 1,
+		},
+		{
+			name:     "not in trash",
+			affected: 0,
+			wantErr:  ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = db.Close() }()
+
+			id := uuid.New()
+			mock.ExpectExec("DELETE FROM entries").WithArgs(id).
+				WillReturnResult(sqlmock.NewResult(0, tt.affected))
+
+			s := ServerStorage{db: db}
+			err = s.Purge(context.Background(), id)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestServerStorage_PurgeTrash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	before := time.Now()
+	mock.ExpectExec("DELETE FROM entries").WithArgs(before).WillReturnResult(sqlmock.NewResult(0, 4))
+
+	s := ServerStorage{db: db}
+	n, err := s.PurgeTrash(context.Background(), before)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), n)
+}
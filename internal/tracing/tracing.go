@@ -0,0 +1,115 @@
+// Package tracing настраивает OpenTelemetry трассировку для клиента и сервера GophKeeper.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/ImpressionableRaccoon/GophKeeper"
+
+	// ExporterOTLP - экспорт спанов по OTLP/gRPC,
+	// адрес коллектора задается переменными окружения OTEL_EXPORTER_OTLP_*.
+	ExporterOTLP = "otlp"
+	// ExporterFilePrefix - префикс экспорта спанов в локальный файл, например file:traces.json.
+	ExporterFilePrefix = "file:"
+)
+
+// ErrUnknownExporter - неизвестный тип экспортера.
+var ErrUnknownExporter = errors.New("unknown trace exporter")
+
+// ShutdownFunc - функция, которая отправляет оставшиеся спаны и освобождает ресурсы.
+type ShutdownFunc func(ctx context.Context) error
+
+// Setup настраивает глобальный TracerProvider и распространение контекста трассировки.
+// Если exporter пустой, трассировка отключена.
+func Setup(ctx context.Context, serviceName, exporter string) (ShutdownFunc, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if exporter == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var (
+		exp     sdktrace.SpanExporter
+		closeFn = func() error { return nil }
+		err     error
+	)
+
+	switch {
+	case exporter == ExporterOTLP:
+		exp, err = otlptracegrpc.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("tracing Setup: otlp exporter: %w", err)
+		}
+	case strings.HasPrefix(exporter, ExporterFilePrefix):
+		var f *os.File
+		f, err = os.OpenFile(strings.TrimPrefix(exporter, ExporterFilePrefix), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("tracing Setup: open file: %w", err)
+		}
+		closeFn = f.Close
+
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("tracing Setup: file exporter: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("tracing Setup: %w: %s", ErrUnknownExporter, exporter)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(serviceName),
+		)),
+	)
+	otel.SetTracerProvider(tp)
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if err != nil {
+			_ = closeFn()
+			return fmt.Errorf("tracing shutdown: %w", err)
+		}
+		err = closeFn()
+		if err != nil {
+			return fmt.Errorf("tracing shutdown: close: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// Start начинает новый спан с именем name.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End завершает спан и записывает в него ошибку, если она есть.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetup(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("disabled", func(t *testing.T) {
+		shutdown, err := Setup(ctx, "test", "")
+		require.NoError(t, err)
+		assert.NoError(t, shutdown(ctx))
+	})
+
+	t.Run("unknown exporter", func(t *testing.T) {
+		_, err := Setup(ctx, "test", "jaeger")
+		assert.ErrorIs(t, err, ErrUnknownExporter)
+	})
+
+	t.Run("wrong file path", func(t *testing.T) {
+		_, err := Setup(ctx, "test", ExporterFilePrefix+filepath.Join(t.TempDir(), "dir", "traces.json"))
+		assert.Error(t, err)
+	})
+
+	t.Run("file exporter", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "traces.json")
+
+		shutdown, err := Setup(ctx, "test", ExporterFilePrefix+path)
+		require.NoError(t, err)
+
+		spanCtx, span := Start(ctx, "parent")
+		_, child := Start(spanCtx, "child")
+		End(child, errors.New("child failed"))
+		End(span, nil)
+
+		require.NoError(t, shutdown(ctx))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"Name":"parent"`)
+		assert.Contains(t, string(data), `"Name":"child"`)
+		assert.Contains(t, string(data), "child failed")
+	})
+}
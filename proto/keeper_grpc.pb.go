@@ -20,22 +20,78 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	Keeper_Get_FullMethodName    = "/GophKeeper.Keeper/Get"
-	Keeper_GetAll_FullMethodName = "/GophKeeper.Keeper/GetAll"
-	Keeper_Create_FullMethodName = "/GophKeeper.Keeper/Create"
-	Keeper_Delete_FullMethodName = "/GophKeeper.Keeper/Delete"
-	Keeper_Update_FullMethodName = "/GophKeeper.Keeper/Update"
+	Keeper_Get_FullMethodName              = "/GophKeeper.Keeper/Get"
+	Keeper_GetAll_FullMethodName           = "/GophKeeper.Keeper/GetAll"
+	Keeper_GetChanges_FullMethodName       = "/GophKeeper.Keeper/GetChanges"
+	Keeper_Create_FullMethodName           = "/GophKeeper.Keeper/Create"
+	Keeper_Delete_FullMethodName           = "/GophKeeper.Keeper/Delete"
+	Keeper_Update_FullMethodName           = "/GophKeeper.Keeper/Update"
+	Keeper_Batch_FullMethodName            = "/GophKeeper.Keeper/Batch"
+	Keeper_History_FullMethodName          = "/GophKeeper.Keeper/History"
+	Keeper_Revert_FullMethodName           = "/GophKeeper.Keeper/Revert"
+	Keeper_ListTrash_FullMethodName        = "/GophKeeper.Keeper/ListTrash"
+	Keeper_Restore_FullMethodName          = "/GophKeeper.Keeper/Restore"
+	Keeper_Purge_FullMethodName            = "/GophKeeper.Keeper/Purge"
+	Keeper_ListAudit_FullMethodName        = "/GophKeeper.Keeper/ListAudit"
+	Keeper_AccountChallenge_FullMethodName = "/GophKeeper.Keeper/AccountChallenge"
+	Keeper_DeleteAccount_FullMethodName    = "/GophKeeper.Keeper/DeleteAccount"
+	Keeper_RegisterDevice_FullMethodName   = "/GophKeeper.Keeper/RegisterDevice"
+	Keeper_ListDevices_FullMethodName      = "/GophKeeper.Keeper/ListDevices"
+	Keeper_RevokeDevice_FullMethodName     = "/GophKeeper.Keeper/RevokeDevice"
+	Keeper_Upload_FullMethodName           = "/GophKeeper.Keeper/Upload"
+	Keeper_UploadStatus_FullMethodName     = "/GophKeeper.Keeper/UploadStatus"
+	Keeper_Download_FullMethodName         = "/GophKeeper.Keeper/Download"
+	Keeper_Watch_FullMethodName            = "/GophKeeper.Keeper/Watch"
 )
 
 // KeeperClient is the client API for Keeper service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type KeeperClient interface {
+	// Получить запись по ID.
 	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	// Получить все записи по публичному ключу постранично.
 	GetAll(ctx context.Context, in *GetAllRequest, opts ...grpc.CallOption) (*GetAllResponse, error)
+	// Получить изменения записей после ревизии.
+	GetChanges(ctx context.Context, in *GetChangesRequest, opts ...grpc.CallOption) (*GetChangesResponse, error)
+	// Создать новую запись.
 	Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateResponse, error)
+	// Переместить запись в корзину.
 	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Обновить запись.
 	Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Выполнить несколько операций с записями атомарно.
+	Batch(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*BatchResponse, error)
+	// Получить предыдущие версии записи.
+	History(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryResponse, error)
+	// Вернуть запись к предыдущей версии.
+	Revert(ctx context.Context, in *RevertRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Получить записи в корзине.
+	ListTrash(ctx context.Context, in *ListTrashRequest, opts ...grpc.CallOption) (*ListTrashResponse, error)
+	// Восстановить запись из корзины.
+	Restore(ctx context.Context, in *RestoreRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Окончательно удалить запись из корзины.
+	Purge(ctx context.Context, in *PurgeRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Получить журнал аудита постранично.
+	ListAudit(ctx context.Context, in *ListAuditRequest, opts ...grpc.CallOption) (*ListAuditResponse, error)
+	// Получить вызов для подтверждения удаления аккаунта.
+	AccountChallenge(ctx context.Context, in *AccountChallengeRequest, opts ...grpc.CallOption) (*AccountChallengeResponse, error)
+	// Удалить все данные владельца публичного ключа.
+	DeleteAccount(ctx context.Context, in *DeleteAccountRequest, opts ...grpc.CallOption) (*DeleteAccountResponse, error)
+	// Зарегистрировать устройство.
+	RegisterDevice(ctx context.Context, in *RegisterDeviceRequest, opts ...grpc.CallOption) (*RegisterDeviceResponse, error)
+	// Получить устройства владельца.
+	ListDevices(ctx context.Context, in *ListDevicesRequest, opts ...grpc.CallOption) (*ListDevicesResponse, error)
+	// Отозвать устройство: его токен сессии перестает действовать.
+	RevokeDevice(ctx context.Context, in *RevokeDeviceRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Загрузить файл по частям.
+	Upload(ctx context.Context, opts ...grpc.CallOption) (Keeper_UploadClient, error)
+	// Получить состояние прерванной загрузки.
+	UploadStatus(ctx context.Context, in *UploadStatusRequest, opts ...grpc.CallOption) (*UploadStatusResponse, error)
+	// Скачать файл по частям.
+	Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (Keeper_DownloadClient, error)
+	// Подписаться на изменения записей.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Keeper_WatchClient, error)
 }
 
 type keeperClient struct {
@@ -64,6 +120,15 @@ func (c *keeperClient) GetAll(ctx context.Context, in *GetAllRequest, opts ...gr
 	return out, nil
 }
 
+func (c *keeperClient) GetChanges(ctx context.Context, in *GetChangesRequest, opts ...grpc.CallOption) (*GetChangesResponse, error) {
+	out := new(GetChangesResponse)
+	err := c.cc.Invoke(ctx, Keeper_GetChanges_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *keeperClient) Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateResponse, error) {
 	out := new(CreateResponse)
 	err := c.cc.Invoke(ctx, Keeper_Create_FullMethodName, in, out, opts...)
@@ -91,15 +156,269 @@ func (c *keeperClient) Update(ctx context.Context, in *UpdateRequest, opts ...gr
 	return out, nil
 }
 
+func (c *keeperClient) Batch(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*BatchResponse, error) {
+	out := new(BatchResponse)
+	err := c.cc.Invoke(ctx, Keeper_Batch_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) History(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryResponse, error) {
+	out := new(HistoryResponse)
+	err := c.cc.Invoke(ctx, Keeper_History_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) Revert(ctx context.Context, in *RevertRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Keeper_Revert_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) ListTrash(ctx context.Context, in *ListTrashRequest, opts ...grpc.CallOption) (*ListTrashResponse, error) {
+	out := new(ListTrashResponse)
+	err := c.cc.Invoke(ctx, Keeper_ListTrash_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) Restore(ctx context.Context, in *RestoreRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Keeper_Restore_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) Purge(ctx context.Context, in *PurgeRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Keeper_Purge_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) ListAudit(ctx context.Context, in *ListAuditRequest, opts ...grpc.CallOption) (*ListAuditResponse, error) {
+	out := new(ListAuditResponse)
+	err := c.cc.Invoke(ctx, Keeper_ListAudit_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) AccountChallenge(ctx context.Context, in *AccountChallengeRequest, opts ...grpc.CallOption) (*AccountChallengeResponse, error) {
+	out := new(AccountChallengeResponse)
+	err := c.cc.Invoke(ctx, Keeper_AccountChallenge_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) DeleteAccount(ctx context.Context, in *DeleteAccountRequest, opts ...grpc.CallOption) (*DeleteAccountResponse, error) {
+	out := new(DeleteAccountResponse)
+	err := c.cc.Invoke(ctx, Keeper_DeleteAccount_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) RegisterDevice(ctx context.Context, in *RegisterDeviceRequest, opts ...grpc.CallOption) (*RegisterDeviceResponse, error) {
+	out := new(RegisterDeviceResponse)
+	err := c.cc.Invoke(ctx, Keeper_RegisterDevice_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) ListDevices(ctx context.Context, in *ListDevicesRequest, opts ...grpc.CallOption) (*ListDevicesResponse, error) {
+	out := new(ListDevicesResponse)
+	err := c.cc.Invoke(ctx, Keeper_ListDevices_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) RevokeDevice(ctx context.Context, in *RevokeDeviceRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Keeper_RevokeDevice_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) Upload(ctx context.Context, opts ...grpc.CallOption) (Keeper_UploadClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Keeper_ServiceDesc.Streams[0], Keeper_Upload_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &keeperUploadClient{stream}
+	return x, nil
+}
+
+type Keeper_UploadClient interface {
+	Send(*UploadRequest) error
+	CloseAndRecv() (*UploadResponse, error)
+	grpc.ClientStream
+}
+
+type keeperUploadClient struct {
+	grpc.ClientStream
+}
+
+func (x *keeperUploadClient) Send(m *UploadRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *keeperUploadClient) CloseAndRecv() (*UploadResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(UploadResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *keeperClient) UploadStatus(ctx context.Context, in *UploadStatusRequest, opts ...grpc.CallOption) (*UploadStatusResponse, error) {
+	out := new(UploadStatusResponse)
+	err := c.cc.Invoke(ctx, Keeper_UploadStatus_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (Keeper_DownloadClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Keeper_ServiceDesc.Streams[1], Keeper_Download_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &keeperDownloadClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Keeper_DownloadClient interface {
+	Recv() (*DownloadResponse, error)
+	grpc.ClientStream
+}
+
+type keeperDownloadClient struct {
+	grpc.ClientStream
+}
+
+func (x *keeperDownloadClient) Recv() (*DownloadResponse, error) {
+	m := new(DownloadResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *keeperClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Keeper_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Keeper_ServiceDesc.Streams[2], Keeper_Watch_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &keeperWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Keeper_WatchClient interface {
+	Recv() (*WatchEvent, error)
+	grpc.ClientStream
+}
+
+type keeperWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *keeperWatchClient) Recv() (*WatchEvent, error) {
+	m := new(WatchEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // KeeperServer is the server API for Keeper service.
 // All implementations must embed UnimplementedKeeperServer
 // for forward compatibility
 type KeeperServer interface {
+	// Получить запись по ID.
 	Get(context.Context, *GetRequest) (*GetResponse, error)
+	// Получить все записи по публичному ключу постранично.
 	GetAll(context.Context, *GetAllRequest) (*GetAllResponse, error)
+	// Получить изменения записей после ревизии.
+	GetChanges(context.Context, *GetChangesRequest) (*GetChangesResponse, error)
+	// Создать новую запись.
 	Create(context.Context, *CreateRequest) (*CreateResponse, error)
+	// Переместить запись в корзину.
 	Delete(context.Context, *DeleteRequest) (*emptypb.Empty, error)
+	// Обновить запись.
 	Update(context.Context, *UpdateRequest) (*emptypb.Empty, error)
+	// Выполнить несколько операций с записями атомарно.
+	Batch(context.Context, *BatchRequest) (*BatchResponse, error)
+	// Получить предыдущие версии записи.
+	History(context.Context, *HistoryRequest) (*HistoryResponse, error)
+	// Вернуть запись к предыдущей версии.
+	Revert(context.Context, *RevertRequest) (*emptypb.Empty, error)
+	// Получить записи в корзине.
+	ListTrash(context.Context, *ListTrashRequest) (*ListTrashResponse, error)
+	// Восстановить запись из корзины.
+	Restore(context.Context, *RestoreRequest) (*emptypb.Empty, error)
+	// Окончательно удалить запись из корзины.
+	Purge(context.Context, *PurgeRequest) (*emptypb.Empty, error)
+	// Получить журнал аудита постранично.
+	ListAudit(context.Context, *ListAuditRequest) (*ListAuditResponse, error)
+	// Получить вызов для подтверждения удаления аккаунта.
+	AccountChallenge(context.Context, *AccountChallengeRequest) (*AccountChallengeResponse, error)
+	// Удалить все данные владельца публичного ключа.
+	DeleteAccount(context.Context, *DeleteAccountRequest) (*DeleteAccountResponse, error)
+	// Зарегистрировать устройство.
+	RegisterDevice(context.Context, *RegisterDeviceRequest) (*RegisterDeviceResponse, error)
+	// Получить устройства владельца.
+	ListDevices(context.Context, *ListDevicesRequest) (*ListDevicesResponse, error)
+	// Отозвать устройство: его токен сессии перестает действовать.
+	RevokeDevice(context.Context, *RevokeDeviceRequest) (*emptypb.Empty, error)
+	// Загрузить файл по частям.
+	Upload(Keeper_UploadServer) error
+	// Получить состояние прерванной загрузки.
+	UploadStatus(context.Context, *UploadStatusRequest) (*UploadStatusResponse, error)
+	// Скачать файл по частям.
+	Download(*DownloadRequest, Keeper_DownloadServer) error
+	// Подписаться на изменения записей.
+	Watch(*WatchRequest, Keeper_WatchServer) error
 	mustEmbedUnimplementedKeeperServer()
 }
 
@@ -113,6 +432,9 @@ func (UnimplementedKeeperServer) Get(context.Context, *GetRequest) (*GetResponse
 func (UnimplementedKeeperServer) GetAll(context.Context, *GetAllRequest) (*GetAllResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetAll not implemented")
 }
+func (UnimplementedKeeperServer) GetChanges(context.Context, *GetChangesRequest) (*GetChangesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetChanges not implemented")
+}
 func (UnimplementedKeeperServer) Create(context.Context, *CreateRequest) (*CreateResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
 }
@@ -122,6 +444,54 @@ func (UnimplementedKeeperServer) Delete(context.Context, *DeleteRequest) (*empty
 func (UnimplementedKeeperServer) Update(context.Context, *UpdateRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
 }
+func (UnimplementedKeeperServer) Batch(context.Context, *BatchRequest) (*BatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Batch not implemented")
+}
+func (UnimplementedKeeperServer) History(context.Context, *HistoryRequest) (*HistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method History not implemented")
+}
+func (UnimplementedKeeperServer) Revert(context.Context, *RevertRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Revert not implemented")
+}
+func (UnimplementedKeeperServer) ListTrash(context.Context, *ListTrashRequest) (*ListTrashResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTrash not implemented")
+}
+func (UnimplementedKeeperServer) Restore(context.Context, *RestoreRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Restore not implemented")
+}
+func (UnimplementedKeeperServer) Purge(context.Context, *PurgeRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Purge not implemented")
+}
+func (UnimplementedKeeperServer) ListAudit(context.Context, *ListAuditRequest) (*ListAuditResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAudit not implemented")
+}
+func (UnimplementedKeeperServer) AccountChallenge(context.Context, *AccountChallengeRequest) (*AccountChallengeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AccountChallenge not implemented")
+}
+func (UnimplementedKeeperServer) DeleteAccount(context.Context, *DeleteAccountRequest) (*DeleteAccountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteAccount not implemented")
+}
+func (UnimplementedKeeperServer) RegisterDevice(context.Context, *RegisterDeviceRequest) (*RegisterDeviceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterDevice not implemented")
+}
+func (UnimplementedKeeperServer) ListDevices(context.Context, *ListDevicesRequest) (*ListDevicesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDevices not implemented")
+}
+func (UnimplementedKeeperServer) RevokeDevice(context.Context, *RevokeDeviceRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeDevice not implemented")
+}
+func (UnimplementedKeeperServer) Upload(Keeper_UploadServer) error {
+	return status.Errorf(codes.Unimplemented, "method Upload not implemented")
+}
+func (UnimplementedKeeperServer) UploadStatus(context.Context, *UploadStatusRequest) (*UploadStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UploadStatus not implemented")
+}
+func (UnimplementedKeeperServer) Download(*DownloadRequest, Keeper_DownloadServer) error {
+	return status.Errorf(codes.Unimplemented, "method Download not implemented")
+}
+func (UnimplementedKeeperServer) Watch(*WatchRequest, Keeper_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
 func (UnimplementedKeeperServer) mustEmbedUnimplementedKeeperServer() {}
 
 // UnsafeKeeperServer may be embedded to opt out of forward compatibility for this service.
@@ -171,6 +541,24 @@ func _Keeper_GetAll_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Keeper_GetChanges_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetChangesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).GetChanges(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_GetChanges_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).GetChanges(ctx, req.(*GetChangesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Keeper_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(CreateRequest)
 	if err := dec(in); err != nil {
@@ -225,6 +613,308 @@ func _Keeper_Update_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Keeper_Batch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).Batch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_Batch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).Batch(ctx, req.(*BatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_History_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).History(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_History_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).History(ctx, req.(*HistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_Revert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevertRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).Revert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_Revert_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).Revert(ctx, req.(*RevertRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_ListTrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTrashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).ListTrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_ListTrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).ListTrash(ctx, req.(*ListTrashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_Restore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).Restore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_Restore_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).Restore(ctx, req.(*RestoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_Purge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PurgeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).Purge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_Purge_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).Purge(ctx, req.(*PurgeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_ListAudit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAuditRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).ListAudit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_ListAudit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).ListAudit(ctx, req.(*ListAuditRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_AccountChallenge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AccountChallengeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).AccountChallenge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_AccountChallenge_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).AccountChallenge(ctx, req.(*AccountChallengeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_DeleteAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteAccountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).DeleteAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_DeleteAccount_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).DeleteAccount(ctx, req.(*DeleteAccountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_RegisterDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).RegisterDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_RegisterDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).RegisterDevice(ctx, req.(*RegisterDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_ListDevices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDevicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).ListDevices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_ListDevices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).ListDevices(ctx, req.(*ListDevicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_RevokeDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).RevokeDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_RevokeDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).RevokeDevice(ctx, req.(*RevokeDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_Upload_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(KeeperServer).Upload(&keeperUploadServer{stream})
+}
+
+type Keeper_UploadServer interface {
+	SendAndClose(*UploadResponse) error
+	Recv() (*UploadRequest, error)
+	grpc.ServerStream
+}
+
+type keeperUploadServer struct {
+	grpc.ServerStream
+}
+
+func (x *keeperUploadServer) SendAndClose(m *UploadResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *keeperUploadServer) Recv() (*UploadRequest, error) {
+	m := new(UploadRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Keeper_UploadStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UploadStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).UploadStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_UploadStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).UploadStatus(ctx, req.(*UploadStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_Download_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KeeperServer).Download(m, &keeperDownloadServer{stream})
+}
+
+type Keeper_DownloadServer interface {
+	Send(*DownloadResponse) error
+	grpc.ServerStream
+}
+
+type keeperDownloadServer struct {
+	grpc.ServerStream
+}
+
+func (x *keeperDownloadServer) Send(m *DownloadResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Keeper_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KeeperServer).Watch(m, &keeperWatchServer{stream})
+}
+
+type Keeper_WatchServer interface {
+	Send(*WatchEvent) error
+	grpc.ServerStream
+}
+
+type keeperWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *keeperWatchServer) Send(m *WatchEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // Keeper_ServiceDesc is the grpc.ServiceDesc for Keeper service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -240,6 +930,10 @@ var Keeper_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetAll",
 			Handler:    _Keeper_GetAll_Handler,
 		},
+		{
+			MethodName: "GetChanges",
+			Handler:    _Keeper_GetChanges_Handler,
+		},
 		{
 			MethodName: "Create",
 			Handler:    _Keeper_Create_Handler,
@@ -252,7 +946,75 @@ var Keeper_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Update",
 			Handler:    _Keeper_Update_Handler,
 		},
+		{
+			MethodName: "Batch",
+			Handler:    _Keeper_Batch_Handler,
+		},
+		{
+			MethodName: "History",
+			Handler:    _Keeper_History_Handler,
+		},
+		{
+			MethodName: "Revert",
+			Handler:    _Keeper_Revert_Handler,
+		},
+		{
+			MethodName: "ListTrash",
+			Handler:    _Keeper_ListTrash_Handler,
+		},
+		{
+			MethodName: "Restore",
+			Handler:    _Keeper_Restore_Handler,
+		},
+		{
+			MethodName: "Purge",
+			Handler:    _Keeper_Purge_Handler,
+		},
+		{
+			MethodName: "ListAudit",
+			Handler:    _Keeper_ListAudit_Handler,
+		},
+		{
+			MethodName: "AccountChallenge",
+			Handler:    _Keeper_AccountChallenge_Handler,
+		},
+		{
+			MethodName: "DeleteAccount",
+			Handler:    _Keeper_DeleteAccount_Handler,
+		},
+		{
+			MethodName: "RegisterDevice",
+			Handler:    _Keeper_RegisterDevice_Handler,
+		},
+		{
+			MethodName: "ListDevices",
+			Handler:    _Keeper_ListDevices_Handler,
+		},
+		{
+			MethodName: "RevokeDevice",
+			Handler:    _Keeper_RevokeDevice_Handler,
+		},
+		{
+			MethodName: "UploadStatus",
+			Handler:    _Keeper_UploadStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Upload",
+			Handler:       _Keeper_Upload_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Download",
+			Handler:       _Keeper_Download_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _Keeper_Watch_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/keeper.proto",
 }
@@ -0,0 +1,8 @@
+package GophKeeper
+
+import _ "embed" // embed OpenAPI description
+
+// OpenAPI - описание Keeper API в формате OpenAPI v2 (Swagger), сгенерированное protoc-gen-openapiv2.
+//
+//go:embed keeper.swagger.json
+var OpenAPI []byte
@@ -0,0 +1,118 @@
+package integration
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/keys"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+func TestDeleteAccount(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	c, err := keeper.NewClient(os.Getenv("SERVER_ADDRESS"))
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	key, _, err := keys.GenRSAKey(ctx)
+	require.NoError(t, err)
+	publicKey := key.PublicKey.N.Bytes()
+
+	sign := func(t *testing.T, hash []byte) []byte {
+		s, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash)
+		require.NoError(t, signErr)
+		return s
+	}
+
+	ids := make([]string, 0, 3)
+	for i := 0; i < 3; i++ {
+		hash := sha256.Sum256(data)
+		resp, createErr := c.Create(ctx, &pb.CreateRequest{
+			PublicKey: publicKey,
+			Data:      data,
+			Sign:      sign(t, hash[:]),
+		})
+		require.NoError(t, createErr)
+		ids = append(ids, resp.Id)
+	}
+
+	t.Run("unknown challenge", func(t *testing.T) {
+		challenge := []byte("not issued by server")
+		hash := keeper.DeleteAccountHash(publicKey, challenge)
+		_, err = c.DeleteAccount(ctx, &pb.DeleteAccountRequest{
+			PublicKey: publicKey,
+			Challenge: challenge,
+			Sign:      sign(t, hash[:]),
+		})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("delete account", func(t *testing.T) {
+		var challengeResp *pb.AccountChallengeResponse
+		challengeResp, err = c.AccountChallenge(ctx, &pb.AccountChallengeRequest{PublicKey: publicKey})
+		require.NoError(t, err)
+
+		hash := keeper.DeleteAccountHash(publicKey, challengeResp.Challenge)
+		var resp *pb.DeleteAccountResponse
+		resp, err = c.DeleteAccount(ctx, &pb.DeleteAccountRequest{
+			PublicKey: publicKey,
+			Challenge: challengeResp.Challenge,
+			Sign:      sign(t, hash[:]),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), resp.DeletedEntries)
+
+		for _, id := range ids {
+			_, err = c.Get(ctx, &pb.GetRequest{Id: id})
+			assert.Equal(t, codes.NotFound, status.Code(err))
+		}
+
+		var allResp *pb.GetAllResponse
+		allResp, err = c.GetAll(ctx, &pb.GetAllRequest{PublicKey: publicKey})
+		require.NoError(t, err)
+		assert.Empty(t, allResp.Entries)
+
+		ts := time.Now().Unix()
+		auditHash := keeper.AuditHash(publicKey, ts)
+		var auditResp *pb.ListAuditResponse
+		auditResp, err = c.ListAudit(ctx, &pb.ListAuditRequest{
+			PublicKey: publicKey,
+			Timestamp: ts,
+			Sign:      sign(t, auditHash[:]),
+		})
+		require.NoError(t, err)
+		assert.Empty(t, auditResp.Records)
+	})
+
+	t.Run("challenge can not be reused", func(t *testing.T) {
+		var challengeResp *pb.AccountChallengeResponse
+		challengeResp, err = c.AccountChallenge(ctx, &pb.AccountChallengeRequest{PublicKey: publicKey})
+		require.NoError(t, err)
+
+		hash := keeper.DeleteAccountHash(publicKey, challengeResp.Challenge)
+		req := &pb.DeleteAccountRequest{
+			PublicKey: publicKey,
+			Challenge: challengeResp.Challenge,
+			Sign:      sign(t, hash[:]),
+		}
+		_, err = c.DeleteAccount(ctx, req)
+		require.NoError(t, err)
+
+		_, err = c.DeleteAccount(ctx, req)
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+}
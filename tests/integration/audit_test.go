@@ -0,0 +1,101 @@
+package integration
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/keys"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+func TestAudit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	c, err := keeper.NewClient(os.Getenv("SERVER_ADDRESS"))
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+	c.SetVersion("integration")
+
+	key, _, err := keys.GenRSAKey(ctx)
+	require.NoError(t, err)
+	publicKey := key.PublicKey.N.Bytes()
+
+	listAudit := func(t *testing.T, pageSize int32, pageToken string) (*pb.ListAuditResponse, error) {
+		ts := time.Now().Unix()
+		hash := keeper.AuditHash(publicKey, ts)
+		sign, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+
+		return c.ListAudit(ctx, &pb.ListAuditRequest{
+			PublicKey: publicKey,
+			Timestamp: ts,
+			Sign:      sign,
+			PageSize:  pageSize,
+			PageToken: pageToken,
+		})
+	}
+
+	hash := sha256.Sum256(data)
+	sign, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	require.NoError(t, err)
+
+	createResp, err := c.Create(ctx, &pb.CreateRequest{
+		PublicKey: publicKey,
+		Data:      data,
+		Sign:      sign,
+	})
+	require.NoError(t, err)
+
+	_, err = c.Get(ctx, &pb.GetRequest{Id: createResp.Id})
+	require.NoError(t, err)
+
+	t.Run("operations are recorded", func(t *testing.T) {
+		resp, listErr := listAudit(t, 0, "")
+		require.NoError(t, listErr)
+		require.Len(t, resp.Records, 2)
+
+		assert.Equal(t, "read", resp.Records[0].Op)
+		assert.Equal(t, "create", resp.Records[1].Op)
+		for _, r := range resp.Records {
+			assert.Equal(t, createResp.Id, r.EntryId)
+			assert.Equal(t, "integration", r.ClientVersion)
+			assert.NotEmpty(t, r.Peer)
+		}
+		assert.Empty(t, resp.NextPageToken)
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		first, listErr := listAudit(t, 1, "")
+		require.NoError(t, listErr)
+		require.Len(t, first.Records, 1)
+		require.NotEmpty(t, first.NextPageToken)
+
+		second, listErr := listAudit(t, 1, first.NextPageToken)
+		require.NoError(t, listErr)
+		require.Len(t, second.Records, 1)
+		assert.Equal(t, "create", second.Records[0].Op)
+		assert.Empty(t, second.NextPageToken)
+	})
+
+	t.Run("wrong sign", func(t *testing.T) {
+		_, err = c.ListAudit(ctx, &pb.ListAuditRequest{
+			PublicKey: publicKey,
+			Timestamp: time.Now().Unix(),
+			Sign:      sign,
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
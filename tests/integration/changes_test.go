@@ -0,0 +1,105 @@
+package integration
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/keys"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+func TestChanges(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	c, err := keeper.NewClient(os.Getenv("SERVER_ADDRESS"))
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	key, _, err := keys.GenRSAKey(ctx)
+	require.NoError(t, err)
+	publicKey := key.PublicKey.N.Bytes()
+
+	create := func(t *testing.T, data []byte) string {
+		hash := sha256.Sum256(data)
+		sign, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+
+		resp, createErr := c.Create(ctx, &pb.CreateRequest{PublicKey: publicKey, Data: data, Sign: sign})
+		require.NoError(t, createErr)
+
+		return resp.Id
+	}
+
+	var revision int64
+
+	t.Run("empty vault", func(t *testing.T) {
+		var resp *pb.GetChangesResponse
+		resp, err = c.GetChanges(ctx, &pb.GetChangesRequest{PublicKey: publicKey})
+		require.NoError(t, err)
+
+		assert.Empty(t, resp.Entries)
+		assert.Empty(t, resp.Deleted)
+		assert.Zero(t, resp.Revision)
+	})
+
+	first := create(t, []byte{1})
+	second := create(t, []byte{2})
+
+	t.Run("all entries", func(t *testing.T) {
+		var resp *pb.GetChangesResponse
+		resp, err = c.GetChanges(ctx, &pb.GetChangesRequest{PublicKey: publicKey})
+		require.NoError(t, err)
+
+		require.Len(t, resp.Entries, 2)
+		assert.Equal(t, first, resp.Entries[0].Id)
+		assert.Equal(t, second, resp.Entries[1].Id)
+		assert.Equal(t, int64(2), resp.Revision)
+
+		revision = resp.Revision
+	})
+
+	t.Run("delta after delete", func(t *testing.T) {
+		hash := sha256.Sum256([]byte{1})
+		hash2 := sha256.Sum256(hash[:])
+		var sign []byte
+		sign, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash2[:])
+		require.NoError(t, err)
+
+		_, err = c.Delete(ctx, &pb.DeleteRequest{Id: first, Sign: sign})
+		require.NoError(t, err)
+
+		third := create(t, []byte{3})
+
+		var resp *pb.GetChangesResponse
+		resp, err = c.GetChanges(ctx, &pb.GetChangesRequest{PublicKey: publicKey, SinceRevision: revision})
+		require.NoError(t, err)
+
+		require.Len(t, resp.Entries, 1)
+		assert.Equal(t, third, resp.Entries[0].Id)
+		assert.Equal(t, []string{first}, resp.Deleted)
+		assert.Greater(t, resp.Revision, revision)
+
+		revision = resp.Revision
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		var resp *pb.GetChangesResponse
+		resp, err = c.GetChanges(ctx, &pb.GetChangesRequest{PublicKey: publicKey, SinceRevision: revision})
+		require.NoError(t, err)
+
+		assert.Empty(t, resp.Entries)
+		assert.Empty(t, resp.Deleted)
+		assert.Equal(t, revision, resp.Revision)
+	})
+}
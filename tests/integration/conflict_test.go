@@ -0,0 +1,102 @@
+package integration
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/keys"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+func TestConflict(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	c, err := keeper.NewClient(os.Getenv("SERVER_ADDRESS"))
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	key, _, err := keys.GenRSAKey(ctx)
+	require.NoError(t, err)
+
+	sign := func(t *testing.T, data []byte, double bool) []byte {
+		hash := sha256.Sum256(data)
+		if double {
+			hash = sha256.Sum256(hash[:])
+		}
+		s, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+		return s
+	}
+
+	createResp, err := c.Create(ctx, &pb.CreateRequest{
+		PublicKey: key.PublicKey.N.Bytes(),
+		Data:      data,
+		Sign:      sign(t, data, false),
+	})
+	require.NoError(t, err)
+	entryID := createResp.Id
+
+	t.Run("new entry has version 1", func(t *testing.T) {
+		var resp *pb.GetResponse
+		resp, err = c.Get(ctx, &pb.GetRequest{Id: entryID})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), resp.Version)
+	})
+
+	t.Run("update with expected version", func(t *testing.T) {
+		_, err = c.Update(ctx, &pb.UpdateRequest{
+			Id:              entryID,
+			Data:            newData,
+			SignOld:         sign(t, data, true),
+			SignNew:         sign(t, newData, false),
+			ExpectedVersion: 1,
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("stale update is rejected", func(t *testing.T) {
+		_, err = c.Update(ctx, &pb.UpdateRequest{
+			Id:              entryID,
+			Data:            data,
+			SignOld:         sign(t, data, true),
+			SignNew:         sign(t, data, false),
+			ExpectedVersion: 1,
+		})
+
+		st := status.Convert(err)
+		require.Equal(t, codes.Aborted, st.Code())
+		require.Len(t, st.Details(), 1)
+		assert.Equal(t, int64(2), st.Details()[0].(*pb.VersionConflict).CurrentVersion)
+	})
+
+	t.Run("stale delete is rejected", func(t *testing.T) {
+		_, err = c.Delete(ctx, &pb.DeleteRequest{
+			Id:              entryID,
+			Sign:            sign(t, newData, true),
+			ExpectedVersion: 1,
+		})
+		assert.Equal(t, codes.Aborted, status.Code(err))
+	})
+
+	t.Run("delete with current version", func(t *testing.T) {
+		_, err = c.Delete(ctx, &pb.DeleteRequest{
+			Id:              entryID,
+			Sign:            sign(t, newData, true),
+			ExpectedVersion: 2,
+		})
+		require.NoError(t, err)
+	})
+}
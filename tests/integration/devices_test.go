@@ -0,0 +1,116 @@
+package integration
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/keys"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+func TestDevices(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	owner, err := keeper.NewClient(os.Getenv("SERVER_ADDRESS"))
+	require.NoError(t, err)
+	defer func() { _ = owner.Close() }()
+
+	phone, err := keeper.NewClient(os.Getenv("SERVER_ADDRESS"))
+	require.NoError(t, err)
+	defer func() { _ = phone.Close() }()
+
+	key, _, err := keys.GenRSAKey(ctx)
+	require.NoError(t, err)
+	publicKey := key.PublicKey.N.Bytes()
+
+	sign := func(t *testing.T, hash [32]byte) []byte {
+		s, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+		return s
+	}
+
+	ts := time.Now().Unix()
+	registerResp, err := phone.RegisterDevice(ctx, &pb.RegisterDeviceRequest{
+		PublicKey: publicKey,
+		Timestamp: ts,
+		Name:      "phone",
+		Sign:      sign(t, keeper.RegisterDeviceHash(publicKey, ts, "", "phone")),
+	})
+	require.NoError(t, err)
+	phone.SetDevice(registerResp.DeviceId, registerResp.SessionToken)
+
+	listDevices := func(t *testing.T) []*pb.ListDevicesResponse_Device {
+		listTS := time.Now().Unix()
+		resp, listErr := owner.ListDevices(ctx, &pb.ListDevicesRequest{
+			PublicKey: publicKey,
+			Timestamp: listTS,
+			Sign:      sign(t, keeper.ListDevicesHash(publicKey, listTS)),
+		})
+		require.NoError(t, listErr)
+		return resp.Devices
+	}
+
+	t.Run("registered device works", func(t *testing.T) {
+		_, err = phone.GetAll(ctx, &pb.GetAllRequest{PublicKey: publicKey})
+		require.NoError(t, err)
+
+		devices := listDevices(t)
+		require.Len(t, devices, 1)
+		assert.Equal(t, registerResp.DeviceId, devices[0].Id)
+		assert.Equal(t, "phone", devices[0].Name)
+		assert.NotEmpty(t, devices[0].LastIp)
+		assert.Nil(t, devices[0].RevokedAt)
+	})
+
+	t.Run("wrong session token", func(t *testing.T) {
+		other, clientErr := keeper.NewClient(os.Getenv("SERVER_ADDRESS"))
+		require.NoError(t, clientErr)
+		defer func() { _ = other.Close() }()
+		other.SetDevice(registerResp.DeviceId, "wrong")
+
+		_, err = other.GetAll(ctx, &pb.GetAllRequest{PublicKey: publicKey})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("revoked device is rejected", func(t *testing.T) {
+		revokeTS := time.Now().Unix()
+		_, err = owner.RevokeDevice(ctx, &pb.RevokeDeviceRequest{
+			PublicKey: publicKey,
+			Timestamp: revokeTS,
+			DeviceId:  registerResp.DeviceId,
+			Sign:      sign(t, keeper.RevokeDeviceHash(publicKey, revokeTS, registerResp.DeviceId)),
+		})
+		require.NoError(t, err)
+
+		_, err = phone.GetAll(ctx, &pb.GetAllRequest{PublicKey: publicKey})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		devices := listDevices(t)
+		require.Len(t, devices, 1)
+		assert.NotNil(t, devices[0].RevokedAt)
+	})
+
+	t.Run("revoked device is not registered again", func(t *testing.T) {
+		registerTS := time.Now().Unix()
+		_, err = owner.RegisterDevice(ctx, &pb.RegisterDeviceRequest{
+			PublicKey: publicKey,
+			Timestamp: registerTS,
+			Name:      "phone",
+			Sign:      sign(t, keeper.RegisterDeviceHash(publicKey, registerTS, registerResp.DeviceId, "phone")),
+			DeviceId:  registerResp.DeviceId,
+		})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+}
@@ -0,0 +1,108 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chzyer/readline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/keys"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/service"
+)
+
+func TestFiles(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	c, err := keeper.NewClient(os.Getenv("SERVER_ADDRESS"))
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	key, _, err := keys.GenRSAKey(ctx)
+	require.NoError(t, err)
+
+	s, err := service.New(c, key)
+	require.NoError(t, err)
+
+	fileName := filepath.Join(t.TempDir(), "large.bin")
+	content := make([]byte, 5<<20+123)
+	_, err = rand.Read(content)
+	require.NoError(t, err)
+	err = os.WriteFile(fileName, content, 0o600)
+	require.NoError(t, err)
+
+	var entryID string
+
+	t.Run("upload", func(t *testing.T) {
+		b := &bytes.Buffer{}
+		_, _ = fmt.Fprintf(b, "large\n%s\n", fileName)
+
+		var l *readline.Instance
+		l, err = readline.NewEx(&readline.Config{
+			Stdin: io.NopCloser(b),
+		})
+		require.NoError(t, err)
+		defer func() { _ = l.Close() }()
+
+		var resp string
+		resp, err = s.Upload(ctx, l)
+		require.NoError(t, err)
+
+		entryID = strings.TrimSpace(strings.Split(resp, ":")[1])
+	})
+
+	t.Run("get file entry", func(t *testing.T) {
+		var resp string
+		resp, err = s.Get(ctx, entryID)
+		require.NoError(t, err)
+		assert.Contains(t, resp, "Type: File")
+	})
+
+	t.Run("download", func(t *testing.T) {
+		err = os.Remove(fileName)
+		require.NoError(t, err)
+
+		_, err = s.Download(ctx, entryID)
+		require.NoError(t, err)
+
+		var got []byte
+		got, err = os.ReadFile(fileName)
+		require.NoError(t, err)
+		assert.Equal(t, content, got)
+	})
+
+	t.Run("download existing file", func(t *testing.T) {
+		_, err = s.Download(ctx, entryID)
+		assert.Error(t, err)
+	})
+
+	t.Run("download not a file", func(t *testing.T) {
+		b := &bytes.Buffer{}
+		b.Write([]byte("name\ncontent\n"))
+
+		var l *readline.Instance
+		l, err = readline.NewEx(&readline.Config{
+			Stdin: io.NopCloser(b),
+		})
+		require.NoError(t, err)
+		defer func() { _ = l.Close() }()
+
+		var resp string
+		resp, err = s.Add(ctx, "text", l)
+		require.NoError(t, err)
+
+		_, err = s.Download(ctx, strings.TrimSpace(strings.Split(resp, ":")[1]))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,132 @@
+package integration
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/keys"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+func TestHistory(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	c, err := keeper.NewClient(os.Getenv("SERVER_ADDRESS"))
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	key, _, err := keys.GenRSAKey(ctx)
+	require.NoError(t, err)
+
+	sign := func(t *testing.T, data []byte, double bool) []byte {
+		hash := sha256.Sum256(data)
+		if double {
+			hash = sha256.Sum256(hash[:])
+		}
+		s, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+		return s
+	}
+
+	history := func(t *testing.T, id string) (*pb.HistoryResponse, error) {
+		ts := time.Now().Unix()
+		hash := keeper.HistoryHash(key.PublicKey.N.Bytes(), ts, id)
+		s, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+		return c.History(ctx, &pb.HistoryRequest{Id: id, Timestamp: ts, Sign: s})
+	}
+
+	createResp, err := c.Create(ctx, &pb.CreateRequest{
+		PublicKey: key.PublicKey.N.Bytes(),
+		Data:      data,
+		Sign:      sign(t, data, false),
+	})
+	require.NoError(t, err)
+	entryID := createResp.Id
+
+	t.Run("new entry has no history", func(t *testing.T) {
+		var resp *pb.HistoryResponse
+		resp, err = history(t, entryID)
+		require.NoError(t, err)
+		assert.Empty(t, resp.Versions)
+	})
+
+	t.Run("history without sign", func(t *testing.T) {
+		_, err = c.History(ctx, &pb.HistoryRequest{Id: entryID})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("update saves previous version", func(t *testing.T) {
+		_, err = c.Update(ctx, &pb.UpdateRequest{
+			Id:      entryID,
+			Data:    newData,
+			SignOld: sign(t, data, true),
+			SignNew: sign(t, newData, false),
+		})
+		require.NoError(t, err)
+
+		var resp *pb.HistoryResponse
+		resp, err = history(t, entryID)
+		require.NoError(t, err)
+		require.Len(t, resp.Versions, 1)
+		assert.Equal(t, int64(1), resp.Versions[0].Version)
+		assert.Equal(t, data, resp.Versions[0].Data)
+	})
+
+	t.Run("revert with wrong sign", func(t *testing.T) {
+		_, err = c.Revert(ctx, &pb.RevertRequest{
+			Id:      entryID,
+			Version: 1,
+			SignOld: sign(t, data, true),
+			SignNew: sign(t, data, false),
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("revert to unknown version", func(t *testing.T) {
+		_, err = c.Revert(ctx, &pb.RevertRequest{
+			Id:      entryID,
+			Version: 100,
+			SignOld: sign(t, newData, true),
+			SignNew: sign(t, data, false),
+		})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("revert", func(t *testing.T) {
+		_, err = c.Revert(ctx, &pb.RevertRequest{
+			Id:              entryID,
+			Version:         1,
+			SignOld:         sign(t, newData, true),
+			SignNew:         sign(t, data, false),
+			ExpectedVersion: 2,
+		})
+		require.NoError(t, err)
+
+		var resp *pb.GetResponse
+		resp, err = c.Get(ctx, &pb.GetRequest{Id: entryID})
+		require.NoError(t, err)
+		assert.Equal(t, data, resp.Data)
+		assert.Equal(t, int64(3), resp.Version)
+
+		var historyResp *pb.HistoryResponse
+		historyResp, err = history(t, entryID)
+		require.NoError(t, err)
+		require.Len(t, historyResp.Versions, 2)
+		assert.Equal(t, int64(2), historyResp.Versions[0].Version)
+		assert.Equal(t, newData, historyResp.Versions[0].Data)
+	})
+}
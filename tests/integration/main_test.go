@@ -0,0 +1,29 @@
+package integration
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// TestMain - запустить тесты во временном каталоге: keys.GenRSAKey сохраняет ключи в текущий каталог.
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	dir, err := os.MkdirTemp("", "gophkeeper-integration")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	err = os.Chdir(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return m.Run()
+}
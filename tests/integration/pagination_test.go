@@ -0,0 +1,71 @@
+package integration
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/keys"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+func TestPagination(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	c, err := keeper.NewClient(os.Getenv("SERVER_ADDRESS"))
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	key, _, err := keys.GenRSAKey(ctx)
+	require.NoError(t, err)
+	publicKey := key.PublicKey.N.Bytes()
+
+	created := map[string]bool{}
+	for i := byte(0); i < 5; i++ {
+		d := []byte{i}
+		hash := sha256.Sum256(d)
+
+		var sign []byte
+		sign, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, err)
+
+		var resp *pb.CreateResponse
+		resp, err = c.Create(ctx, &pb.CreateRequest{PublicKey: publicKey, Data: d, Sign: sign})
+		require.NoError(t, err)
+		created[resp.Id] = true
+	}
+
+	got := map[string]bool{}
+	var pages int
+	var pageToken string
+	for {
+		var resp *pb.GetAllResponse
+		resp, err = c.GetAll(ctx, &pb.GetAllRequest{PublicKey: publicKey, PageSize: 2, PageToken: pageToken})
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(resp.Entries), 2)
+
+		for _, e := range resp.Entries {
+			assert.False(t, got[e.Id], "entry %s returned twice", e.Id)
+			got[e.Id] = true
+		}
+
+		pages++
+		pageToken = resp.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+
+	assert.Equal(t, created, got)
+	assert.Equal(t, 3, pages)
+}
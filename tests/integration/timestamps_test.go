@@ -0,0 +1,70 @@
+package integration
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/keys"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+func TestTimestamps(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	c, err := keeper.NewClient(os.Getenv("SERVER_ADDRESS"))
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	key, _, err := keys.GenRSAKey(ctx)
+	require.NoError(t, err)
+
+	sign := func(t *testing.T, hash [sha256.Size]byte) []byte {
+		s, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+		return s
+	}
+
+	createResp, err := c.Create(ctx, &pb.CreateRequest{
+		PublicKey: key.PublicKey.N.Bytes(),
+		Data:      data,
+		Sign:      sign(t, sha256.Sum256(data)),
+	})
+	require.NoError(t, err)
+
+	created, err := c.Get(ctx, &pb.GetRequest{Id: createResp.Id})
+	require.NoError(t, err)
+	require.NotNil(t, created.CreatedAt)
+	require.NotNil(t, created.UpdatedAt)
+	assert.WithinDuration(t, time.Now(), created.CreatedAt.AsTime(), time.Minute)
+	assert.Equal(t, created.CreatedAt.AsTime(), created.UpdatedAt.AsTime())
+
+	oldHash := sha256.Sum256(data)
+	_, err = c.Update(ctx, &pb.UpdateRequest{
+		Id:      createResp.Id,
+		Data:    newData,
+		SignOld: sign(t, sha256.Sum256(oldHash[:])),
+		SignNew: sign(t, sha256.Sum256(newData)),
+	})
+	require.NoError(t, err)
+
+	updated, err := c.Get(ctx, &pb.GetRequest{Id: createResp.Id})
+	require.NoError(t, err)
+	assert.Equal(t, created.CreatedAt.AsTime(), updated.CreatedAt.AsTime())
+	assert.True(t, updated.UpdatedAt.AsTime().After(created.UpdatedAt.AsTime()))
+
+	all, err := c.GetAll(ctx, &pb.GetAllRequest{PublicKey: key.PublicKey.N.Bytes()})
+	require.NoError(t, err)
+	require.Len(t, all.Entries, 1)
+	assert.Equal(t, updated.UpdatedAt.AsTime(), all.Entries[0].UpdatedAt.AsTime())
+}
@@ -0,0 +1,136 @@
+package integration
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/keys"
+	pb "github.com/ImpressionableRaccoon/GophKeeper/proto"
+)
+
+func TestTrash(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	c, err := keeper.NewClient(os.Getenv("SERVER_ADDRESS"))
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	key, _, err := keys.GenRSAKey(ctx)
+	require.NoError(t, err)
+
+	sign := func(t *testing.T, data []byte, double bool) []byte {
+		hash := sha256.Sum256(data)
+		if double {
+			hash = sha256.Sum256(hash[:])
+		}
+		s, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+		return s
+	}
+
+	create := func(t *testing.T) string {
+		resp, createErr := c.Create(ctx, &pb.CreateRequest{
+			PublicKey: key.PublicKey.N.Bytes(),
+			Data:      data,
+			Sign:      sign(t, data, false),
+		})
+		require.NoError(t, createErr)
+		return resp.Id
+	}
+
+	signHash := func(t *testing.T, hash [sha256.Size]byte) []byte {
+		s, signErr := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, signErr)
+		return s
+	}
+
+	listTrash := func(t *testing.T) []*pb.ListTrashResponse_Entry {
+		ts := time.Now().Unix()
+		resp, listErr := c.ListTrash(ctx, &pb.ListTrashRequest{
+			PublicKey: key.PublicKey.N.Bytes(),
+			Timestamp: ts,
+			Sign:      signHash(t, keeper.ListTrashHash(key.PublicKey.N.Bytes(), ts)),
+		})
+		require.NoError(t, listErr)
+		return resp.Entries
+	}
+
+	restore := func(t *testing.T, id string) error {
+		ts := time.Now().Unix()
+		_, restoreErr := c.Restore(ctx, &pb.RestoreRequest{
+			Id:        id,
+			Timestamp: ts,
+			Sign:      signHash(t, keeper.RestoreHash(key.PublicKey.N.Bytes(), ts, id, data)),
+		})
+		return restoreErr
+	}
+
+	entryID := create(t)
+
+	t.Run("delete moves entry to trash", func(t *testing.T) {
+		_, err = c.Delete(ctx, &pb.DeleteRequest{Id: entryID, Sign: sign(t, data, true)})
+		require.NoError(t, err)
+
+		_, err = c.Get(ctx, &pb.GetRequest{Id: entryID})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+
+		entries := listTrash(t)
+		require.Len(t, entries, 1)
+		assert.Equal(t, entryID, entries[0].Id)
+		assert.Equal(t, data, entries[0].Data)
+		assert.True(t, entries[0].PurgeAt.AsTime().After(entries[0].DeletedAt.AsTime()))
+	})
+
+	t.Run("list without sign", func(t *testing.T) {
+		_, err = c.ListTrash(ctx, &pb.ListTrashRequest{PublicKey: key.PublicKey.N.Bytes()})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("restore with delete sign", func(t *testing.T) {
+		_, err = c.Restore(ctx, &pb.RestoreRequest{Id: entryID, Sign: sign(t, data, true)})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		require.NoError(t, restore(t, entryID))
+
+		var resp *pb.GetResponse
+		resp, err = c.Get(ctx, &pb.GetRequest{Id: entryID})
+		require.NoError(t, err)
+		assert.Equal(t, data, resp.Data)
+		assert.Empty(t, listTrash(t))
+	})
+
+	t.Run("restore entry not in trash", func(t *testing.T) {
+		assert.Equal(t, codes.NotFound, status.Code(restore(t, entryID)))
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		_, err = c.Delete(ctx, &pb.DeleteRequest{Id: entryID, Sign: sign(t, data, true)})
+		require.NoError(t, err)
+
+		ts := time.Now().Unix()
+		_, err = c.Purge(ctx, &pb.PurgeRequest{
+			Id:        entryID,
+			Timestamp: ts,
+			Sign:      signHash(t, keeper.PurgeHash(key.PublicKey.N.Bytes(), ts, entryID, data)),
+		})
+		require.NoError(t, err)
+		assert.Empty(t, listTrash(t))
+
+		assert.Equal(t, codes.NotFound, status.Code(restore(t, entryID)))
+	})
+}
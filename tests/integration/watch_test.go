@@ -0,0 +1,73 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chzyer/readline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/grpc/keeper"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/keys"
+	"github.com/ImpressionableRaccoon/GophKeeper/internal/service"
+)
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	key, _, err := keys.GenRSAKey(ctx)
+	require.NoError(t, err)
+
+	// два устройства одного владельца
+	c1, err := keeper.NewClient(os.Getenv("SERVER_ADDRESS"))
+	require.NoError(t, err)
+	defer func() { _ = c1.Close() }()
+	s1, err := service.New(c1, key)
+	require.NoError(t, err)
+
+	c2, err := keeper.NewClient(os.Getenv("SERVER_ADDRESS"))
+	require.NoError(t, err)
+	defer func() { _ = c2.Close() }()
+	s2, err := service.New(c2, key)
+	require.NoError(t, err)
+
+	notices1 := make(chan string, 10)
+	notices2 := make(chan string, 10)
+	go func() { _ = s1.Watch(ctx, func(msg string) { notices1 <- msg }) }()
+	go func() { _ = s2.Watch(ctx, func(msg string) { notices2 <- msg }) }()
+
+	// даем подпискам установиться
+	time.Sleep(time.Second)
+
+	b := &bytes.Buffer{}
+	b.Write([]byte("name\ncontent\n"))
+	l, err := readline.NewEx(&readline.Config{
+		Stdin: io.NopCloser(b),
+	})
+	require.NoError(t, err)
+	defer func() { _ = l.Close() }()
+
+	resp, err := s1.Add(ctx, "text", l)
+	require.NoError(t, err)
+	entryID := strings.TrimSpace(strings.Split(resp, ":")[1])
+
+	select {
+	case msg := <-notices2:
+		assert.Equal(t, "Entry "+entryID+" was created on another device", msg)
+	case <-ctx.Done():
+		t.Fatal("notice not received")
+	}
+
+	select {
+	case msg := <-notices1:
+		t.Fatalf("own change notice received: %s", msg)
+	case <-time.After(time.Second):
+	}
+}